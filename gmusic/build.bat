@@ -72,7 +72,7 @@ exit /b 0
 
 :start_dev
 echo 🎵 开发模式启动后端...
-go run cmd/server/main.go
+go run -tags sqlite_fts5 cmd/server/main.go
 exit /b 0
 
 :start_frontend
@@ -85,7 +85,7 @@ exit /b 0
 :build_backend
 echo 正在构建后端...
 if not exist "bin" mkdir bin
-go build -o bin/gmusic.exe cmd/server/main.go
+go build -tags sqlite_fts5 -o bin/gmusic.exe cmd/server/main.go
 echo ✅ 构建完成: bin/gmusic.exe
 exit /b 0
 
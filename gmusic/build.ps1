@@ -33,7 +33,7 @@ function Install-Deps {
 
 function Start-Dev {
     Write-Host "🎵 开发模式启动后端..." -ForegroundColor Cyan
-    go run cmd/server/main.go
+    go run -tags sqlite_fts5 cmd/server/main.go
 }
 
 function Start-Frontend {
@@ -48,7 +48,7 @@ function Build-Backend {
     if (-not (Test-Path "bin")) {
         New-Item -ItemType Directory -Path "bin" | Out-Null
     }
-    go build -o bin/gmusic.exe cmd/server/main.go
+    go build -tags sqlite_fts5 -o bin/gmusic.exe cmd/server/main.go
     Write-Host "✅ 构建完成: bin/gmusic.exe" -ForegroundColor Green
 }
 
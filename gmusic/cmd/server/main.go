@@ -3,12 +3,17 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/yudongyouqing/GMusic/internal/api"
+	"github.com/yudongyouqing/GMusic/internal/artists"
 	"github.com/yudongyouqing/GMusic/internal/storage"
 )
 
 func main() {
+	// 艺术家拆分规则（GMUSIC_ARTIST_SPLIT 等），规则变化后初始化数据库时重新拆分曲库
+	storage.SetArtistSplitConfig(artists.ConfigFromEnv())
+
 	// 初始化数据库
 	db, err := storage.InitDB("gmusic.db")
 	if err != nil {
@@ -18,8 +23,13 @@ func main() {
 	// 初始化 API 服务器
 	router := api.SetupRouter(db)
 
-	fmt.Println("🎵 GMusic 服务器启动在 http://localhost:8080")
-	if err := router.Run(":8080"); err != nil {
+	// 监听地址可由 GMUSIC_HTTP_ADDR 覆盖（如在同一台机器上运行多个实例测试多房间同步）
+	addr := os.Getenv("GMUSIC_HTTP_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	fmt.Println("🎵 GMusic 服务器启动在 " + addr)
+	if err := router.Run(addr); err != nil {
 		log.Fatalf("服务器启动失败: %v", err)
 	}
 }
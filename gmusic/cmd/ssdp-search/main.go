@@ -0,0 +1,30 @@
+// ssdp-search 发送 SSDP M-SEARCH 并打印响应，用于在本机或局域网内检查 UPnP 媒体服务器是否可被发现。
+//
+//	go run ./cmd/ssdp-search                          # 组播搜索 MediaServer
+//	go run ./cmd/ssdp-search -addr 127.0.0.1:1900     # 回环单播搜索本机服务
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/yudongyouqing/GMusic/internal/upnp"
+)
+
+func main() {
+	addr := flag.String("addr", upnp.SSDPGroup, "M-SEARCH 目标地址（组播或单播）")
+	st := flag.String("st", "urn:schemas-upnp-org:device:MediaServer:1", "搜索目标 ST，如 ssdp:all")
+	mx := flag.Int("mx", 2, "最长响应等待秒数 MX")
+	flag.Parse()
+
+	results, err := upnp.Search(context.Background(), *addr, *st, *mx)
+	if err != nil {
+		log.Fatalf("搜索失败: %v", err)
+	}
+	for _, r := range results {
+		fmt.Printf("%s\n  ST: %s\n  LOCATION: %s\n  SERVER: %s\n", r.USN, r.ST, r.Location, r.Server)
+	}
+	fmt.Printf("共 %d 条响应\n", len(results))
+}
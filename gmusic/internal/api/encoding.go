@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/metadata"
+	"github.com/yudongyouqing/GMusic/internal/scanner"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"gorm.io/gorm"
+)
+
+// maxEncodingCandidates 待修复歌曲列表单次最多返回的条数
+const maxEncodingCandidates = 500
+
+// registerEncodingRoutes 注册标签编码相关路由：单个文件指定编码，以及曲库级的修复任务
+func registerEncodingRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	api.PUT("/songs/:id/encoding", setSongEncoding(db))
+	api.DELETE("/songs/:id/encoding", resetSongEncoding(db))
+
+	g := api.Group("/refresh/encodings")
+	{
+		g.GET("", encodingRepairStatus())
+		g.POST("", startEncodingRepair(db))
+		g.POST("/cancel", cancelEncodingRepair())
+		g.GET("/candidates", listEncodingCandidates(db))
+	}
+}
+
+// encodingRepair 曲库级编码修复任务，同一时间只运行一个
+var encodingRepair struct {
+	mu         sync.Mutex
+	scanner    *scanner.Scanner // 运行中或最近一次任务
+	running    bool
+	startedAt  time.Time
+	finishedAt time.Time
+	err        string
+}
+
+// setSongEncoding 为歌曲所在的文件指定标签编码：{"charset":"big5"}（auto 恢复自动检测），
+// 随即按新编码重新读取文件并返回刷新后的歌曲；CUE 分轨会刷新同一 CUE 的全部分轨
+func setSongEncoding(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Charset string `json:"charset" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		charset, err := metadata.ParseCharset(req.Charset)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		applySongEncoding(c, db, charset)
+	}
+}
+
+// resetSongEncoding 删除歌曲所在文件的编码指定，按自动检测重新读取
+func resetSongEncoding(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		applySongEncoding(c, db, metadata.CharsetAuto)
+	}
+}
+
+// applySongEncoding 保存编码指定并重新读取歌曲所在的文件
+func applySongEncoding(c *gin.Context, db *gorm.DB, charset metadata.Charset) {
+	id, ok := idParam(c, "歌曲")
+	if !ok {
+		return
+	}
+	song, err := storage.GetSongByID(db, id)
+	if err != nil {
+		respondNotFound(c, err, "歌曲不存在")
+		return
+	}
+	if err := storage.SetTagCharsetOverride(db, song.FilePath, string(charset)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := scanner.NewScanner(db).ReimportSong(c.Request.Context(), song); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if song, err = storage.GetSongByID(db, id); err != nil {
+		respondNotFound(c, err, "歌曲不存在")
+		return
+	}
+	name := string(charset)
+	if charset == metadata.CharsetAuto {
+		name = "auto"
+	}
+	c.JSON(http.StatusOK, gin.H{"charset": name, "song": song})
+}
+
+// listEncodingCandidates 列出标签疑似被错误解码的歌曲及按检测结果重新解码后的预览，不修改曲库；limit 默认 100
+func listEncodingCandidates(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if limit <= 0 || limit > maxEncodingCandidates {
+			limit = maxEncodingCandidates
+		}
+		candidates := []scanner.EncodingCandidate{}
+		total := 0
+		err := scanner.FindEncodingCandidates(db, func(ec scanner.EncodingCandidate) bool {
+			total++
+			if len(candidates) < limit {
+				candidates = append(candidates, ec)
+			}
+			return true
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"total": total, "candidates": candidates})
+	}
+}
+
+// startEncodingRepair 启动曲库级编码修复任务（异步），已有任务在运行时返回 409
+func startEncodingRepair(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encodingRepair.mu.Lock()
+		defer encodingRepair.mu.Unlock()
+		if encodingRepair.running {
+			c.JSON(http.StatusConflict, gin.H{"error": "编码修复任务正在运行"})
+			return
+		}
+		// 任务在请求结束后继续运行，不使用请求的 context
+		s := scanner.NewScanner(db)
+		encodingRepair.scanner, encodingRepair.running = s, true
+		encodingRepair.startedAt, encodingRepair.finishedAt, encodingRepair.err = time.Now(), time.Time{}, ""
+		go func() {
+			_, err := s.RepairEncodings(context.Background())
+			encodingRepair.mu.Lock()
+			defer encodingRepair.mu.Unlock()
+			encodingRepair.running, encodingRepair.finishedAt = false, time.Now()
+			if err != nil {
+				encodingRepair.err = err.Error()
+			}
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"message": "编码修复已启动"})
+	}
+}
+
+// encodingRepairStatus 编码修复任务的状态：是否运行中、需要重新读取的文件数、已刷新与失败的数量
+func encodingRepairStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encodingRepair.mu.Lock()
+		defer encodingRepair.mu.Unlock()
+		if encodingRepair.scanner == nil {
+			c.JSON(http.StatusOK, gin.H{"running": false})
+			return
+		}
+		p := encodingRepair.scanner.Progress()
+		status := gin.H{
+			"running":       encodingRepair.running,
+			"started_at":    encodingRepair.startedAt.Unix(),
+			"total_files":   p.TotalFiles,
+			"updated_songs": p.UpdatedSongs + p.AddedSongs,
+			"failed_files":  p.FailedFiles,
+			"errors":        p.Errors,
+		}
+		if !encodingRepair.running {
+			status["finished_at"] = encodingRepair.finishedAt.Unix()
+		}
+		if encodingRepair.err != "" {
+			status["error"] = encodingRepair.err
+		}
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+// cancelEncodingRepair 取消运行中的编码修复任务
+func cancelEncodingRepair() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encodingRepair.mu.Lock()
+		defer encodingRepair.mu.Unlock()
+		if !encodingRepair.running {
+			c.JSON(http.StatusNotFound, gin.H{"error": "没有运行中的编码修复任务"})
+			return
+		}
+		encodingRepair.scanner.Cancel()
+		c.JSON(http.StatusOK, gin.H{"message": "编码修复已取消"})
+	}
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/jukebox"
+	"github.com/yudongyouqing/GMusic/internal/playback"
+)
+
+// jukeboxSearchLimit 来宾搜索最多返回的歌曲数
+const jukeboxSearchLimit = 50
+
+// registerJukeboxRoutes 注册点歌模式的路由：来宾与管理员都通过请求头 X-Jukebox-Token
+// （WebSocket 用查询参数 token）表明身份
+func registerJukeboxRoutes(api *gin.RouterGroup, router *gin.Engine, jb *jukebox.Jukebox) {
+	g := api.Group("/jukebox")
+	{
+		g.POST("/session", jukeboxJoin(jb))
+		g.GET("", jukeboxState(jb))
+		g.GET("/search", requireGuest(jb), jukeboxSearch(jb))
+		g.POST("/requests", requireGuest(jb), jukeboxRequest(jb))
+		g.POST("/requests/:id/vote", requireGuest(jb), jukeboxVote(jb))
+		g.DELETE("/requests/:id", jukeboxWithdraw(jb))
+
+		admin := g.Group("/admin", requireJukeboxAdmin(jb))
+		{
+			admin.POST("/skip", jukeboxSkip(jb))
+			admin.POST("/requests/:id/pin", jukeboxPin(jb))
+			admin.GET("/guests", jukeboxGuests(jb))
+			admin.DELETE("/guests/:id", jukeboxKick(jb))
+			admin.PUT("/limits", jukeboxLimits(jb))
+		}
+	}
+	router.GET("/ws/jukebox", jukeboxWebSocket(jb))
+}
+
+func jukeboxToken(c *gin.Context) string {
+	if t := c.GetHeader("X-Jukebox-Token"); t != "" {
+		return t
+	}
+	return c.Query("token")
+}
+
+// requireGuest 要求有效的来宾令牌
+func requireGuest(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := jb.Guest(jukeboxToken(c)); !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": jukebox.ErrUnknownGuest.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireJukeboxAdmin 要求管理员令牌
+func requireJukeboxAdmin(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !jb.IsAdmin(jukeboxToken(c)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "需要管理员令牌"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// respondJukeboxError 将点歌错误映射为 HTTP 状态码
+func respondJukeboxError(c *gin.Context, err error) {
+	var limit *jukebox.LimitError
+	var nf *playback.NotFoundError
+	switch {
+	case errors.As(err, &limit):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "max_pending": limit.Max})
+	case errors.As(err, &nf), errors.Is(err, jukebox.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, jukebox.ErrUnknownGuest):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, jukebox.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, jukebox.ErrNowPlaying):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// jukeboxJoin 来宾加入：{"name": "小王"}，返回会话令牌
+func jukeboxJoin(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name string `json:"name"`
+		}
+		_ = c.ShouldBindJSON(&req)
+		if len([]rune(req.Name)) > 32 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "昵称最多 32 个字"})
+			return
+		}
+		guest, token, err := jb.Join(req.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"token": token, "guest": guest})
+	}
+}
+
+// jukeboxState 当前播放与点播队列（带令牌时包含本人的投票）
+func jukeboxState(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, jb.State(jukeboxToken(c)))
+	}
+}
+
+func jukeboxSearch(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := c.Query("q")
+		if q == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "搜索关键词不能为空"})
+			return
+		}
+		songs, err := jb.Search(q, jukeboxSearchLimit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, songs)
+	}
+}
+
+// jukeboxRequest 点播：{"song_id": 12}
+func jukeboxRequest(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			SongID uint `json:"song_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		r, err := jb.Request(jukeboxToken(c), req.SongID)
+		if err != nil {
+			respondJukeboxError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+// jukeboxVote 投票：{"vote": 1 | -1 | 0}
+func jukeboxVote(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的点播 ID"})
+			return
+		}
+		var req struct {
+			Vote *int `json:"vote" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := jb.Vote(jukeboxToken(c), id, *req.Vote); err != nil {
+			respondJukeboxError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, jb.State(jukeboxToken(c)))
+	}
+}
+
+// jukeboxWithdraw 撤回自己的点播（管理员令牌可删除任意点播）
+func jukeboxWithdraw(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的点播 ID"})
+			return
+		}
+		if err := jb.Withdraw(jukeboxToken(c), id); err != nil {
+			respondJukeboxError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "已撤回点播"})
+	}
+}
+
+func jukeboxSkip(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jb.Skip()
+		c.JSON(http.StatusOK, jb.State(""))
+	}
+}
+
+// jukeboxPin 置顶或取消置顶：{"pinned": true}
+func jukeboxPin(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的点播 ID"})
+			return
+		}
+		req := struct {
+			Pinned bool `json:"pinned"`
+		}{Pinned: true}
+		_ = c.ShouldBindJSON(&req)
+		if err := jb.Pin(id, req.Pinned); err != nil {
+			respondJukeboxError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, jb.State(""))
+	}
+}
+
+func jukeboxGuests(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, jb.Guests())
+	}
+}
+
+// jukeboxKick 移出来宾并删除其点播
+func jukeboxKick(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的来宾 ID"})
+			return
+		}
+		if err := jb.Kick(id); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "已移出来宾"})
+	}
+}
+
+// jukeboxLimits 调整每位来宾的点播上限：{"max_pending": 5}
+func jukeboxLimits(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			MaxPending int `json:"max_pending" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := jb.SetMaxPending(req.MaxPending); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"max_pending": req.MaxPending})
+	}
+}
+
+// jukeboxWebSocket 推送点歌台状态：连接时发送一次，之后每次变化都推送（/ws/jukebox?token=...）
+func jukeboxWebSocket(jb *jukebox.Jukebox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := jukeboxToken(c)
+		ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+
+		updates := jb.Subscribe()
+		defer jb.Unsubscribe(updates)
+		// 读协程只用于感知连接关闭
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := ws.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+		for {
+			if err := ws.WriteJSON(jb.State(token)); err != nil {
+				return
+			}
+			select {
+			case <-closed:
+				return
+			case <-updates:
+			}
+		}
+	}
+}
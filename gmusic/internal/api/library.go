@@ -0,0 +1,242 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/artists"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"gorm.io/gorm"
+)
+
+// maxLibraryLimit 艺术家、专辑列表单页最多返回的条数，用 offset 翻页
+const maxLibraryLimit = 500
+
+// registerLibraryRoutes 注册艺术家、专辑与流派路由
+func registerLibraryRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	artists := api.Group("/artists")
+	{
+		artists.GET("", listArtists(db))
+		artists.GET("/:id", getArtist(db))
+		artists.POST("/:id/aliases", addArtistAlias(db))
+		artists.DELETE("/:id/aliases/:name", removeArtistAlias(db))
+	}
+	albums := api.Group("/albums")
+	{
+		albums.GET("", listAlbums(db))
+		albums.GET("/:id", getAlbum(db))
+	}
+	api.GET("/genres", listGenres(db))
+}
+
+// libraryQuery 读取 q、limit（默认 100）、offset
+func libraryQuery(c *gin.Context) storage.LibraryQuery {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if limit <= 0 || limit > maxLibraryLimit {
+		limit = maxLibraryLimit
+	}
+	return storage.LibraryQuery{Keyword: c.Query("q"), Limit: limit, Offset: max(offset, 0)}
+}
+
+// idParam 解析路径参数 :id，失败时已写入 400 响应
+func idParam(c *gin.Context, what string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的" + what + " ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondNotFound 记录不存在时返回 404，其他错误返回 500
+func respondNotFound(c *gin.Context, err error, msg string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// listArtists 艺术家列表（按名称排序）：q 按名称过滤（不区分大小写与简繁体），limit/offset 翻页
+func listArtists(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		artists, total, err := storage.ListArtistInfos(db, libraryQuery(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"total": total, "artists": artists})
+	}
+}
+
+// artistRoles getArtist 的 role 参数可选的角色
+var artistRoles = map[string]bool{
+	string(artists.RoleMain): true, string(artists.RoleFeatured): true,
+	string(artists.RoleRemixer): true, string(artists.RoleComposer): true,
+}
+
+// getArtist 艺术家详情：别名、作为专辑艺术家的专辑、参与的其他专辑（合辑、客串、混音），
+// 以及署名的全部歌曲（按专辑、碟号、曲目序号排序，每首附带角色）；role=main/featured/remixer/composer 只返回该角色的歌曲
+func getArtist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := idParam(c, "艺术家")
+		if !ok {
+			return
+		}
+		role := c.Query("role")
+		if role != "" && !artistRoles[role] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role 应为 main、featured、remixer 或 composer"})
+			return
+		}
+		artist, err := storage.GetArtistInfo(db, id)
+		if err != nil {
+			respondNotFound(c, err, "艺术家不存在")
+			return
+		}
+		aliases, err := storage.GetArtistAliases(db, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		albums, _, err := storage.ListAlbumInfos(db, storage.LibraryQuery{AlbumArtistID: id, Sort: "year"})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		appearsOn, err := storage.GetArtistAppearances(db, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		songs, err := storage.GetArtistTracks(db, id, role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"artist":     artist,
+			"aliases":    aliases,
+			"albums":     albums,
+			"appears_on": appearsOn,
+			"songs":      songs,
+		})
+	}
+}
+
+// addArtistAlias 添加别名：{"name": "Jay Chou"}。已有同名艺术家时将其合并进来
+func addArtistAlias(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := idParam(c, "艺术家")
+		if !ok {
+			return
+		}
+		var req struct {
+			Name string `json:"name" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := storage.AddArtistAlias(db, id, req.Name); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "艺术家不存在"})
+			} else {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		aliases, err := storage.GetArtistAliases(db, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"artist_id": id, "aliases": aliases})
+	}
+}
+
+// removeArtistAlias 删除别名，经该别名归入的歌曲重新按原名关联
+func removeArtistAlias(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := idParam(c, "艺术家")
+		if !ok {
+			return
+		}
+		if err := storage.RemoveArtistAlias(db, id, c.Param("name")); err != nil {
+			respondNotFound(c, err, "别名不存在")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "已删除别名"})
+	}
+}
+
+// listAlbums 专辑列表：q 按名称过滤，artist_id 指定专辑艺术家，compilation=true/false 过滤合辑，
+// sort 为 name（默认）、year、-year、newest，limit/offset 翻页
+func listAlbums(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := libraryQuery(c)
+		q.Sort = c.Query("sort")
+		if s := c.Query("artist_id"); s != "" {
+			id, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 artist_id"})
+				return
+			}
+			q.AlbumArtistID = uint(id)
+		}
+		if s := c.Query("compilation"); s != "" {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "compilation 应为 true 或 false"})
+				return
+			}
+			q.Compilation = &b
+		}
+		albums, total, err := storage.ListAlbumInfos(db, q)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"total": total, "albums": albums, "sorts": storage.AlbumSorts()})
+	}
+}
+
+// getAlbum 专辑详情：专辑艺术家、曲目艺术家与按碟号、曲目序号排序的歌曲
+func getAlbum(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := idParam(c, "专辑")
+		if !ok {
+			return
+		}
+		album, err := storage.GetAlbumInfo(db, id)
+		if err != nil {
+			respondNotFound(c, err, "专辑不存在")
+			return
+		}
+		artists, err := storage.GetAlbumArtists(db, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		songs, err := storage.GetAlbumTracks(db, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"album": album, "artists": artists, "songs": songs})
+	}
+}
+
+// listGenres 流派列表及各流派的歌曲数；按流派筛选歌曲用搜索 genre:摇滚
+func listGenres(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		genres, err := storage.ListGenres(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"total": len(genres), "genres": genres})
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/live"
+	"github.com/yudongyouqing/GMusic/internal/transcode"
+)
+
+// liveWriteTimeout 单次写入收听者连接的超时；网络卡住的收听者会因此断开，
+// 即使其缓冲尚未写满也不会一直占用连接
+const liveWriteTimeout = 10 * time.Second
+
+// liveStream 直播挂载点：把服务端正在播放的声音作为连续流输出给收听者。
+//
+//	GET /live              WAV（44.1 kHz / 16-bit 立体声，长度未知）
+//	GET /live?format=pcm   audio/L16 裸 PCM（大端，RFC 2586）
+//
+// 请求头带 Icy-MetaData: 1 时按 SHOUTcast 约定在流中穿插“正在播放”元数据（icy-metaint）。
+// 收听者消费过慢时由广播器断开；暂停或停止期间输出静音。
+func liveStream() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rate, channels := liveBroadcaster.Format()
+		format := c.DefaultQuery("format", "wav")
+		var contentType string
+		switch format {
+		case "wav":
+			contentType = "audio/wav"
+		case "pcm":
+			contentType = "audio/L16;rate=" + strconv.Itoa(rate) + ";channels=" + strconv.Itoa(channels)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的直播格式: " + format + "（可选 wav、pcm）"})
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Set("Content-Type", contentType)
+		h.Set("Cache-Control", "no-cache, no-store")
+		h.Set("icy-name", "GMusic")
+		h.Set("icy-br", strconv.Itoa(rate*channels*16/1000))
+		icy := c.GetHeader("Icy-MetaData") == "1"
+		if icy {
+			h.Set("icy-metaint", strconv.Itoa(live.ICYMetaInt))
+		}
+
+		l := liveBroadcaster.Subscribe()
+		defer liveBroadcaster.Unsubscribe(l)
+		c.Status(http.StatusOK)
+
+		var w io.Writer = c.Writer
+		if icy {
+			w = live.NewICYWriter(c.Writer, liveBroadcaster.Title)
+		}
+		rc := http.NewResponseController(c.Writer)
+		send := func(b []byte) bool {
+			_ = rc.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if _, err := w.Write(b); err != nil {
+				return false
+			}
+			return rc.Flush() == nil
+		}
+
+		if format == "wav" {
+			var header bytes.Buffer
+			// 流长度未知：data 块长度取最大值，播放器会一直读到连接结束
+			_ = transcode.WriteWAVHeader(&header, rate, channels, math.MaxUint32-36)
+			if !send(header.Bytes()) {
+				return
+			}
+		}
+		var swapped []byte
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case chunk, ok := <-l.C:
+				if !ok {
+					return // 消费过慢被广播器断开
+				}
+				if format == "pcm" {
+					swapped = swapBytes16(swapped[:0], chunk)
+					chunk = swapped
+				}
+				if !send(chunk) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// swapBytes16 将 16-bit 小端 PCM 转为大端，追加到 dst
+func swapBytes16(dst, src []byte) []byte {
+	for i := 0; i+1 < len(src); i += 2 {
+		dst = append(dst, src[i+1], src[i])
+	}
+	return dst
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/multiroom"
+)
+
+// syncStatus 多房间状态：本机作为从机的同步情况，以及作为主机时已连接的房间
+func syncStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		follower := roomFollower.Status()
+		rooms := roomLeader.Rooms()
+		role := "standalone"
+		switch {
+		case follower.Following:
+			role = "follower"
+		case len(rooms) > 0:
+			role = "leader"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"role":     role,
+			"room":     follower.Room,
+			"volume":   zones.Default().Player.GetVolume(),
+			"follower": follower,
+			"rooms":    rooms,
+			"dropped":  roomLeader.Dropped(),
+		})
+	}
+}
+
+// joinRoom 让本机跟随主机：{"leader": "http://192.168.1.10:8080", "room": "kitchen"}，room 可省略
+func joinRoom() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Leader string `json:"leader" binding:"required"`
+			Room   string `json:"room"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := roomFollower.Join(req.Leader, req.Room); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, roomFollower.Status())
+	}
+}
+
+// leaveRoom 停止跟随，恢复独立播放
+func leaveRoom() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roomFollower.Leave()
+		c.JSON(http.StatusOK, roomFollower.Status())
+	}
+}
+
+// listRooms 作为主机时已连接的从机房间
+func listRooms() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, roomLeader.Rooms())
+	}
+}
+
+// setRoomVolume 调整某个从机房间的音量：{"volume": 0.5}
+func setRoomVolume() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Volume *float64 `json:"volume" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if *req.Volume < 0 || *req.Volume > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "音量应在 0 到 1 之间"})
+			return
+		}
+		if err := roomLeader.SetRoomVolume(c.Param("room"), *req.Volume); err != nil {
+			respondRoomError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"room": c.Param("room"), "volume": *req.Volume})
+	}
+}
+
+// removeRoom 把某个房间移出同步组，从机停止跟随且不再重连
+func removeRoom() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := roomLeader.RemoveRoom(c.Param("room")); err != nil {
+			respondRoomError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "已移出房间 " + c.Param("room")})
+	}
+}
+
+func respondRoomError(c *gin.Context, err error) {
+	if errors.Is(err, multiroom.ErrNoRoom) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// syncWebSocket 主机端点：从机连接到这里接收带时间戳的 PCM（/ws/sync?room=kitchen）
+func syncWebSocket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room := c.Query("room")
+		if room == "" {
+			room = c.ClientIP()
+		}
+		ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return
+		}
+		roomLeader.ServeFollower(ws, room)
+	}
+}
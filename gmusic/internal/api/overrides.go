@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"gorm.io/gorm"
+)
+
+// getSongOverrides 歌曲手动修改过的字段及文件中的原值：{"overrides":{"title":{"value":"...","file_value":"..."}}}，
+// fields 为可修改的字段
+func getSongOverrides(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := idParam(c, "歌曲")
+		if !ok {
+			return
+		}
+		song, err := storage.GetSongByID(db, id)
+		if err != nil {
+			respondNotFound(c, err, "歌曲不存在")
+			return
+		}
+		overrides, err := storage.GetSongOverrides(db, song)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"song_id": song.ID, "overrides": overrides, "fields": storage.OverrideFields()})
+	}
+}
+
+// revertSongOverrides 把字段（:field，省略时为全部字段）恢复为文件中的值，返回恢复后的歌曲
+func revertSongOverrides(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := idParam(c, "歌曲")
+		if !ok {
+			return
+		}
+		var fields []string
+		if field := c.Param("field"); field != "" {
+			if !storage.IsOverrideField(field) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "字段 " + field + " 不可修改"})
+				return
+			}
+			fields = append(fields, field)
+		}
+		song, err := storage.RevertSongOverrides(db, id, fields...)
+		if err != nil {
+			respondNotFound(c, err, "歌曲不存在")
+			return
+		}
+		c.JSON(http.StatusOK, song)
+	}
+}
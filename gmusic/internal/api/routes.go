@@ -2,26 +2,48 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/yudongyouqing/GMusic/internal/jukebox"
+	"github.com/yudongyouqing/GMusic/internal/live"
 	"github.com/yudongyouqing/GMusic/internal/lyrics"
 	"github.com/yudongyouqing/GMusic/internal/metadata"
+	"github.com/yudongyouqing/GMusic/internal/mpd"
+	"github.com/yudongyouqing/GMusic/internal/mpris"
+	"github.com/yudongyouqing/GMusic/internal/multiroom"
+	"github.com/yudongyouqing/GMusic/internal/playback"
 	"github.com/yudongyouqing/GMusic/internal/player"
 	"github.com/yudongyouqing/GMusic/internal/scanner"
 	"github.com/yudongyouqing/GMusic/internal/storage"
+	"github.com/yudongyouqing/GMusic/internal/subsonic"
+	"github.com/yudongyouqing/GMusic/internal/transcode"
+	"github.com/yudongyouqing/GMusic/internal/upnp"
+	"github.com/yudongyouqing/GMusic/internal/zone"
 	"gorm.io/gorm"
 )
 
 var (
-	audioPlayer *player.Player
-	upgrader    = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	// 播放区域：每个区域有独立的播放器与服务端队列；默认区域输出到本机声卡
+	zones *zone.Manager
+	// 直播广播器：转发播放器输出的 PCM 给 /live 的收听者
+	liveBroadcaster *live.Broadcaster
+	// 多房间同步：作为主机转发 PCM 给从机，或作为从机跟随其他实例
+	roomLeader   *multiroom.Leader
+	roomFollower *multiroom.Follower
+	// 流媒体转码器：结果缓存在 ./cache/transcode，总量上限 2 GiB
+	transcoder = transcode.NewTranscoder(filepath.Join("cache", "transcode"), 2<<30)
+	upgrader   = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
 	// 扫描任务管理：key 是任务 ID（可以用目录路径或 UUID），value 是 scanner 实例
 	activeScanners = make(map[string]*scanner.Scanner)
 	scannerMu      sync.Mutex
@@ -36,17 +58,48 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		AllowAllOrigins:  true,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"},
 		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag", "X-Track-Start-Ms", "X-Track-End-Ms"},
 		AllowCredentials: false,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// 初始化播放器
-	var err error
-	audioPlayer, err = player.NewPlayer()
+	// 初始化播放器与播放区域
+	audioPlayer, err := player.NewPlayer()
 	if err != nil {
 		fmt.Printf("播放器初始化失败: %v\n", err)
 	}
+	zones = zone.NewManager(db, audioPlayer)
+	if err := zones.Start(zone.ConfigFromEnv()); err != nil {
+		fmt.Printf("播放区域创建失败: %v\n", err)
+	}
+	playbackCtl := zones.Default().Controller
+	liveBroadcaster = live.NewBroadcaster(player.OutputFormat())
+	liveBroadcaster.SetTitleFunc(nowPlayingTitle)
+	rate, channels := player.OutputFormat()
+	roomLeader = multiroom.NewLeader(rate, channels)
+	if audioPlayer != nil {
+		audioPlayer.SetPCMTap(func(pcm []byte, at time.Time) {
+			liveBroadcaster.Write(pcm)
+			roomLeader.Write(pcm, at)
+		})
+	}
+	roomCfg := multiroom.ConfigFromEnv()
+	roomFollower = multiroom.NewFollower(audioPlayer, roomCfg.Room)
+	// 设置 GMUSIC_SYNC_LEADER 后启动即跟随该主机
+	if roomCfg.Leader != "" {
+		if err := roomFollower.Join(roomCfg.Leader, ""); err != nil {
+			fmt.Printf("加入多房间同步失败: %v\n", err)
+		}
+	}
+
+	// 可选的 MPD 协议服务（设置 GMUSIC_MPD_ADDR 后启用），与 HTTP API 共享播放队列
+	if _, err := mpd.Start(db, playbackCtl, mpd.ConfigFromEnv()); err != nil {
+		fmt.Printf("MPD 服务启动失败: %v\n", err)
+	}
+	// Linux 桌面会话中导出 MPRIS 接口，使媒体键与系统“正在播放”面板可以控制播放
+	if _, err := mpris.Start(playbackCtl, mpris.ConfigFromEnv()); err != nil {
+		fmt.Printf("MPRIS 接口启动失败: %v\n", err)
+	}
 
 	// API V1 路由组
 	apiV1 := router.Group("/api")
@@ -59,20 +112,39 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 			songs.GET("/:id", getSongByID(db))
 			songs.POST("", addSong(db))
 			songs.PUT("/:id", updateSong(db))
+			songs.GET("/:id/overrides", getSongOverrides(db))
+			songs.DELETE("/:id/overrides", revertSongOverrides(db))
+			songs.DELETE("/:id/overrides/:field", revertSongOverrides(db))
+		}
+		// 艺术家与专辑
+		registerLibraryRoutes(apiV1, db)
+		// 搜索框自动补全：分组提示与纠错
+		apiV1.GET("/search/suggest", suggestSearch(storage.NewSuggester(db, suggestCacheTTL)))
+
+		// 播放控制 API：/api/player 控制默认区域，/api/zones/:zone/player 控制指定区域
+		registerPlayerRoutes(apiV1.Group("/player", withZone()))
+		zoneGroup := apiV1.Group("/zones")
+		{
+			zoneGroup.GET("", listZones())
+			zoneGroup.POST("", createZone())
+			zoneGroup.DELETE("/:zone", deleteZone())
+			registerPlayerRoutes(zoneGroup.Group("/:zone/player", withZone()))
 		}
 
-		// 播放控制 API
-		playerGroup := apiV1.Group("/player")
+		// 多房间同步 API
+		rooms := apiV1.Group("/sync")
 		{
-			playerGroup.POST("/play", playHandler())
-			playerGroup.POST("/pause", pauseHandler())
-			playerGroup.POST("/resume", resumeHandler())
-			playerGroup.POST("/stop", stopHandler())
-			playerGroup.POST("/volume", setVolumeHandler())
-			playerGroup.POST("/seek", seekHandler())
-			playerGroup.GET("/status", getPlayerStatus())
+			rooms.GET("/status", syncStatus())
+			rooms.POST("/join", joinRoom())
+			rooms.POST("/leave", leaveRoom())
+			rooms.GET("/rooms", listRooms())
+			rooms.POST("/rooms/:room/volume", setRoomVolume())
+			rooms.DELETE("/rooms/:room", removeRoom())
 		}
 
+		// 智能播放列表：保存结构化查询，歌曲实时计算
+		registerSmartPlaylistRoutes(apiV1, db)
+
 		// 音频信息（时长等）API
 		audio := apiV1.Group("/audio")
 		{
@@ -80,6 +152,11 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 			audio.POST("/probe", audioProbeByPath())
 		}
 
+		// 音频流（供浏览器/移动端本地播放，支持 Range）
+		apiV1.GET("/stream/:songID", streamSong(db))
+		apiV1.HEAD("/stream/:songID", streamSong(db))
+		apiV1.GET("/hls/:songID/:profile/:file", streamHLS(db))
+
 		// 歌词/封面/扫描
 		apiV1.GET("/lyrics/:songID", getLyrics(db))
 		apiV1.GET("/cover/:songID", getCover(db))
@@ -94,10 +171,41 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 
 		// 工具 API：补全时长
 		apiV1.POST("/refresh/durations", refreshDurations(db))
+		// 旧标签编码（GBK/Big5/Shift-JIS）：单个文件指定编码与曲库级修复
+		registerEncodingRoutes(apiV1, db)
+		registerTagPatternRoutes(apiV1, db)
+	}
+
+	// Subsonic 兼容接口（/rest/*），供 DSub、Symfonium 等第三方客户端使用
+	subsonic.Register(router, db, transcoder, subsonic.ConfigFromEnv())
+
+	// 可选的 UPnP/DLNA 媒体服务器（设置 GMUSIC_UPNP_ADDR 后启用），供局域网内的电视、功放浏览曲库
+	if _, err := upnp.Start(router, db, upnp.ConfigFromEnv()); err != nil {
+		fmt.Printf("UPnP 服务启动失败: %v\n", err)
+	}
+
+	// 可选的聚会点歌模式（设置 GMUSIC_JUKEBOX_ADMIN_TOKEN 后启用），接管一个播放区域
+	jbCfg := jukebox.ConfigFromEnv()
+	if jbCfg.Zone == "" {
+		jbCfg.Zone = zone.DefaultName
+	}
+	if z, ok := zones.Get(jbCfg.Zone); !ok {
+		if jbCfg.AdminToken != "" {
+			fmt.Printf("点歌模式启动失败: 区域 %s 不存在\n", jbCfg.Zone)
+		}
+	} else if jb := jukebox.Start(db, z.Controller, jbCfg); jb != nil {
+		registerJukeboxRoutes(apiV1, router, jb)
 	}
 
 	// WebSocket 实时播放状态
-	router.GET("/ws/player", playerWebSocket())
+	router.GET("/ws/player", withZone(), playerWebSocket())
+	router.GET("/ws/zones/:zone", withZone(), playerWebSocket())
+
+	// 多房间同步：从机连接主机接收带时间戳的 PCM
+	router.GET(multiroom.SyncPath, syncWebSocket())
+
+	// 直播挂载点：收听服务端正在播放的声音
+	router.GET("/live", liveStream())
 
 	// 静态文件
 	router.Static("/covers", "./covers")
@@ -113,10 +221,19 @@ func getSongs(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if err := storage.AttachOverrides(db, songs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"total": len(songs), "songs": songs})
 	}
 }
 
+// maxSearchLimit 单次搜索最多返回的结果数，用 offset 翻页
+const maxSearchLimit = 200
+
+// searchSongs 搜索歌曲：q 为关键词或结构化查询（如 artist:"陈奕迅" year:2000..2010 -live），
+// 语法错误返回 400 与出错位置；limit 默认 50，offset 翻页
 func searchSongs(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		keyword := c.Query("q")
@@ -124,12 +241,41 @@ func searchSongs(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "搜索关键词不能为空"})
 			return
 		}
-		songs, err := storage.SearchSongs(db, keyword)
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		offset, _ := strconv.Atoi(c.Query("offset"))
+		if limit <= 0 || limit > maxSearchLimit {
+			limit = maxSearchLimit
+		}
+		res, err := storage.Search(db, storage.SearchQuery{Keyword: keyword, Limit: limit, Offset: max(offset, 0)})
+		if err != nil {
+			respondQueryError(c, err)
+			return
+		}
+		// songs 中每项为歌曲字段加上 score 与 highlight（命中部分以 <mark> 标记）
+		c.JSON(http.StatusOK, gin.H{"keyword": keyword, "total": res.Total, "engine": res.Engine, "songs": res.Hits})
+	}
+}
+
+// suggestCacheTTL 自动补全结果与词表的缓存时间
+const suggestCacheTTL = 15 * time.Second
+
+// maxSuggestLimit 自动补全每组最多返回的条数
+const maxSuggestLimit = 20
+
+// suggestSearch 自动补全：q 按普通关键词处理（不解析查询语法），返回歌曲、艺术家、专辑、播放列表四组提示，
+// 每组含命中总数与前 limit 条（默认 5）；都没有命中时 did_you_mean 给出拼写相近的名称
+func suggestSearch(s *storage.Suggester) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
+		if limit <= 0 || limit > maxSuggestLimit {
+			limit = maxSuggestLimit
+		}
+		res, err := s.Suggest(c.Query("q"), limit)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"keyword": keyword, "total": len(songs), "songs": songs})
+		c.JSON(http.StatusOK, res)
 	}
 }
 
@@ -142,7 +288,20 @@ func getSongByID(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": "歌曲不存在"})
 			return
 		}
-		c.JSON(http.StatusOK, song)
+		// 附带拆分后的署名（artists 字段），artist 字段仍为标签原文
+		credits, err := storage.GetSongCredits(db, song.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		song.Credits = credits
+		// overridden 列出手动修改过的字段
+		songs := []storage.Song{song}
+		if err := storage.AttachOverrides(db, songs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, songs[0])
 	}
 }
 
@@ -160,63 +319,162 @@ func addSong(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if err := storage.AddSong(db, song); err != nil {
+		// 标签缺失或不完整时与扫描一样按文件名推断
+		patterns, err := scanner.TagPatterns(db)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusCreated, song)
+		metadata.InferTags(song, patterns)
+		// 同一文件再次添加时刷新已有记录的元数据（200），首次添加返回 201
+		created, err := storage.UpsertSong(db, song)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		c.JSON(status, song)
 	}
 }
 
-// updateSong 更新歌曲信息（目前仅 duration）
+// updateSong 手动修改歌曲信息：{"title":"...","track_num":3,"genres":["Rock"]}，可修改的字段见 storage.OverrideFields。
+// 修改单独记录，重新扫描后仍然保留；某字段为 null 时恢复为文件中的值。返回修改后的歌曲，overridden 列出修改过的字段。
+// duration（界面探测到的真实时长）不是修改：直接写入，不记录，之后补齐时长或重新扫描照常更新
 func updateSong(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		var song storage.Song
-		if err := db.First(&song, id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "歌曲不存在"})
+		id, ok := idParam(c, "歌曲")
+		if !ok {
 			return
 		}
-
-		var req struct {
-			Duration *int `json:"duration"` // 使用指针以区分 0 和未提供
-		}
+		var req map[string]json.RawMessage
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		if req.Duration != nil {
-			if err := db.Model(&song).Update("duration", *req.Duration).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		duration := -1
+		if raw, ok := req["duration"]; ok {
+			if err := json.Unmarshal(raw, &duration); err != nil || duration < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "duration 应为非负整数（秒）"})
 				return
 			}
+			delete(req, "duration")
+		}
+		values := map[string]string{}
+		var revert []string
+		for field, raw := range req {
+			if string(raw) == "null" {
+				if !storage.IsOverrideField(field) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "字段 " + field + " 不可修改"})
+					return
+				}
+				revert = append(revert, field)
+				continue
+			}
+			value, err := storage.NormalizeOverride(field, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			values[field] = value
+		}
+		// 修改与恢复在同一事务中完成，任一步失败都不留下部分修改
+		var song *storage.Song
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if duration >= 0 {
+				cur, err := storage.GetSongByID(tx, id)
+				if err != nil {
+					return err
+				}
+				if err := storage.UpdateSongDuration(tx, cur, duration); err != nil {
+					return err
+				}
+			}
+			var err error
+			if song, err = storage.SetSongOverrides(tx, id, values); err != nil {
+				return err
+			}
+			if len(revert) > 0 {
+				song, err = storage.RevertSongOverrides(tx, id, revert...)
+			}
+			return err
+		})
+		if err != nil {
+			respondNotFound(c, err, "歌曲不存在")
+			return
 		}
 		c.JSON(http.StatusOK, song)
 	}
 }
 
 // =========== 播放控制 ===========
+// playHandler 按曲库实体播放：song_id / album(+artist) / artist / playlist_id，
+// 可选 start_index 与 shuffle。不再接受文件路径，避免客户端依赖服务器文件系统。
 func playHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req struct {
-			FilePath string `json:"file_path" binding:"required"`
-		}
+		var req playback.Request
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		fmt.Printf("/api/player/play -> %s\n", req.FilePath)
-		if _, err := os.Stat(req.FilePath); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("文件不存在或不可读: %v", err)})
+		ctl := currentZone(c).Controller
+		song, err := ctl.Play(req)
+		if err != nil {
+			respondPlaybackError(c, err)
 			return
 		}
-		if err := audioPlayer.Play(req.FilePath); err != nil {
-			fmt.Printf("播放失败: %v\n", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_, index := ctl.Current()
+		c.JSON(http.StatusOK, gin.H{"message": "播放开始", "song": song, "index": index})
+	}
+}
+
+// nextHandler 播放服务端队列中的下一首
+func nextHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		song, err := currentZone(c).Controller.Next()
+		if err != nil {
+			respondPlaybackError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"song": song})
+	}
+}
+
+// previousHandler 播放服务端队列中的上一首
+func previousHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		song, err := currentZone(c).Controller.Previous()
+		if err != nil {
+			respondPlaybackError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "播放开始"})
+		c.JSON(http.StatusOK, gin.H{"song": song})
+	}
+}
+
+// getQueueHandler 返回服务端播放队列
+func getQueueHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctl := currentZone(c).Controller
+		songs, source := ctl.Queue()
+		_, index := ctl.Current()
+		c.JSON(http.StatusOK, gin.H{"source": source, "index": index, "total": len(songs), "songs": songs})
+	}
+}
+
+// respondPlaybackError 将播放控制错误映射为 HTTP 状态码
+func respondPlaybackError(c *gin.Context, err error) {
+	var nf *playback.NotFoundError
+	switch {
+	case errors.As(err, &nf):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, playback.ErrEmptyQueue):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("播放失败: %v\n", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 	}
 }
 
@@ -232,7 +490,7 @@ func seekHandler() gin.HandlerFunc {
 		if req.Position < 0 {
 			req.Position = 0
 		}
-		if err := audioPlayer.SeekTo(req.Position); err != nil {
+		if err := currentZone(c).Player.SeekTo(req.Position); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -241,13 +499,22 @@ func seekHandler() gin.HandlerFunc {
 }
 
 func pauseHandler() gin.HandlerFunc {
-	return func(c *gin.Context) { audioPlayer.Pause(); c.JSON(http.StatusOK, gin.H{"message": "已暂停"}) }
+	return func(c *gin.Context) {
+		currentZone(c).Player.Pause()
+		c.JSON(http.StatusOK, gin.H{"message": "已暂停"})
+	}
 }
 func resumeHandler() gin.HandlerFunc {
-	return func(c *gin.Context) { audioPlayer.Resume(); c.JSON(http.StatusOK, gin.H{"message": "已恢复"}) }
+	return func(c *gin.Context) {
+		currentZone(c).Player.Resume()
+		c.JSON(http.StatusOK, gin.H{"message": "已恢复"})
+	}
 }
 func stopHandler() gin.HandlerFunc {
-	return func(c *gin.Context) { audioPlayer.Stop(); c.JSON(http.StatusOK, gin.H{"message": "已停止"}) }
+	return func(c *gin.Context) {
+		currentZone(c).Player.Stop()
+		c.JSON(http.StatusOK, gin.H{"message": "已停止"})
+	}
 }
 func setVolumeHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -258,14 +525,47 @@ func setVolumeHandler() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		audioPlayer.SetVolume(req.Volume)
+		if z := currentZone(c); z.Name == zone.DefaultName {
+			// 默认区域经由从机设置，跟随主机时音量会同步上报
+			roomFollower.SetVolume(float64(req.Volume))
+		} else {
+			z.Player.SetVolume(req.Volume)
+		}
 		c.JSON(http.StatusOK, gin.H{"volume": req.Volume})
 	}
 }
 func getPlayerStatus() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"is_playing": audioPlayer.IsPlaying(), "position": audioPlayer.GetCurrentPosition(), "duration": audioPlayer.GetDuration()})
+		c.JSON(http.StatusOK, playerStatus(currentZone(c)))
+	}
+}
+
+// playerStatus 区域的播放状态快照（HTTP 与 WebSocket 共用）
+func playerStatus(z *zone.Zone) gin.H {
+	p := z.Player
+	status := gin.H{"zone": z.Name, "is_playing": p.IsPlaying(), "position": p.GetCurrentPosition(), "duration": p.GetDuration(), "volume": p.GetVolume()}
+	if song, index := z.Controller.Current(); song != nil {
+		status["song_id"] = song.ID
+		status["queue_index"] = index
+	}
+	// 直播只转发默认区域
+	if z.Name == zone.DefaultName {
+		status["live_listeners"] = liveBroadcaster.Listeners()
 	}
+	return status
+}
+
+// nowPlayingTitle 直播元数据中的“正在播放”标题（艺术家 - 标题），未播放时为空
+func nowPlayingTitle() string {
+	z := zones.Default()
+	song, _ := z.Controller.Current()
+	if song == nil || z.Player == nil || !(z.Player.IsPlaying() || z.Player.IsPaused()) {
+		return ""
+	}
+	if song.Artist == "" {
+		return song.Title
+	}
+	return song.Artist + " - " + song.Title
 }
 
 // =========== 音频信息（时长等） ===========
@@ -282,6 +582,11 @@ func audioInfoByID(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		// CUE 分轨只返回本轨的时长，而不是整轨镜像的
+		if song.IsCueTrack() {
+			info.Duration = metadata.TrackDuration(&song, info.Duration)
+			info.DurationText = metadata.FormatDuration(info.Duration)
+		}
 		c.JSON(http.StatusOK, info)
 	}
 }
@@ -322,10 +627,10 @@ func refreshDurations(db *gorm.DB) gin.HandlerFunc {
 				skipped++
 				continue
 			}
-			sec := metadata.ComputeDurationSeconds(songs[i].FilePath)
+			// CUE 分轨只取区间长度
+			sec := metadata.TrackDuration(&songs[i], metadata.ComputeDurationSeconds(songs[i].FilePath))
 			if sec > 0 {
-				songs[i].Duration = sec
-				if err := db.Model(&songs[i]).Update("duration", sec).Error; err == nil {
+				if err := storage.UpdateSongDuration(db, &songs[i], sec); err == nil {
 					updated++
 				}
 			}
@@ -397,7 +702,7 @@ func scanDirectory(db *gorm.DB) gin.HandlerFunc {
 				}
 				return
 			}
-			fmt.Printf("扫描完成: 总文件数=%d, 添加=%d, 失败=%d\n", result.TotalFiles, result.AddedSongs, result.FailedFiles)
+			fmt.Printf("扫描完成: 总文件数=%d, 添加=%d, 更新=%d, 失败=%d\n", result.TotalFiles, result.AddedSongs, result.UpdatedSongs, result.FailedFiles)
 		}()
 
 		c.JSON(http.StatusAccepted, gin.H{
@@ -507,6 +812,7 @@ func getCover(db *gorm.DB) gin.HandlerFunc {
 // =========== WebSocket ===========
 func playerWebSocket() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		z := currentZone(c)
 		ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
 			fmt.Printf("WebSocket 升级失败: %v\n", err)
@@ -518,8 +824,7 @@ func playerWebSocket() gin.HandlerFunc {
 			if err := ws.ReadJSON(&msg); err != nil {
 				break
 			}
-			status := map[string]any{"is_playing": audioPlayer.IsPlaying(), "position": audioPlayer.GetCurrentPosition(), "duration": audioPlayer.GetDuration()}
-			if err := ws.WriteJSON(status); err != nil {
+			if err := ws.WriteJSON(playerStatus(z)); err != nil {
 				break
 			}
 		}
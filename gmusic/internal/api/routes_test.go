@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// TestUpdateSongDuration 界面探测到时长后 PUT {duration} 只写入时长，不把歌曲标记为手动修改过；
+// 与其他字段一起提交时其他字段照常记为修改
+func TestUpdateSongDuration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := storage.InitDB(filepath.Join(t.TempDir(), "gmusic.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	song := &storage.Song{Title: "十年", Artist: "陈奕迅", FilePath: "/music/十年.mp3"}
+	if err := storage.AddSong(db, song); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.PUT("/songs/:id", updateSong(db))
+
+	tests := []struct {
+		body       string
+		status     int
+		duration   int
+		overridden []string
+	}{
+		{`{"duration":205}`, http.StatusOK, 205, nil},
+		{`{"duration":206,"year":2003}`, http.StatusOK, 206, []string{"year"}},
+		{`{"duration":-1}`, http.StatusBadRequest, 206, []string{"year"}},
+		{`{"duration":"3:25"}`, http.StatusBadRequest, 206, []string{"year"}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/songs/1", strings.NewReader(tt.body)))
+		if w.Code != tt.status {
+			t.Errorf("%s: 状态 %d，期望 %d（%s）", tt.body, w.Code, tt.status, w.Body)
+			continue
+		}
+		if w.Code == http.StatusOK {
+			var got storage.Song
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Duration != tt.duration || strings.Join(got.Overridden, ",") != strings.Join(tt.overridden, ",") {
+				t.Errorf("%s: 返回时长 %d、修改过的字段 %v，期望 %d、%v", tt.body, got.Duration, got.Overridden, tt.duration, tt.overridden)
+			}
+		}
+		got, err := storage.GetSongByID(db, song.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		overrides, err := storage.GetSongOverrides(db, got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := overrides["duration"]; got.Duration != tt.duration || ok || len(overrides) != len(tt.overridden) {
+			t.Errorf("%s: 库中时长 %d、修改记录 %v", tt.body, got.Duration, overrides)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"gorm.io/gorm"
+)
+
+// registerSmartPlaylistRoutes 注册智能播放列表路由
+func registerSmartPlaylistRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	g := api.Group("/smart-playlists")
+	{
+		g.GET("", listSmartPlaylists(db))
+		g.POST("", createSmartPlaylist(db))
+		g.GET("/:id", getSmartPlaylist(db))
+		g.PUT("/:id", updateSmartPlaylist(db))
+		g.DELETE("/:id", deleteSmartPlaylist(db))
+	}
+}
+
+// respondQueryError 查询语法错误返回 400 并附带出错位置，其他错误返回 500
+func respondQueryError(c *gin.Context, err error) {
+	var qe *storage.QueryError
+	if errors.As(err, &qe) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "position": qe.Pos})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// validateSmartPlaylist 校验失败时返回 400（查询语法错误附带出错位置），失败时已写入响应
+func validateSmartPlaylist(c *gin.Context, sp *storage.SmartPlaylist) bool {
+	err := sp.Validate()
+	if err == nil {
+		return true
+	}
+	var qe *storage.QueryError
+	if errors.As(err, &qe) {
+		respondQueryError(c, err)
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// smartPlaylistFromParam 按路径参数 :id 读取智能播放列表，失败时已写入响应
+func smartPlaylistFromParam(c *gin.Context, db *gorm.DB) (*storage.SmartPlaylist, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的智能播放列表 ID"})
+		return nil, false
+	}
+	sp, err := storage.GetSmartPlaylist(db, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "智能播放列表不存在"})
+		return nil, false
+	}
+	return sp, true
+}
+
+func listSmartPlaylists(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lists, err := storage.ListSmartPlaylists(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"total": len(lists), "smart_playlists": lists, "sorts": storage.SmartPlaylistSorts()})
+	}
+}
+
+// createSmartPlaylist 创建：{"name": "2000 年代的陈奕迅", "query": "artist:陈奕迅 year:2000..2009", "sort": "year", "limit": 100}
+func createSmartPlaylist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var sp storage.SmartPlaylist
+		if err := c.ShouldBindJSON(&sp); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		sp.ID = 0
+		if !validateSmartPlaylist(c, &sp) {
+			return
+		}
+		if err := storage.SaveSmartPlaylist(db, &sp); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, sp)
+	}
+}
+
+// getSmartPlaylist 返回列表定义及按查询实时计算出的歌曲
+func getSmartPlaylist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sp, ok := smartPlaylistFromParam(c, db)
+		if !ok {
+			return
+		}
+		songs, err := storage.SmartPlaylistSongs(db, sp)
+		if err != nil {
+			respondQueryError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"smart_playlist": sp, "total": len(songs), "songs": songs})
+	}
+}
+
+// updateSmartPlaylist 修改名称、查询、排序或上限，未给出的字段保持不变
+func updateSmartPlaylist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sp, ok := smartPlaylistFromParam(c, db)
+		if !ok {
+			return
+		}
+		id := sp.ID
+		if err := c.ShouldBindJSON(sp); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		sp.ID = id
+		if !validateSmartPlaylist(c, sp) {
+			return
+		}
+		if err := storage.SaveSmartPlaylist(db, sp); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, sp)
+	}
+}
+
+func deleteSmartPlaylist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sp, ok := smartPlaylistFromParam(c, db)
+		if !ok {
+			return
+		}
+		if err := storage.DeleteSmartPlaylist(db, sp.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "已删除智能播放列表"})
+	}
+}
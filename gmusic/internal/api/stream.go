@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/metadata"
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"github.com/yudongyouqing/GMusic/internal/transcode"
+	"github.com/yudongyouqing/GMusic/internal/upnp"
+	"gorm.io/gorm"
+)
+
+// streamSong 输出歌曲音频，支持 Range（206 Partial Content）、HEAD 与 ETag 条件请求，
+// 供浏览器/移动端在本地播放。
+//
+// 未指定 format 时输出原始文件。CUE 分轨共享整轨文件，此时输出的是完整源文件，
+// 并通过 X-Track-Start-Ms / X-Track-End-Ms 告知客户端该分轨在文件中的区间。
+//
+// 指定 ?format=wav|flac|ogg（可选 &maxRate=48000）时先转码再输出，转码结果按歌曲与配置缓存；
+// 转码只输出分轨对应的区间。源文件须能被播放器解码（mp3、flac、wav）；DSD（.dsf/.dff）、
+// ALAC/AAC（.m4a）、APE 等格式暂无解码器，转码与 HLS 返回 415，只能不带 format 原样输出。
+func streamSong(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("songID"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的歌曲 ID"})
+			return
+		}
+		song, err := storage.GetSongByID(db, uint(id))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "歌曲不存在"})
+			return
+		}
+		if format := c.Query("format"); format != "" && format != "raw" {
+			serveTranscoded(c, song, format, c.Query("maxRate"))
+			return
+		}
+		serveSongFile(c, song)
+	}
+}
+
+// serveTranscoded 转码（或命中缓存）后输出，缓存文件同样支持 Range 与 ETag
+func serveTranscoded(c *gin.Context, song *storage.Song, format, maxRate string) {
+	profile, err := transcode.ParseProfile(format, maxRate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	path, err := transcoder.Transcode(c.Request.Context(), song, profile)
+	if err != nil {
+		respondTranscodeError(c, err)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取转码缓存失败"})
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取转码缓存失败"})
+		return
+	}
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", profile.ContentType())
+	setDLNAHeaders(c, profile.ContentType(), true)
+	// 缓存文件名已包含源文件指纹，直接作为 ETag
+	h.Set("ETag", `"`+strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+`"`)
+	h.Set("Cache-Control", "private, max-age=0, must-revalidate")
+	http.ServeContent(c.Writer, c.Request, filepath.Base(path), info.ModTime(), f)
+}
+
+// serveSongFile 输出歌曲源文件（Range/ETag 由 http.ServeContent 处理）
+func serveSongFile(c *gin.Context, song *storage.Song) {
+	f, err := os.Open(song.FilePath)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "音频文件不存在或不可读"})
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "音频文件不存在或不可读"})
+		return
+	}
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", metadata.AudioContentType(song.FilePath))
+	setDLNAHeaders(c, metadata.AudioContentType(song.FilePath), false)
+	// 强 ETag：歌曲 ID + 文件大小 + 修改时间，文件被替换后自动失效
+	h.Set("ETag", fmt.Sprintf(`"%d-%x-%x"`, song.ID, info.Size(), info.ModTime().UnixNano()))
+	h.Set("Cache-Control", "private, max-age=0, must-revalidate")
+	if song.IsCueTrack() {
+		h.Set("X-Track-Start-Ms", strconv.FormatInt(song.StartMs, 10))
+		h.Set("X-Track-End-Ms", strconv.FormatInt(song.EndMs, 10))
+	}
+	http.ServeContent(c.Writer, c.Request, filepath.Base(song.FilePath), info.ModTime(), f)
+}
+
+// setDLNAHeaders DLNA 渲染端通过 getcontentFeatures.dlna.org 请求头询问资源特性（是否可 seek 等），
+// 部分电视收不到 contentFeatures.dlna.org 响应头时会拒绝播放
+func setDLNAHeaders(c *gin.Context, contentType string, transcoded bool) {
+	if c.GetHeader("getcontentFeatures.dlna.org") == "" {
+		return
+	}
+	h := c.Writer.Header()
+	h.Set("contentFeatures.dlna.org", upnp.ContentFeatures(contentType, transcoded))
+	h.Set("transferMode.dlna.org", "Streaming")
+}
+
+// streamHLS 输出歌曲的 HLS 播放列表与分段：
+//
+//	GET /api/hls/:songID/:profile/index.m3u8   播放列表（profile 如 flac、flac-48000）
+//	GET /api/hls/:songID/:profile/init.mp4     初始化分段
+//	GET /api/hls/:songID/:profile/seg-N.m4s    第 N 个媒体分段（首次请求时生成并缓存）
+func streamHLS(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("songID"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的歌曲 ID"})
+			return
+		}
+		profile, err := transcode.ParseProfileKey(c.Param("profile"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if profile.Format != transcode.FormatFLAC {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "HLS 目前仅支持 flac 分段"})
+			return
+		}
+		song, err := storage.GetSongByID(db, uint(id))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "歌曲不存在"})
+			return
+		}
+
+		file := c.Param("file")
+		var path string
+		switch {
+		case file == "index.m3u8":
+			playlist, err := transcoder.HLSPlaylist(song, profile)
+			if err != nil {
+				respondTranscodeError(c, err)
+				return
+			}
+			c.Header("Cache-Control", "private, max-age=0, must-revalidate")
+			c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(playlist))
+			return
+		case file == "init.mp4":
+			path, err = transcoder.HLSInit(c.Request.Context(), song, profile)
+		case strings.HasPrefix(file, "seg-") && strings.HasSuffix(file, ".m4s"):
+			n, perr := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(file, "seg-"), ".m4s"))
+			if perr != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "分段不存在"})
+				return
+			}
+			path, err = transcoder.HLSSegment(c.Request.Context(), song, profile, n)
+		default:
+			c.JSON(http.StatusNotFound, gin.H{"error": "分段不存在"})
+			return
+		}
+		if err != nil {
+			respondTranscodeError(c, err)
+			return
+		}
+		// 分段内容由缓存路径（含源文件指纹）唯一确定，可长期缓存
+		c.Header("Content-Type", "audio/mp4")
+		c.Header("Cache-Control", "private, max-age=86400")
+		http.ServeFile(c.Writer, c.Request, path)
+	}
+}
+
+// respondTranscodeError 转码/分段失败时的统一响应
+func respondTranscodeError(c *gin.Context, err error) {
+	if errors.Is(err, player.ErrUnsupportedFormat) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("转码失败: %v", err)})
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/metadata"
+	"github.com/yudongyouqing/GMusic/internal/scanner"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"gorm.io/gorm"
+)
+
+// maxPatternPreview 模式预览单次最多的样本文件数
+const maxPatternPreview = 200
+
+// tagPatternFields 模式中可用的字段，供前端提示
+var tagPatternFields = []string{"title", "artist", "album", "albumartist", "composer", "genre", "track", "disc", "year", "ignore"}
+
+// registerTagPatternRoutes 注册按文件名推断标签的模式设置与预览路由
+func registerTagPatternRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	g := api.Group("/tag-patterns")
+	{
+		g.GET("", getTagPatterns(db))
+		g.PUT("", setTagPatterns(db))
+		g.DELETE("", resetTagPatterns(db))
+		g.POST("/preview", previewTagPatterns(db))
+	}
+}
+
+// getTagPatterns 返回生效的推断模式、默认模式与可用字段
+func getTagPatterns(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		saved, err := storage.GetTagPatterns(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		patterns := saved
+		if patterns == nil {
+			patterns = metadata.DefaultTagPatterns
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"patterns": patterns,
+			"default":  saved == nil,
+			"defaults": metadata.DefaultTagPatterns,
+			"fields":   tagPatternFields,
+		})
+	}
+}
+
+// setTagPatterns 保存推断模式：{"patterns":["%artist%/%album%/%track% - %title%", ...]}，按顺序尝试；
+// 只影响之后扫描或重新读取的文件，已入库的歌曲需重新扫描才会按新模式推断
+func setTagPatterns(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Patterns []string `json:"patterns" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		parsed, err := metadata.ParseTagPatterns(req.Patterns)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		patterns := make([]string, len(parsed))
+		for i, p := range parsed {
+			patterns[i] = p.Source
+		}
+		if err := storage.SetTagPatterns(db, patterns); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"patterns": patterns, "default": false})
+	}
+}
+
+// resetTagPatterns 删除保存的模式，恢复默认模式
+func resetTagPatterns(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := storage.SetTagPatterns(db, nil); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"patterns": metadata.DefaultTagPatterns, "default": true})
+	}
+}
+
+// patternPreview 单个文件的推断预览
+type patternPreview struct {
+	FilePath string              `json:"file_path"`
+	Matches  []map[string]string `json:"matches"`         // 各模式提取的字段，不匹配时为 null
+	Applied  int                 `json:"applied"`         // 实际采用的模式序号，-1 表示都不匹配
+	Filled   []string            `json:"filled"`          // 由推断填补的字段
+	Song     *storage.Song       `json:"song"`            // 推断后的标签
+	Error    string              `json:"error,omitempty"` // 读取标签失败
+}
+
+// previewTagPatterns 预览推断结果，不修改曲库：{"patterns":[...], "dir":"/music/未整理", "limit":20}。
+// patterns 省略时使用生效的模式；指定 dir 时取该目录下的音频文件，否则取曲库中标签不完整的歌曲
+func previewTagPatterns(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Patterns []string `json:"patterns"`
+			Dir      string   `json:"dir"`
+			Limit    int      `json:"limit"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Limit <= 0 {
+			req.Limit = 20
+		} else if req.Limit > maxPatternPreview {
+			req.Limit = maxPatternPreview
+		}
+
+		var patterns []*metadata.TagPattern
+		var err error
+		if req.Patterns != nil {
+			patterns, err = metadata.ParseTagPatterns(req.Patterns)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		} else if patterns, err = scanner.TagPatterns(db); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var songs []*storage.Song
+		var readErrs map[string]string
+		if req.Dir != "" {
+			songs, readErrs, err = sampleDirSongs(req.Dir, req.Limit)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		} else if songs, err = sampleIncompleteSongs(db, req.Limit); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		sources := make([]string, len(patterns))
+		for i, p := range patterns {
+			sources[i] = p.Source
+		}
+		previews := make([]patternPreview, 0, len(songs))
+		for _, s := range songs {
+			pv := patternPreview{FilePath: s.FilePath, Applied: -1, Filled: []string{}, Error: readErrs[s.FilePath]}
+			pv.Matches = make([]map[string]string, len(patterns))
+			for i, p := range patterns {
+				pv.Matches[i] = p.Match(s.FilePath)
+				if pv.Applied < 0 && pv.Matches[i] != nil {
+					pv.Applied = i
+				}
+			}
+			if pv.Error == "" {
+				if filled := metadata.InferTags(s, patterns); filled != nil {
+					pv.Filled = filled
+				} else if !metadata.NeedsTagInference(s) {
+					pv.Applied = -1 // 标签完整，不会推断
+				}
+				pv.Song = s
+			}
+			previews = append(previews, pv)
+		}
+		c.JSON(http.StatusOK, gin.H{"patterns": sources, "files": previews})
+	}
+}
+
+// sampleDirSongs 读取目录下（含子目录，按路径排序）前 limit 个音频文件的标签；读取失败的文件记入 errs
+func sampleDirSongs(dir string, limit int) ([]*storage.Song, map[string]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && scanner.IsSupportedAudio(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(paths)
+	if len(paths) > limit {
+		paths = paths[:limit]
+	}
+	songs := make([]*storage.Song, 0, len(paths))
+	errs := map[string]string{}
+	for _, path := range paths {
+		s, err := metadata.ReadTags(path)
+		if err != nil {
+			errs[path] = err.Error()
+			s = &storage.Song{FilePath: path}
+		}
+		songs = append(songs, s)
+	}
+	return songs, errs, nil
+}
+
+// sampleIncompleteSongs 曲库中标签不完整（见 metadata.NeedsTagInference）的前 limit 首歌曲，不含 CUE 分轨
+func sampleIncompleteSongs(db *gorm.DB, limit int) ([]*storage.Song, error) {
+	var rows []storage.Song
+	err := db.Where("COALESCE(cue_file, '') = ''").
+		Where("title = '' OR COALESCE(artist, '') = '' OR album = '' OR track_num = 0").
+		Order("file_path").Limit(limit).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	songs := make([]*storage.Song, len(rows))
+	for i := range rows {
+		songs[i] = &rows[i]
+	}
+	return songs, nil
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/zone"
+)
+
+// zoneKey gin.Context 中保存当前区域的键
+const zoneKey = "zone"
+
+// withZone 按路径参数 :zone 解析播放区域（没有该参数时为默认区域），不存在则返回 404
+func withZone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("zone")
+		if name == "" {
+			name = zone.DefaultName
+		}
+		z, ok := zones.Get(name)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "区域不存在: " + name})
+			return
+		}
+		if z.Player == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "区域 " + name + " 的播放器不可用"})
+			return
+		}
+		c.Set(zoneKey, z)
+		c.Next()
+	}
+}
+
+// currentZone 取出 withZone 解析的区域
+func currentZone(c *gin.Context) *zone.Zone {
+	return c.MustGet(zoneKey).(*zone.Zone)
+}
+
+// registerPlayerRoutes 注册一组播放控制路由（默认区域与各命名区域共用）
+func registerPlayerRoutes(g *gin.RouterGroup) {
+	g.POST("/play", playHandler())
+	g.POST("/next", nextHandler())
+	g.POST("/previous", previousHandler())
+	g.GET("/queue", getQueueHandler())
+	g.POST("/pause", pauseHandler())
+	g.POST("/resume", resumeHandler())
+	g.POST("/stop", stopHandler())
+	g.POST("/volume", setVolumeHandler())
+	g.POST("/seek", seekHandler())
+	g.GET("/status", getPlayerStatus())
+}
+
+// zoneInfo 区域列表中的一项
+func zoneInfo(z *zone.Zone) gin.H {
+	info := gin.H{"name": z.Name, "output": z.Output}
+	if z.Player != nil {
+		for k, v := range playerStatus(z) {
+			info[k] = v
+		}
+		delete(info, "zone")
+	}
+	return info
+}
+
+// listZones 列出全部播放区域及其状态
+func listZones() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list := zones.List()
+		out := make([]gin.H, 0, len(list))
+		for _, z := range list {
+			out = append(out, zoneInfo(z))
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// createZone 创建区域：{"name": "office", "output": "null"}。
+// 出于安全考虑，API 只能创建 local 与 null 输出的区域，command 输出只能通过 GMUSIC_ZONES 配置。
+func createZone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name   string `json:"name" binding:"required"`
+			Output string `json:"output"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Output == "" {
+			req.Output = "null"
+		}
+		if strings.HasPrefix(req.Output, "command") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "command 输出只能通过环境变量 GMUSIC_ZONES 配置"})
+			return
+		}
+		z, err := zones.Add(req.Name, req.Output)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, zone.ErrExists) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, zoneInfo(z))
+	}
+}
+
+// deleteZone 停止并删除区域（默认区域不可删除）
+func deleteZone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("zone")
+		if err := zones.Remove(name); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, zone.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "已删除区域 " + name})
+	}
+}
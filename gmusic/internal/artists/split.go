@@ -0,0 +1,243 @@
+// Package artists 把艺术家标签拆分为多位艺术家及其角色，例如
+//
+//	"周杰伦 & 费玉清"              → 周杰伦(main) 费玉清(main)
+//	"Calvin Harris feat. Rihanna" → Calvin Harris(main) Rihanna(featured)
+//	标题 "Song (David Guetta Remix)" → David Guetta(remixer)
+//	作曲 "A/B"                     → A(composer) B(composer)
+//
+// 拆分规则（分隔符、客串标记、不拆分的名称）可通过环境变量配置，见 ConfigFromEnv。
+package artists
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Role 艺术家在歌曲中的角色
+type Role string
+
+const (
+	RoleMain     Role = "main"     // 演唱/演奏者
+	RoleFeatured Role = "featured" // 客串（feat.）
+	RoleRemixer  Role = "remixer"  // 混音
+	RoleComposer Role = "composer" // 作曲
+)
+
+// Credit 一位艺术家及其角色
+type Credit struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+}
+
+// Config 拆分规则；匹配均不区分大小写
+type Config struct {
+	Disabled   bool     // 不拆分：整个艺术家标签作为唯一的 main，也不解析标题与作曲
+	Separators []string // 并列艺术家之间的分隔符
+	Featuring  []string // 客串标记，之后的艺术家为 featured
+	Keep       []string // 含分隔符但不应拆开的名称，如 AC/DC
+}
+
+// DefaultConfig 默认规则
+func DefaultConfig() Config {
+	return Config{
+		Separators: []string{" & ", "/", "、", ";", "；", ",", "，", " x ", " × ", " vs. ", " vs "},
+		Featuring:  []string{"featuring", "feat.", "feat", "ft.", "ft"},
+		Keep: []string{
+			"AC/DC", "Simon & Garfunkel", "Earth, Wind & Fire", "Crosby, Stills, Nash & Young",
+			"Hall & Oates", "Tyler, The Creator", "Emerson, Lake & Palmer", "Peter, Paul and Mary",
+		},
+	}
+}
+
+// ConfigFromEnv 在默认规则基础上读取环境变量：
+//   - GMUSIC_ARTIST_SPLIT=off 关闭拆分
+//   - GMUSIC_ARTIST_SEPARATORS 分隔符列表，以 | 分隔，如 " & |/|、"（注意保留两侧空格）
+//   - GMUSIC_ARTIST_FEATURING 客串标记列表，以 | 分隔
+//   - GMUSIC_ARTIST_KEEP 追加的不拆分名称，以 | 分隔
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	if v := strings.ToLower(os.Getenv("GMUSIC_ARTIST_SPLIT")); v == "off" || v == "false" || v == "0" {
+		cfg.Disabled = true
+	}
+	if v := os.Getenv("GMUSIC_ARTIST_SEPARATORS"); v != "" {
+		cfg.Separators = strings.Split(v, "|")
+	}
+	if v := os.Getenv("GMUSIC_ARTIST_FEATURING"); v != "" {
+		cfg.Featuring = strings.Split(v, "|")
+	}
+	if v := os.Getenv("GMUSIC_ARTIST_KEEP"); v != "" {
+		cfg.Keep = append(cfg.Keep, strings.Split(v, "|")...)
+	}
+	return cfg
+}
+
+// Fingerprint 规则的文本摘要，规则变化时据此重新拆分曲库
+func (c Config) Fingerprint() string {
+	if c.Disabled {
+		return "off"
+	}
+	return strings.Join([]string{
+		strings.Join(c.Separators, "\x1f"), strings.Join(c.Featuring, "\x1f"), strings.Join(c.Keep, "\x1f"),
+	}, "\x1e")
+}
+
+// Splitter 按规则拆分，创建后只读，可并发使用
+type Splitter struct {
+	cfg        Config
+	featTitle  *regexp.Regexp // 标题中的 (feat. X)
+	remixTitle *regexp.Regexp // 标题中的 (X Remix)
+}
+
+// NewSplitter 按规则创建拆分器
+func NewSplitter(cfg Config) *Splitter {
+	markers := make([]string, 0, len(cfg.Featuring))
+	for _, m := range cfg.Featuring {
+		if m = strings.TrimSpace(m); m != "" {
+			markers = append(markers, regexp.QuoteMeta(m))
+		}
+	}
+	s := &Splitter{cfg: cfg, remixTitle: regexp.MustCompile(`(?i)[(\[]([^()\[\]]+?)\s+(?:remix|rmx)[)\]]`)}
+	if len(markers) > 0 {
+		s.featTitle = regexp.MustCompile(`(?i)[(\[]\s*(?:` + strings.Join(markers, "|") + `)\s+([^()\[\]]+)[)\]]`)
+	}
+	return s
+}
+
+// Config 返回拆分规则
+func (s *Splitter) Config() Config { return s.cfg }
+
+// Split 由艺术家标签、标题与作曲标签得到全部署名，按 main、featured、remixer、composer 顺序，
+// 同名同角色只保留一次。艺术家标签为空时没有 main。
+func (s *Splitter) Split(artist, title, composer string) []Credit {
+	if s.cfg.Disabled {
+		if a := strings.TrimSpace(artist); a != "" {
+			return []Credit{{a, RoleMain}}
+		}
+		return nil
+	}
+	var credits []Credit
+	add := func(names []string, role Role) {
+		for _, n := range names {
+			credits = append(credits, Credit{n, role})
+		}
+	}
+	main, featured := s.cutFeaturing(artist)
+	add(s.SplitNames(main), RoleMain)
+	add(s.SplitNames(featured), RoleFeatured)
+	if s.featTitle != nil {
+		for _, m := range s.featTitle.FindAllStringSubmatch(title, -1) {
+			add(s.SplitNames(m[1]), RoleFeatured)
+		}
+	}
+	for _, m := range s.remixTitle.FindAllStringSubmatch(title, -1) {
+		add(s.SplitNames(m[1]), RoleRemixer)
+	}
+	add(s.SplitNames(composer), RoleComposer)
+
+	seen := map[string]bool{}
+	out := credits[:0]
+	for _, c := range credits {
+		key := strings.ToLower(c.Name) + "\x00" + string(c.Role)
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// cutFeaturing 在第一个客串标记处切开："A feat. B"、"A (ft. B)" → ("A", "B")。
+// 标记须是独立的词：前面是空白或左括号，后面是空白（标记本身以 . 结尾时也可直接接名字）。
+func (s *Splitter) cutFeaturing(text string) (main, featured string) {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		lower = text
+	}
+	best, bestLen := -1, 0
+	for _, m := range s.cfg.Featuring {
+		m = strings.ToLower(strings.TrimSpace(m))
+		if m == "" {
+			continue
+		}
+		for from := 0; ; {
+			i := strings.Index(lower[from:], m)
+			if i < 0 {
+				break
+			}
+			i += from
+			end := i + len(m)
+			prev, _ := utf8.DecodeLastRuneInString(lower[:i]) // 全角括号是多字节字符，须按 rune 判断
+			before := i == 0 || strings.ContainsRune(" ([（【", prev)
+			after := end < len(lower) && (lower[end] == ' ' || strings.HasSuffix(m, "."))
+			if i > 0 && before && after && (best < 0 || i < best) {
+				best, bestLen = i, len(m)
+			}
+			from = end
+		}
+	}
+	if best < 0 {
+		return text, ""
+	}
+	main = strings.TrimRight(text[:best], " ([（【")
+	featured = strings.TrimRight(strings.TrimSpace(text[best+bestLen:]), ")]）】")
+	return main, featured
+}
+
+// SplitNames 按分隔符拆分并列的名称，Keep 中的名称作为整体保留；结果去掉首尾空白与空项
+func (s *Splitter) SplitNames(text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		lower = text
+	}
+	// 标出 Keep 名称覆盖的区间，区间内的分隔符不生效
+	protected := make([]bool, len(text))
+	for _, k := range s.cfg.Keep {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "" {
+			continue
+		}
+		for from := 0; ; {
+			i := strings.Index(lower[from:], k)
+			if i < 0 {
+				break
+			}
+			i += from
+			for j := i; j < i+len(k); j++ {
+				protected[j] = true
+			}
+			from = i + len(k)
+		}
+	}
+
+	var names []string
+	start := 0
+	for i := 0; i < len(lower); {
+		matched := 0
+		if !protected[i] {
+			for _, sep := range s.cfg.Separators {
+				if sep = strings.ToLower(sep); sep != "" && strings.HasPrefix(lower[i:], sep) && len(sep) > matched {
+					matched = len(sep)
+				}
+			}
+		}
+		if matched == 0 {
+			i++
+			continue
+		}
+		if n := strings.TrimSpace(text[start:i]); n != "" {
+			names = append(names, n)
+		}
+		i += matched
+		start = i
+	}
+	if n := strings.TrimSpace(text[start:]); n != "" {
+		names = append(names, n)
+	}
+	return names
+}
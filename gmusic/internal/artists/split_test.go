@@ -0,0 +1,102 @@
+package artists
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestCutFeaturing 客串标记须是独立的词，不区分大小写，括号随标记一起去掉
+func TestCutFeaturing(t *testing.T) {
+	s := NewSplitter(DefaultConfig())
+	tests := []struct {
+		text, main, featured string
+	}{
+		{"Calvin Harris feat. Rihanna", "Calvin Harris", "Rihanna"},
+		{"Eminem Featuring Rihanna", "Eminem", "Rihanna"},
+		{"The Weeknd (featuring Daft Punk)", "The Weeknd", "Daft Punk"},
+		{"A (ft. B)", "A", "B"},
+		{"A ft B", "A", "B"},
+		{"A feat.B", "A", "B"},
+		{"周杰伦 feat. 五月天", "周杰伦", "五月天"},
+		{"周杰伦（feat. 五月天）", "周杰伦", "五月天"},
+		// 不是独立的词或在开头时不切开
+		{"Lefty Band", "Lefty Band", ""},
+		{"Daft Punk", "Daft Punk", ""},
+		{"Feat. X", "Feat. X", ""},
+		{"Soft Cell", "Soft Cell", ""},
+	}
+	for _, tt := range tests {
+		main, featured := s.cutFeaturing(tt.text)
+		if main != tt.main || featured != tt.featured {
+			t.Errorf("cutFeaturing(%q) = %q, %q，期望 %q, %q", tt.text, main, featured, tt.main, tt.featured)
+		}
+	}
+}
+
+// TestSplitNames 按分隔符拆分，Keep 中的名称不拆
+func TestSplitNames(t *testing.T) {
+	s := NewSplitter(DefaultConfig())
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"周杰伦 & 费玉清", []string{"周杰伦", "费玉清"}},
+		{"A/B", []string{"A", "B"}},
+		{"A、B、C", []string{"A", "B", "C"}},
+		{"A; B；C", []string{"A", "B", "C"}},
+		{"A, , B", []string{"A", "B"}},
+		{"Alan Walker x Ava Max", []string{"Alan Walker", "Ava Max"}},
+		{"Dimitri Vegas vs. Like Mike", []string{"Dimitri Vegas", "Like Mike"}},
+		{"Tyler, The Creator/Kali Uchis", []string{"Tyler, The Creator", "Kali Uchis"}},
+		{"simon & garfunkel & Paul Simon", []string{"simon & garfunkel", "Paul Simon"}},
+		// 不应拆开的名称
+		{"AC/DC", []string{"AC/DC"}},
+		{"Simon & Garfunkel", []string{"Simon & Garfunkel"}},
+		{"Earth, Wind & Fire", []string{"Earth, Wind & Fire"}},
+		{"Maxx", []string{"Maxx"}},
+		{"Rock&Roll", []string{"Rock&Roll"}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		if got := s.SplitNames(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("SplitNames(%q) = %q，期望 %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestSplit 艺术家标签、标题中的 feat./Remix 与作曲合并为署名，按角色排序并去重
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name                    string
+		cfg                     Config
+		artist, title, composer string
+		want                    []Credit
+	}{
+		{"客串", DefaultConfig(), "Calvin Harris feat. Rihanna", "This Is What You Came For", "",
+			[]Credit{{"Calvin Harris", RoleMain}, {"Rihanna", RoleFeatured}}},
+		{"并列与作曲", DefaultConfig(), "周杰伦 & 费玉清", "千里之外", "周杰伦",
+			[]Credit{{"周杰伦", RoleMain}, {"费玉清", RoleMain}, {"周杰伦", RoleComposer}}},
+		{"标题中的客串与多位作曲", DefaultConfig(), "Mark Ronson", "Uptown Funk (feat. Bruno Mars)", "Mark Ronson/Bruno Mars/Jeff Bhasker",
+			[]Credit{{"Mark Ronson", RoleMain}, {"Bruno Mars", RoleFeatured},
+				{"Mark Ronson", RoleComposer}, {"Bruno Mars", RoleComposer}, {"Jeff Bhasker", RoleComposer}}},
+		{"混音", DefaultConfig(), "Avicii", "Levels (Skrillex Remix)", "",
+			[]Credit{{"Avicii", RoleMain}, {"Skrillex", RoleRemixer}}},
+		{"多位混音", DefaultConfig(), "Martin Garrix", "Animals [Tiësto & KSHMR RMX]", "",
+			[]Credit{{"Martin Garrix", RoleMain}, {"Tiësto", RoleRemixer}, {"KSHMR", RoleRemixer}}},
+		{"同名同角色去重", DefaultConfig(), "A feat. B", "Song (feat. b)", "",
+			[]Credit{{"A", RoleMain}, {"B", RoleFeatured}}},
+		{"不拆分的名称", DefaultConfig(), "AC/DC", "Back in Black", "Angus Young/Malcolm Young",
+			[]Credit{{"AC/DC", RoleMain}, {"Angus Young", RoleComposer}, {"Malcolm Young", RoleComposer}}},
+		{"没有艺术家", DefaultConfig(), "", "Song", "C", []Credit{{"C", RoleComposer}}},
+		{"关闭拆分", Config{Disabled: true}, " A & B ", "Song (X Remix)", "C", []Credit{{"A & B", RoleMain}}},
+		{"关闭拆分且没有艺术家", Config{Disabled: true}, "  ", "Song", "C", nil},
+		{"自定义规则", Config{Separators: []string{" + "}, Featuring: []string{"with"}}, "A with B + C", "Song (feat. D)", "",
+			[]Credit{{"A", RoleMain}, {"B", RoleFeatured}, {"C", RoleFeatured}}},
+	}
+	for _, tt := range tests {
+		got := NewSplitter(tt.cfg).Split(tt.artist, tt.title, tt.composer)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
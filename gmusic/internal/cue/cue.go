@@ -0,0 +1,270 @@
+// Package cue 解析 CUE 索引表（.cue），用于“整轨音频 + CUE”形式的专辑镜像。
+//
+// 只处理播放所需的子集：FILE、TRACK、TITLE、PERFORMER、SONGWRITER、ISRC、
+// INDEX 01 以及常见的 REM（DATE/GENRE/COMMENT）。其余指令忽略。
+package cue
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// framesPerSecond CUE 时间戳 mm:ss:ff 中的帧率（CD 规格，每秒 75 帧）
+const framesPerSecond = 75
+
+// Sheet 表示一份解析后的 CUE 表
+type Sheet struct {
+	Title     string  // 专辑名（全局 TITLE）
+	Performer string  // 专辑艺术家（全局 PERFORMER）
+	Date      string  // REM DATE
+	Genre     string  // REM GENRE
+	Comment   string  // REM COMMENT
+	Catalog   string  // CATALOG（UPC/EAN）
+	Tracks    []Track // 按出现顺序排列的音轨
+}
+
+// Track 表示 CUE 中的一个音轨
+type Track struct {
+	Number     int           // 音轨号
+	Title      string        // 标题
+	Performer  string        // 艺术家（为空时应回退到 Sheet.Performer）
+	Songwriter string        // 词曲作者
+	ISRC       string        // ISRC 编码
+	File       string        // 所属 FILE 指令引用的文件名（原样保留，可能是相对路径）
+	Start      time.Duration // INDEX 01 相对所属文件开头的偏移
+}
+
+// Files 返回 CUE 引用的所有文件名（去重，保持出现顺序）
+func (s *Sheet) Files() []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, t := range s.Tracks {
+		if t.File == "" || seen[t.File] {
+			continue
+		}
+		seen[t.File] = true
+		files = append(files, t.File)
+	}
+	return files
+}
+
+// End 返回第 i 个音轨的结束偏移：同一文件中下一音轨的起点；
+// 若为该文件的最后一个音轨则返回 0，表示播放到文件末尾。
+func (s *Sheet) End(i int) time.Duration {
+	if i < 0 || i+1 >= len(s.Tracks) {
+		return 0
+	}
+	next := s.Tracks[i+1]
+	if next.File != s.Tracks[i].File {
+		return 0
+	}
+	return next.Start
+}
+
+// Parse 解析 CUE 原始字节，自动识别 UTF-8（含 BOM）、UTF-16 与 GBK 编码
+func Parse(data []byte) (*Sheet, error) {
+	return ParseString(DecodeText(data))
+}
+
+// ParseString 解析已解码为 UTF-8 的 CUE 文本
+func ParseString(content string) (*Sheet, error) {
+	sheet := &Sheet{}
+	var (
+		currentFile string
+		current     *Track
+		hasIndex    bool
+	)
+
+	// 收尾当前音轨：没有 INDEX 01 的音轨无法定位，直接丢弃
+	flush := func() {
+		if current != nil && hasIndex {
+			sheet.Tracks = append(sheet.Tracks, *current)
+		}
+		current = nil
+		hasIndex = false
+	}
+
+	sc := bufio.NewScanner(strings.NewReader(content))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		cmd, rest := splitCommand(line)
+		switch strings.ToUpper(cmd) {
+		case "FILE":
+			flush()
+			currentFile = parseFileName(rest)
+		case "TRACK":
+			flush()
+			fields := strings.Fields(rest)
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("第 %d 行: TRACK 缺少音轨号", lineNo)
+			}
+			num, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行: 无效的音轨号 %q", lineNo, fields[0])
+			}
+			// 数据轨（如 MODE1/2352）不可播放，仅记录音频轨
+			if len(fields) > 1 && !strings.EqualFold(fields[1], "AUDIO") {
+				continue
+			}
+			current = &Track{Number: num, File: currentFile}
+		case "TITLE":
+			if current != nil {
+				current.Title = unquote(rest)
+			} else {
+				sheet.Title = unquote(rest)
+			}
+		case "PERFORMER":
+			if current != nil {
+				current.Performer = unquote(rest)
+			} else {
+				sheet.Performer = unquote(rest)
+			}
+		case "SONGWRITER":
+			if current != nil {
+				current.Songwriter = unquote(rest)
+			}
+		case "ISRC":
+			if current != nil {
+				current.ISRC = unquote(rest)
+			}
+		case "CATALOG":
+			sheet.Catalog = unquote(rest)
+		case "INDEX":
+			if current == nil {
+				continue
+			}
+			fields := strings.Fields(rest)
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("第 %d 行: INDEX 格式错误", lineNo)
+			}
+			// 仅 INDEX 01 是音轨的实际起点，INDEX 00 为预间隙
+			if n, _ := strconv.Atoi(fields[0]); n != 1 {
+				continue
+			}
+			d, err := ParseTimestamp(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行: %w", lineNo, err)
+			}
+			current.Start = d
+			hasIndex = true
+		case "REM":
+			key, val := splitCommand(rest)
+			switch strings.ToUpper(key) {
+			case "DATE":
+				sheet.Date = unquote(val)
+			case "GENRE":
+				sheet.Genre = unquote(val)
+			case "COMMENT":
+				sheet.Comment = unquote(val)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	if len(sheet.Tracks) == 0 {
+		return nil, fmt.Errorf("CUE 中没有可用的音轨")
+	}
+	return sheet, nil
+}
+
+// ParseTimestamp 解析 CUE 时间戳 mm:ss:ff（ff 为 1/75 秒的帧）
+func ParseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("无效的时间戳 %q", s)
+	}
+	var v [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("无效的时间戳 %q", s)
+		}
+		v[i] = n
+	}
+	if v[1] >= 60 || v[2] >= framesPerSecond {
+		return 0, fmt.Errorf("无效的时间戳 %q", s)
+	}
+	frames := (v[0]*60+v[1])*framesPerSecond + v[2]
+	return time.Duration(frames) * time.Second / framesPerSecond, nil
+}
+
+// DecodeText 将 CUE 原始字节转为 UTF-8 文本：
+// 优先识别 BOM（UTF-8/UTF-16LE/UTF-16BE），其次合法 UTF-8 原样返回，否则按 GBK（GB18030）解码。
+func DecodeText(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:])
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], false)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], true)
+	}
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	if out, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data); err == nil {
+		return string(out)
+	}
+	return string(data)
+}
+
+func decodeUTF16(b []byte, bigEndian bool) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		if bigEndian {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		} else {
+			u = append(u, uint16(b[i+1])<<8|uint16(b[i]))
+		}
+	}
+	return string(utf16.Decode(u))
+}
+
+// splitCommand 拆分 “命令 参数” 形式的一行
+func splitCommand(line string) (string, string) {
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimSpace(line[i+1:])
+}
+
+// parseFileName 解析 FILE 指令参数：`"name.flac" WAVE` 或 `name.flac WAVE`
+func parseFileName(rest string) string {
+	if strings.HasPrefix(rest, `"`) {
+		if end := strings.Index(rest[1:], `"`); end >= 0 {
+			return rest[1 : end+1]
+		}
+		return strings.Trim(rest, `"`)
+	}
+	// 未加引号时，最后一个字段是文件类型
+	if i := strings.LastIndexAny(rest, " \t"); i > 0 {
+		return strings.TrimSpace(rest[:i])
+	}
+	return rest
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,107 @@
+// Package hanzi 提供中文搜索所需的文本归一化：繁体转简体，以及生成全拼与拼音首字母，
+// 使 "zjl"、"zhoujielun"、"周杰倫" 都能找到 周杰伦。
+package hanzi
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// t2s 由 t2sPairs 构建的繁 → 简映射
+var t2s = func() map[rune]rune {
+	pairs := []rune(t2sPairs)
+	m := make(map[rune]rune, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		m[pairs[i]] = pairs[i+1]
+	}
+	return m
+}()
+
+// pinyinArgs 无声调、多音字取最常用读音
+var pinyinArgs = pinyin.NewArgs()
+
+// Simplify 逐字把繁体转为简体，其他字符原样保留。逐字替换不改变字符数。
+func Simplify(s string) string {
+	return strings.Map(func(r rune) rune {
+		if s, ok := t2s[r]; ok {
+			return s
+		}
+		return r
+	}, s)
+}
+
+// Fold 搜索比较用的归一形式：繁体转简体并转小写
+func Fold(s string) string {
+	return strings.ToLower(Simplify(s))
+}
+
+// Syllable 单个汉字的拼音（小写无声调，多音字取最常用读音）；非汉字返回空串
+func Syllable(r rune) string {
+	if !unicode.Is(unicode.Han, r) {
+		return ""
+	}
+	if s, ok := t2s[r]; ok {
+		r = s
+	}
+	if py := pinyin.SinglePinyin(r, pinyinArgs); len(py) > 0 {
+		return py[0]
+	}
+	return ""
+}
+
+// Romanize 生成全拼与首字母，如 "周杰伦 - 晴天" → ("zhoujielun - qingtian", "zjl - qt")。
+// 汉字之间不加分隔，非汉字（字母转小写）原样保留在两者中；不含汉字时返回两个空串。
+func Romanize(s string) (full, initials string) {
+	var f, i strings.Builder
+	han := false
+	for _, r := range s {
+		if py := Syllable(r); py != "" {
+			han = true
+			f.WriteString(py)
+			i.WriteByte(py[0])
+			continue
+		}
+		r = unicode.ToLower(r)
+		f.WriteRune(r)
+		i.WriteRune(r)
+	}
+	if !han {
+		return "", ""
+	}
+	return f.String(), i.String()
+}
+
+// MatchRomanized 判断 term 能否从 text 的第 start 个字符起按拼音匹配：每个汉字可用全拼或首字母，
+// 最后一个汉字允许只输入拼音前缀（边输入边搜索），非汉字需逐字相同（不区分大小写）。
+// 匹配成功时返回覆盖的字符数。
+func MatchRomanized(text []rune, start int, term string) (int, bool) {
+	term = strings.ToLower(term)
+	i := start
+	for term != "" {
+		if i >= len(text) {
+			return 0, false
+		}
+		if py := Syllable(text[i]); py != "" {
+			switch {
+			case strings.HasPrefix(term, py):
+				term = term[len(py):]
+			case strings.HasPrefix(py, term):
+				term = ""
+			case term[0] == py[0]:
+				term = term[1:]
+			default:
+				return 0, false
+			}
+		} else {
+			r := []rune(term)[0]
+			if unicode.ToLower(text[i]) != r {
+				return 0, false
+			}
+			term = term[len(string(r)):]
+		}
+		i++
+	}
+	return i - start, i > start
+}
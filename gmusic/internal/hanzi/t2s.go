@@ -0,0 +1,83 @@
+package hanzi
+
+// t2sPairs 繁体 → 简体单字对照，每两个字符为一组（繁、简），按简体拼音大致排序。
+// 只收录曲名、歌手名、专辑名中常见的字；一简对多繁（如 發/髮 → 发）在此方向上没有歧义，
+// 一繁对多简、需按词转换的情形（如 乾、著）不收录，保持原字。
+const t2sPairs = "愛爱礙碍襖袄骯肮" +
+	"罷罢擺摆敗败頒颁辦办絆绊幫帮綁绑鎊镑謗谤寶宝飽饱報报鮑鲍輩辈貝贝備备筆笔畢毕斃毙" +
+	"幣币閉闭邊边編编貶贬變变辯辩辮辫標标錶表別别賓宾瀕濒餅饼撥拨缽钵鉑铂駁驳補补佈布" +
+	"闆板" +
+	"財财採采參参蠶蚕殘残慚惭慘惨燦灿倉仓艙舱滄沧蒼苍廁厕側侧冊册測测層层詫诧攙搀摻掺" +
+	"蟬蝉饞馋讒谗纏缠鏟铲產产闡阐顫颤場场嘗尝長长償偿腸肠廠厂暢畅鈔钞車车徹彻塵尘陳陈" +
+	"襯衬稱称懲惩誠诚騁骋癡痴遲迟馳驰恥耻齒齿熾炽衝冲蟲虫寵宠疇畴躊踌籌筹綢绸醜丑櫥橱" +
+	"廚厨鋤锄雛雏礎础儲储觸触處处傳传瘡疮闖闯創创錘锤純纯綽绰辭辞詞词賜赐聰聪蔥葱囪囱" +
+	"從从叢丛湊凑竄窜錯错" +
+	"達达帶带貸贷擔担單单鄲郸膽胆憚惮誕诞彈弹當当擋挡黨党蕩荡檔档島岛禱祷導导盜盗燈灯" +
+	"鄧邓敵敌滌涤遞递締缔顛颠點点墊垫電电澱淀釣钓調调諜谍疊叠釘钉頂顶錠锭訂订東东動动" +
+	"棟栋凍冻鬥斗犢犊獨独讀读賭赌鍍镀鍛锻斷断緞缎兌兑隊队對对噸吨頓顿鈍钝奪夺墮堕" +
+	"鵝鹅額额訛讹惡恶餓饿兒儿爾尔餌饵貳贰" +
+	"發发髮发罰罚閥阀琺珐礬矾釩钒煩烦範范販贩飯饭訪访紡纺飛飞誹诽廢废費费紛纷墳坟奮奋" +
+	"憤愤糞粪豐丰楓枫鋒锋風风瘋疯馮冯縫缝諷讽鳳凤膚肤輻辐撫抚輔辅賦赋複复復复負负訃讣" +
+	"婦妇縛缚" +
+	"該该鈣钙蓋盖幹干趕赶稈秆贛赣岡冈剛刚鋼钢綱纲崗岗鎬镐擱搁鴿鸽閣阁鉻铬個个給给龔龚" +
+	"宮宫鞏巩貢贡鉤钩溝沟構构購购夠够蠱蛊顧顾颳刮關关觀观館馆慣惯貫贯廣广規规歸归龜龟" +
+	"閨闺軌轨詭诡櫃柜貴贵劊刽輥辊滾滚鍋锅國国過过穀谷" +
+	"駭骇韓韩漢汉號号閡阂鶴鹤賀贺橫横轟轰鴻鸿紅红後后壺壶護护滬沪戶户嘩哗華华畫画劃划" +
+	"話话懷怀壞坏歡欢環环還还緩缓換换喚唤瘓痪煥焕渙涣黃黄謊谎揮挥輝辉毀毁賄贿穢秽會会" +
+	"燴烩匯汇彙汇諱讳誨诲繪绘葷荤渾浑夥伙獲获貨货禍祸鬍胡迴回" +
+	"擊击機机積积饑饥跡迹譏讥雞鸡績绩緝缉極极輯辑級级擠挤幾几薊蓟劑剂濟济計计記记際际" +
+	"繼继紀纪夾夹莢荚頰颊賈贾鉀钾價价駕驾殲歼監监堅坚箋笺間间艱艰緘缄繭茧檢检鹼碱揀拣" +
+	"撿捡簡简儉俭減减薦荐檻槛鑒鉴踐践賤贱見见鍵键艦舰劍剑餞饯漸渐濺溅澗涧將将漿浆蔣蒋" +
+	"槳桨獎奖講讲醬酱膠胶澆浇驕骄嬌娇攪搅鉸铰矯矫僥侥腳脚餃饺繳缴絞绞轎轿較较階阶節节" +
+	"潔洁結结誡诫屆届緊紧錦锦僅仅謹谨進进晉晋燼烬盡尽勁劲荊荆莖茎鯨鲸驚惊經经頸颈靜静" +
+	"鏡镜徑径痙痉競竞淨净糾纠廄厩舊旧駒驹舉举據据鋸锯懼惧劇剧鵑鹃絹绢傑杰訣诀絕绝覺觉" +
+	"軍军駿骏捲卷" +
+	"開开凱凯顆颗殼壳課课墾垦懇恳摳抠庫库褲裤誇夸塊块儈侩寬宽礦矿曠旷況况虧亏巋岿窺窥" +
+	"饋馈潰溃擴扩闊阔鄺邝" +
+	"蠟蜡臘腊萊莱來来賴赖藍蓝欄栏攔拦籃篮闌阑蘭兰瀾澜讕谰攬揽覽览懶懒纜缆爛烂濫滥撈捞" +
+	"勞劳澇涝樂乐鐳镭壘垒類类淚泪籬篱離离裡里裏里鯉鲤禮礼麗丽厲厉勵励礫砾歷历曆历瀝沥" +
+	"隸隶倆俩聯联蓮莲連连鐮镰憐怜漣涟簾帘斂敛臉脸鏈链戀恋煉炼練练糧粮涼凉兩两輛辆諒谅" +
+	"療疗遼辽鐐镣獵猎臨临鄰邻鱗鳞凜凛賃赁齡龄鈴铃靈灵嶺岭領领餾馏劉刘龍龙聾聋嚨咙籠笼" +
+	"壟垄攏拢隴陇樓楼婁娄摟搂簍篓蘆芦盧卢顱颅廬庐爐炉擄掳鹵卤虜虏魯鲁賂赂祿禄錄录陸陆" +
+	"驢驴呂吕鋁铝侶侣屢屡縷缕慮虑濾滤綠绿巒峦攣挛孿孪灤滦亂乱掄抡輪轮倫伦侖仑淪沦綸纶" +
+	"論论蘿萝羅罗邏逻鑼锣籮箩騾骡駱骆絡络嵐岚囉啰" +
+	"媽妈瑪玛碼码螞蚂馬马罵骂嗎吗買买麥麦賣卖邁迈脈脉瞞瞒饅馒蠻蛮滿满謾谩貓猫錨锚鉚铆" +
+	"貿贸麼么沒没鎂镁門门悶闷們们錳锰夢梦謎谜彌弥覓觅綿绵緬缅廟庙滅灭憫悯閩闽鳴鸣銘铭" +
+	"謬谬謀谋畝亩麵面矇蒙濛蒙" +
+	"鈉钠納纳難难撓挠腦脑惱恼鬧闹餒馁內内擬拟膩腻攆撵釀酿鳥鸟聶聂齧啮鑷镊鎳镍檸柠獰狞" +
+	"寧宁擰拧濘泞鈕钮紐纽膿脓濃浓農农瘧疟諾诺儂侬妳你" +
+	"歐欧鷗鸥毆殴嘔呕漚沤" +
+	"盤盘龐庞賠赔噴喷鵬鹏騙骗飄飘頻频貧贫蘋苹憑凭評评潑泼頗颇撲扑鋪铺樸朴譜谱僕仆" +
+	"棲栖臍脐齊齐騎骑豈岂啟启氣气棄弃訖讫牽牵鉛铅遷迁簽签謙谦錢钱鉗钳潛潜淺浅譴谴塹堑" +
+	"槍枪嗆呛牆墙薔蔷強强搶抢鍬锹橋桥喬乔僑侨翹翘竅窍竊窃欽钦親亲寢寝輕轻氫氢傾倾頃顷" +
+	"請请慶庆瓊琼窮穷趨趋區区軀躯驅驱齲龋顴颧權权勸劝卻却鵲鹊確确綺绮" +
+	"讓让饒饶擾扰繞绕熱热韌韧認认紉纫榮荣絨绒軟软銳锐閏闰潤润" +
+	"灑洒薩萨鰓鳃賽赛傘伞喪丧騷骚掃扫澀涩殺杀紗纱篩筛曬晒閃闪陝陕贍赡繕缮傷伤賞赏燒烧" +
+	"紹绍賒赊攝摄懾慑設设紳绅審审嬸婶腎肾滲渗聲声繩绳勝胜聖圣師师獅狮濕湿詩诗屍尸時时" +
+	"蝕蚀實实識识駛驶勢势適适釋释飾饰視视試试壽寿獸兽樞枢輸输書书贖赎屬属術术樹树豎竖" +
+	"數数帥帅雙双誰谁稅税順顺說说碩硕爍烁絲丝飼饲聳耸慫怂頌颂訟讼誦诵擻擞蘇苏訴诉肅肃" +
+	"雖虽隨随綏绥歲岁孫孙損损筍笋縮缩瑣琐鎖锁鬆松係系繫系" +
+	"獺獭撻挞臺台颱台檯台態态攤摊貪贪癱瘫灘滩壇坛譚谭談谈嘆叹湯汤燙烫濤涛縧绦討讨騰腾" +
+	"謄誊銻锑題题體体屜屉條条貼贴鐵铁廳厅聽听烴烃銅铜統统頭头禿秃圖图塗涂團团頹颓蛻蜕" +
+	"脫脱鴕鸵馱驮駝驼橢椭牠它" +
+	"窪洼襪袜彎弯灣湾頑顽萬万網网韋韦違违圍围為为濰潍維维葦苇偉伟偽伪緯纬謂谓衛卫溫温" +
+	"聞闻紋纹穩稳問问甕瓮撾挝蝸蜗渦涡窩窝臥卧嗚呜鎢钨烏乌汙污誣诬無无蕪芜吳吴塢坞霧雾" +
+	"務务誤误鄔邬" +
+	"錫锡犧牺襲袭習习銑铣戲戏細细蝦虾轄辖峽峡俠侠狹狭廈厦嚇吓鮮鲜纖纤鹹咸賢贤銜衔閒闲" +
+	"顯显險险現现獻献縣县餡馅羨羡憲宪線线絃弦廂厢鑲镶鄉乡詳详響响項项蕭萧瀟潇囂嚣銷销" +
+	"曉晓嘯啸蠍蝎協协挾挟攜携脅胁諧谐寫写瀉泻謝谢鋅锌釁衅興兴洶汹鏽锈繡绣虛虚噓嘘須须" +
+	"許许敘叙緒绪續续軒轩懸悬選选癬癣絢绚學学勳勋詢询尋寻馴驯訓训訊讯遜逊嚮向" +
+	"壓压鴉鸦鴨鸭啞哑亞亚訝讶閹阉煙烟鹽盐嚴严顏颜閻阎閆闫豔艳艷艳厭厌硯砚彥彦諺谚驗验" +
+	"鴦鸯楊杨揚扬瘍疡陽阳癢痒養养樣样瑤瑶搖摇堯尧遙遥窯窑謠谣藥药爺爷頁页業业葉叶醫医" +
+	"銥铱頤颐遺遗儀仪蟻蚁藝艺億亿憶忆義义詣诣議议誼谊譯译異异繹绎蔭荫陰阴銀银飲饮隱隐" +
+	"櫻樱嬰婴鷹鹰應应纓缨瑩莹螢萤營营熒荧蠅蝇贏赢穎颖擁拥傭佣癰痈踴踊詠咏湧涌優优憂忧" +
+	"郵邮鈾铀猶犹遊游誘诱輿舆魚鱼漁渔娛娱與与嶼屿語语獄狱譽誉預预馭驭鴛鸳淵渊轅辕園园" +
+	"員员圓圆緣缘遠远願愿約约躍跃鑰钥嶽岳粵粤悅悦閱阅雲云鄖郧勻匀隕陨運运蘊蕴醞酝暈晕" +
+	"韻韵於于餘余鬱郁籲吁禦御燁烨瀅滢鈺钰縈萦喲哟裊袅" +
+	"雜杂災灾載载攢攒暫暂贊赞髒脏臟脏鑿凿棗枣竈灶責责擇择則则澤泽賊贼贈赠紮扎劄札軋轧" +
+	"鍘铡閘闸詐诈齋斋債债氈毡盞盏斬斩輾辗嶄崭棧栈戰战綻绽張张漲涨帳帐賬账脹胀趙赵蟄蛰" +
+	"轍辙鍺锗這这貞贞針针偵侦診诊鎮镇陣阵掙挣睜睁猙狰爭争幀帧鄭郑證证織织職职執执紙纸" +
+	"摯挚擲掷幟帜質质滯滞鐘钟鍾钟終终種种腫肿眾众謅诌軸轴皺皱晝昼驟骤豬猪諸诸誅诛燭烛" +
+	"矚瞩囑嘱貯贮鑄铸築筑註注駐驻專专磚砖轉转賺赚樁桩莊庄裝装妝妆壯壮狀状錐锥贅赘墜坠" +
+	"綴缀諄谆準准濁浊茲兹資资漬渍蹤踪綜综總总縱纵鄒邹詛诅組组鑽钻纘缵誌志佔占隻只僱雇" +
+	"週周徵征緋绯繚缭朧胧噠哒嘍喽"
@@ -0,0 +1,499 @@
+// Package jukebox 实现聚会点歌模式：来宾凭会话令牌（无需账号）搜索并点播歌曲，
+// 对队列中的点播投赞成或反对票；播放顺序按票数从高到低、同票按点播时间先后排列。
+//
+// 点歌台接管一个播放区域：当前歌曲播完后自动播放排在最前的点播。管理员令牌可以置顶、删除点播，
+// 切歌，移出来宾和调整每位来宾的点播上限。任何变化都会通知订阅者，由 API 层推送给所有客户端。
+package jukebox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yudongyouqing/GMusic/internal/playback"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"gorm.io/gorm"
+)
+
+const defaultMaxPending = 3
+
+var (
+	// ErrUnknownGuest 令牌无效或来宾已被移出
+	ErrUnknownGuest = errors.New("会话无效，请重新加入点歌")
+	// ErrNotFound 点播不存在
+	ErrNotFound = errors.New("点播不存在")
+	// ErrForbidden 无权操作他人的点播
+	ErrForbidden = errors.New("只能撤回自己的点播")
+	// ErrNowPlaying 歌曲正在播放
+	ErrNowPlaying = errors.New("这首歌正在播放")
+)
+
+// LimitError 来宾的待播点播数已达上限
+type LimitError struct{ Max int }
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("每人最多同时点播 %d 首，请等已点的歌播完", e.Max)
+}
+
+// Config 点歌配置
+type Config struct {
+	Zone       string // 点歌台驱动的播放区域
+	AdminToken string // 管理员令牌；为空表示不启用点歌模式
+	MaxPending int    // 每位来宾同时排队的点播上限
+}
+
+// ConfigFromEnv 从环境变量 GMUSIC_JUKEBOX_ADMIN_TOKEN、GMUSIC_JUKEBOX_ZONE（为空表示默认区域）、
+// GMUSIC_JUKEBOX_MAX_REQUESTS（默认 3）读取配置
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Zone:       os.Getenv("GMUSIC_JUKEBOX_ZONE"),
+		AdminToken: os.Getenv("GMUSIC_JUKEBOX_ADMIN_TOKEN"),
+		MaxPending: defaultMaxPending,
+	}
+	if n, err := strconv.Atoi(os.Getenv("GMUSIC_JUKEBOX_MAX_REQUESTS")); err == nil && n > 0 {
+		cfg.MaxPending = n
+	}
+	return cfg
+}
+
+// Guest 一位来宾
+type Guest struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	JoinedAt time.Time `json:"joined_at"`
+	token    string
+}
+
+// Request 一条点播
+type Request struct {
+	ID          int          `json:"id"`
+	Song        storage.Song `json:"song"`
+	GuestID     int          `json:"guest_id"`
+	GuestName   string       `json:"guest_name"`
+	RequestedAt time.Time    `json:"requested_at"`
+	Score       int          `json:"score"`
+	Pinned      bool         `json:"pinned"` // 管理员置顶，排在所有按票数排序的点播之前
+	votes       map[int]int  // 来宾 ID → +1 / -1
+}
+
+// RequestView 点播在某位来宾视角下的快照
+type RequestView struct {
+	Request
+	MyVote int  `json:"my_vote"`
+	Mine   bool `json:"mine"`
+}
+
+// State 点歌台状态快照
+type State struct {
+	NowPlaying *RequestView  `json:"now_playing"`
+	Queue      []RequestView `json:"queue"`
+	MaxPending int           `json:"max_pending"`
+	Guests     int           `json:"guests"`
+}
+
+// Jukebox 点歌台
+type Jukebox struct {
+	db  *gorm.DB
+	ctl *playback.Controller
+	cfg Config
+
+	advanceMu sync.Mutex // 串行化切歌（播完回调与管理员切歌可能同时发生）
+
+	mu          sync.Mutex
+	guests      map[string]*Guest // 令牌 → 来宾
+	requests    []*Request
+	nowPlaying  *Request
+	nextGuest   int
+	nextRequest int
+	subscribers map[chan struct{}]struct{}
+}
+
+// Start 接管 ctl 所属的播放区域开始点歌模式；AdminToken 为空时返回 nil（不启用）
+func Start(db *gorm.DB, ctl *playback.Controller, cfg Config) *Jukebox {
+	if cfg.AdminToken == "" {
+		return nil
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = defaultMaxPending
+	}
+	j := &Jukebox{
+		db:          db,
+		ctl:         ctl,
+		cfg:         cfg,
+		guests:      make(map[string]*Guest),
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+	ctl.SetOnQueueEnd(j.advance)
+	return j
+}
+
+// Join 新来宾加入，返回其会话令牌
+func (j *Jukebox) Join(name string) (*Guest, string, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, "", err
+	}
+	j.mu.Lock()
+	j.nextGuest++
+	if name == "" {
+		name = fmt.Sprintf("来宾 %d", j.nextGuest)
+	}
+	g := &Guest{ID: j.nextGuest, Name: name, JoinedAt: time.Now(), token: token}
+	j.guests[token] = g
+	j.mu.Unlock()
+	j.notify()
+	return g, token, nil
+}
+
+// Guest 按令牌查找来宾
+func (j *Jukebox) Guest(token string) (*Guest, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	g, ok := j.guests[token]
+	return g, ok
+}
+
+// IsAdmin 令牌是否为管理员令牌
+func (j *Jukebox) IsAdmin(token string) bool {
+	return token != "" && token == j.cfg.AdminToken
+}
+
+// Search 供来宾点歌的曲库搜索，最多返回 limit 条
+func (j *Jukebox) Search(keyword string, limit int) ([]storage.Song, error) {
+	res, err := storage.Search(j.db, storage.SearchQuery{Keyword: keyword, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	songs := make([]storage.Song, len(res.Hits))
+	for i, h := range res.Hits {
+		songs[i] = h.Song
+	}
+	return songs, nil
+}
+
+// Request 来宾点播一首歌。歌曲已在队列中时视为给它投赞成票并返回已有的点播。
+func (j *Jukebox) Request(token string, songID uint) (*Request, error) {
+	song, err := storage.GetSongByID(j.db, songID)
+	if err != nil {
+		return nil, &playback.NotFoundError{What: "歌曲"}
+	}
+
+	j.mu.Lock()
+	g, ok := j.guests[token]
+	if !ok {
+		j.mu.Unlock()
+		return nil, ErrUnknownGuest
+	}
+	if j.nowPlaying != nil && j.nowPlaying.Song.ID == song.ID {
+		j.mu.Unlock()
+		return nil, ErrNowPlaying
+	}
+	for _, r := range j.requests {
+		if r.Song.ID == song.ID {
+			r.vote(g.ID, 1)
+			j.sortLocked()
+			req := *r
+			j.mu.Unlock()
+			j.notify()
+			return &req, nil
+		}
+	}
+	pending := 0
+	for _, r := range j.requests {
+		if r.GuestID == g.ID {
+			pending++
+		}
+	}
+	if pending >= j.cfg.MaxPending {
+		j.mu.Unlock()
+		return nil, &LimitError{Max: j.cfg.MaxPending}
+	}
+	j.nextRequest++
+	r := &Request{
+		ID:          j.nextRequest,
+		Song:        *song,
+		GuestID:     g.ID,
+		GuestName:   g.Name,
+		RequestedAt: time.Now(),
+		votes:       make(map[int]int),
+	}
+	r.vote(g.ID, 1) // 点播本身算一票赞成
+	j.requests = append(j.requests, r)
+	j.sortLocked()
+	req := *r
+	j.mu.Unlock()
+
+	j.startIfIdle()
+	return &req, nil
+}
+
+// Vote 来宾对点播投票：1 赞成、-1 反对、0 撤销
+func (j *Jukebox) Vote(token string, requestID, value int) error {
+	if value < -1 || value > 1 {
+		return errors.New("投票只能是 1、-1 或 0")
+	}
+	j.mu.Lock()
+	g, ok := j.guests[token]
+	if !ok {
+		j.mu.Unlock()
+		return ErrUnknownGuest
+	}
+	r := j.findLocked(requestID)
+	if r == nil {
+		j.mu.Unlock()
+		return ErrNotFound
+	}
+	r.vote(g.ID, value)
+	j.sortLocked()
+	j.mu.Unlock()
+	j.notify()
+	return nil
+}
+
+// Withdraw 撤回点播：来宾只能撤回自己的，管理员可以删除任意点播
+func (j *Jukebox) Withdraw(token string, requestID int) error {
+	j.mu.Lock()
+	r := j.findLocked(requestID)
+	if r == nil {
+		j.mu.Unlock()
+		return ErrNotFound
+	}
+	if !j.IsAdmin(token) {
+		g, ok := j.guests[token]
+		if !ok {
+			j.mu.Unlock()
+			return ErrUnknownGuest
+		}
+		if r.GuestID != g.ID {
+			j.mu.Unlock()
+			return ErrForbidden
+		}
+	}
+	j.removeLocked(requestID)
+	j.mu.Unlock()
+	j.notify()
+	return nil
+}
+
+// Pin 管理员置顶或取消置顶点播
+func (j *Jukebox) Pin(requestID int, pinned bool) error {
+	j.mu.Lock()
+	r := j.findLocked(requestID)
+	if r == nil {
+		j.mu.Unlock()
+		return ErrNotFound
+	}
+	r.Pinned = pinned
+	j.sortLocked()
+	j.mu.Unlock()
+	j.notify()
+	return nil
+}
+
+// Skip 管理员切到下一首点播（队列为空时停止播放）
+func (j *Jukebox) Skip() { j.advance() }
+
+// Guests 当前来宾列表
+func (j *Jukebox) Guests() []Guest {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	list := make([]Guest, 0, len(j.guests))
+	for _, g := range j.guests {
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].ID < list[b].ID })
+	return list
+}
+
+// Kick 管理员移出来宾：令牌失效，其待播点播一并删除
+func (j *Jukebox) Kick(guestID int) error {
+	j.mu.Lock()
+	found := false
+	for token, g := range j.guests {
+		if g.ID == guestID {
+			delete(j.guests, token)
+			found = true
+		}
+	}
+	if !found {
+		j.mu.Unlock()
+		return ErrUnknownGuest
+	}
+	kept := j.requests[:0]
+	for _, r := range j.requests {
+		if r.GuestID != guestID {
+			kept = append(kept, r)
+		}
+	}
+	j.requests = kept
+	j.mu.Unlock()
+	j.notify()
+	return nil
+}
+
+// SetMaxPending 管理员调整每位来宾的点播上限（只影响之后的点播）
+func (j *Jukebox) SetMaxPending(n int) error {
+	if n <= 0 {
+		return errors.New("点播上限必须大于 0")
+	}
+	j.mu.Lock()
+	j.cfg.MaxPending = n
+	j.mu.Unlock()
+	j.notify()
+	return nil
+}
+
+// State 以 token 对应来宾的视角生成状态快照（token 可为空）
+func (j *Jukebox) State(token string) State {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	guestID := 0
+	if g, ok := j.guests[token]; ok {
+		guestID = g.ID
+	}
+	view := func(r *Request) RequestView {
+		return RequestView{Request: *r, MyVote: r.votes[guestID], Mine: guestID != 0 && r.GuestID == guestID}
+	}
+	s := State{Queue: make([]RequestView, 0, len(j.requests)), MaxPending: j.cfg.MaxPending, Guests: len(j.guests)}
+	if j.nowPlaying != nil {
+		v := view(j.nowPlaying)
+		s.NowPlaying = &v
+	}
+	for _, r := range j.requests {
+		s.Queue = append(s.Queue, view(r))
+	}
+	return s
+}
+
+// Subscribe 订阅变化通知；通道有缓冲且会合并连续的通知，收到后应重新读取 State
+func (j *Jukebox) Subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	j.mu.Lock()
+	j.subscribers[ch] = struct{}{}
+	j.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe 取消订阅
+func (j *Jukebox) Unsubscribe(ch chan struct{}) {
+	j.mu.Lock()
+	delete(j.subscribers, ch)
+	j.mu.Unlock()
+}
+
+// advance 播放排在最前的点播；队列为空时停止播放。也作为区域队列播完的回调。
+func (j *Jukebox) advance() {
+	j.advanceMu.Lock()
+	defer j.advanceMu.Unlock()
+	j.advanceLocked()
+}
+
+// startIfIdle 区域空闲（没有在播放或暂停的歌曲）时立即开始播放点播
+func (j *Jukebox) startIfIdle() {
+	j.advanceMu.Lock()
+	defer j.advanceMu.Unlock()
+	if p := j.ctl.Player(); p != nil && !p.IsPlaying() && !p.IsPaused() {
+		j.advanceLocked()
+		return
+	}
+	j.notify()
+}
+
+func (j *Jukebox) advanceLocked() {
+	if j.ctl.Player() == nil {
+		return
+	}
+	for {
+		j.mu.Lock()
+		if len(j.requests) == 0 {
+			j.nowPlaying = nil
+			j.mu.Unlock()
+			if p := j.ctl.Player(); p != nil {
+				p.Stop()
+			}
+			j.notify()
+			return
+		}
+		r := j.requests[0]
+		j.requests = j.requests[1:]
+		j.nowPlaying = r
+		j.mu.Unlock()
+
+		if _, err := j.ctl.Play(playback.Request{SongID: r.Song.ID}); err != nil {
+			fmt.Printf("点歌播放失败（%s）: %v\n", r.Song.Title, err)
+			continue
+		}
+		j.notify()
+		return
+	}
+}
+
+func (j *Jukebox) notify() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for ch := range j.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default: // 已有未处理的通知
+		}
+	}
+}
+
+func (j *Jukebox) findLocked(id int) *Request {
+	for _, r := range j.requests {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func (j *Jukebox) removeLocked(id int) {
+	for i, r := range j.requests {
+		if r.ID == id {
+			j.requests = append(j.requests[:i], j.requests[i+1:]...)
+			return
+		}
+	}
+}
+
+// sortLocked 置顶在前，其后按票数从高到低，同票按点播时间先后
+func (j *Jukebox) sortLocked() {
+	sort.SliceStable(j.requests, func(a, b int) bool {
+		ra, rb := j.requests[a], j.requests[b]
+		if ra.Pinned != rb.Pinned {
+			return ra.Pinned
+		}
+		if ra.Score != rb.Score {
+			return ra.Score > rb.Score
+		}
+		return ra.RequestedAt.Before(rb.RequestedAt)
+	})
+}
+
+// vote 记录来宾的投票并重新计算票数
+func (r *Request) vote(guestID, value int) {
+	if value == 0 {
+		delete(r.votes, guestID)
+	} else {
+		r.votes[guestID] = value
+	}
+	r.Score = 0
+	for _, v := range r.votes {
+		r.Score += v
+	}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
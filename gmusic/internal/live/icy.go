@@ -0,0 +1,64 @@
+package live
+
+import (
+	"io"
+	"strings"
+)
+
+// ICYMetaInt 每隔多少字节音频数据插入一次 ICY 元数据块（SHOUTcast/Icecast 的常用取值）
+const ICYMetaInt = 16000
+
+// ICYWriter 按 SHOUTcast 约定在音频数据中穿插元数据：每 ICYMetaInt 字节音频后插入一个长度字节
+// （实际长度 /16）与 StreamTitle='...'; 文本。标题未变化时插入长度为 0 的空块。
+type ICYWriter struct {
+	w         io.Writer
+	title     func() string
+	remaining int
+	sent      string
+}
+
+// NewICYWriter 创建 ICY 写入器；title 在每个元数据位置被调用
+func NewICYWriter(w io.Writer, title func() string) *ICYWriter {
+	return &ICYWriter{w: w, title: title, remaining: ICYMetaInt}
+}
+
+// Write 写入音频数据，在到达间隔处插入元数据块
+func (iw *ICYWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), iw.remaining)
+		m, err := iw.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+		iw.remaining -= n
+		if iw.remaining == 0 {
+			if _, err := iw.w.Write(iw.metadataBlock()); err != nil {
+				return written, err
+			}
+			iw.remaining = ICYMetaInt
+		}
+	}
+	return written, nil
+}
+
+// metadataBlock 构造一个元数据块；标题只在变化时发送
+func (iw *ICYWriter) metadataBlock() []byte {
+	title := iw.title()
+	if title == iw.sent {
+		return []byte{0}
+	}
+	iw.sent = title
+	// 标题中的单引号会提前结束 StreamTitle，替换为全角以保持可读
+	text := "StreamTitle='" + strings.ReplaceAll(title, "'", "’") + "';"
+	if len(text) > 255*16 {
+		text = text[:255*16]
+	}
+	blocks := (len(text) + 15) / 16
+	block := make([]byte, 1+blocks*16)
+	block[0] = byte(blocks)
+	copy(block[1:], text)
+	return block
+}
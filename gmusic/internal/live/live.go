@@ -0,0 +1,172 @@
+// Package live 将服务端播放器输出的 PCM 转发给任意数量的 HTTP 收听者，实现电台式的直播挂载点。
+//
+// 播放器每写入一块 PCM（本机音量之前）就交给 Broadcaster.Write，广播器把数据复制到每个收听者的缓冲通道；
+// 通道写满（收听者消费速度跟不上实时播放）时直接断开该收听者，保证播放循环永不被阻塞。
+// 暂停或停止期间没有 PCM 产生，广播器以静音补齐，使收听端的流保持连续。
+package live
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// listenerBuffer 每个收听者最多积压的数据块数；按播放器每块 4 KiB 计约 3 秒音频
+	listenerBuffer = 128
+	// silenceTick 静音补齐的时间粒度
+	silenceTick = 100 * time.Millisecond
+	// silenceAfter 超过该时间没有收到 PCM 才开始补静音，避免与正常播放交错
+	silenceAfter = 250 * time.Millisecond
+)
+
+// Listener 一个收听者。C 在收听者被断开（过慢或广播器关闭）时关闭。
+type Listener struct {
+	C <-chan []byte
+	c chan []byte
+}
+
+// Broadcaster 直播广播器
+type Broadcaster struct {
+	sampleRate int
+	channels   int
+
+	mu        sync.Mutex
+	listeners map[*Listener]struct{}
+	lastPCM   time.Time
+	dropped   int
+	titleFunc func() string
+
+	done chan struct{}
+}
+
+// NewBroadcaster 创建广播器；sampleRate/channels 为 PCM（16-bit 小端）格式
+func NewBroadcaster(sampleRate, channels int) *Broadcaster {
+	b := &Broadcaster{
+		sampleRate: sampleRate,
+		channels:   channels,
+		listeners:  make(map[*Listener]struct{}),
+		done:       make(chan struct{}),
+	}
+	go b.fillSilence()
+	return b
+}
+
+// Format 返回 PCM 的采样率与声道数
+func (b *Broadcaster) Format() (sampleRate, channels int) {
+	return b.sampleRate, b.channels
+}
+
+// SetTitleFunc 设置“正在播放”标题的来源，收听端的 ICY 元数据由它生成
+func (b *Broadcaster) SetTitleFunc(fn func() string) {
+	b.mu.Lock()
+	b.titleFunc = fn
+	b.mu.Unlock()
+}
+
+// Title 当前的“正在播放”标题
+func (b *Broadcaster) Title() string {
+	b.mu.Lock()
+	fn := b.titleFunc
+	b.mu.Unlock()
+	if fn == nil {
+		return ""
+	}
+	return fn()
+}
+
+// Subscribe 新增收听者，从下一块 PCM 开始接收
+func (b *Broadcaster) Subscribe() *Listener {
+	c := make(chan []byte, listenerBuffer)
+	l := &Listener{C: c, c: c}
+	b.mu.Lock()
+	b.listeners[l] = struct{}{}
+	b.mu.Unlock()
+	return l
+}
+
+// Unsubscribe 移除收听者（可重复调用）
+func (b *Broadcaster) Unsubscribe(l *Listener) {
+	b.mu.Lock()
+	b.removeLocked(l)
+	b.mu.Unlock()
+}
+
+// Listeners 当前收听者数量
+func (b *Broadcaster) Listeners() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.listeners)
+}
+
+// Dropped 因消费过慢被断开的收听者累计数量
+func (b *Broadcaster) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// Write 广播一块 PCM（可直接作为 player.SetPCMTap 的回调）。数据会被复制，调用方可复用 pcm。
+func (b *Broadcaster) Write(pcm []byte) {
+	b.mu.Lock()
+	b.lastPCM = time.Now()
+	b.broadcastLocked(pcm)
+	b.mu.Unlock()
+}
+
+// Close 停止静音补齐并断开全部收听者
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-b.done:
+		return
+	default:
+	}
+	close(b.done)
+	for l := range b.listeners {
+		b.removeLocked(l)
+	}
+}
+
+// broadcastLocked 将数据非阻塞地投递给每个收听者，缓冲已满的收听者被断开
+func (b *Broadcaster) broadcastLocked(pcm []byte) {
+	if len(b.listeners) == 0 {
+		return
+	}
+	chunk := append([]byte(nil), pcm...)
+	for l := range b.listeners {
+		select {
+		case l.c <- chunk:
+		default:
+			b.removeLocked(l)
+			b.dropped++
+		}
+	}
+}
+
+func (b *Broadcaster) removeLocked(l *Listener) {
+	if _, ok := b.listeners[l]; ok {
+		delete(b.listeners, l)
+		close(l.c)
+	}
+}
+
+// fillSilence 在没有 PCM（暂停、停止、切歌间隙）时按实时速率补静音
+func (b *Broadcaster) fillSilence() {
+	frameBytes := b.channels * 2
+	silence := make([]byte, int(silenceTick.Seconds()*float64(b.sampleRate))*frameBytes)
+	ticker := time.NewTicker(silenceTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+		b.mu.Lock()
+		if time.Since(b.lastPCM) >= silenceAfter {
+			b.broadcastLocked(silence)
+		}
+		b.mu.Unlock()
+	}
+}
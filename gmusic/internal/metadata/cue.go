@@ -0,0 +1,274 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/meta"
+	"github.com/yudongyouqing/GMusic/internal/cue"
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// cueAudioExts CUE 中 FILE 指向的文件不存在时，依次尝试的同名替代扩展名
+// （很多 CUE 由 EAC 生成时写的是 .wav，实际文件已转为 .flac）。
+// .ape 目前无法解码，列在这里是为了找到文件后报告“格式不支持”，而不是“文件不存在”
+var cueAudioExts = []string{".flac", ".mp3", ".wav", ".ape"}
+
+// leadOutTrackNum FLAC CUESHEET 块中 lead-out 音轨的编号（CD-DA 为 170，非 CD 为 255）
+const (
+	leadOutTrackCD    = 170
+	leadOutTrackNonCD = 255
+)
+
+// ParseCueFile 读取并解析 CUE 文件（自动处理 UTF-8/UTF-16/GBK 编码）
+func ParseCueFile(cuePath string) (*cue.Sheet, error) {
+	data, err := os.ReadFile(cuePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取 CUE 失败: %w", err)
+	}
+	sheet, err := cue.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析 CUE 失败: %w", err)
+	}
+	return sheet, nil
+}
+
+// CueAudioFiles 返回 CUE 引用且实际存在的音频文件路径，扫描器据此跳过整轨导入
+func CueAudioFiles(cuePath string) ([]string, error) {
+	sheet, err := ParseCueFile(cuePath)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, name := range sheet.Files() {
+		if p := resolveCueAudio(cuePath, name); p != "" {
+			files = append(files, p)
+		}
+	}
+	return files, nil
+}
+
+// ExtractCueTracks 为 CUE 中的每个音轨生成一条虚拟 Song（兼容旧接口风格）
+func ExtractCueTracks(cuePath string) ([]*storage.Song, error) {
+	return ExtractCueTracksWithContext(context.Background(), cuePath)
+}
+
+// ExtractCueTracksWithContext 为 CUE 中的每个音轨生成一条虚拟 Song。
+// 各音轨共享源音频的 FilePath，以 StartMs/EndMs 标识播放区间；
+// 封面、格式、年份等从源音频的标签中继承。
+func ExtractCueTracksWithContext(ctx context.Context, cuePath string) ([]*storage.Song, error) {
+	sheet, err := ParseCueFile(cuePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每个源文件只读取一次标签与时长
+	bases := make(map[string]*storage.Song)
+	resolved := make(map[string]string)
+	for _, name := range sheet.Files() {
+		p := resolveCueAudio(cuePath, name)
+		if p == "" {
+			return nil, fmt.Errorf("CUE 引用的音频文件不存在: %s", name)
+		}
+		// 无法解码的整轨镜像（常见的是 APE）导入后既不能播放也不能转码，整个 CUE 跳过
+		if !player.CanDecode(p) {
+			return nil, fmt.Errorf("%w: CUE 引用的 %s 无法解码，已跳过（可转换为 FLAC 后重新扫描）",
+				player.ErrUnsupportedFormat, filepath.Base(p))
+		}
+		resolved[name] = p
+		bases[p] = baseSongForCue(ctx, p)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	songs := make([]*storage.Song, 0, len(sheet.Tracks))
+	for i, t := range sheet.Tracks {
+		audioPath := resolved[t.File]
+		songs = append(songs, cueTrackSong(sheet, i, bases[audioPath], audioPath, cuePath))
+	}
+	return songs, nil
+}
+
+// ExtractEmbeddedCueTracksWithContext 读取 FLAC 内嵌的索引表并生成分轨歌曲。
+// 优先使用 Vorbis 注释中的 CUESHEET 文本（含标题/艺术家），其次使用二进制 CUESHEET 块。
+// 文件不含内嵌索引表时返回 (nil, nil)，调用方应按普通歌曲导入。
+func ExtractEmbeddedCueTracksWithContext(ctx context.Context, filePath string) ([]*storage.Song, error) {
+	if strings.ToLower(filepath.Ext(filePath)) != ".flac" {
+		return nil, nil
+	}
+	sheet, err := readEmbeddedCueSheet(filePath)
+	if err != nil || sheet == nil || len(sheet.Tracks) < 2 {
+		// 单音轨的索引表没有分轨意义
+		return nil, err
+	}
+
+	// 内嵌索引表的 FILE 指令无意义，统一指向 FLAC 本身
+	for i := range sheet.Tracks {
+		sheet.Tracks[i].File = filePath
+	}
+
+	base := baseSongForCue(ctx, filePath)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	songs := make([]*storage.Song, 0, len(sheet.Tracks))
+	for i := range sheet.Tracks {
+		songs = append(songs, cueTrackSong(sheet, i, base, filePath, filePath))
+	}
+	return songs, nil
+}
+
+// readEmbeddedCueSheet 从 FLAC 元数据块中读取索引表，不存在时返回 (nil, nil)
+func readEmbeddedCueSheet(filePath string) (*cue.Sheet, error) {
+	stream, err := flac.ParseFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var binary *meta.CueSheet
+	for _, block := range stream.Blocks {
+		switch body := block.Body.(type) {
+		case *meta.VorbisComment:
+			for _, kv := range body.Tags {
+				if strings.EqualFold(kv[0], "CUESHEET") && strings.TrimSpace(kv[1]) != "" {
+					return cue.ParseString(kv[1])
+				}
+			}
+		case *meta.CueSheet:
+			binary = body
+		}
+	}
+	if binary == nil || stream.Info.SampleRate == 0 {
+		return nil, nil
+	}
+	return cueSheetFromFLACBlock(binary, stream.Info.SampleRate), nil
+}
+
+// cueSheetFromFLACBlock 将二进制 CUESHEET 块（以采样数为单位）转换为 cue.Sheet
+func cueSheetFromFLACBlock(cs *meta.CueSheet, sampleRate uint32) *cue.Sheet {
+	sheet := &cue.Sheet{Catalog: cs.MCN}
+	for _, t := range cs.Tracks {
+		if t.Num == leadOutTrackCD || t.Num == leadOutTrackNonCD || !t.IsAudio {
+			continue
+		}
+		offset := t.Offset
+		for _, idx := range t.Indicies {
+			if idx.Num == 1 {
+				offset += idx.Offset
+				break
+			}
+		}
+		sheet.Tracks = append(sheet.Tracks, cue.Track{
+			Number: int(t.Num),
+			ISRC:   t.ISRC,
+			Start:  time.Duration(offset) * time.Second / time.Duration(sampleRate),
+		})
+	}
+	return sheet
+}
+
+// baseSongForCue 读取源音频的标签与时长，作为各分轨的默认值；读取失败时仅填充格式
+func baseSongForCue(ctx context.Context, audioPath string) *storage.Song {
+	if song, err := ExtractMetadataWithContext(ctx, audioPath); err == nil {
+		return song
+	}
+	return &storage.Song{
+		FilePath: audioPath,
+		Format:   getFormat(audioPath),
+		Duration: ComputeDurationSecondsWithContext(ctx, audioPath),
+	}
+}
+
+// cueTrackSong 由索引表第 i 个音轨生成虚拟 Song
+func cueTrackSong(sheet *cue.Sheet, i int, base *storage.Song, audioPath, cuePath string) *storage.Song {
+	t := sheet.Tracks[i]
+	startMs := t.Start.Milliseconds()
+	endMs := sheet.End(i).Milliseconds()
+
+	song := &storage.Song{
+		Title:       t.Title,
+		Artist:      firstNonEmpty(t.Performer, sheet.Performer, base.Artist),
+		Album:       firstNonEmpty(sheet.Title, base.Album),
+		AlbumArtist: firstNonEmpty(sheet.Performer, base.AlbumArtist), // 整张专辑的 PERFORMER 即专辑艺术家
+		FilePath:    audioPath,
+		BitRate:     base.BitRate,
+		Format:      base.Format,
+		CoverURL:    base.CoverURL,
+		TrackNum:    t.Number,
+		TrackTotal:  len(sheet.Tracks),
+		DiscNum:     base.DiscNum,
+		DiscTotal:   base.DiscTotal,
+		Compilation: base.Compilation,
+		Composer:    firstNonEmpty(t.Songwriter, base.Composer),
+		Genres:      base.Genres,
+		Comment:     firstNonEmpty(sheet.Comment, base.Comment),
+		Year:        base.Year,
+		StartMs:     startMs,
+		EndMs:       endMs,
+		CueFile:     cuePath,
+	}
+	if song.Title == "" {
+		song.Title = fmt.Sprintf("Track %02d", t.Number)
+	}
+	if y, err := strconv.Atoi(strings.TrimSpace(sheet.Date)); err == nil && y > 0 {
+		song.Year = y
+	}
+	if genres := SplitGenres(sheet.Genre); len(genres) > 0 {
+		song.Genres = genres
+	}
+	if song.Genres == nil {
+		song.Genres = []string{}
+	}
+
+	song.Duration = TrackDuration(song, base.Duration)
+	return song
+}
+
+// resolveCueAudio 将 CUE 中的 FILE 名解析为实际存在的音频路径，找不到时返回空串
+func resolveCueAudio(cuePath, name string) string {
+	// 兼容 Windows 风格的分隔符
+	name = strings.ReplaceAll(name, `\`, string(filepath.Separator))
+	candidate := name
+	if !filepath.IsAbs(candidate) {
+		candidate = filepath.Join(filepath.Dir(cuePath), name)
+	}
+	if fileExists(candidate) {
+		return candidate
+	}
+	stem := strings.TrimSuffix(candidate, filepath.Ext(candidate))
+	for _, ext := range cueAudioExts {
+		if fileExists(stem + ext) {
+			return stem + ext
+		}
+	}
+	return ""
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package metadata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// TestExtractCueTracksUndecodable 指向 APE 等无法解码的整轨镜像的 CUE 整个跳过，错误说明原因
+func TestExtractCueTracksUndecodable(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string // CUE 中 FILE 的文件名
+		exist string // 实际存在的音频文件
+	}{
+		{"FILE 直接指向 APE", "album.ape", "album.ape"},
+		{"FILE 写的是 WAV，实际是 APE", "album.wav", "album.ape"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, tt.exist), []byte("MAC "), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sheet := "PERFORMER \"陈奕迅\"\nTITLE \"黑白灰\"\nFILE \"" + tt.file + "\" WAVE\n" +
+			"  TRACK 01 AUDIO\n    TITLE \"十年\"\n    INDEX 01 00:00:00\n" +
+			"  TRACK 02 AUDIO\n    TITLE \"浮夸\"\n    INDEX 01 03:25:00\n"
+		cuePath := filepath.Join(dir, "album.cue")
+		if err := os.WriteFile(cuePath, []byte(sheet), 0644); err != nil {
+			t.Fatal(err)
+		}
+		songs, err := ExtractCueTracks(cuePath)
+		if !errors.Is(err, player.ErrUnsupportedFormat) || !strings.Contains(err.Error(), tt.exist) {
+			t.Errorf("%s: 得到 %d 首，错误 %v，期望指明 %s 无法解码", tt.name, len(songs), err, tt.exist)
+		}
+	}
+}
+
+// TestTrackDuration CUE 分轨的时长为区间长度，最后一轨为文件剩余部分；普通歌曲即文件时长
+func TestTrackDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		song    storage.Song
+		fileSec int
+		want    int
+	}{
+		{"普通歌曲", storage.Song{FilePath: "/a.flac"}, 245, 245},
+		{"中间的分轨", storage.Song{CueFile: "/a.cue", StartMs: 205000, EndMs: 488500}, 4200, 283},
+		{"最后一轨", storage.Song{CueFile: "/a.cue", StartMs: 3900000}, 4200, 300},
+		{"最后一轨，文件时长未知", storage.Song{CueFile: "/a.cue", StartMs: 3900000}, 0, 0},
+		{"第一轨", storage.Song{CueFile: "/a.cue", EndMs: 205000}, 4200, 205},
+	}
+	for _, tt := range tests {
+		if got := TrackDuration(&tt.song, tt.fileSec); got != tt.want {
+			t.Errorf("%s: %d，期望 %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dhowden/tag"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// 旧的 MP3 常把 GBK/Big5/Shift-JIS 编码的文字写进 ID3v1 或声明为 ISO-8859-1 的 ID3v2 帧，
+// tag 库按 Latin-1 解码后成了乱码（"ÖÜ½ÜÂ×"）。这里先还原出原始字节，再按各编码的字节结构与常用字分布打分，
+// 得分明显高于 Latin-1 的编码即用来重新解码。
+//   - 只处理 ID3 标签：Vorbis 注释、MP4 等格式规定使用 UTF-8
+//   - 同一文件的各字段合在一起判断，样本更多也保证各字段按同一编码解码
+//   - 检测出错时可为文件指定编码（见 storage.SetTagCharsetOverride），扫描时优先使用
+
+// Charset 标签文字的编码
+type Charset string
+
+const (
+	CharsetAuto     Charset = ""          // 自动检测
+	CharsetLatin1   Charset = "latin1"    // 按 ISO-8859-1 原样保留
+	CharsetGBK      Charset = "gbk"       // 简体中文（GBK/GB18030）
+	CharsetBig5     Charset = "big5"      // 繁体中文
+	CharsetShiftJIS Charset = "shift_jis" // 日文
+)
+
+// charsetAliases 可接受的编码名称（小写）
+var charsetAliases = map[string]Charset{
+	"": CharsetAuto, "auto": CharsetAuto,
+	"latin1": CharsetLatin1, "latin-1": CharsetLatin1, "iso-8859-1": CharsetLatin1, "iso8859-1": CharsetLatin1,
+	"gbk": CharsetGBK, "gb2312": CharsetGBK, "gb18030": CharsetGBK, "cp936": CharsetGBK,
+	"big5": CharsetBig5, "big-5": CharsetBig5, "cp950": CharsetBig5,
+	"shift_jis": CharsetShiftJIS, "shift-jis": CharsetShiftJIS, "sjis": CharsetShiftJIS, "cp932": CharsetShiftJIS,
+}
+
+// ParseCharset 解析编码名称，不区分大小写；空串与 auto 表示自动检测
+func ParseCharset(name string) (Charset, error) {
+	if c, ok := charsetAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return c, nil
+	}
+	return "", fmt.Errorf("不支持的编码 %q（可选：auto、latin1、gbk、big5、shift_jis）", name)
+}
+
+// decoder 编码对应的解码器；Latin-1 与自动检测返回 nil
+func (c Charset) decoder() *encoding.Decoder {
+	switch c {
+	case CharsetGBK:
+		return simplifiedchinese.GB18030.NewDecoder()
+	case CharsetBig5:
+		return traditionalchinese.Big5.NewDecoder()
+	case CharsetShiftJIS:
+		return japanese.ShiftJIS.NewDecoder()
+	}
+	return nil
+}
+
+// legacyBytes 还原被当作 Latin-1 的标签文字的原始字节：
+// ID3v2 的 ISO-8859-1 帧逐字节解码为 U+0000–U+00FF，ID3v1 则直接是未解码的字节（通常不是合法的 UTF-8）。
+// 纯 ASCII 或含有 U+00FF 以上字符（已是正确的 Unicode）时返回 false。
+func legacyBytes(s string) ([]byte, bool) {
+	if !utf8.ValidString(s) {
+		return []byte(s), true
+	}
+	b := make([]byte, 0, len(s))
+	high := false
+	for _, r := range s {
+		if r > 0xFF {
+			return nil, false
+		}
+		high = high || r >= 0x80
+		b = append(b, byte(r))
+	}
+	return b, high
+}
+
+// DetectCharset 按字节结构与常用字分布判断原始字节最可能的编码。
+// 各 CJK 编码中结构不合法的直接排除；合法的按常用字（GB2312 一级汉字、Big5 常用字、假名等）计分，
+// 得分须高于按 Latin-1 理解（字母与 ASCII 字母相邻）的得分，否则返回 CharsetLatin1。
+func DetectCharset(raw []byte) Charset {
+	best, bestScore := CharsetLatin1, scoreLatin1(raw)
+	for _, c := range []struct {
+		charset Charset
+		score   func([]byte) int
+	}{{CharsetGBK, scoreGBK}, {CharsetBig5, scoreBig5}, {CharsetShiftJIS, scoreShiftJIS}} {
+		if s := c.score(raw); s > bestScore && s > 0 {
+			best, bestScore = c.charset, s
+		}
+	}
+	return best
+}
+
+// invalid 字节结构不合法时的得分
+const invalid = -1 << 20
+
+// scoreLatin1 Latin-1：字母（À–ÿ）与 ASCII 字母相邻得分；0x80–0x9F 在 ISO-8859-1 中是控制字符，视为不合法
+func scoreLatin1(b []byte) int {
+	isASCIILetter := func(i int) bool {
+		return i >= 0 && i < len(b) && (b[i]|0x20) >= 'a' && (b[i]|0x20) <= 'z'
+	}
+	score := 0
+	for i, x := range b {
+		switch {
+		case x < 0x80:
+		case x < 0xA0:
+			return invalid
+		case x >= 0xC0 && x != 0xD7 && x != 0xF7 && (isASCIILetter(i-1) || isASCIILetter(i+1)):
+			score += 3
+		}
+	}
+	return score
+}
+
+// scoreGBK GBK：双字节，首字节 0x81–0xFE，尾字节 0x40–0xFE（除 0x7F）；GB2312 一级汉字（0xB0–0xD7）最常见
+func scoreGBK(b []byte) int {
+	score := 0
+	for i := 0; i < len(b); i++ {
+		lead := b[i]
+		if lead < 0x80 {
+			continue
+		}
+		if lead == 0x80 || lead == 0xFF || i+1 >= len(b) {
+			return invalid
+		}
+		trail := b[i+1]
+		if trail < 0x40 || trail == 0x7F || trail == 0xFF {
+			return invalid
+		}
+		i++
+		if trail < 0xA1 {
+			continue // GBK 扩充区，少见
+		}
+		switch {
+		case lead >= 0xB0 && lead <= 0xD7:
+			score += 3
+		case lead >= 0xD8 && lead <= 0xF7, lead >= 0xA1 && lead <= 0xA3:
+			score++
+		}
+	}
+	return score
+}
+
+// scoreBig5 Big5：双字节，首字节 0xA1–0xF9，尾字节 0x40–0x7E 或 0xA1–0xFE；常用字首字节 0xA4–0xC6
+func scoreBig5(b []byte) int {
+	score := 0
+	for i := 0; i < len(b); i++ {
+		lead := b[i]
+		if lead < 0x80 {
+			continue
+		}
+		if lead < 0xA1 || lead > 0xF9 || i+1 >= len(b) {
+			return invalid
+		}
+		trail := b[i+1]
+		if !(trail >= 0x40 && trail <= 0x7E) && !(trail >= 0xA1 && trail <= 0xFE) {
+			return invalid
+		}
+		i++
+		switch {
+		case lead >= 0xA4 && lead <= 0xC6:
+			score += 3
+		case lead >= 0xC9, lead <= 0xA3:
+			score++
+		}
+	}
+	return score
+}
+
+// scoreShiftJIS Shift-JIS：0xA1–0xDF 为半角片假名（乱码中常见，扣分）；双字节首字节 0x81–0x9F、0xE0–0xEF，
+// 尾字节 0x40–0xFC（除 0x7F）。平假名（0x82）、片假名（0x83）最有代表性，一级汉字（0x88–0x9F）次之
+func scoreShiftJIS(b []byte) int {
+	score := 0
+	for i := 0; i < len(b); i++ {
+		lead := b[i]
+		switch {
+		case lead < 0x80:
+			continue
+		case lead >= 0xA1 && lead <= 0xDF:
+			score--
+			continue
+		case !(lead >= 0x81 && lead <= 0x9F) && !(lead >= 0xE0 && lead <= 0xEF), i+1 >= len(b):
+			return invalid
+		}
+		trail := b[i+1]
+		if trail < 0x40 || trail == 0x7F || trail > 0xFC {
+			return invalid
+		}
+		i++
+		switch {
+		case lead == 0x82 && trail >= 0x9F, lead == 0x83 && trail <= 0x96:
+			score += 3
+		case lead >= 0x88 && lead <= 0x9F:
+			score += 2
+		case lead == 0x81, lead >= 0xE0 && lead <= 0xEA:
+			score++
+		}
+	}
+	return score
+}
+
+// isID3 标签是否为 ID3（只有 ID3 会把文字按 Latin-1 解码）
+func isID3(md tag.Metadata) bool {
+	switch md.Format() {
+	case tag.ID3v1, tag.ID3v2_2, tag.ID3v2_3, tag.ID3v2_4:
+		return true
+	}
+	return false
+}
+
+// SongTexts 歌曲中参与编码检测的文字字段
+func SongTexts(s *storage.Song) []string {
+	return append([]string{s.Title, s.Artist, s.Album, s.AlbumArtist, s.Composer, s.Comment}, s.Genres...)
+}
+
+// DetectTextCharset 判断一组文字（如同一文件的各个标签字段）的原始编码；
+// 都是 ASCII 或已是正确的 Unicode 时返回 CharsetAuto，表示不需要处理
+func DetectTextCharset(texts ...string) Charset {
+	var raw []byte
+	for _, t := range texts {
+		if b, ok := legacyBytes(t); ok {
+			raw = append(append(raw, b...), ' ')
+		}
+	}
+	if raw == nil {
+		return CharsetAuto
+	}
+	return DetectCharset(raw)
+}
+
+// FixEncoding 按 charset（CharsetAuto 时自动检测）就地重新解码各字段，返回实际采用的编码；
+// 没有需要处理的字段时返回 CharsetAuto。按 Latin-1 保留时，ID3v1 中不合法的 UTF-8 字节也转为对应的 Latin-1 字符。
+func FixEncoding(charset Charset, fields ...*string) Charset {
+	if charset == CharsetAuto {
+		texts := make([]string, len(fields))
+		for i, f := range fields {
+			texts[i] = *f
+		}
+		if charset = DetectTextCharset(texts...); charset == CharsetAuto {
+			return charset
+		}
+	}
+	dec := charset.decoder()
+	fixed := false
+	for _, f := range fields {
+		b, ok := legacyBytes(*f)
+		if !ok {
+			continue
+		}
+		fixed = true
+		if dec == nil {
+			r := make([]rune, len(b))
+			for i, x := range b {
+				r[i] = rune(x)
+			}
+			*f = string(r)
+		} else if out, err := dec.Bytes(b); err == nil {
+			*f = strings.TrimSpace(string(out))
+		}
+	}
+	if !fixed {
+		return CharsetAuto
+	}
+	return charset
+}
@@ -0,0 +1,137 @@
+package metadata
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// mojibake 模拟 tag 库读出的乱码：文字按 enc 编码后，ID3v2 的 ISO-8859-1 帧逐字节解码为 Latin-1 字符，
+// ID3v1 则原样保留字节
+func mojibake(t *testing.T, enc encoding.Encoding, s string, id3v1 bool) string {
+	t.Helper()
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatalf("编码 %q 失败: %v", s, err)
+	}
+	if id3v1 {
+		return string(b)
+	}
+	r := make([]rune, len(b))
+	for i, x := range b {
+		r[i] = rune(x)
+	}
+	return string(r)
+}
+
+// TestFixEncoding 按 GBK/Big5/Shift-JIS 写入的标签能识别并还原，真正的 Latin-1 文字（如 "Sigur Rós"）保持不变
+func TestFixEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		enc    encoding.Encoding
+		fields []string // 标题、艺术家、专辑
+		want   Charset
+	}{
+		{"GBK", simplifiedchinese.GBK, []string{"十年", "陈奕迅", "黑白灰"}, CharsetGBK},
+		{"GBK 单字段", simplifiedchinese.GBK, []string{"后来", "", ""}, CharsetGBK},
+		{"GBK 中英混合", simplifiedchinese.GBK, []string{"K歌之王", "Eason 陈奕迅", "打得火热"}, CharsetGBK},
+		{"Big5", traditionalchinese.Big5, []string{"月亮代表我的心", "鄧麗君", "淡淡幽情"}, CharsetBig5},
+		{"Big5 繁体", traditionalchinese.Big5, []string{"聽海", "張惠妹", "BAD BOY"}, CharsetBig5},
+		{"Shift-JIS 平假名", japanese.ShiftJIS, []string{"さくらんぼ", "大塚愛", "LOVE PUNCH"}, CharsetShiftJIS},
+		{"Shift-JIS 片假名", japanese.ShiftJIS, []string{"ハナミズキ", "一青窈", "もらい泣き"}, CharsetShiftJIS},
+		{"Latin-1 冰岛语", charmap.ISO8859_1, []string{"Hoppípolla", "Sigur Rós", "Takk..."}, CharsetLatin1},
+		{"Latin-1 只有艺术家", charmap.ISO8859_1, []string{"Svefn-g-englar", "Sigur Rós", "Ágætis byrjun"}, CharsetLatin1},
+		{"Latin-1 德语", charmap.ISO8859_1, []string{"Ace of Spades", "Motörhead", "Ace of Spades"}, CharsetLatin1},
+		{"Latin-1 法语", charmap.ISO8859_1, []string{"Été indien", "Joe Dassin", "Le Café des 3 Colombes"}, CharsetLatin1},
+	}
+	for _, tt := range tests {
+		for _, id3v1 := range []bool{false, true} {
+			fields := make([]string, len(tt.fields))
+			ptrs := make([]*string, len(tt.fields))
+			for i, f := range tt.fields {
+				fields[i] = mojibake(t, tt.enc, f, id3v1)
+				ptrs[i] = &fields[i]
+			}
+			got := FixEncoding(CharsetAuto, ptrs...)
+			if got != tt.want {
+				t.Errorf("%s（ID3v1=%v）: 检测为 %q，期望 %q", tt.name, id3v1, got, tt.want)
+				continue
+			}
+			for i := range fields {
+				if fields[i] != tt.fields[i] {
+					t.Errorf("%s（ID3v1=%v）: 还原为 %q，期望 %q", tt.name, id3v1, fields[i], tt.fields[i])
+				}
+			}
+		}
+	}
+}
+
+// TestFixEncodingUnchanged ASCII 与含 U+00FF 以上字符（已是正确 Unicode）的文字不需要处理；
+// 只含 Latin-1 字符的 "Sigur Rós" 与 ISO-8859-1 帧读出的结果相同，见 TestFixEncoding
+func TestFixEncodingUnchanged(t *testing.T) {
+	tests := [][]string{
+		{"Hello", "Adele", "25"},
+		{"十年", "陈奕迅", "黑白灰"},
+		{"十年", "Eason 陈奕迅", "ＵＳ"},
+		{"", "", ""},
+	}
+	for _, fields := range tests {
+		got := append([]string(nil), fields...)
+		if c := FixEncoding(CharsetAuto, &got[0], &got[1], &got[2]); c != CharsetAuto {
+			t.Errorf("%q: 检测为 %q，期望不处理", fields, c)
+		}
+		for i := range got {
+			if got[i] != fields[i] {
+				t.Errorf("%q: 被改为 %q", fields, got)
+			}
+		}
+	}
+}
+
+// TestFixEncodingManual 手动指定编码时不做检测，按指定编码解码
+func TestFixEncodingManual(t *testing.T) {
+	tests := []struct {
+		charset Charset
+		enc     encoding.Encoding
+		text    string
+	}{
+		{CharsetGBK, simplifiedchinese.GBK, "红豆"},
+		{CharsetBig5, traditionalchinese.Big5, "紅豆"},
+		{CharsetShiftJIS, japanese.ShiftJIS, "紅"},
+		{CharsetLatin1, charmap.ISO8859_1, "Rós"},
+	}
+	for _, tt := range tests {
+		s := mojibake(t, tt.enc, tt.text, false)
+		if c := FixEncoding(tt.charset, &s); c != tt.charset || s != tt.text {
+			t.Errorf("%s: 得到 %q（%q），期望 %q", tt.charset, s, c, tt.text)
+		}
+	}
+}
+
+// TestParseCharset 编码名称不区分大小写，接受常见别名
+func TestParseCharset(t *testing.T) {
+	tests := []struct {
+		name string
+		want Charset
+		ok   bool
+	}{
+		{"", CharsetAuto, true},
+		{"AUTO", CharsetAuto, true},
+		{" GB2312 ", CharsetGBK, true},
+		{"cp950", CharsetBig5, true},
+		{"SJIS", CharsetShiftJIS, true},
+		{"ISO-8859-1", CharsetLatin1, true},
+		{"utf-8", "", false},
+		{"euc-kr", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseCharset(tt.name)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseCharset(%q) = %q, %v", tt.name, got, err)
+		}
+	}
+}
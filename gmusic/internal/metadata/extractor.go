@@ -3,6 +3,7 @@ package metadata
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -33,8 +34,14 @@ func ExtractMetadata(filePath string) (*storage.Song, error) {
 	return ExtractMetadataWithContext(context.Background(), filePath)
 }
 
-// ExtractMetadataWithContext 从音频文件提取元数据（支持 context 取消和超时）
+// ExtractMetadataWithContext 从音频文件提取元数据（支持 context 取消和超时），ID3 标签的文字编码自动检测
 func ExtractMetadataWithContext(ctx context.Context, filePath string) (*storage.Song, error) {
+	return ExtractMetadataWithCharset(ctx, filePath, CharsetAuto)
+}
+
+// ExtractMetadataWithCharset 同 ExtractMetadataWithContext，charset 非 CharsetAuto 时按指定编码解码标签文字
+// （不限于 ID3），用于纠正自动检测的错误
+func ExtractMetadataWithCharset(ctx context.Context, filePath string, charset Charset) (*storage.Song, error) {
 	// 检查取消
 	select {
 	case <-ctx.Done():
@@ -72,23 +79,16 @@ func ExtractMetadataWithContext(ctx context.Context, filePath string) (*storage.
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	case result := <-mdChan:
-		if result.err != nil {
+		// 没有任何标签的文件照常导入，标题等由扫描器按文件名推断（见 pattern.go）
+		if result.err != nil && !errors.Is(result.err, tag.ErrNoTagsFound) {
 			return nil, fmt.Errorf("读取 metadata 失败: %w", result.err)
 		}
 		md = result.md
 	}
 
-	track, _ := md.Track()
-
-	song := &storage.Song{
-		Title:    md.Title(),
-		Artist:   md.Artist(),
-		Album:    md.Album(),
-		FilePath: filePath,
-		Duration: 0,
-		TrackNum: track,
-		Year:     md.Year(),
-		Format:   getFormat(filePath),
+	song := &storage.Song{FilePath: filePath, Format: getFormat(filePath), Genres: []string{}}
+	if md != nil {
+		song = songFromTags(md, filePath, charset)
 	}
 
 	// 再次检查取消
@@ -98,8 +98,10 @@ func ExtractMetadataWithContext(ctx context.Context, filePath string) (*storage.
 	default:
 	}
 
-	if pic := md.Picture(); pic != nil {
-		song.CoverURL = saveCover(pic.Data, filePath)
+	if md != nil {
+		if pic := md.Picture(); pic != nil {
+			song.CoverURL = saveCover(pic.Data, filePath)
+		}
 	}
 
 	// 计算时长（可能耗时）
@@ -110,6 +112,91 @@ func ExtractMetadataWithContext(ctx context.Context, filePath string) (*storage.
 	return song, nil
 }
 
+// ReadTags 只读取标签（不保存封面、不计算时长），用于预览；没有标签时返回只含路径与格式的记录
+func ReadTags(filePath string) (*storage.Song, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+	md, err := tag.ReadFrom(file)
+	if errors.Is(err, tag.ErrNoTagsFound) {
+		return &storage.Song{FilePath: filePath, Format: getFormat(filePath), Genres: []string{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 metadata 失败: %w", err)
+	}
+	return songFromTags(md, filePath, CharsetAuto), nil
+}
+
+// songFromTags 由标签生成歌曲记录
+func songFromTags(md tag.Metadata, filePath string, charset Charset) *storage.Song {
+	track, trackTotal := md.Track()
+	disc, discTotal := md.Disc()
+
+	song := &storage.Song{
+		Title:       md.Title(),
+		Artist:      md.Artist(),
+		Album:       md.Album(),
+		AlbumArtist: md.AlbumArtist(),
+		FilePath:    filePath,
+		Duration:    0,
+		TrackNum:    track,
+		TrackTotal:  trackTotal,
+		DiscNum:     disc,
+		DiscTotal:   discTotal,
+		Compilation: isCompilation(md),
+		Composer:    strings.TrimSpace(md.Composer()),
+		Comment:     strings.TrimSpace(md.Comment()),
+		Year:        md.Year(),
+		Format:      getFormat(filePath),
+	}
+	// 旧 ID3 标签中按 Latin-1 解码的 GBK/Big5 等文字重新解码；流派在拆分前处理，避免多字节字符被拆开
+	genre := md.Genre()
+	if charset != CharsetAuto || isID3(md) {
+		song.TagCharset = string(FixEncoding(charset, &song.Title, &song.Artist, &song.Album,
+			&song.AlbumArtist, &song.Composer, &song.Comment, &genre))
+	}
+	song.Genres = SplitGenres(genre)
+	return song
+}
+
+// genreSeparators 多个流派之间的分隔符：ID3v2.4 的多值以 NUL 分隔，其余为常见的手工写法
+const genreSeparators = "\x00;；/、,，|"
+
+// SplitGenres 把流派标签拆为多个流派，去掉空白与重复项（不区分大小写），保持出现顺序；
+// 没有流派时返回空切片而不是 nil
+func SplitGenres(s string) []string {
+	genres := []string{}
+	seen := map[string]bool{}
+	for _, g := range strings.FieldsFunc(s, func(r rune) bool { return strings.ContainsRune(genreSeparators, r) }) {
+		g = strings.TrimSpace(g)
+		if key := strings.ToLower(g); g != "" && !seen[key] {
+			seen[key] = true
+			genres = append(genres, g)
+		}
+	}
+	return genres
+}
+
+// isCompilation 读取合辑标记：ID3v2 的 TCMP、MP4 的 cpil、Vorbis 注释的 COMPILATION
+func isCompilation(md tag.Metadata) bool {
+	raw := md.Raw()
+	for _, key := range []string{"TCMP", "cpil", "compilation"} {
+		switch v := raw[key].(type) {
+		case string:
+			if s := strings.TrimSpace(v); s != "" && s != "0" {
+				return true
+			}
+		case int:
+			if v != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // ComputeDurationSeconds 计算音频时长（秒），支持 mp3/flac，其他返回 0（兼容旧接口）
 func ComputeDurationSeconds(filePath string) int {
 	return ComputeDurationSecondsWithContext(context.Background(), filePath)
@@ -130,6 +217,18 @@ func ComputeDurationSecondsWithContext(ctx context.Context, filePath string) int
 	return info.Duration
 }
 
+// TrackDuration 歌曲实际播放的秒数：CUE 分轨取 [StartMs, EndMs) 区间长度，最后一轨（没有终点）为
+// 整个文件的时长 fileSec 减去起点；普通歌曲即 fileSec
+func TrackDuration(s *storage.Song, fileSec int) int {
+	if !s.IsCueTrack() {
+		return fileSec
+	}
+	if s.EndMs > s.StartMs {
+		return int((s.EndMs - s.StartMs) / 1000)
+	}
+	return max0(fileSec - int(s.StartMs/1000))
+}
+
 // ProbeAudio 探测音频基础信息（兼容旧接口）
 func ProbeAudio(filePath string) (*AudioInfo, error) {
 	return ProbeAudioWithContext(context.Background(), filePath)
@@ -232,6 +331,26 @@ func getFormat(filePath string) string {
 	}
 }
 
+// audioContentTypes 按扩展名映射音频 MIME 类型（mime 包在各平台上对音频类型的支持不一致）
+var audioContentTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".flac": "audio/flac",
+	".wav":  "audio/wav",
+	".aac":  "audio/aac",
+	".m4a":  "audio/mp4",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".ape":  "audio/x-ape",
+}
+
+// AudioContentType 返回音频文件的 MIME 类型，未知格式返回 application/octet-stream
+func AudioContentType(filePath string) string {
+	if ct, ok := audioContentTypes[strings.ToLower(filepath.Ext(filePath))]; ok {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
 func saveCover(data []byte, audioPath string) string {
 	dir := filepath.Join(filepath.Dir(audioPath), ".covers")
 	_ = os.MkdirAll(dir, 0755)
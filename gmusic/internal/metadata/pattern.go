@@ -0,0 +1,169 @@
+package metadata
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// 按文件名与目录推断标签：标签缺失或不完整的文件（没有标题、艺术家、专辑或曲目序号）按模式从路径中提取，
+// 只填补空缺的字段，标签中已有的值不会被覆盖。模式形如
+//
+//	%artist%/%album%/%track% - %title%
+//
+// 以 / 分隔的各段依次对应路径的最后几级（最后一段为去掉扩展名的文件名），%字段% 以外的文字须原样出现。
+// 多个模式按顺序尝试，采用第一个能匹配的。
+
+// DefaultTagPatterns 默认的推断模式
+var DefaultTagPatterns = []string{
+	"%artist%/%album%/%track% - %title%",
+	"%artist%/%album%/%track%. %title%",
+	"%artist%/%album%/%track% %title%",
+	"%track% - %artist% - %title%",
+	"%track% - %title%",
+	"%artist% - %title%",
+	"%title%",
+}
+
+// patternFields 模式中可用的字段；数字字段只匹配数字
+var patternFields = map[string]bool{
+	"title": false, "artist": false, "album": false, "albumartist": false, "composer": false, "genre": false,
+	"track": true, "disc": true, "year": true,
+	"ignore": false, // 匹配任意文字但不使用
+}
+
+// patternPlaceholder 模式中的 %字段%
+var patternPlaceholder = regexp.MustCompile(`%([a-z]+)%`)
+
+// TagPattern 编译后的推断模式
+type TagPattern struct {
+	Source string // 原始模式
+	re     *regexp.Regexp
+	fields []string // 各捕获组对应的字段
+}
+
+// ParseTagPattern 编译推断模式；字段未知、没有任何字段或同一字段出现两次时返回错误
+func ParseTagPattern(pattern string) (*TagPattern, error) {
+	src := strings.Trim(strings.TrimSpace(pattern), "/")
+	if src == "" {
+		return nil, fmt.Errorf("模式不能为空")
+	}
+	p := &TagPattern{Source: src}
+	var b strings.Builder
+	b.WriteString(`(?:^|/)`)
+	last := 0
+	for _, m := range patternPlaceholder.FindAllStringSubmatchIndex(src, -1) {
+		field := src[m[2]:m[3]]
+		numeric, ok := patternFields[field]
+		if !ok {
+			return nil, fmt.Errorf("模式 %q 中有未知字段 %%%s%%", pattern, field)
+		}
+		for _, f := range p.fields {
+			if f == field && field != "ignore" {
+				return nil, fmt.Errorf("模式 %q 中字段 %%%s%% 出现了多次", pattern, field)
+			}
+		}
+		b.WriteString(regexp.QuoteMeta(src[last:m[0]]))
+		if numeric {
+			b.WriteString(`(\d+)`)
+		} else {
+			b.WriteString(`([^/]+?)`)
+		}
+		p.fields = append(p.fields, field)
+		last = m[1]
+	}
+	if len(p.fields) == 0 {
+		return nil, fmt.Errorf("模式 %q 中没有任何字段", pattern)
+	}
+	b.WriteString(regexp.QuoteMeta(src[last:]))
+	b.WriteString(`$`)
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("模式 %q 无效: %w", pattern, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// ParseTagPatterns 依次编译多个模式
+func ParseTagPatterns(patterns []string) ([]*TagPattern, error) {
+	out := make([]*TagPattern, 0, len(patterns))
+	for _, s := range patterns {
+		p, err := ParseTagPattern(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// Match 从文件路径中提取字段（字段名 → 去掉首尾空白的值，不含 ignore）；不匹配时返回 nil
+func (p *TagPattern) Match(filePath string) map[string]string {
+	path := strings.ReplaceAll(filePath, `\`, "/")
+	path = strings.TrimSuffix(path, filepath.Ext(path))
+	m := p.re.FindStringSubmatch(path)
+	if m == nil {
+		return nil
+	}
+	fields := make(map[string]string, len(p.fields))
+	for i, f := range p.fields {
+		if v := strings.TrimSpace(m[i+1]); f != "ignore" && v != "" {
+			fields[f] = v
+		}
+	}
+	return fields
+}
+
+// MatchTagPatterns 返回第一个能匹配路径的模式序号及提取的字段；都不匹配时返回 -1
+func MatchTagPatterns(patterns []*TagPattern, filePath string) (int, map[string]string) {
+	for i, p := range patterns {
+		if fields := p.Match(filePath); fields != nil {
+			return i, fields
+		}
+	}
+	return -1, nil
+}
+
+// NeedsTagInference 标签是否缺失或不完整：没有标题、艺术家、专辑或曲目序号
+func NeedsTagInference(s *storage.Song) bool {
+	return s.Title == "" || s.Artist == "" || s.Album == "" || s.TrackNum == 0
+}
+
+// InferTags 标签缺失或不完整时按模式从文件路径推断，只填补空缺的字段；返回填补了的字段名
+func InferTags(s *storage.Song, patterns []*TagPattern) []string {
+	if !NeedsTagInference(s) {
+		return nil
+	}
+	_, fields := MatchTagPatterns(patterns, s.FilePath)
+	var filled []string
+	setText := func(name string, dst *string) {
+		if v, ok := fields[name]; ok && strings.TrimSpace(*dst) == "" {
+			*dst = v
+			filled = append(filled, name)
+		}
+	}
+	setInt := func(name string, dst *int) {
+		if v, err := strconv.Atoi(fields[name]); err == nil && v > 0 && *dst == 0 {
+			*dst = v
+			filled = append(filled, name)
+		}
+	}
+	setText("title", &s.Title)
+	setText("artist", &s.Artist)
+	setText("album", &s.Album)
+	setText("albumartist", &s.AlbumArtist)
+	setText("composer", &s.Composer)
+	if v, ok := fields["genre"]; ok && len(s.Genres) == 0 {
+		s.Genres = SplitGenres(v)
+		filled = append(filled, "genre")
+	}
+	setInt("track", &s.TrackNum)
+	setInt("disc", &s.DiscNum)
+	setInt("year", &s.Year)
+	return filled
+}
@@ -0,0 +1,140 @@
+package metadata
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// TestMatchTagPatterns 默认模式按顺序尝试，/ 与 \ 分隔的路径都能匹配，数字字段只匹配数字
+func TestMatchTagPatterns(t *testing.T) {
+	patterns, err := ParseTagPatterns(DefaultTagPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path  string
+		index int
+		want  map[string]string
+	}{
+		{"/music/Sigur Rós/Takk.../03 - Hoppípolla.flac", 0,
+			map[string]string{"artist": "Sigur Rós", "album": "Takk...", "track": "03", "title": "Hoppípolla"}},
+		{`D:\Music\陈奕迅\黑白灰\01. 十年.mp3`, 1,
+			map[string]string{"artist": "陈奕迅", "album": "黑白灰", "track": "01", "title": "十年"}},
+		{`\\nas\share\周杰伦\叶惠美\05 晴天.flac`, 2,
+			map[string]string{"artist": "周杰伦", "album": "叶惠美", "track": "05", "title": "晴天"}},
+		{`Music\Queen\A Night at the Opera\11 - Bohemian Rhapsody.mp3`, 0,
+			map[string]string{"artist": "Queen", "album": "A Night at the Opera", "track": "11", "title": "Bohemian Rhapsody"}},
+		// 只有文件名时才轮到不含目录的模式
+		{"07 - Queen - Bohemian Rhapsody.mp3", 3,
+			map[string]string{"track": "07", "artist": "Queen", "title": "Bohemian Rhapsody"}},
+		{"12 - Hey Jude.mp3", 4, map[string]string{"track": "12", "title": "Hey Jude"}},
+		// 曲目序号不是数字时，前几个模式都不匹配
+		{"/music/Adele/25/Intro - Hello.mp3", 5, map[string]string{"artist": "Intro", "title": "Hello"}},
+		{`C:\Users\me\Music\Adele\25\Hello.mp3`, 6, map[string]string{"title": "Hello"}},
+		{"/music/Adele/25/  Hello  .mp3", 6, map[string]string{"title": "Hello"}},
+	}
+	for _, tt := range tests {
+		index, got := MatchTagPatterns(patterns, tt.path)
+		if index != tt.index || !maps.Equal(got, tt.want) {
+			t.Errorf("%s: 模式 %d %v，期望模式 %d %v", tt.path, index, got, tt.index, tt.want)
+		}
+	}
+}
+
+// TestTagPatternMatch 自定义模式：数字字段、字面文字与 %ignore%
+func TestTagPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    map[string]string // nil 表示不匹配
+	}{
+		{"%year% - %album%/%disc%-%track% %title%", `E:\Rips\2003 - 黑白灰\1-01 十年.flac`,
+			map[string]string{"year": "2003", "album": "黑白灰", "disc": "1", "track": "01", "title": "十年"}},
+		{"%year% - %album%/%disc%-%track% %title%", "/rips/20XX - 黑白灰/1-01 十年.flac", nil},
+		{"%year% - %album%/%disc%-%track% %title%", "/rips/2003 - 黑白灰/A-01 十年.flac", nil},
+		{"%artist%/[%year%] %album%/%track%. %title%", `Music\Sigur Rós\[2005] Takk...\02. Hoppípolla.mp3`,
+			map[string]string{"artist": "Sigur Rós", "year": "2005", "album": "Takk...", "track": "02", "title": "Hoppípolla"}},
+		{"%artist%/[%year%] %album%/%track%. %title%", `Music\Sigur Rós\Takk...\02. Hoppípolla.mp3`, nil},
+		{"%ignore%/%ignore%/%artist% - %title%", "/a/b/周杰伦 - 晴天.mp3", map[string]string{"artist": "周杰伦", "title": "晴天"}},
+		// 每个字段只匹配一级目录
+		{"%artist% - %title%", "/music/周杰伦 - 叶惠美/晴天.mp3", nil},
+	}
+	for _, tt := range tests {
+		p, err := ParseTagPattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.pattern, err)
+		}
+		got := p.Match(tt.path)
+		if (got == nil) != (tt.want == nil) || !maps.Equal(got, tt.want) {
+			t.Errorf("%s 匹配 %s: %v，期望 %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestParseTagPatternErrors 空模式、未知字段、没有字段与重复字段都是错误
+func TestParseTagPatternErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		ok      bool
+	}{
+		{"%artist%/%album%/%track% - %title%", true},
+		{" /%title%/ ", true},
+		{"%ignore% - %ignore% - %title%", true},
+		{"", false},
+		{" / ", false},
+		{"%artist% - %name%", false},
+		{"no fields", false},
+		{"%title% (%title%)", false},
+	}
+	for _, tt := range tests {
+		if _, err := ParseTagPattern(tt.pattern); (err == nil) != tt.ok {
+			t.Errorf("ParseTagPattern(%q) 错误 = %v，期望成功 = %v", tt.pattern, err, tt.ok)
+		}
+	}
+}
+
+// TestInferTags 只填补空缺的字段，数字字段转为整数
+func TestInferTags(t *testing.T) {
+	patterns, err := ParseTagPatterns(DefaultTagPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		song   storage.Song
+		want   storage.Song
+		filled []string
+	}{
+		{"没有标签",
+			storage.Song{FilePath: `D:\Music\陈奕迅\黑白灰\03 - 十年.mp3`},
+			storage.Song{Title: "十年", Artist: "陈奕迅", Album: "黑白灰", TrackNum: 3},
+			[]string{"title", "artist", "album", "track"}},
+		{"只缺曲目序号",
+			storage.Song{FilePath: "/music/Sigur Rós/Takk.../03 - Hoppipolla.flac", Title: "Hoppípolla", Artist: "Sigur Rós", Album: "Takk..."},
+			storage.Song{Title: "Hoppípolla", Artist: "Sigur Rós", Album: "Takk...", TrackNum: 3},
+			[]string{"track"}},
+		{"标签完整时不推断",
+			storage.Song{FilePath: "/music/A/B/01 - C.mp3", Title: "T", Artist: "Ar", Album: "Al", TrackNum: 9},
+			storage.Song{Title: "T", Artist: "Ar", Album: "Al", TrackNum: 9},
+			nil},
+		{"序号为 0 不填",
+			storage.Song{FilePath: "/music/A/B/00 - C.mp3"},
+			storage.Song{Title: "C", Artist: "A", Album: "B"},
+			[]string{"title", "artist", "album"}},
+	}
+	for _, tt := range tests {
+		s := tt.song
+		filled := InferTags(&s, patterns)
+		s.FilePath = ""
+		if s.Title != tt.want.Title || s.Artist != tt.want.Artist || s.Album != tt.want.Album || s.TrackNum != tt.want.TrackNum {
+			t.Errorf("%s: 得到 %q/%q/%q/%d，期望 %q/%q/%q/%d", tt.name, s.Title, s.Artist, s.Album, s.TrackNum,
+				tt.want.Title, tt.want.Artist, tt.want.Album, tt.want.TrackNum)
+		}
+		if !slices.Equal(filled, tt.filled) {
+			t.Errorf("%s: 填补了 %v，期望 %v", tt.name, filled, tt.filled)
+		}
+	}
+}
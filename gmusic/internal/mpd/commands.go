@@ -0,0 +1,1038 @@
+package mpd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yudongyouqing/GMusic/internal/playback"
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// command 命令处理函数，输出写入 sess.w（不含结尾的 OK）
+type command func(s *Server, sess *session, args []string) error
+
+// commands 支持的命令；idle/noidle/close/command_list_* 在连接循环中单独处理
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		// 连接与状态
+		"ping":               func(*Server, *session, []string) error { return nil },
+		"password":           (*Server).cmdPassword,
+		"clearerror":         func(*Server, *session, []string) error { return nil },
+		"status":             (*Server).cmdStatus,
+		"currentsong":        (*Server).cmdCurrentSong,
+		"stats":              (*Server).cmdStats,
+		"commands":           (*Server).cmdCommands,
+		"notcommands":        func(*Server, *session, []string) error { return nil },
+		"tagtypes":           (*Server).cmdTagTypes,
+		"urlhandlers":        func(*Server, *session, []string) error { return nil },
+		"outputs":            (*Server).cmdOutputs,
+		"replay_gain_status": (*Server).cmdReplayGainStatus,
+		"random":             (*Server).cmdOption,
+		"repeat":             (*Server).cmdOption,
+		"single":             (*Server).cmdOption,
+		"consume":            (*Server).cmdOption,
+		"crossfade":          (*Server).cmdOption,
+		"update":             (*Server).cmdUpdate,
+		"rescan":             (*Server).cmdUpdate,
+		"enableoutput":       func(*Server, *session, []string) error { return nil },
+		"disableoutput":      func(*Server, *session, []string) error { return nil },
+
+		// 播放控制
+		"play":     (*Server).cmdPlay,
+		"playid":   (*Server).cmdPlayID,
+		"pause":    (*Server).cmdPause,
+		"stop":     (*Server).cmdStop,
+		"next":     (*Server).cmdNext,
+		"previous": (*Server).cmdPrevious,
+		"seek":     (*Server).cmdSeek,
+		"seekid":   (*Server).cmdSeekID,
+		"seekcur":  (*Server).cmdSeekCur,
+		"setvol":   (*Server).cmdSetVol,
+		"volume":   (*Server).cmdVolume,
+		"getvol":   (*Server).cmdGetVol,
+
+		// 队列
+		"add":            (*Server).cmdAdd,
+		"addid":          (*Server).cmdAddID,
+		"delete":         (*Server).cmdDelete,
+		"deleteid":       (*Server).cmdDeleteID,
+		"clear":          (*Server).cmdClear,
+		"move":           (*Server).cmdMove,
+		"moveid":         (*Server).cmdMoveID,
+		"shuffle":        (*Server).cmdShuffle,
+		"playlist":       (*Server).cmdPlaylist,
+		"playlistinfo":   (*Server).cmdPlaylistInfo,
+		"playlistid":     (*Server).cmdPlaylistID,
+		"plchanges":      (*Server).cmdPlChanges,
+		"plchangesposid": (*Server).cmdPlChangesPosID,
+		"playlistfind":   (*Server).cmdPlaylistFind,
+		"playlistsearch": (*Server).cmdPlaylistSearch,
+
+		// 曲库
+		"find":        (*Server).cmdFind,
+		"search":      (*Server).cmdSearch,
+		"findadd":     (*Server).cmdFindAdd,
+		"searchadd":   (*Server).cmdSearchAdd,
+		"list":        (*Server).cmdList,
+		"count":       (*Server).cmdCount,
+		"lsinfo":      (*Server).cmdLsInfo,
+		"listall":     (*Server).cmdListAll,
+		"listallinfo": (*Server).cmdListAllInfo,
+
+		// 存储的播放列表（只读）
+		"listplaylists":    (*Server).cmdListPlaylists,
+		"listplaylist":     (*Server).cmdListPlaylist,
+		"listplaylistinfo": (*Server).cmdListPlaylistInfo,
+		"load":             (*Server).cmdLoad,
+	}
+}
+
+// exec 执行单条命令
+func (s *Server) exec(sess *session, name string, args []string) error {
+	if !sess.authed && name != "password" && name != "ping" {
+		return &ackError{code: ackErrorPermission, msg: fmt.Sprintf("you don't have permission for %q", name)}
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return &ackError{code: ackErrorUnknown, msg: fmt.Sprintf("unknown command %q", name)}
+	}
+	return cmd(s, sess, args)
+}
+
+// player 返回底层播放器，未初始化时返回错误
+func (s *Server) player() (*player.Player, error) {
+	if p := s.ctl.Player(); p != nil {
+		return p, nil
+	}
+	return nil, &ackError{code: ackErrorSystem, msg: "播放器未初始化"}
+}
+
+// playbackError 将队列错误转换为 ACK
+func playbackError(err error) error {
+	var nf *playback.NotFoundError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, playback.ErrEmptyQueue):
+		return errArg("Bad song index")
+	case errors.As(err, &nf):
+		return errNoExist("%s", nf.Error())
+	default:
+		return &ackError{code: ackErrorSystem, msg: err.Error()}
+	}
+}
+
+func needArgs(args []string, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return errArg("wrong number of arguments")
+	}
+	return nil
+}
+
+func parseInt(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, errArg("Integer expected: %s", arg)
+	}
+	return n, nil
+}
+
+func parseFloat(arg string) (float64, error) {
+	f, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, errArg("Number expected: %s", arg)
+	}
+	return f, nil
+}
+
+// =========== 输出 ===========
+
+func (sess *session) kv(key string, value any) {
+	fmt.Fprintf(sess.w, "%s: %v\n", key, value)
+}
+
+// writeSong 输出歌曲信息；pos/id 为队列位置与条目 ID，<0 时不输出
+func (sess *session) writeSong(song *storage.Song, pos, id int) {
+	sess.kv("file", songURI(song))
+	title := song.Title
+	if title == "" {
+		title = path.Base(songURI(song))
+	}
+	if song.Artist != "" {
+		sess.kv("Artist", song.Artist)
+		sess.kv("AlbumArtist", song.Artist)
+	}
+	sess.kv("Title", title)
+	if song.Album != "" {
+		sess.kv("Album", song.Album)
+	}
+	if song.TrackNum > 0 {
+		sess.kv("Track", song.TrackNum)
+	}
+	if song.Year > 0 {
+		sess.kv("Date", song.Year)
+	}
+	sess.kv("Time", song.Duration)
+	sess.kv("duration", fmt.Sprintf("%.3f", float64(song.Duration)))
+	if pos >= 0 {
+		sess.kv("Pos", pos)
+		sess.kv("Id", id)
+	}
+}
+
+func (sess *session) writeSongs(songs []storage.Song) {
+	for i := range songs {
+		sess.writeSong(&songs[i], -1, -1)
+	}
+}
+
+// =========== 连接与状态 ===========
+
+func (s *Server) cmdPassword(sess *session, args []string) error {
+	if err := needArgs(args, 1, 1); err != nil {
+		return err
+	}
+	if s.password != "" && args[0] != s.password {
+		return &ackError{code: ackErrorPassword, msg: "incorrect password"}
+	}
+	sess.authed = true
+	return nil
+}
+
+func (s *Server) cmdStatus(sess *session, _ []string) error {
+	entries, version := s.ctl.Entries()
+	state := s.state()
+	volume := 100
+	var elapsed, duration float64
+	if p := s.ctl.Player(); p != nil {
+		volume = int(p.GetVolume()*100 + 0.5)
+		elapsed, duration = p.GetCurrentPosition(), p.GetDuration()
+	}
+	sess.kv("volume", volume)
+	sess.kv("repeat", 0)
+	sess.kv("random", 0)
+	sess.kv("single", 0)
+	sess.kv("consume", 0)
+	sess.kv("playlist", version)
+	sess.kv("playlistlength", len(entries))
+	sess.kv("mixrampdb", "0.000000")
+	sess.kv("state", state)
+	if _, index := s.ctl.Current(); index >= 0 && index < len(entries) {
+		sess.kv("song", index)
+		sess.kv("songid", entries[index].ID)
+		if state != "stop" {
+			if duration <= 0 {
+				duration = float64(entries[index].Song.Duration)
+			}
+			sess.kv("time", fmt.Sprintf("%d:%d", int(elapsed), int(duration+0.5)))
+			sess.kv("elapsed", fmt.Sprintf("%.3f", elapsed))
+			sess.kv("duration", fmt.Sprintf("%.3f", duration))
+			sess.kv("bitrate", entries[index].Song.BitRate)
+			sess.kv("audio", "44100:16:2")
+		}
+		if index+1 < len(entries) {
+			sess.kv("nextsong", index+1)
+			sess.kv("nextsongid", entries[index+1].ID)
+		}
+	}
+	return nil
+}
+
+func (s *Server) cmdCurrentSong(sess *session, _ []string) error {
+	entries, _ := s.ctl.Entries()
+	if _, index := s.ctl.Current(); index >= 0 && index < len(entries) {
+		sess.writeSong(&entries[index].Song, index, entries[index].ID)
+	}
+	return nil
+}
+
+func (s *Server) cmdStats(sess *session, _ []string) error {
+	var stats struct {
+		Artists  int64
+		Albums   int64
+		Songs    int64
+		Playtime int64
+	}
+	err := s.db.Model(&storage.Song{}).Select(`COUNT(DISTINCT artist) AS artists, COUNT(DISTINCT album) AS albums,
+		COUNT(*) AS songs, COALESCE(SUM(duration), 0) AS playtime`).Scan(&stats).Error
+	if err != nil {
+		return err
+	}
+	uptime := int(time.Since(s.started).Seconds())
+	sess.kv("artists", stats.Artists)
+	sess.kv("albums", stats.Albums)
+	sess.kv("songs", stats.Songs)
+	sess.kv("uptime", uptime)
+	sess.kv("playtime", uptime)
+	sess.kv("db_playtime", stats.Playtime)
+	sess.kv("db_update", s.started.Unix())
+	return nil
+}
+
+func (s *Server) cmdCommands(sess *session, _ []string) error {
+	names := []string{"close", "command_list_begin", "command_list_ok_begin", "command_list_end", "idle", "noidle"}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sess.kv("command", name)
+	}
+	return nil
+}
+
+// cmdTagTypes 列出支持的标签；“tagtypes clear/enable/disable/all” 只影响输出，这里直接接受
+func (s *Server) cmdTagTypes(sess *session, args []string) error {
+	if len(args) == 0 {
+		for _, t := range tagTypes {
+			sess.kv("tagtype", t)
+		}
+	}
+	return nil
+}
+
+func (s *Server) cmdOutputs(sess *session, _ []string) error {
+	sess.kv("outputid", 0)
+	sess.kv("outputname", "GMusic")
+	sess.kv("plugin", "oto")
+	sess.kv("outputenabled", 1)
+	return nil
+}
+
+func (s *Server) cmdReplayGainStatus(sess *session, _ []string) error {
+	sess.kv("replay_gain_mode", "off")
+	return nil
+}
+
+// cmdOption random/repeat/single/consume/crossfade：播放队列不支持这些模式，只接受关闭
+func (s *Server) cmdOption(sess *session, args []string) error {
+	if err := needArgs(args, 1, 1); err != nil {
+		return err
+	}
+	if args[0] != "0" {
+		return errArg("not supported")
+	}
+	return nil
+}
+
+// cmdUpdate 曲库更新由 HTTP 扫描接口负责，MPD 中没有音乐目录的概念
+func (s *Server) cmdUpdate(*session, []string) error {
+	return &ackError{code: ackErrorSystem, msg: "请使用 HTTP 接口 POST /api/scan 扫描曲库"}
+}
+
+// =========== 播放控制 ===========
+
+func (s *Server) cmdPlay(sess *session, args []string) error {
+	if err := needArgs(args, 0, 1); err != nil {
+		return err
+	}
+	p, err := s.player()
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		pos, err := parseInt(args[0])
+		if err != nil {
+			return err
+		}
+		_, err = s.ctl.PlayAt(pos)
+		return playbackError(err)
+	}
+	switch s.state() {
+	case "pause":
+		p.Resume()
+		return nil
+	case "play":
+		return nil
+	}
+	_, index := s.ctl.Current()
+	if index < 0 {
+		index = 0
+	}
+	_, err = s.ctl.PlayAt(index)
+	return playbackError(err)
+}
+
+func (s *Server) cmdPlayID(sess *session, args []string) error {
+	if len(args) == 0 {
+		return s.cmdPlay(sess, nil)
+	}
+	pos, err := s.positionOfID(args[0])
+	if err != nil {
+		return err
+	}
+	_, err = s.ctl.PlayAt(pos)
+	return playbackError(err)
+}
+
+func (s *Server) cmdPause(sess *session, args []string) error {
+	if err := needArgs(args, 0, 1); err != nil {
+		return err
+	}
+	p, err := s.player()
+	if err != nil {
+		return err
+	}
+	pause := s.state() == "play"
+	if len(args) == 1 {
+		pause = args[0] == "1"
+	}
+	if pause {
+		p.Pause()
+	} else {
+		p.Resume()
+	}
+	return nil
+}
+
+func (s *Server) cmdStop(*session, []string) error {
+	p, err := s.player()
+	if err != nil {
+		return err
+	}
+	p.Stop()
+	return nil
+}
+
+func (s *Server) cmdNext(*session, []string) error {
+	_, err := s.ctl.Next()
+	if errors.Is(err, playback.ErrEmptyQueue) {
+		// 已是最后一首：与 MPD 一致，停止播放
+		return s.cmdStop(nil, nil)
+	}
+	return playbackError(err)
+}
+
+func (s *Server) cmdPrevious(*session, []string) error {
+	_, err := s.ctl.Previous()
+	if errors.Is(err, playback.ErrEmptyQueue) {
+		// 已是第一首：从头播放
+		if p := s.ctl.Player(); p != nil {
+			return playbackError(p.SeekTo(0))
+		}
+	}
+	return playbackError(err)
+}
+
+// seekTo 跳转到队列第 pos 首的 sec 秒；不是当前歌曲时先切歌
+func (s *Server) seekTo(pos int, sec float64) error {
+	p, err := s.player()
+	if err != nil {
+		return err
+	}
+	if _, index := s.ctl.Current(); index != pos || s.state() == "stop" {
+		if _, err := s.ctl.PlayAt(pos); err != nil {
+			return playbackError(err)
+		}
+	}
+	return playbackError(p.SeekTo(sec))
+}
+
+func (s *Server) cmdSeek(_ *session, args []string) error {
+	if err := needArgs(args, 2, 2); err != nil {
+		return err
+	}
+	pos, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+	sec, err := parseFloat(args[1])
+	if err != nil {
+		return err
+	}
+	return s.seekTo(pos, sec)
+}
+
+func (s *Server) cmdSeekID(_ *session, args []string) error {
+	if err := needArgs(args, 2, 2); err != nil {
+		return err
+	}
+	pos, err := s.positionOfID(args[0])
+	if err != nil {
+		return err
+	}
+	sec, err := parseFloat(args[1])
+	if err != nil {
+		return err
+	}
+	return s.seekTo(pos, sec)
+}
+
+// cmdSeekCur 当前歌曲内跳转，+N/-N 表示相对当前位置
+func (s *Server) cmdSeekCur(_ *session, args []string) error {
+	if err := needArgs(args, 1, 1); err != nil {
+		return err
+	}
+	p, err := s.player()
+	if err != nil {
+		return err
+	}
+	sec, err := parseFloat(args[0])
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+		sec += p.GetCurrentPosition()
+	}
+	return playbackError(p.SeekTo(max(sec, 0)))
+}
+
+func (s *Server) cmdSetVol(_ *session, args []string) error {
+	if err := needArgs(args, 1, 1); err != nil {
+		return err
+	}
+	vol, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+	if vol < 0 || vol > 100 {
+		return errArg("Invalid volume value")
+	}
+	p, err := s.player()
+	if err != nil {
+		return err
+	}
+	p.SetVolume(float32(vol) / 100)
+	return nil
+}
+
+// cmdVolume 相对调整音量（已废弃但 mpc 仍在使用）
+func (s *Server) cmdVolume(sess *session, args []string) error {
+	if err := needArgs(args, 1, 1); err != nil {
+		return err
+	}
+	delta, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+	p, err := s.player()
+	if err != nil {
+		return err
+	}
+	vol := int(p.GetVolume()*100+0.5) + delta
+	return s.cmdSetVol(sess, []string{strconv.Itoa(min(max(vol, 0), 100))})
+}
+
+func (s *Server) cmdGetVol(sess *session, _ []string) error {
+	p, err := s.player()
+	if err != nil {
+		return err
+	}
+	sess.kv("volume", int(p.GetVolume()*100+0.5))
+	return nil
+}
+
+// =========== 队列 ===========
+
+// positionOfID 条目 ID 对应的队列位置
+func (s *Server) positionOfID(arg string) (int, error) {
+	id, err := parseInt(arg)
+	if err != nil {
+		return 0, err
+	}
+	pos := s.ctl.PositionOf(id)
+	if pos < 0 {
+		return 0, errNoExist("No such song")
+	}
+	return pos, nil
+}
+
+func (s *Server) cmdAdd(_ *session, args []string) error {
+	if err := needArgs(args, 1, 2); err != nil {
+		return err
+	}
+	songs, err := s.resolveURI(args[0])
+	if err != nil {
+		return err
+	}
+	pos := -1
+	if len(args) == 2 {
+		if pos, err = parseInt(args[1]); err != nil {
+			return err
+		}
+	}
+	s.ctl.Add(songs, pos)
+	return nil
+}
+
+func (s *Server) cmdAddID(sess *session, args []string) error {
+	if err := needArgs(args, 1, 2); err != nil {
+		return err
+	}
+	song, err := s.findSongByURI(args[0])
+	if err != nil {
+		return err
+	}
+	pos := -1
+	if len(args) == 2 {
+		if pos, err = parseInt(args[1]); err != nil {
+			return err
+		}
+	}
+	ids := s.ctl.Add([]storage.Song{*song}, pos)
+	sess.kv("Id", ids[0])
+	return nil
+}
+
+func (s *Server) cmdDelete(_ *session, args []string) error {
+	if err := needArgs(args, 1, 1); err != nil {
+		return err
+	}
+	entries, _ := s.ctl.Entries()
+	start, end, err := parseRange(args[0], len(entries))
+	if err != nil {
+		return err
+	}
+	if err := s.ctl.Remove(start, end); err != nil {
+		return errArg("Bad song index")
+	}
+	return nil
+}
+
+func (s *Server) cmdDeleteID(_ *session, args []string) error {
+	if err := needArgs(args, 1, 1); err != nil {
+		return err
+	}
+	pos, err := s.positionOfID(args[0])
+	if err != nil {
+		return err
+	}
+	return playbackError(s.ctl.Remove(pos, pos+1))
+}
+
+func (s *Server) cmdClear(*session, []string) error {
+	s.ctl.Clear()
+	return nil
+}
+
+func (s *Server) cmdMove(_ *session, args []string) error {
+	if err := needArgs(args, 2, 2); err != nil {
+		return err
+	}
+	entries, _ := s.ctl.Entries()
+	start, end, err := parseRange(args[0], len(entries))
+	if err != nil {
+		return err
+	}
+	to, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	// 区间移动拆成逐首移动，保持相对顺序
+	for i := 0; i < end-start; i++ {
+		from, dst := start+i, to+i
+		if to > start {
+			from, dst = start, to+end-start-1
+		}
+		if err := s.ctl.Move(from, dst); err != nil {
+			return errArg("Bad song index")
+		}
+	}
+	return nil
+}
+
+func (s *Server) cmdMoveID(_ *session, args []string) error {
+	if err := needArgs(args, 2, 2); err != nil {
+		return err
+	}
+	from, err := s.positionOfID(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	if err := s.ctl.Move(from, to); err != nil {
+		return errArg("Bad song index")
+	}
+	return nil
+}
+
+func (s *Server) cmdShuffle(*session, []string) error {
+	s.ctl.Shuffle()
+	return nil
+}
+
+// cmdPlaylist 旧式队列列表：“pos:file”
+func (s *Server) cmdPlaylist(sess *session, _ []string) error {
+	entries, _ := s.ctl.Entries()
+	for i, e := range entries {
+		fmt.Fprintf(sess.w, "%d:file: %s\n", i, songURI(&e.Song))
+	}
+	return nil
+}
+
+func (s *Server) cmdPlaylistInfo(sess *session, args []string) error {
+	if err := needArgs(args, 0, 1); err != nil {
+		return err
+	}
+	entries, _ := s.ctl.Entries()
+	start, end := 0, len(entries)
+	if len(args) == 1 {
+		var err error
+		if start, end, err = parseRange(args[0], len(entries)); err != nil {
+			return err
+		}
+		if start >= len(entries) && !strings.Contains(args[0], ":") {
+			return errArg("Bad song index")
+		}
+		end = min(end, len(entries))
+	}
+	for i := start; i < end; i++ {
+		sess.writeSong(&entries[i].Song, i, entries[i].ID)
+	}
+	return nil
+}
+
+func (s *Server) cmdPlaylistID(sess *session, args []string) error {
+	if err := needArgs(args, 0, 1); err != nil {
+		return err
+	}
+	entries, _ := s.ctl.Entries()
+	if len(args) == 0 {
+		return s.cmdPlaylistInfo(sess, nil)
+	}
+	pos, err := s.positionOfID(args[0])
+	if err != nil {
+		return err
+	}
+	if pos < len(entries) {
+		sess.writeSong(&entries[pos].Song, pos, entries[pos].ID)
+	}
+	return nil
+}
+
+// cmdPlChanges 返回自指定版本以来变化的条目。队列不记录逐条变更，版本不同时返回整个队列。
+func (s *Server) cmdPlChanges(sess *session, args []string) error {
+	if err := needArgs(args, 1, 2); err != nil {
+		return err
+	}
+	entries, version := s.ctl.Entries()
+	if args[0] == strconv.FormatUint(uint64(version), 10) {
+		return nil
+	}
+	for i := range entries {
+		sess.writeSong(&entries[i].Song, i, entries[i].ID)
+	}
+	return nil
+}
+
+func (s *Server) cmdPlChangesPosID(sess *session, args []string) error {
+	if err := needArgs(args, 1, 2); err != nil {
+		return err
+	}
+	entries, version := s.ctl.Entries()
+	if args[0] == strconv.FormatUint(uint64(version), 10) {
+		return nil
+	}
+	for i, e := range entries {
+		sess.kv("cpos", i)
+		sess.kv("Id", e.ID)
+	}
+	return nil
+}
+
+func (s *Server) queueFind(sess *session, args []string, exact bool) error {
+	f, _, err := parseFilter(args, exact)
+	if err != nil {
+		return err
+	}
+	entries, _ := s.ctl.Entries()
+	for i := range entries {
+		if f(&entries[i].Song) {
+			sess.writeSong(&entries[i].Song, i, entries[i].ID)
+		}
+	}
+	return nil
+}
+
+func (s *Server) cmdPlaylistFind(sess *session, args []string) error {
+	return s.queueFind(sess, args, true)
+}
+
+func (s *Server) cmdPlaylistSearch(sess *session, args []string) error {
+	return s.queueFind(sess, args, false)
+}
+
+// =========== 曲库 ===========
+
+func (s *Server) find(args []string, exact bool) ([]storage.Song, error) {
+	if len(args) == 0 {
+		return nil, errArg("incorrect arguments")
+	}
+	f, window, err := parseFilter(args, exact)
+	if err != nil {
+		return nil, err
+	}
+	return s.searchSongs(f, window)
+}
+
+func (s *Server) cmdFind(sess *session, args []string) error {
+	songs, err := s.find(args, true)
+	if err != nil {
+		return err
+	}
+	sess.writeSongs(songs)
+	return nil
+}
+
+func (s *Server) cmdSearch(sess *session, args []string) error {
+	songs, err := s.find(args, false)
+	if err != nil {
+		return err
+	}
+	sess.writeSongs(songs)
+	return nil
+}
+
+func (s *Server) cmdFindAdd(_ *session, args []string) error {
+	songs, err := s.find(args, true)
+	if err != nil {
+		return err
+	}
+	s.ctl.Add(songs, -1)
+	return nil
+}
+
+func (s *Server) cmdSearchAdd(_ *session, args []string) error {
+	songs, err := s.find(args, false)
+	if err != nil {
+		return err
+	}
+	s.ctl.Add(songs, -1)
+	return nil
+}
+
+// cmdList 列出标签的不同取值：list TYPE [FILTER...] [group GROUPTYPE...]
+func (s *Server) cmdList(sess *session, args []string) error {
+	if len(args) == 0 {
+		return errArg("incorrect arguments")
+	}
+	tag := args[0]
+	if _, ok := songTag(&storage.Song{}, tag); !ok {
+		return errArg("Unknown tag type: %s", tag)
+	}
+	rest := args[1:]
+	var groups []string
+	for i := 0; i < len(rest); i++ {
+		if strings.EqualFold(rest[i], "group") && i+1 < len(rest) {
+			groups = append(groups, rest[i+1])
+			rest = append(rest[:i:i], rest[i+2:]...)
+			i--
+		}
+	}
+	// 旧式语法：list album ARTIST
+	if strings.EqualFold(tag, "album") && len(rest) == 1 && !strings.HasPrefix(rest[0], "(") {
+		rest = []string{"artist", rest[0]}
+	}
+	f, _, err := parseFilter(rest, true)
+	if err != nil {
+		return err
+	}
+	songs, err := s.searchSongs(f, [2]int{-1, -1})
+	if err != nil {
+		return err
+	}
+
+	// 每行为 [分组值..., 标签值]，去重排序后依次输出
+	seen := make(map[string]bool)
+	var rows [][]string
+	for i := range songs {
+		values := make([]string, 0, len(groups)+1)
+		for _, g := range groups {
+			v, _ := songTag(&songs[i], g)
+			values = append(values, v)
+		}
+		v, _ := songTag(&songs[i], tag)
+		if v == "" {
+			continue
+		}
+		values = append(values, v)
+		key := strings.Join(values, "\x00")
+		if !seen[key] {
+			seen[key] = true
+			rows = append(rows, values)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return strings.Join(rows[i], "\x00") < strings.Join(rows[j], "\x00") })
+
+	names := make([]string, 0, len(groups)+1)
+	for _, g := range groups {
+		names = append(names, canonicalTag(g))
+	}
+	names = append(names, canonicalTag(tag))
+	var prev []string
+	for _, values := range rows {
+		for i, v := range values {
+			// 分组值与上一行相同时不重复输出
+			if i < len(groups) && prev != nil && prev[i] == v {
+				continue
+			}
+			sess.kv(names[i], v)
+		}
+		prev = values
+	}
+	return nil
+}
+
+func (s *Server) cmdCount(sess *session, args []string) error {
+	songs, err := s.find(args, true)
+	if err != nil {
+		return err
+	}
+	playtime := 0
+	for _, song := range songs {
+		playtime += song.Duration
+	}
+	sess.kv("songs", len(songs))
+	sess.kv("playtime", playtime)
+	return nil
+}
+
+// cmdLsInfo 浏览目录：输出直接子目录与歌曲；根目录额外列出存储的播放列表
+func (s *Server) cmdLsInfo(sess *session, args []string) error {
+	if err := needArgs(args, 0, 1); err != nil {
+		return err
+	}
+	dir := ""
+	if len(args) == 1 {
+		dir = strings.Trim(args[0], "/")
+	}
+	if dir != "" {
+		if song, err := s.findSongByURI(dir); err == nil {
+			sess.writeSong(song, -1, -1)
+			return nil
+		}
+	}
+	songs, err := s.songsUnder(dir)
+	if err != nil {
+		return err
+	}
+	if dir != "" && len(songs) == 0 {
+		return errNoExist("No such directory")
+	}
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	seenDirs := make(map[string]bool)
+	for i := range songs {
+		rel := strings.TrimPrefix(songURI(&songs[i]), prefix)
+		if sub, _, isDir := strings.Cut(rel, "/"); isDir {
+			if !seenDirs[sub] {
+				seenDirs[sub] = true
+				sess.kv("directory", prefix+sub)
+			}
+			continue
+		}
+		sess.writeSong(&songs[i], -1, -1)
+	}
+	if dir == "" {
+		return s.cmdListPlaylists(sess, nil)
+	}
+	return nil
+}
+
+func (s *Server) cmdListAll(sess *session, args []string) error {
+	return s.listAll(sess, args, false)
+}
+
+func (s *Server) cmdListAllInfo(sess *session, args []string) error {
+	return s.listAll(sess, args, true)
+}
+
+// listAll 递归列出目录下的全部歌曲（及途经的目录）
+func (s *Server) listAll(sess *session, args []string, info bool) error {
+	if err := needArgs(args, 0, 1); err != nil {
+		return err
+	}
+	dir := ""
+	if len(args) == 1 {
+		dir = strings.Trim(args[0], "/")
+	}
+	songs, err := s.songsUnder(dir)
+	if err != nil {
+		return err
+	}
+	seenDirs := make(map[string]bool)
+	for i := range songs {
+		uri := songURI(&songs[i])
+		for d := path.Dir(uri); d != "." && d != dir && d != "/"; d = path.Dir(d) {
+			if seenDirs[d] {
+				break
+			}
+			seenDirs[d] = true
+			sess.kv("directory", d)
+		}
+		if info {
+			sess.writeSong(&songs[i], -1, -1)
+		} else {
+			sess.kv("file", uri)
+		}
+	}
+	return nil
+}
+
+// =========== 存储的播放列表 ===========
+
+func (s *Server) cmdListPlaylists(sess *session, _ []string) error {
+	playlists, err := storage.ListPlaylists(s.db)
+	if err != nil {
+		return err
+	}
+	for _, p := range playlists {
+		sess.kv("playlist", p.Name)
+	}
+	return nil
+}
+
+// playlistByName 按名称查找播放列表
+func (s *Server) playlistByName(args []string) (*storage.Playlist, error) {
+	if err := needArgs(args, 1, 2); err != nil {
+		return nil, err
+	}
+	var p storage.Playlist
+	if err := s.db.Where("name = ?", args[0]).First(&p).Error; err != nil {
+		return nil, errNoExist("No such playlist")
+	}
+	return storage.GetPlaylist(s.db, p.ID)
+}
+
+func (s *Server) cmdListPlaylist(sess *session, args []string) error {
+	p, err := s.playlistByName(args)
+	if err != nil {
+		return err
+	}
+	for i := range p.Songs {
+		sess.kv("file", songURI(&p.Songs[i]))
+	}
+	return nil
+}
+
+func (s *Server) cmdListPlaylistInfo(sess *session, args []string) error {
+	p, err := s.playlistByName(args)
+	if err != nil {
+		return err
+	}
+	sess.writeSongs(p.Songs)
+	return nil
+}
+
+// cmdLoad 把存储的播放列表追加到队列
+func (s *Server) cmdLoad(_ *session, args []string) error {
+	p, err := s.playlistByName(args)
+	if err != nil {
+		return err
+	}
+	s.ctl.Add(p.Songs, -1)
+	return nil
+}
@@ -0,0 +1,430 @@
+package mpd
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// 本文件负责曲库与 MPD 概念之间的映射：歌曲 URI、标签、过滤表达式与目录浏览。
+
+// cueTrackPattern CUE 分轨 URI 的末段，如 track0003
+var cueTrackPattern = regexp.MustCompile(`^track(\d{4})$`)
+
+// uriOf 文件路径对应的 URI：统一为正斜杠并去掉开头的 /
+func uriOf(p string) string {
+	return strings.TrimPrefix(filepath.ToSlash(p), "/")
+}
+
+// songURI 歌曲的 URI；CUE 分轨为 “<cue 文件>/trackNNNN”
+func songURI(song *storage.Song) string {
+	if song.IsCueTrack() {
+		return fmt.Sprintf("%s/track%04d", uriOf(song.CueFile), song.TrackNum)
+	}
+	return uriOf(song.FilePath)
+}
+
+// pathCandidates URI 可能对应的数据库路径（Unix 绝对路径去掉了开头的 /）
+func pathCandidates(uri string) []string {
+	return []string{uri, "/" + uri, filepath.FromSlash(uri), filepath.FromSlash("/" + uri)}
+}
+
+// findSongByURI 按 URI 查找歌曲
+func (s *Server) findSongByURI(uri string) (*storage.Song, error) {
+	uri = strings.TrimPrefix(uri, "file://")
+	var song storage.Song
+	if dir, base := path.Split(uri); dir != "" {
+		if m := cueTrackPattern.FindStringSubmatch(base); m != nil {
+			track, _ := strconv.Atoi(m[1])
+			err := s.db.Where("cue_file IN ? AND track_num = ?", pathCandidates(strings.TrimSuffix(dir, "/")), track).
+				First(&song).Error
+			if err == nil {
+				return &song, nil
+			}
+		}
+	}
+	if err := s.db.Where("file_path IN ? AND (cue_file = '' OR cue_file IS NULL)", pathCandidates(uri)).First(&song).Error; err != nil {
+		return nil, errNoExist("No such song")
+	}
+	return &song, nil
+}
+
+// songsUnder 返回 URI 位于目录 dir 之下的歌曲（dir 为空表示整个曲库），按 URI 排序
+func (s *Server) songsUnder(dir string) ([]storage.Song, error) {
+	dir = strings.Trim(dir, "/")
+	var dirs []string
+	if dir != "" {
+		dirs = []string{dir, "/" + dir}
+	}
+	songs, err := storage.GetSongsUnderDir(s.db, dirs...)
+	if err != nil {
+		return nil, err
+	}
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	out := songs[:0]
+	for _, song := range songs {
+		if strings.HasPrefix(songURI(&song), prefix) {
+			out = append(out, song)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return songURI(&out[i]) < songURI(&out[j]) })
+	return out, nil
+}
+
+// resolveURI add/findadd 的目标：单首歌曲或目录下的全部歌曲
+func (s *Server) resolveURI(uri string) ([]storage.Song, error) {
+	uri = strings.Trim(uri, "/")
+	if uri != "" {
+		if song, err := s.findSongByURI(uri); err == nil {
+			return []storage.Song{*song}, nil
+		}
+	}
+	songs, err := s.songsUnder(uri)
+	if err != nil {
+		return nil, err
+	}
+	if len(songs) == 0 {
+		return nil, errNoExist("No such directory")
+	}
+	return songs, nil
+}
+
+// =========== 标签 ===========
+
+// tagTypes 支持的标签（tagtypes 命令输出）
+var tagTypes = []string{"Artist", "AlbumArtist", "Album", "Title", "Track", "Date"}
+
+// songTag 读取歌曲的标签值；tag 不区分大小写
+func songTag(song *storage.Song, tag string) (string, bool) {
+	switch strings.ToLower(tag) {
+	case "artist", "albumartist", "artistsort", "albumartistsort":
+		return song.Artist, true
+	case "album", "albumsort":
+		return song.Album, true
+	case "title":
+		return song.Title, true
+	case "track":
+		if song.TrackNum > 0 {
+			return strconv.Itoa(song.TrackNum), true
+		}
+		return "", true
+	case "date", "originaldate":
+		if song.Year > 0 {
+			return strconv.Itoa(song.Year), true
+		}
+		return "", true
+	case "genre", "composer", "performer", "disc", "comment":
+		return "", true
+	case "file":
+		return songURI(song), true
+	case "base":
+		return path.Dir(songURI(song)), true
+	case "filename":
+		return path.Base(songURI(song)), true
+	default:
+		return "", false
+	}
+}
+
+// canonicalTag 标签的规范写法（list 输出使用）
+func canonicalTag(tag string) string {
+	for _, t := range tagTypes {
+		if strings.EqualFold(t, tag) {
+			return t
+		}
+	}
+	if strings.EqualFold(tag, "file") {
+		return "file"
+	}
+	return tag
+}
+
+// =========== 过滤 ===========
+
+// filter 歌曲过滤条件
+type filter func(song *storage.Song) bool
+
+// tagMatcher 构造单个标签条件；exact 为 false 时按不区分大小写的子串匹配
+func tagMatcher(tag, value string, exact bool) (filter, error) {
+	if strings.EqualFold(tag, "any") {
+		return func(song *storage.Song) bool {
+			for _, t := range []string{"artist", "album", "title", "file"} {
+				v, _ := songTag(song, t)
+				if matchValue(v, value, exact) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	if _, ok := songTag(&storage.Song{}, tag); !ok {
+		return nil, errArg("Unknown tag type: %s", tag)
+	}
+	return func(song *storage.Song) bool {
+		v, _ := songTag(song, tag)
+		return matchValue(v, value, exact)
+	}, nil
+}
+
+func matchValue(v, want string, exact bool) bool {
+	if exact {
+		return v == want
+	}
+	return strings.Contains(strings.ToLower(v), strings.ToLower(want))
+}
+
+// parseFilter 解析 find/search/list 的过滤参数，支持旧式 “TAG VALUE ...” 成对参数
+// 与 MPD 0.21 起的过滤表达式（如 ((artist == 'a') AND (album contains 'b'))）。
+// 参数中的 sort/window 选项被剥离后返回 window 区间（未指定时为 -1,-1）。
+func parseFilter(args []string, exact bool) (filter, [2]int, error) {
+	window := [2]int{-1, -1}
+	var filters []filter
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.EqualFold(arg, "sort") && i+1 < len(args):
+			i++
+		case strings.EqualFold(arg, "window") && i+1 < len(args):
+			start, end, err := parseRange(args[i+1], -1)
+			if err != nil {
+				return nil, window, err
+			}
+			window = [2]int{start, end}
+			i++
+		case strings.HasPrefix(arg, "("):
+			f, err := parseExpression(arg)
+			if err != nil {
+				return nil, window, err
+			}
+			filters = append(filters, f)
+		case i+1 < len(args):
+			f, err := tagMatcher(arg, args[i+1], exact)
+			if err != nil {
+				return nil, window, err
+			}
+			filters = append(filters, f)
+			i++
+		default:
+			return nil, window, errArg("incorrect arguments")
+		}
+	}
+	return func(song *storage.Song) bool {
+		for _, f := range filters {
+			if !f(song) {
+				return false
+			}
+		}
+		return true
+	}, window, nil
+}
+
+// exprParser 过滤表达式解析器
+type exprParser struct {
+	s   string
+	pos int
+}
+
+// parseExpression 解析过滤表达式，支持 ==、!=、contains、starts_with、=~，AND 与 ! 取反
+func parseExpression(s string) (filter, error) {
+	p := &exprParser{s: s}
+	f, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, errArg("Unparsed garbage after expression")
+	}
+	return f, nil
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) expect(c byte) error {
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != c {
+		return errArg("'%c' expected", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *exprParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] != ' ' && p.s[p.pos] != '(' && p.s[p.pos] != ')' {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *exprParser) quoted() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) || (p.s[p.pos] != '\'' && p.s[p.pos] != '"') {
+		return "", errArg("quoted string expected")
+	}
+	quote := p.s[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '\\' && p.pos < len(p.s):
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		case c == quote:
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errArg("closing quote expected")
+}
+
+func (p *exprParser) expr() (filter, error) {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == '!' {
+		p.pos++
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return func(song *storage.Song) bool { return !inner(song) }, nil
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) && (p.s[p.pos] == '(' || p.s[p.pos] == '!') {
+		// 复合表达式：(expr AND expr ...)
+		parts := []filter{}
+		for {
+			f, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, f)
+			p.skipSpace()
+			if p.pos < len(p.s) && p.s[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			if op := p.word(); op != "AND" {
+				return nil, errArg("'AND' expected, got %q", op)
+			}
+		}
+		return func(song *storage.Song) bool {
+			for _, f := range parts {
+				if !f(song) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+
+	tag := p.word()
+	op := p.word()
+	value, err := p.quoted()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	var match func(v string) bool
+	switch op {
+	case "==":
+		match = func(v string) bool { return v == value }
+	case "!=":
+		match = func(v string) bool { return v != value }
+	case "contains":
+		match = func(v string) bool { return strings.Contains(strings.ToLower(v), strings.ToLower(value)) }
+	case "starts_with":
+		match = func(v string) bool { return strings.HasPrefix(v, value) }
+	case "=~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, errArg("invalid regular expression: %v", err)
+		}
+		match = re.MatchString
+	default:
+		return nil, errArg("unknown filter operator: %s", op)
+	}
+	if strings.EqualFold(tag, "any") {
+		return func(song *storage.Song) bool {
+			for _, t := range []string{"artist", "album", "title", "file"} {
+				if v, _ := songTag(song, t); match(v) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	if _, ok := songTag(&storage.Song{}, tag); !ok {
+		return nil, errArg("Unknown tag type: %s", tag)
+	}
+	return func(song *storage.Song) bool {
+		v, _ := songTag(song, tag)
+		return match(v)
+	}, nil
+}
+
+// parseRange 解析 “START:END”、“START:” 或单个位置；n>=0 时 END 缺省为 n，否则为 -1
+func parseRange(arg string, n int) (int, int, error) {
+	startStr, endStr, isRange := strings.Cut(arg, ":")
+	start, err := strconv.Atoi(startStr)
+	if err != nil || start < 0 {
+		return 0, 0, errArg("Integer or range expected: %s", arg)
+	}
+	if !isRange {
+		return start, start + 1, nil
+	}
+	if endStr == "" {
+		return start, n, nil
+	}
+	end, err := strconv.Atoi(endStr)
+	if err != nil || end < start {
+		return 0, 0, errArg("Integer or range expected: %s", arg)
+	}
+	return start, end, nil
+}
+
+// searchSongs 按过滤条件查找曲库歌曲，window 为 -1 时不截取
+func (s *Server) searchSongs(f filter, window [2]int) ([]storage.Song, error) {
+	songs, err := s.songsUnder("")
+	if err != nil {
+		return nil, err
+	}
+	out := songs[:0]
+	for _, song := range songs {
+		if f(&song) {
+			out = append(out, song)
+		}
+	}
+	if window[0] >= 0 {
+		start, end := window[0], window[1]
+		if end < 0 || end > len(out) {
+			end = len(out)
+		}
+		if start > end {
+			start = end
+		}
+		out = out[start:end]
+	}
+	return out, nil
+}
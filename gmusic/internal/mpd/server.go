@@ -0,0 +1,496 @@
+// Package mpd 实现 MPD（Music Player Daemon）协议的常用子集，使 ncmpcpp、mpc 等终端客户端
+// 可以通过 TCP 控制 GMusic 的服务端播放器。
+//
+// 协议命令直接作用于 playback.Controller（队列）与其底层 player.Player，
+// 因此与 HTTP API 共享同一个播放状态；通过 HTTP 触发的变化也会以 idle 通知推送给 MPD 客户端。
+//
+// 歌曲 URI 为数据库中的文件路径（去掉开头的 /，统一为正斜杠）；CUE 分轨沿用 MPD 的约定，
+// 写作 “<cue 文件>/trackNNNN”。
+package mpd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yudongyouqing/GMusic/internal/playback"
+	"gorm.io/gorm"
+)
+
+// protocolVersion 握手时声明的协议版本
+const protocolVersion = "0.23.5"
+
+// pollInterval 检测播放状态变化（用于 idle 通知）的轮询间隔
+const pollInterval = 250 * time.Millisecond
+
+// Config 监听配置
+type Config struct {
+	Addr     string // 监听地址，如 :6600；为空表示不启用
+	Password string // 可选密码，设置后客户端需先发送 password 命令
+}
+
+// ConfigFromEnv 从环境变量 GMUSIC_MPD_ADDR、GMUSIC_MPD_PASSWORD 读取配置
+func ConfigFromEnv() Config {
+	return Config{Addr: os.Getenv("GMUSIC_MPD_ADDR"), Password: os.Getenv("GMUSIC_MPD_PASSWORD")}
+}
+
+// Server MPD 协议服务
+type Server struct {
+	db       *gorm.DB
+	ctl      *playback.Controller
+	password string
+	started  time.Time
+
+	mu       sync.Mutex
+	sessions map[*session]struct{}
+	listener net.Listener
+}
+
+// NewServer 创建服务
+func NewServer(db *gorm.DB, ctl *playback.Controller, password string) *Server {
+	return &Server{
+		db:       db,
+		ctl:      ctl,
+		password: password,
+		started:  time.Now(),
+		sessions: make(map[*session]struct{}),
+	}
+}
+
+// Start 按配置启动服务（Addr 为空时什么都不做），监听在后台 goroutine 中运行
+func Start(db *gorm.DB, ctl *playback.Controller, cfg Config) (*Server, error) {
+	if cfg.Addr == "" {
+		return nil, nil
+	}
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("MPD 服务监听失败: %w", err)
+	}
+	s := NewServer(db, ctl, cfg.Password)
+	go func() {
+		if err := s.Serve(ln); err != nil && !errors.Is(err, net.ErrClosed) {
+			fmt.Printf("MPD 服务异常退出: %v\n", err)
+		}
+	}()
+	return s, nil
+}
+
+// Serve 在给定监听器上接受连接，直到监听器关闭
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.listener = ln
+	s.mu.Unlock()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.watch(stop)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// Addr 实际监听地址（用于 :0 随机端口）
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close 关闭监听器与所有连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	ln := s.listener
+	sessions := make([]*session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+	for _, sess := range sessions {
+		_ = sess.conn.Close()
+	}
+	if ln != nil {
+		return ln.Close()
+	}
+	return nil
+}
+
+// notify 向所有连接广播子系统变化
+func (s *Server) notify(subsystems ...string) {
+	if len(subsystems) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sess := range s.sessions {
+		sess.addEvents(subsystems)
+	}
+}
+
+// snapshot 用于检测变化的播放状态摘要
+type snapshot struct {
+	state    string
+	entryID  int
+	position float64
+	volume   int
+	version  uint32
+	songs    int64
+	taken    time.Time
+}
+
+// watch 轮询播放器与队列状态，把变化转换为 idle 事件。
+// 这样无论变化来自 MPD 命令、HTTP API 还是自然播放结束，客户端都能收到通知。
+func (s *Server) watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	prev := s.snapshot(true)
+	for tick := 1; ; tick++ {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		// 曲库规模变化（扫描）检测成本较高，降低频率
+		cur := s.snapshot(tick%20 == 0)
+		if cur.songs < 0 {
+			cur.songs = prev.songs
+		}
+		var changed []string
+		if cur.state != prev.state || cur.entryID != prev.entryID || seeked(prev, cur) {
+			changed = append(changed, "player")
+		}
+		if cur.volume != prev.volume {
+			changed = append(changed, "mixer")
+		}
+		if cur.version != prev.version {
+			changed = append(changed, "playlist")
+		}
+		if cur.songs != prev.songs {
+			changed = append(changed, "database")
+		}
+		s.notify(changed...)
+		prev = cur
+	}
+}
+
+// seeked 判断两次快照之间播放位置是否发生了跳转
+func seeked(prev, cur snapshot) bool {
+	if cur.state == "stop" || cur.entryID != prev.entryID {
+		return false
+	}
+	expected := prev.position
+	if prev.state == "play" {
+		expected += cur.taken.Sub(prev.taken).Seconds()
+	}
+	diff := cur.position - expected
+	return diff > 1.5 || diff < -1.5
+}
+
+func (s *Server) snapshot(countSongs bool) snapshot {
+	snap := snapshot{state: s.state(), entryID: -1, songs: -1, taken: time.Now()}
+	if p := s.ctl.Player(); p != nil {
+		snap.position = p.GetCurrentPosition()
+		snap.volume = int(p.GetVolume()*100 + 0.5)
+	}
+	if _, index := s.ctl.Current(); index >= 0 {
+		entries, _ := s.ctl.Entries()
+		if index < len(entries) {
+			snap.entryID = entries[index].ID
+		}
+	}
+	snap.version = s.ctl.Version()
+	if countSongs {
+		_ = s.db.Table("songs").Count(&snap.songs).Error
+	}
+	return snap
+}
+
+// state 播放状态：play / pause / stop
+func (s *Server) state() string {
+	p := s.ctl.Player()
+	switch {
+	case p == nil:
+		return "stop"
+	case p.IsPlaying():
+		return "play"
+	case p.IsPaused():
+		return "pause"
+	default:
+		return "stop"
+	}
+}
+
+// =========== 连接 ===========
+
+// session 一个客户端连接
+type session struct {
+	conn   net.Conn
+	w      *bufio.Writer
+	authed bool
+
+	mu      sync.Mutex
+	pending map[string]bool // 自上次 idle 以来发生变化的子系统
+	wake    chan struct{}
+}
+
+func (sess *session) addEvents(subsystems []string) {
+	sess.mu.Lock()
+	for _, name := range subsystems {
+		sess.pending[name] = true
+	}
+	sess.mu.Unlock()
+	select {
+	case sess.wake <- struct{}{}:
+	default:
+	}
+}
+
+// takeEvents 取出并清除 filter 中关注的待通知子系统（filter 为空表示全部）
+func (sess *session) takeEvents(filter []string) []string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	var out []string
+	for _, name := range subsystemOrder {
+		if !sess.pending[name] {
+			continue
+		}
+		if len(filter) > 0 && !contains(filter, name) {
+			continue
+		}
+		out = append(out, name)
+		delete(sess.pending, name)
+	}
+	return out
+}
+
+// subsystemOrder idle 通知的输出顺序
+var subsystemOrder = []string{"database", "update", "stored_playlist", "playlist", "player", "mixer", "output", "options"}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handle(conn net.Conn) {
+	sess := &session{
+		conn:    conn,
+		w:       bufio.NewWriter(conn),
+		authed:  s.password == "",
+		pending: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+	}
+	s.mu.Lock()
+	s.sessions[sess] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, sess)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	// 读取放在独立 goroutine 中，idle 等待期间仍能收到 noidle
+	lines := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(lines)
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			select {
+			case lines <- strings.TrimRight(line, "\r\n"):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	fmt.Fprintf(sess.w, "OK MPD %s\n", protocolVersion)
+	_ = sess.w.Flush()
+
+	var list []string // command list 中累积的命令
+	inList, listOK := false, false
+	for line := range lines {
+		switch {
+		case line == "command_list_begin" || line == "command_list_ok_begin":
+			inList, listOK, list = true, line == "command_list_ok_begin", nil
+			continue
+		case inList && line != "command_list_end":
+			list = append(list, line)
+			continue
+		case inList:
+			inList = false
+			s.runList(sess, list, listOK)
+		default:
+			args, err := splitArgs(line)
+			if err != nil {
+				sess.ack(ackErrorArg, 0, "", err.Error())
+			} else if len(args) > 0 && args[0] == "idle" {
+				if !s.idle(sess, args[1:], lines) {
+					return
+				}
+			} else if len(args) > 0 && args[0] == "close" {
+				return
+			} else if len(args) > 0 && args[0] == "noidle" {
+				// idle 已先一步返回时客户端发来的 noidle：按 MPD 的行为静默忽略
+				continue
+			} else {
+				s.runList(sess, []string{line}, false)
+			}
+		}
+		if sess.w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// runList 依次执行命令，遇到错误时停止并输出 ACK；listOK 时每条命令后输出 list_OK
+func (s *Server) runList(sess *session, lines []string, listOK bool) {
+	for i, line := range lines {
+		args, err := splitArgs(line)
+		if err != nil {
+			sess.ack(ackErrorArg, i, "", err.Error())
+			return
+		}
+		if len(args) == 0 {
+			sess.ack(ackErrorUnknown, i, "", "No command given")
+			return
+		}
+		if err := s.exec(sess, args[0], args[1:]); err != nil {
+			var ack *ackError
+			if !errors.As(err, &ack) {
+				ack = &ackError{code: ackErrorSystem, msg: err.Error()}
+			}
+			sess.ack(ack.code, i, args[0], ack.msg)
+			return
+		}
+		if listOK {
+			sess.w.WriteString("list_OK\n")
+		}
+	}
+	sess.w.WriteString("OK\n")
+}
+
+// idle 阻塞直到关注的子系统发生变化或收到 noidle；返回 false 表示连接已断开
+func (s *Server) idle(sess *session, filter []string, lines <-chan string) bool {
+	if !sess.authed {
+		sess.ack(ackErrorPermission, 0, "idle", "you don't have permission for \"idle\"")
+		return sess.w.Flush() == nil
+	}
+	for {
+		if changed := sess.takeEvents(filter); len(changed) > 0 {
+			for _, name := range changed {
+				fmt.Fprintf(sess.w, "changed: %s\n", name)
+			}
+			sess.w.WriteString("OK\n")
+			return sess.w.Flush() == nil
+		}
+		select {
+		case <-sess.wake:
+		case line, ok := <-lines:
+			if !ok {
+				return false
+			}
+			if strings.TrimSpace(line) == "noidle" {
+				sess.w.WriteString("OK\n")
+				return sess.w.Flush() == nil
+			}
+			// idle 期间只允许 noidle，其他命令按协议视为错误并断开
+			return false
+		}
+	}
+}
+
+// =========== 错误 ===========
+
+// MPD ACK 错误码
+const (
+	ackErrorArg        = 2
+	ackErrorPassword   = 3
+	ackErrorPermission = 4
+	ackErrorUnknown    = 5
+	ackErrorNoExist    = 50
+	ackErrorSystem     = 52
+)
+
+// ackError 命令执行失败，以 ACK 行返回给客户端
+type ackError struct {
+	code int
+	msg  string
+}
+
+func (e *ackError) Error() string { return e.msg }
+
+func errArg(format string, args ...any) error {
+	return &ackError{code: ackErrorArg, msg: fmt.Sprintf(format, args...)}
+}
+
+func errNoExist(format string, args ...any) error {
+	return &ackError{code: ackErrorNoExist, msg: fmt.Sprintf(format, args...)}
+}
+
+func (sess *session) ack(code, index int, command, msg string) {
+	fmt.Fprintf(sess.w, "ACK [%d@%d] {%s} %s\n", code, index, command, msg)
+}
+
+// splitArgs 按 MPD 规则拆分命令行：空白分隔，双引号内可用反斜杠转义
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg, quoted, escaped := false, false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			if quoted {
+				args = append(args, cur.String())
+				cur.Reset()
+				quoted, inArg = false, false
+			} else if !inArg {
+				quoted, inArg = true, true
+			} else {
+				cur.WriteRune(r)
+			}
+		case !quoted && (r == ' ' || r == '\t'):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("Missing closing '\"'")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,25 @@
+// Package mpris 在 Linux 桌面的 D-Bus 会话总线上导出 MPRIS 接口（org.mpris.MediaPlayer2 与
+// org.mpris.MediaPlayer2.Player），使媒体键、桌面的“正在播放”小部件等可以控制服务端播放器。
+//
+// 与 MPD 服务相同，接口直接作用于 playback.Controller 及其底层 player.Player；
+// 播放状态、当前歌曲与音量通过轮询检测变化，并以 PropertiesChanged 信号发布。
+// 非 Linux 平台上 Start 为空操作。
+package mpris
+
+import (
+	"os"
+	"time"
+)
+
+// pollInterval 检测播放状态变化的轮询间隔
+const pollInterval = 250 * time.Millisecond
+
+// Config MPRIS 配置
+type Config struct {
+	Enabled bool
+}
+
+// ConfigFromEnv 存在会话总线（DBUS_SESSION_BUS_ADDRESS）时默认启用；设置 GMUSIC_MPRIS=0 可关闭
+func ConfigFromEnv() Config {
+	return Config{Enabled: os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" && os.Getenv("GMUSIC_MPRIS") != "0"}
+}
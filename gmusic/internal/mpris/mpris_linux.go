@@ -0,0 +1,436 @@
+package mpris
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/yudongyouqing/GMusic/internal/playback"
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+const (
+	busName     = "org.mpris.MediaPlayer2.gmusic"
+	objectPath  = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	rootIface   = "org.mpris.MediaPlayer2"
+	playerIface = "org.mpris.MediaPlayer2.Player"
+
+	// noTrack 规范约定的“无当前歌曲”轨道 ID
+	noTrack = dbus.ObjectPath("/org/mpris/MediaPlayer2/TrackList/NoTrack")
+)
+
+// Server MPRIS 服务
+type Server struct {
+	conn  *dbus.Conn
+	ctl   *playback.Controller
+	p     *player.Player
+	props *prop.Properties
+
+	mu   sync.Mutex
+	last snapshot
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// snapshot 用于检测变化的播放状态
+type snapshot struct {
+	status   string
+	songID   uint
+	index    int
+	size     int
+	volume   float32
+	position float64
+	at       time.Time
+}
+
+// Start 连接会话总线并导出 MPRIS 对象（未启用时什么都不做）。
+// 总线名已被占用（多个实例）时按规范追加 .instance<pid> 后缀。
+func Start(ctl *playback.Controller, cfg Config) (*Server, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	p := ctl.Player()
+	if p == nil {
+		return nil, errors.New("播放器未初始化")
+	}
+	conn, err := dbus.SessionBusPrivateNoAutoStartup()
+	if err != nil {
+		return nil, fmt.Errorf("连接 D-Bus 会话总线失败: %w", err)
+	}
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("D-Bus 认证失败: %w", err)
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("D-Bus 握手失败: %w", err)
+	}
+
+	s := &Server{conn: conn, ctl: ctl, p: p, done: make(chan struct{})}
+	if err := s.export(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	name := busName
+	reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+	if err == nil && reply != dbus.RequestNameReplyPrimaryOwner {
+		name = fmt.Sprintf("%s.instance%d", busName, os.Getpid())
+		reply, err = conn.RequestName(name, dbus.NameFlagDoNotQueue)
+	}
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, fmt.Errorf("无法获取总线名 %s: %v", name, err)
+	}
+
+	go s.watch()
+	return s, nil
+}
+
+// Close 释放总线名并断开连接
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// export 导出两个接口的方法、属性与内省数据
+func (s *Server) export() error {
+	root := &rootObject{}
+	pl := &playerObject{s: s}
+	st := s.take()
+	props, err := prop.Export(s.conn, objectPath, prop.Map{
+		rootIface: {
+			"CanQuit":             {Value: false, Emit: prop.EmitConst},
+			"CanRaise":            {Value: false, Emit: prop.EmitConst},
+			"HasTrackList":        {Value: false, Emit: prop.EmitConst},
+			"Identity":            {Value: "GMusic", Emit: prop.EmitConst},
+			"SupportedUriSchemes": {Value: []string{}, Emit: prop.EmitConst},
+			"SupportedMimeTypes":  {Value: []string{}, Emit: prop.EmitConst},
+		},
+		playerIface: {
+			"PlaybackStatus": {Value: st.status, Emit: prop.EmitTrue},
+			"Rate":           {Value: 1.0, Emit: prop.EmitConst},
+			"MinimumRate":    {Value: 1.0, Emit: prop.EmitConst},
+			"MaximumRate":    {Value: 1.0, Emit: prop.EmitConst},
+			"Metadata":       {Value: s.metadata(), Emit: prop.EmitTrue},
+			"Volume":         {Value: float64(st.volume), Writable: true, Emit: prop.EmitTrue, Callback: s.setVolume},
+			"Position":       {Value: int64(0), Emit: prop.EmitFalse},
+			"CanGoNext":      {Value: st.index >= 0 && st.index < st.size-1, Emit: prop.EmitTrue},
+			"CanGoPrevious":  {Value: st.index > 0, Emit: prop.EmitTrue},
+			"CanPlay":        {Value: st.size > 0, Emit: prop.EmitTrue},
+			"CanPause":       {Value: true, Emit: prop.EmitConst},
+			"CanSeek":        {Value: true, Emit: prop.EmitConst},
+			"CanControl":     {Value: true, Emit: prop.EmitConst},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("导出 MPRIS 属性失败: %w", err)
+	}
+	s.props = props
+	s.last = st
+
+	if err := s.conn.Export(root, objectPath, rootIface); err != nil {
+		return err
+	}
+	if err := s.conn.ExportMethodTable(pl.methods(), objectPath, playerIface); err != nil {
+		return err
+	}
+	node := &introspect.Node{
+		Name: string(objectPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: rootIface, Methods: introspect.Methods(root), Properties: props.Introspection(rootIface)},
+			{
+				Name:       playerIface,
+				Methods:    playerMethods,
+				Properties: props.Introspection(playerIface),
+				Signals:    []introspect.Signal{{Name: "Seeked", Args: []introspect.Arg{{Name: "Position", Type: "x"}}}},
+			},
+		},
+	}
+	return s.conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+}
+
+// take 读取当前状态
+func (s *Server) take() snapshot {
+	st := snapshot{status: "Stopped", index: -1, volume: s.p.GetVolume(), position: s.p.GetCurrentPosition(), at: time.Now()}
+	switch {
+	case s.p.IsPlaying():
+		st.status = "Playing"
+	case s.p.IsPaused():
+		st.status = "Paused"
+	}
+	song, index := s.ctl.Current()
+	if song != nil {
+		st.songID = song.ID
+	}
+	st.index = index
+	st.size = s.ctl.Len()
+	return st
+}
+
+// watch 轮询状态变化并发布 PropertiesChanged；位置跳变超过正常播放进度时发出 Seeked
+func (s *Server) watch() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		st := s.take()
+		s.mu.Lock()
+		last := s.last
+		s.last = st
+		s.mu.Unlock()
+
+		s.props.SetMust(playerIface, "Position", int64(st.position*1e6))
+		if st.songID != last.songID {
+			s.props.SetMust(playerIface, "Metadata", s.metadata())
+		}
+		if st.status != last.status {
+			s.props.SetMust(playerIface, "PlaybackStatus", st.status)
+		}
+		if st.volume != last.volume {
+			s.props.SetMust(playerIface, "Volume", float64(st.volume))
+		}
+		if st.index != last.index || st.size != last.size {
+			s.props.SetMust(playerIface, "CanGoNext", st.index >= 0 && st.index < st.size-1)
+			s.props.SetMust(playerIface, "CanGoPrevious", st.index > 0)
+			s.props.SetMust(playerIface, "CanPlay", st.size > 0)
+		}
+		if st.songID == last.songID && st.status != "Stopped" {
+			expected := last.position
+			if last.status == "Playing" {
+				expected += st.at.Sub(last.at).Seconds()
+			}
+			if math.Abs(st.position-expected) > 1 {
+				s.emitSeeked(st.position)
+			}
+		}
+	}
+}
+
+// emitSeeked 发出 Seeked 信号（位置单位为微秒）
+func (s *Server) emitSeeked(sec float64) {
+	_ = s.conn.Emit(objectPath, playerIface+".Seeked", int64(sec*1e6))
+}
+
+// trackID 歌曲的 MPRIS 轨道 ID
+func trackID(song *storage.Song) dbus.ObjectPath {
+	if song == nil {
+		return noTrack
+	}
+	return dbus.ObjectPath(fmt.Sprintf("/org/gmusic/track/%d", song.ID))
+}
+
+// fileURL 将本地路径转换为 file:// URL；已是 http(s) 地址时原样返回
+func fileURL(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// metadata 构造当前歌曲的 Metadata 属性
+func (s *Server) metadata() map[string]dbus.Variant {
+	song, _ := s.ctl.Current()
+	m := map[string]dbus.Variant{"mpris:trackid": dbus.MakeVariant(trackID(song))}
+	if song == nil {
+		return m
+	}
+	length := float64(song.Duration)
+	if song.Duration <= 0 {
+		length = s.p.GetDuration()
+	}
+	m["mpris:length"] = dbus.MakeVariant(int64(length * 1e6))
+	m["xesam:title"] = dbus.MakeVariant(song.Title)
+	m["xesam:url"] = dbus.MakeVariant(fileURL(song.FilePath))
+	if song.Artist != "" {
+		m["xesam:artist"] = dbus.MakeVariant([]string{song.Artist})
+	}
+	if song.Album != "" {
+		m["xesam:album"] = dbus.MakeVariant(song.Album)
+	}
+	if song.TrackNum > 0 {
+		m["xesam:trackNumber"] = dbus.MakeVariant(int32(song.TrackNum))
+	}
+	if song.CoverURL != "" {
+		m["mpris:artUrl"] = dbus.MakeVariant(fileURL(song.CoverURL))
+	}
+	return m
+}
+
+// setVolume 处理客户端写入 Volume 属性
+func (s *Server) setVolume(c *prop.Change) *dbus.Error {
+	v, ok := c.Value.(float64)
+	if !ok {
+		return prop.ErrInvalidArg
+	}
+	vol := float32(math.Max(0, math.Min(1, v)))
+	s.p.SetVolume(vol)
+	// 属性值由 prop 包更新并发出信号，同步快照以免轮询再发一次
+	s.mu.Lock()
+	s.last.volume = vol
+	s.mu.Unlock()
+	return nil
+}
+
+// rootObject org.mpris.MediaPlayer2 的方法。服务端没有窗口，也不允许远程退出，两者均为空操作。
+type rootObject struct{}
+
+func (*rootObject) Raise() *dbus.Error { return nil }
+func (*rootObject) Quit() *dbus.Error  { return nil }
+
+// playerObject org.mpris.MediaPlayer2.Player 的方法
+type playerObject struct{ s *Server }
+
+// methods 方法表：Go 方法名不能直接使用 Seek（与 io.Seeker 签名冲突），因此按表导出
+func (o *playerObject) methods() map[string]any {
+	return map[string]any{
+		"Next":        o.next,
+		"Previous":    o.previous,
+		"Pause":       o.pause,
+		"PlayPause":   o.playPause,
+		"Stop":        o.stop,
+		"Play":        o.play,
+		"Seek":        o.seek,
+		"SetPosition": o.setPosition,
+		"OpenUri":     o.openUri,
+	}
+}
+
+// playerMethods Player 接口方法的内省数据
+var playerMethods = []introspect.Method{
+	{Name: "Next"},
+	{Name: "Previous"},
+	{Name: "Pause"},
+	{Name: "PlayPause"},
+	{Name: "Stop"},
+	{Name: "Play"},
+	{Name: "Seek", Args: []introspect.Arg{{Name: "Offset", Type: "x", Direction: "in"}}},
+	{Name: "SetPosition", Args: []introspect.Arg{{Name: "TrackId", Type: "o", Direction: "in"}, {Name: "Position", Type: "x", Direction: "in"}}},
+	{Name: "OpenUri", Args: []introspect.Arg{{Name: "Uri", Type: "s", Direction: "in"}}},
+}
+
+// next 下一首；已是最后一首时为空操作
+func (o *playerObject) next() *dbus.Error {
+	return dbusError(ignoreEmptyQueue(o.s.ctl.Next()))
+}
+
+// previous 上一首；已是第一首时从头播放
+func (o *playerObject) previous() *dbus.Error {
+	_, err := o.s.ctl.Previous()
+	if errors.Is(err, playback.ErrEmptyQueue) {
+		return dbusError(o.s.p.SeekTo(0))
+	}
+	return dbusError(err)
+}
+
+func (o *playerObject) pause() *dbus.Error {
+	o.s.p.Pause()
+	return nil
+}
+
+func (o *playerObject) playPause() *dbus.Error {
+	if o.s.p.IsPlaying() {
+		o.s.p.Pause()
+		return nil
+	}
+	return o.play()
+}
+
+func (o *playerObject) stop() *dbus.Error {
+	o.s.p.Stop()
+	return nil
+}
+
+// play 暂停时继续播放；已停止时从队列当前位置重新播放
+func (o *playerObject) play() *dbus.Error {
+	switch {
+	case o.s.p.IsPlaying():
+		return nil
+	case o.s.p.IsPaused():
+		o.s.p.Resume()
+		return nil
+	}
+	if _, index := o.s.ctl.Current(); index >= 0 {
+		_, err := o.s.ctl.PlayAt(index)
+		return dbusError(err)
+	}
+	return nil
+}
+
+// seek 相对跳转 offset 微秒；越过歌曲末尾时切到下一首
+func (o *playerObject) seek(offset int64) *dbus.Error {
+	if !o.s.p.IsPlaying() && !o.s.p.IsPaused() {
+		return nil
+	}
+	pos := o.s.p.GetCurrentPosition() + float64(offset)/1e6
+	if d := o.s.p.GetDuration(); d > 0 && pos >= d {
+		return o.next()
+	}
+	return o.seekTo(math.Max(pos, 0))
+}
+
+// setPosition 跳转到绝对位置；trackID 不是当前歌曲或位置越界时按规范忽略
+func (o *playerObject) setPosition(track dbus.ObjectPath, position int64) *dbus.Error {
+	song, _ := o.s.ctl.Current()
+	pos := float64(position) / 1e6
+	if song == nil || track != trackID(song) || pos < 0 || (o.s.p.GetDuration() > 0 && pos > o.s.p.GetDuration()) {
+		return nil
+	}
+	return o.seekTo(pos)
+}
+
+// openUri 不支持：SupportedUriSchemes 为空
+func (o *playerObject) openUri(string) *dbus.Error {
+	return dbus.NewError("org.mpris.MediaPlayer2.GMusic.Error.NotSupported", []any{"不支持打开 URI"})
+}
+
+// seekTo 跳转并立即发出 Seeked，同时更新快照以免轮询重复发出
+func (o *playerObject) seekTo(sec float64) *dbus.Error {
+	if err := o.s.p.SeekTo(sec); err != nil {
+		return dbusError(err)
+	}
+	o.s.mu.Lock()
+	o.s.last.position, o.s.last.at = sec, time.Now()
+	o.s.mu.Unlock()
+	o.s.emitSeeked(sec)
+	return nil
+}
+
+// ignoreEmptyQueue 忽略队列边界错误（对应的 CanGoNext/CanGoPrevious 已为 false）
+func ignoreEmptyQueue(_ *storage.Song, err error) error {
+	if errors.Is(err, playback.ErrEmptyQueue) {
+		return nil
+	}
+	return err
+}
+
+// dbusError 将错误转换为 D-Bus 错误回复
+func dbusError(err error) *dbus.Error {
+	if err == nil {
+		return nil
+	}
+	return dbus.MakeFailedError(err)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package mpris
+
+import "github.com/yudongyouqing/GMusic/internal/playback"
+
+// Server 非 Linux 平台上的占位类型
+type Server struct{}
+
+// Start 非 Linux 平台没有 MPRIS，什么都不做
+func Start(*playback.Controller, Config) (*Server, error) { return nil, nil }
+
+// Close 空操作
+func (s *Server) Close() error { return nil }
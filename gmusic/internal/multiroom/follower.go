@@ -0,0 +1,447 @@
+package multiroom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yudongyouqing/GMusic/internal/player"
+)
+
+const (
+	// queueSize 从机收到但尚未到发声时间的音频块上限
+	queueSize = 1024
+	// syncTolerance 本机输出与目标发声时间的偏差在此范围内不做修正，避免频繁插入静音或裁剪造成杂音
+	syncTolerance = 2 * time.Millisecond
+	// clockSamples 时钟同步保留的样本数，取其中往返时间最短的一次
+	clockSamples = 8
+	// pingBurst/pingBurstInterval 连接建立后快速同步时钟
+	pingBurst         = 8
+	pingBurstInterval = 100 * time.Millisecond
+	// pingInterval 之后持续校准的间隔
+	pingInterval = 2 * time.Second
+	// maxBackoff 断线重连的最长等待
+	maxBackoff = 10 * time.Second
+)
+
+// errLeft 主机要求本机离开
+var errLeft = errors.New("已被主机移出房间组")
+
+// FollowerStatus 从机同步状态
+type FollowerStatus struct {
+	Following bool   `json:"following"`
+	Leader    string `json:"leader,omitempty"`
+	Room      string `json:"room"`
+	Connected bool   `json:"connected"`
+	// OffsetMs 主机时钟减本机时钟；RTTMs 为所用样本的往返时间
+	OffsetMs float64 `json:"offset_ms"`
+	RTTMs    float64 `json:"rtt_ms"`
+	// Played 已写入设备的块数；Late 整块迟到被丢弃的块数；Unsynced 时钟尚未同步时被丢弃的块数
+	Played   int64 `json:"played"`
+	Late     int64 `json:"late"`
+	Unsynced int64 `json:"unsynced"`
+	// PaddedMs/TrimmedMs 为对齐主机累计插入的静音与裁掉的音频时长
+	PaddedMs  float64 `json:"padded_ms"`
+	TrimmedMs float64 `json:"trimmed_ms"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// chunk 一块待播放的音频
+type chunk struct {
+	at  int64 // 主机时钟下的发声时间（Unix 纳秒）
+	pcm []byte
+}
+
+// Follower 多房间从机：连接主机、同步时钟并按时间戳把 PCM 写入本机设备。
+// 房间音量即本机播放器音量。
+type Follower struct {
+	p           *player.Player
+	defaultRoom string
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+	status  FollowerStatus
+	conn    *websocket.Conn
+	writeMu sync.Mutex // 连接上的写操作（ping 与音量上报）互斥
+}
+
+// NewFollower 创建从机；room 为未指定房间名时使用的默认值
+func NewFollower(p *player.Player, room string) *Follower {
+	return &Follower{p: p, defaultRoom: room, status: FollowerStatus{Room: room}}
+}
+
+// Join 以 room 房间的身份跟随 leader（如 http://192.168.1.10:8080）；已在跟随时先离开。
+// 本机当前的播放会被停止，断线后自动重连，直到 Leave 或被主机移出。
+func (f *Follower) Join(leader, room string) error {
+	if f.p == nil {
+		return errors.New("播放器未初始化")
+	}
+	if room == "" {
+		room = f.defaultRoom
+	}
+	wsURL, err := syncURL(leader, room)
+	if err != nil {
+		return err
+	}
+	f.Leave()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	f.mu.Lock()
+	f.cancel, f.done = cancel, done
+	f.status = FollowerStatus{Following: true, Leader: leader, Room: room}
+	f.mu.Unlock()
+	go func() {
+		defer close(done)
+		f.run(ctx, wsURL)
+	}()
+	return nil
+}
+
+// Leave 停止跟随（未在跟随时什么都不做）
+func (f *Follower) Leave() {
+	f.mu.Lock()
+	cancel, done := f.cancel, f.done
+	f.cancel, f.done = nil, nil
+	f.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+	f.mu.Lock()
+	f.status = FollowerStatus{Room: f.defaultRoom}
+	f.mu.Unlock()
+}
+
+// Status 当前同步状态
+func (f *Follower) Status() FollowerStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+// SetVolume 设置本房间音量并上报给主机
+func (f *Follower) SetVolume(volume float64) {
+	if f.p == nil {
+		return
+	}
+	f.p.SetVolume(float32(volume))
+	f.reportVolume()
+}
+
+// syncURL 把主机的 HTTP 地址转换为同步 WebSocket 地址
+func syncURL(leader, room string) (string, error) {
+	if leader == "" {
+		return "", errors.New("缺少主机地址")
+	}
+	if !strings.Contains(leader, "://") {
+		leader = "http://" + leader
+	}
+	u, err := url.Parse(leader)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("无效的主机地址: %s", leader)
+	}
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("不支持的主机地址协议: %s", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + SyncPath
+	q := url.Values{}
+	if room != "" {
+		q.Set("room", room)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// run 维持与主机的连接，断线后指数退避重连
+func (f *Follower) run(ctx context.Context, wsURL string) {
+	backoff := time.Second
+	for {
+		connected, err := f.session(ctx, wsURL)
+		if ctx.Err() != nil {
+			return
+		}
+		f.mu.Lock()
+		f.status.Connected = false
+		if err != nil {
+			f.status.LastError = err.Error()
+		}
+		f.mu.Unlock()
+		if errors.Is(err, errLeft) {
+			f.mu.Lock()
+			if f.cancel != nil {
+				f.cancel()
+			}
+			f.cancel, f.done = nil, nil
+			f.status = FollowerStatus{Room: f.defaultRoom, LastError: err.Error()}
+			f.mu.Unlock()
+			return
+		}
+		if connected {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// session 一次连接的完整生命周期；connected 表示是否曾成功建立连接
+func (f *Follower) session(ctx context.Context, wsURL string) (connected bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("连接主机失败: %w", err)
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	stream, err := f.p.OpenStream()
+	if err != nil {
+		return true, err
+	}
+	defer stream.Close()
+
+	f.mu.Lock()
+	f.conn = conn
+	f.status.Connected = true
+	f.status.LastError = ""
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.conn = nil
+		f.mu.Unlock()
+	}()
+
+	clock := &clockEstimator{}
+	queue := make(chan chunk, queueSize)
+	playoutDone := make(chan struct{})
+	go func() {
+		defer close(playoutDone)
+		f.playout(ctx, stream, queue, clock)
+	}()
+	// 先停止写设备的 goroutine，再关闭输出流
+	defer func() {
+		cancel()
+		<-playoutDone
+	}()
+	go f.pinger(ctx, conn)
+	f.reportVolume()
+
+	for {
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return true, nil
+			}
+			return true, fmt.Errorf("与主机的连接中断: %w", err)
+		}
+		if mt == websocket.BinaryMessage {
+			at, pcm, err := decodeAudio(data)
+			if err != nil {
+				continue
+			}
+			select {
+			case queue <- chunk{at: at, pcm: pcm}:
+			default:
+				f.mu.Lock()
+				f.status.Late++
+				f.mu.Unlock()
+			}
+			continue
+		}
+		var msg controlMessage
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		switch msg.Type {
+		case msgHello:
+			rate, channels := player.OutputFormat()
+			if msg.SampleRate != rate || msg.Channels != channels {
+				return true, fmt.Errorf("主机音频格式 %d Hz/%d 声道与本机 %d Hz/%d 声道不一致",
+					msg.SampleRate, msg.Channels, rate, channels)
+			}
+		case msgPong:
+			clock.add(msg.T0, msg.T1, msg.T2, time.Now().UnixNano())
+			offset, rtt, _ := clock.estimate()
+			f.mu.Lock()
+			f.status.OffsetMs = float64(offset) / float64(time.Millisecond)
+			f.status.RTTMs = float64(rtt) / float64(time.Millisecond)
+			f.mu.Unlock()
+		case msgVolume:
+			if msg.Volume != nil {
+				f.SetVolume(*msg.Volume)
+			}
+		case msgLeave:
+			return true, errLeft
+		}
+	}
+}
+
+// playout 把音频块对齐到本机时钟下的发声时间后写入设备：
+// 设备输出早于目标时先补静音，晚于目标时裁掉块首部分（整块都已过时则丢弃）
+func (f *Follower) playout(ctx context.Context, stream *player.Stream, queue <-chan chunk, clock *clockEstimator) {
+	rate, channels := player.OutputFormat()
+	frameBytes := channels * 2
+	framesIn := func(d time.Duration) int { return int(d * time.Duration(rate) / time.Second) }
+	var silence []byte
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		var c chunk
+		select {
+		case <-ctx.Done():
+			return
+		case c = <-queue:
+		}
+		offset, _, ok := clock.estimate()
+		if !ok {
+			f.mu.Lock()
+			f.status.Unsynced++
+			f.mu.Unlock()
+			continue
+		}
+		due := time.Unix(0, c.at-int64(offset))
+		// 离发声还早：等到只剩设备缓冲的时长再写，避免长时间阻塞在设备写入上
+		if wait := time.Until(due) - player.OutputLatency(); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+		}
+
+		pcm := c.pcm
+		var padded, trimmed time.Duration
+		switch drift := stream.NextStart().Sub(due); {
+		case drift < -syncTolerance:
+			n := framesIn(-drift) * frameBytes
+			if cap(silence) < n {
+				silence = make([]byte, n)
+			}
+			if _, err := stream.Write(silence[:n]); err != nil {
+				return
+			}
+			padded = -drift
+		case drift > syncTolerance:
+			n := framesIn(drift) * frameBytes
+			if n >= len(pcm) {
+				f.mu.Lock()
+				f.status.Late++
+				f.mu.Unlock()
+				continue
+			}
+			pcm = pcm[n:]
+			trimmed = drift
+		}
+		if _, err := stream.Write(pcm); err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.status.Played++
+		f.status.PaddedMs += float64(padded) / float64(time.Millisecond)
+		f.status.TrimmedMs += float64(trimmed) / float64(time.Millisecond)
+		f.mu.Unlock()
+	}
+}
+
+// pinger 建立连接后先快速发送一组 ping，之后定期校准
+func (f *Follower) pinger(ctx context.Context, conn *websocket.Conn) {
+	for i := 0; ; i++ {
+		interval := pingInterval
+		if i < pingBurst {
+			interval = pingBurstInterval
+		}
+		if f.write(conn, controlMessage{Type: msgPing, T0: time.Now().UnixNano()}) != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// reportVolume 把本机音量上报给主机（未连接时忽略）
+func (f *Follower) reportVolume() {
+	f.mu.Lock()
+	conn := f.conn
+	f.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	v := float64(f.p.GetVolume())
+	_ = f.write(conn, controlMessage{Type: msgVolume, Volume: &v})
+}
+
+func (f *Follower) write(conn *websocket.Conn, msg controlMessage) error {
+	f.writeMu.Lock()
+	defer f.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return conn.WriteJSON(msg)
+}
+
+// clockEstimator NTP 式时钟偏移估计：保留最近若干样本，采用往返时间最短的一次，
+// 因为往返越短，来回路径不对称带来的误差上限越小
+type clockEstimator struct {
+	mu      sync.Mutex
+	samples []clockSample
+}
+
+type clockSample struct {
+	offset time.Duration // 主机时钟 - 本机时钟
+	rtt    time.Duration
+}
+
+// add 记录一次交换：t0 本机发出、t1 主机收到、t2 主机发出、t3 本机收到
+func (c *clockEstimator) add(t0, t1, t2, t3 int64) {
+	s := clockSample{
+		offset: time.Duration(((t1 - t0) + (t2 - t3)) / 2),
+		rtt:    time.Duration((t3 - t0) - (t2 - t1)),
+	}
+	c.mu.Lock()
+	c.samples = append(c.samples, s)
+	if len(c.samples) > clockSamples {
+		c.samples = c.samples[1:]
+	}
+	c.mu.Unlock()
+}
+
+// estimate 返回当前的偏移与对应往返时间；ok 为 false 表示尚无样本
+func (c *clockEstimator) estimate() (offset, rtt time.Duration, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.samples) == 0 {
+		return 0, 0, false
+	}
+	best := c.samples[0]
+	for _, s := range c.samples[1:] {
+		if s.rtt < best.rtt {
+			best = s
+		}
+	}
+	return best.offset, best.rtt, true
+}
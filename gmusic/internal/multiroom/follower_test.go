@@ -0,0 +1,115 @@
+package multiroom
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yudongyouqing/GMusic/internal/player"
+)
+
+// exchange 模拟一次 ping/pong：主机时钟比本机快 offset，去程 up、回程 down，主机处理耗时 1ms
+type exchange struct{ offset, up, down time.Duration }
+
+// TestClockEstimator 偏移取往返最短的样本；路径不对称时误差为两段延迟差的一半；只保留最近 clockSamples 个样本
+func TestClockEstimator(t *testing.T) {
+	ms := time.Millisecond
+	repeat := func(n int, e exchange) []exchange {
+		out := make([]exchange, n)
+		for i := range out {
+			out[i] = e
+		}
+		return out
+	}
+	tests := []struct {
+		name      string
+		exchanges []exchange
+		offset    time.Duration
+		rtt       time.Duration
+		ok        bool
+	}{
+		{"没有样本", nil, 0, 0, false},
+		{"对称路径", []exchange{{50 * ms, 5 * ms, 5 * ms}}, 50 * ms, 10 * ms, true},
+		{"主机时钟较慢", []exchange{{-2 * time.Second, 3 * ms, 3 * ms}}, -2 * time.Second, 6 * ms, true},
+		{"不对称路径", []exchange{{50 * ms, 8 * ms, 2 * ms}}, 53 * ms, 10 * ms, true},
+		{"取往返最短", []exchange{{50 * ms, 30 * ms, 2 * ms}, {50 * ms, 1 * ms, 1 * ms}, {50 * ms, 2 * ms, 40 * ms}}, 50 * ms, 2 * ms, true},
+		{"旧样本被淘汰", append([]exchange{{50 * ms, 1 * ms, 1 * ms}}, repeat(clockSamples, exchange{20 * ms, 5 * ms, 5 * ms})...),
+			20 * ms, 10 * ms, true},
+	}
+	for _, tt := range tests {
+		var c clockEstimator
+		t0 := time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC).UnixNano()
+		for _, e := range tt.exchanges {
+			t1 := t0 + int64(e.up+e.offset)
+			t2 := t1 + int64(time.Millisecond)
+			t3 := t2 - int64(e.offset) + int64(e.down)
+			c.add(t0, t1, t2, t3)
+			t0 = t3 + int64(100*time.Millisecond)
+		}
+		offset, rtt, ok := c.estimate()
+		if offset != tt.offset || rtt != tt.rtt || ok != tt.ok {
+			t.Errorf("%s: 偏移 %v、往返 %v、%v，期望 %v、%v、%v", tt.name, offset, rtt, ok, tt.offset, tt.rtt, tt.ok)
+		}
+	}
+}
+
+// TestFollowerSync 从机通过回环连接跟随主机：时钟偏移收敛到 0 附近（同一台机器），
+// 按时间戳连续发送的音频块全部按时写入设备，不因迟到或未同步被丢弃，也不需要裁剪
+func TestFollowerSync(t *testing.T) {
+	rate, channels := player.OutputFormat()
+	leader := NewLeader(rate, channels)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		leader.ServeFollower(ws, r.URL.Query().Get("room"))
+	}))
+	defer srv.Close()
+
+	f := NewFollower(player.NewPlayerWithOutput(player.NullOutput()), "客厅")
+	if err := f.Join(srv.URL, "书房"); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Leave()
+
+	waitFor := func(what string, timeout time.Duration, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(timeout); !cond(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("等待%s超时，状态 %+v", what, f.Status())
+			}
+		}
+	}
+	waitFor("从机连接", 5*time.Second, func() bool {
+		rooms := leader.Rooms()
+		return len(rooms) == 1 && rooms[0].Room == "书房" && f.Status().Connected
+	})
+	// 快速同步阶段结束后已有 pingBurst 个样本
+	waitFor("时钟同步", 5*time.Second, func() bool { return f.Status().RTTMs > 0 })
+	time.Sleep(pingBurst * pingBurstInterval)
+	if st := f.Status(); math.Abs(st.OffsetMs) > 2 || st.RTTMs > 10 {
+		t.Errorf("时钟偏移 %.3fms、往返 %.3fms，期望收敛到 0 附近", st.OffsetMs, st.RTTMs)
+	}
+
+	// 模拟主机播放器的输出：每块 1024 帧，发声时间首尾相接
+	const chunks, frames = 40, 1024
+	pcm := make([]byte, frames*channels*2)
+	start := time.Now().Add(player.OutputLatency() + 300*time.Millisecond)
+	for i := 0; i < chunks; i++ {
+		leader.Write(pcm, start.Add(time.Duration(i*frames)*time.Second/time.Duration(rate)))
+	}
+	waitFor("播放完成", 10*time.Second, func() bool { return f.Status().Played == chunks })
+
+	st := f.Status()
+	if st.Late != 0 || st.Unsynced != 0 || st.TrimmedMs != 0 {
+		t.Errorf("迟到 %d 块、未同步 %d 块、裁剪 %.3fms，期望全部按时播放", st.Late, st.Unsynced, st.TrimmedMs)
+	}
+	if leader.Dropped() != 0 {
+		t.Errorf("主机断开了 %d 个过慢的从机", leader.Dropped())
+	}
+}
@@ -0,0 +1,238 @@
+package multiroom
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// memberBuffer 每个从机最多积压的音频块数；按播放器每块 4 KiB 计约 6 秒音频
+	memberBuffer = 256
+	// writeTimeout 单次写入从机连接的超时
+	writeTimeout = 5 * time.Second
+)
+
+// ErrNoRoom 没有该名称的从机连接
+var ErrNoRoom = errors.New("房间不存在或未连接")
+
+// RoomInfo 一个已连接的从机房间
+type RoomInfo struct {
+	Room        string    `json:"room"`
+	Addr        string    `json:"addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Volume      float64   `json:"volume"` // 从机上报的房间音量
+}
+
+// member 一条从机连接
+type member struct {
+	room   string
+	addr   string
+	since  time.Time
+	volume float64
+
+	send chan []byte         // 音频块
+	ctrl chan controlMessage // 控制消息，写出时优先于音频
+	done chan struct{}       // 被移除（断开、过慢或被踢出）时关闭
+	conn *websocket.Conn
+}
+
+// Leader 多房间主机：给每块 PCM 打上发声时间戳并转发给全部从机
+type Leader struct {
+	sampleRate int
+	channels   int
+
+	mu      sync.Mutex
+	members map[*member]struct{}
+	dropped int
+}
+
+// NewLeader 创建主机；sampleRate/channels 为 PCM（16-bit 小端）格式
+func NewLeader(sampleRate, channels int) *Leader {
+	return &Leader{
+		sampleRate: sampleRate,
+		channels:   channels,
+		members:    make(map[*member]struct{}),
+	}
+}
+
+// Write 转发一块即将写入本机设备的 PCM，at 为它在本机的发声时间
+// （可作为 player.SetPCMTap 的回调，不会阻塞）。pcm 须为 hello 中宣告的格式，即 NewLeader 的参数；
+// 播放器已把各种源格式转为 player.OutputFormat，跟随端据此校验并按该采样率对齐
+func (l *Leader) Write(pcm []byte, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.members) == 0 {
+		return
+	}
+	frame := encodeAudio(at, pcm)
+	for m := range l.members {
+		select {
+		case m.send <- frame:
+		default:
+			// 从机跟不上实时速率：断开，由从机自行重连
+			l.removeLocked(m)
+			l.dropped++
+		}
+	}
+}
+
+// ServeFollower 处理一条从机连接，直到连接断开或从机被移除；room 为从机声明的房间名
+func (l *Leader) ServeFollower(conn *websocket.Conn, room string) {
+	m := &member{
+		room:   room,
+		addr:   conn.RemoteAddr().String(),
+		since:  time.Now(),
+		volume: 1,
+		send:   make(chan []byte, memberBuffer),
+		ctrl:   make(chan controlMessage, 16),
+		done:   make(chan struct{}),
+		conn:   conn,
+	}
+	m.ctrl <- controlMessage{Type: msgHello, SampleRate: l.sampleRate, Channels: l.channels}
+	l.mu.Lock()
+	l.members[m] = struct{}{}
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.removeLocked(m)
+		l.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	go l.writeLoop(m)
+	for {
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if mt != websocket.TextMessage {
+			continue
+		}
+		var msg controlMessage
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		switch msg.Type {
+		case msgPing:
+			pong := controlMessage{Type: msgPong, T0: msg.T0, T1: time.Now().UnixNano()}
+			select {
+			case m.ctrl <- pong:
+			case <-m.done:
+				return
+			}
+		case msgVolume:
+			if msg.Volume != nil {
+				l.mu.Lock()
+				m.volume = *msg.Volume
+				l.mu.Unlock()
+			}
+		}
+	}
+}
+
+// writeLoop 是连接上唯一的写者；控制消息优先，使 pong 不被排在积压的音频之后而拉长往返时间
+func (l *Leader) writeLoop(m *member) {
+	defer m.conn.Close()
+	for {
+		select {
+		case msg := <-m.ctrl:
+			if !l.writeControl(m, msg) {
+				return
+			}
+			continue
+		default:
+		}
+		select {
+		case <-m.done:
+			return
+		case msg := <-m.ctrl:
+			if !l.writeControl(m, msg) {
+				return
+			}
+		case frame := <-m.send:
+			_ = m.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if m.conn.WriteMessage(websocket.BinaryMessage, frame) != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeControl 写出一条控制消息；返回 false 表示连接应当结束
+func (l *Leader) writeControl(m *member, msg controlMessage) bool {
+	if msg.Type == msgPong {
+		msg.T2 = time.Now().UnixNano()
+	}
+	_ = m.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if m.conn.WriteJSON(msg) != nil {
+		return false
+	}
+	return msg.Type != msgLeave
+}
+
+// Rooms 当前连接的从机，按房间名排序
+func (l *Leader) Rooms() []RoomInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rooms := make([]RoomInfo, 0, len(l.members))
+	for m := range l.members {
+		rooms = append(rooms, RoomInfo{Room: m.room, Addr: m.addr, ConnectedAt: m.since, Volume: m.volume})
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].Room != rooms[j].Room {
+			return rooms[i].Room < rooms[j].Room
+		}
+		return rooms[i].Addr < rooms[j].Addr
+	})
+	return rooms
+}
+
+// Dropped 因跟不上实时速率被断开的从机累计次数
+func (l *Leader) Dropped() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.dropped
+}
+
+// SetRoomVolume 调整某个房间的音量（0.0 - 1.0），由从机应用到自己的输出上
+func (l *Leader) SetRoomVolume(room string, volume float64) error {
+	return l.sendToRoom(room, controlMessage{Type: msgVolume, Volume: &volume})
+}
+
+// RemoveRoom 让某个房间离开同步组：从机收到后停止跟随且不再重连
+func (l *Leader) RemoveRoom(room string) error {
+	return l.sendToRoom(room, controlMessage{Type: msgLeave})
+}
+
+func (l *Leader) sendToRoom(room string, msg controlMessage) error {
+	l.mu.Lock()
+	var targets []*member
+	for m := range l.members {
+		if m.room == room {
+			targets = append(targets, m)
+		}
+	}
+	l.mu.Unlock()
+	if len(targets) == 0 {
+		return ErrNoRoom
+	}
+	for _, m := range targets {
+		select {
+		case m.ctrl <- msg:
+		case <-m.done:
+		}
+	}
+	return nil
+}
+
+func (l *Leader) removeLocked(m *member) {
+	if _, ok := l.members[m]; ok {
+		delete(l.members, m)
+		close(m.done)
+	}
+}
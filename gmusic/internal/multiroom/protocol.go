@@ -0,0 +1,78 @@
+// Package multiroom 让多台 GMusic 组成多房间同步播放：一台作为主机（leader），
+// 其余作为从机（follower）接收主机播放器输出的 PCM 并与主机同步发声。
+//
+// 主从之间是一条 WebSocket 连接（主机的 /ws/sync）：
+//   - 二进制消息是音频块：8 字节大端 int64（主机时钟下该块应当发声的 Unix 纳秒时间）+ 16-bit 小端 PCM；
+//   - 文本消息是 JSON 控制消息（hello、ping/pong、volume、leave）。
+//
+// 从机用 NTP 式的 ping/pong 估计与主机的时钟偏移（取近期往返时间最短的一次样本），
+// 把每块音频的发声时间换算到本机时钟。主从双方都按“设备以标称采样率连续消费已写入数据”推算
+// 输出的播放进度：从机输出早于目标时补静音、晚于目标时裁掉块首，因此同一块音频在各房间几乎同时响起。
+package multiroom
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"time"
+)
+
+// SyncPath 主机上供从机连接的 WebSocket 路径
+const SyncPath = "/ws/sync"
+
+// 控制消息类型
+const (
+	msgHello  = "hello"  // 主机 → 从机：音频格式与主机设备延迟
+	msgPing   = "ping"   // 从机 → 主机：时钟同步请求
+	msgPong   = "pong"   // 主机 → 从机：时钟同步应答
+	msgVolume = "volume" // 双向：主机要求从机调整房间音量 / 从机上报当前音量
+	msgLeave  = "leave"  // 主机 → 从机：要求从机离开（被移出房间组）
+)
+
+// controlMessage 文本控制消息
+type controlMessage struct {
+	Type       string   `json:"type"`
+	Room       string   `json:"room,omitempty"`
+	SampleRate int      `json:"sample_rate,omitempty"`
+	Channels   int      `json:"channels,omitempty"`
+	T0         int64    `json:"t0,omitempty"` // ping 发出时间（从机时钟）
+	T1         int64    `json:"t1,omitempty"` // ping 到达时间（主机时钟）
+	T2         int64    `json:"t2,omitempty"` // pong 发出时间（主机时钟）
+	Volume     *float64 `json:"volume,omitempty"`
+}
+
+// audioHeaderSize 音频块头部（发声时间戳）的字节数
+const audioHeaderSize = 8
+
+var errShortFrame = errors.New("音频块过短")
+
+// encodeAudio 构造一个音频块
+func encodeAudio(at time.Time, pcm []byte) []byte {
+	frame := make([]byte, audioHeaderSize+len(pcm))
+	binary.BigEndian.PutUint64(frame, uint64(at.UnixNano()))
+	copy(frame[audioHeaderSize:], pcm)
+	return frame
+}
+
+// decodeAudio 解析音频块，返回主机时钟下的发声时间（Unix 纳秒）与 PCM
+func decodeAudio(frame []byte) (int64, []byte, error) {
+	if len(frame) < audioHeaderSize {
+		return 0, nil, errShortFrame
+	}
+	return int64(binary.BigEndian.Uint64(frame)), frame[audioHeaderSize:], nil
+}
+
+// Config 多房间配置
+type Config struct {
+	Room   string // 本机的房间名
+	Leader string // 启动时自动跟随的主机地址；为空表示独立运行（仍可作为主机被跟随）
+}
+
+// ConfigFromEnv 从环境变量 GMUSIC_ROOM（默认主机名）、GMUSIC_SYNC_LEADER 读取配置
+func ConfigFromEnv() Config {
+	cfg := Config{Room: os.Getenv("GMUSIC_ROOM"), Leader: os.Getenv("GMUSIC_SYNC_LEADER")}
+	if cfg.Room == "" {
+		cfg.Room, _ = os.Hostname()
+	}
+	return cfg
+}
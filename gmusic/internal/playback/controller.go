@@ -0,0 +1,422 @@
+// Package playback 在 player.Player 之上维护服务端播放队列。
+//
+// 播放请求以曲库实体（歌曲、专辑、艺术家、播放列表）为目标，经数据库解析为歌曲队列，
+// 客户端无需知道文件路径；每次开始播放都会写入 PlayHistory，便于统计。
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"gorm.io/gorm"
+)
+
+// ErrEmptyQueue 队列为空或已到达边界
+var ErrEmptyQueue = errors.New("播放队列为空")
+
+// NotFoundError 请求的曲库实体不存在
+type NotFoundError struct{ What string }
+
+func (e *NotFoundError) Error() string { return e.What + "不存在" }
+
+// Request 播放请求：SongID、AlbumID、ArtistID、PlaylistID、SmartPlaylistID 中指定一种目标。
+// 按名称的 Album、Artist 只在没有给出 ID 时使用（同名专辑无法区分、艺术家别名不会合并），
+// 两者同时给出时表示“该艺术家的该专辑”。
+type Request struct {
+	SongID     uint   `json:"song_id"`
+	AlbumID    uint   `json:"album_id"`
+	ArtistID   uint   `json:"artist_id"`
+	Album      string `json:"album"`
+	Artist     string `json:"artist"`
+	PlaylistID uint   `json:"playlist_id"`
+	// SmartPlaylistID 智能播放列表，按其查询在开始播放时计算队列
+	SmartPlaylistID uint `json:"smart_playlist_id"`
+	StartIndex      *int `json:"start_index"` // 从队列中的第几首开始（默认 0）
+	Shuffle         bool `json:"shuffle"`     // 是否打乱队列
+}
+
+// Source 描述当前队列来自哪个曲库实体
+type Source struct {
+	Type string `json:"type"` // song / album / artist / playlist / smart_playlist
+	ID   uint   `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Entry 队列中的一项。ID 在队列内唯一且不随位置变化，供 MPD 等按条目 ID 操作的协议使用。
+type Entry struct {
+	ID   int
+	Song storage.Song
+}
+
+// Controller 服务端播放控制器：持有队列并驱动 player.Player
+type Controller struct {
+	mu      sync.Mutex
+	db      *gorm.DB
+	player  *player.Player
+	queue   []storage.Song
+	ids     []int // 与 queue 一一对应的条目 ID
+	nextID  int
+	version uint32 // 队列每次变化加 1
+	index   int
+	source  Source
+
+	onQueueEnd func() // 队列自然播完时回调（点歌模式据此接着播放下一首点播）
+}
+
+// NewController 创建控制器，并接管播放器的自然结束回调以实现队列自动续播
+func NewController(db *gorm.DB, p *player.Player) *Controller {
+	c := &Controller{db: db, player: p, index: -1}
+	if p != nil {
+		p.SetOnFinished(c.handleFinished)
+	}
+	return c
+}
+
+// Player 返回底层播放器（暂停、音量等不涉及队列的操作直接调用）
+func (c *Controller) Player() *player.Player { return c.player }
+
+// Play 解析请求为歌曲队列并开始播放，返回正在播放的歌曲
+func (c *Controller) Play(req Request) (*storage.Song, error) {
+	songs, source, err := c.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(songs) == 0 {
+		return nil, ErrEmptyQueue
+	}
+
+	start := 0
+	if req.StartIndex != nil {
+		start = *req.StartIndex
+		if start < 0 || start >= len(songs) {
+			return nil, fmt.Errorf("start_index 越界: %d（共 %d 首）", start, len(songs))
+		}
+	}
+	if req.Shuffle {
+		songs, start = shuffleQueue(songs, start, req.StartIndex != nil)
+	}
+
+	c.mu.Lock()
+	c.queue = nil
+	c.ids = nil
+	c.appendLocked(songs)
+	c.index = start
+	c.source = source
+	c.mu.Unlock()
+	return c.playIndex(start)
+}
+
+// PlayAt 播放队列中第 i 首
+func (c *Controller) PlayAt(i int) (*storage.Song, error) { return c.playIndex(i) }
+
+// Next 播放队列中的下一首
+func (c *Controller) Next() (*storage.Song, error) { return c.step(1) }
+
+// Previous 播放队列中的上一首
+func (c *Controller) Previous() (*storage.Song, error) { return c.step(-1) }
+
+// Current 返回当前歌曲及其在队列中的位置；无播放时返回 (nil, -1)
+func (c *Controller) Current() (*storage.Song, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.index < 0 || c.index >= len(c.queue) {
+		return nil, -1
+	}
+	song := c.queue[c.index]
+	return &song, c.index
+}
+
+// Queue 返回当前队列的副本及其来源
+func (c *Controller) Queue() ([]storage.Song, Source) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]storage.Song(nil), c.queue...), c.source
+}
+
+// Entries 返回队列条目的副本及队列版本号
+func (c *Controller) Entries() ([]Entry, uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]Entry, len(c.queue))
+	for i, song := range c.queue {
+		entries[i] = Entry{ID: c.ids[i], Song: song}
+	}
+	return entries, c.version
+}
+
+// Version 队列版本号，队列内容或顺序每次变化都会递增
+func (c *Controller) Version() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// Len 队列长度
+func (c *Controller) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queue)
+}
+
+// PositionOf 返回条目 ID 在队列中的位置，不存在时返回 -1
+func (c *Controller) PositionOf(id int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, v := range c.ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add 将歌曲插入队列第 pos 位（pos<0 或越界时追加到末尾），返回新条目的 ID
+func (c *Controller) Add(songs []storage.Song, pos int) []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pos < 0 || pos > len(c.queue) {
+		pos = len(c.queue)
+	}
+	tailSongs := append([]storage.Song(nil), c.queue[pos:]...)
+	tailIDs := append([]int(nil), c.ids[pos:]...)
+	c.queue, c.ids = c.queue[:pos], c.ids[:pos]
+	added := c.appendLocked(songs)
+	c.queue = append(c.queue, tailSongs...)
+	c.ids = append(c.ids, tailIDs...)
+	if c.index >= pos {
+		c.index += len(songs)
+	}
+	return added
+}
+
+// Remove 删除队列中 [start, end) 区间的歌曲。
+// 正在播放的歌曲被删除时，若后面还有歌曲则接着播放，否则停止。
+func (c *Controller) Remove(start, end int) error {
+	c.mu.Lock()
+	if start < 0 || end > len(c.queue) || start >= end {
+		c.mu.Unlock()
+		return fmt.Errorf("队列区间越界: %d:%d（共 %d 首）", start, end, len(c.queue))
+	}
+	c.queue = append(c.queue[:start], c.queue[end:]...)
+	c.ids = append(c.ids[:start], c.ids[end:]...)
+	c.version++
+	removedCurrent := c.index >= start && c.index < end
+	switch {
+	case removedCurrent:
+		c.index = -1
+	case c.index >= end:
+		c.index -= end - start
+	}
+	remaining := len(c.queue)
+	c.mu.Unlock()
+
+	if removedCurrent && c.player != nil {
+		wasPlaying := c.player.IsPlaying()
+		c.player.Stop()
+		if wasPlaying && start < remaining {
+			_, err := c.playIndex(start)
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear 停止播放并清空队列
+func (c *Controller) Clear() {
+	if c.player != nil {
+		c.player.Stop()
+	}
+	c.mu.Lock()
+	c.queue, c.ids = nil, nil
+	c.index = -1
+	c.source = Source{}
+	c.version++
+	c.mu.Unlock()
+}
+
+// Move 将第 from 首移动到第 to 位，当前播放位置随之调整
+func (c *Controller) Move(from, to int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.queue)
+	if from < 0 || from >= n || to < 0 || to >= n {
+		return fmt.Errorf("队列位置越界: %d -> %d（共 %d 首）", from, to, n)
+	}
+	song, id := c.queue[from], c.ids[from]
+	c.queue = append(c.queue[:from], c.queue[from+1:]...)
+	c.ids = append(c.ids[:from], c.ids[from+1:]...)
+	c.queue = append(c.queue[:to], append([]storage.Song{song}, c.queue[to:]...)...)
+	c.ids = append(c.ids[:to], append([]int{id}, c.ids[to:]...)...)
+	switch {
+	case c.index == from:
+		c.index = to
+	case from < c.index && to >= c.index:
+		c.index--
+	case from > c.index && to <= c.index:
+		c.index++
+	}
+	c.version++
+	return nil
+}
+
+// Shuffle 打乱整个队列，正在播放的歌曲继续播放（位置随之变化）
+func (c *Controller) Shuffle() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current := -1
+	if c.index >= 0 && c.index < len(c.ids) {
+		current = c.ids[c.index]
+	}
+	rand.Shuffle(len(c.queue), func(i, j int) {
+		c.queue[i], c.queue[j] = c.queue[j], c.queue[i]
+		c.ids[i], c.ids[j] = c.ids[j], c.ids[i]
+	})
+	for i, id := range c.ids {
+		if id == current {
+			c.index = i
+		}
+	}
+	c.version++
+}
+
+// appendLocked 追加歌曲并分配条目 ID，调用方需持有锁
+func (c *Controller) appendLocked(songs []storage.Song) []int {
+	added := make([]int, len(songs))
+	for i, song := range songs {
+		c.nextID++
+		added[i] = c.nextID
+		c.queue = append(c.queue, song)
+		c.ids = append(c.ids, c.nextID)
+	}
+	c.version++
+	return added
+}
+
+func (c *Controller) step(delta int) (*storage.Song, error) {
+	c.mu.Lock()
+	next := c.index + delta
+	if next < 0 || next >= len(c.queue) {
+		c.mu.Unlock()
+		return nil, ErrEmptyQueue
+	}
+	c.mu.Unlock()
+	return c.playIndex(next)
+}
+
+// playIndex 播放队列中第 i 首并记录播放历史
+func (c *Controller) playIndex(i int) (*storage.Song, error) {
+	c.mu.Lock()
+	if i < 0 || i >= len(c.queue) {
+		c.mu.Unlock()
+		return nil, ErrEmptyQueue
+	}
+	song := c.queue[i]
+	c.index = i
+	c.mu.Unlock()
+
+	if c.player == nil {
+		return nil, fmt.Errorf("播放器未初始化")
+	}
+	if err := c.player.PlayRange(song.FilePath, float64(song.StartMs)/1000, float64(song.EndMs)/1000); err != nil {
+		return nil, err
+	}
+	if err := storage.AddPlayHistory(c.db, song.ID); err != nil {
+		fmt.Printf("记录播放历史失败: %v\n", err)
+	}
+	return &song, nil
+}
+
+// SetOnQueueEnd 设置队列最后一首自然播完时的回调（在独立 goroutine 中调用，可在其中直接播放）
+func (c *Controller) SetOnQueueEnd(fn func()) {
+	c.mu.Lock()
+	c.onQueueEnd = fn
+	c.mu.Unlock()
+}
+
+// handleFinished 当前歌曲自然播放结束：队列中还有下一首则自动续播，否则通知队列已播完
+func (c *Controller) handleFinished() {
+	_, err := c.Next()
+	switch {
+	case errors.Is(err, ErrEmptyQueue):
+		c.mu.Lock()
+		fn := c.onQueueEnd
+		c.mu.Unlock()
+		if fn != nil {
+			fn()
+		}
+	case err != nil:
+		fmt.Printf("自动播放下一首失败: %v\n", err)
+	}
+}
+
+// resolve 将请求解析为歌曲列表
+func (c *Controller) resolve(req Request) ([]storage.Song, Source, error) {
+	switch {
+	case req.SongID != 0:
+		song, err := storage.GetSongByID(c.db, req.SongID)
+		if err != nil {
+			return nil, Source{}, notFound("歌曲", err)
+		}
+		return []storage.Song{*song}, Source{Type: "song", ID: song.ID, Name: song.Title}, nil
+	case req.PlaylistID != 0:
+		songs, err := storage.GetPlaylistSongs(c.db, req.PlaylistID)
+		if err != nil {
+			return nil, Source{}, notFound("播放列表", err)
+		}
+		return songs, Source{Type: "playlist", ID: req.PlaylistID}, nil
+	case req.SmartPlaylistID != 0:
+		sp, err := storage.GetSmartPlaylist(c.db, req.SmartPlaylistID)
+		if err != nil {
+			return nil, Source{}, notFound("智能播放列表", err)
+		}
+		songs, err := storage.SmartPlaylistSongs(c.db, sp)
+		return songs, Source{Type: "smart_playlist", ID: sp.ID, Name: sp.Name}, err
+	case req.AlbumID != 0:
+		album, err := storage.GetAlbumInfo(c.db, req.AlbumID)
+		if err != nil {
+			return nil, Source{}, notFound("专辑", err)
+		}
+		songs, err := storage.GetAlbumTracks(c.db, album.ID)
+		return songs, Source{Type: "album", ID: album.ID, Name: album.Name}, err
+	case req.ArtistID != 0:
+		artist, err := storage.GetArtistInfo(c.db, req.ArtistID)
+		if err != nil {
+			return nil, Source{}, notFound("艺术家", err)
+		}
+		songs, err := storage.GetArtistSongs(c.db, artist.ID)
+		return songs, Source{Type: "artist", ID: artist.ID, Name: artist.Name}, err
+	case req.Album != "":
+		songs, err := storage.GetSongsByAlbum(c.db, req.Album, req.Artist)
+		return songs, Source{Type: "album", Name: req.Album}, err
+	case req.Artist != "":
+		songs, err := storage.GetSongsByArtist(c.db, req.Artist)
+		return songs, Source{Type: "artist", Name: req.Artist}, err
+	default:
+		return nil, Source{}, fmt.Errorf("需要指定 song_id、album_id、artist_id、playlist_id 或 smart_playlist_id（也可按名称指定 album、artist）")
+	}
+}
+
+// shuffleQueue 打乱队列。指定了起始位置时该歌曲固定排在队首，否则整体随机。
+func shuffleQueue(songs []storage.Song, start int, keepStart bool) ([]storage.Song, int) {
+	out := append([]storage.Song(nil), songs...)
+	if keepStart {
+		out[0], out[start] = out[start], out[0]
+		rest := out[1:]
+		rand.Shuffle(len(rest), func(i, j int) { rest[i], rest[j] = rest[j], rest[i] })
+	} else {
+		rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	}
+	return out, 0
+}
+
+// notFound 将 gorm 的未找到错误转为 NotFoundError，其余错误原样返回
+func notFound(what string, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &NotFoundError{What: what}
+	}
+	return err
+}
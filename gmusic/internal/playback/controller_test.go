@@ -0,0 +1,72 @@
+package playback
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// TestResolveByID 按 album_id/artist_id 播放能区分不同专辑艺术家的同名专辑，并包含别名归并的歌曲；
+// 只给名称时仍按文本匹配
+func TestResolveByID(t *testing.T) {
+	db, err := storage.InitDB(filepath.Join(t.TempDir(), "gmusic.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := func(title, artist, album string) *storage.Song {
+		s := &storage.Song{Title: title, Artist: artist, Album: album, FilePath: "/music/" + title + ".flac"}
+		if err := storage.AddSong(db, s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	queen := add("Bohemian Rhapsody", "Queen", "Greatest Hits")
+	add("Dancing Queen", "ABBA", "Greatest Hits")
+	jay := add("晴天", "周杰伦", "叶惠美")
+	add("Nunchucks", "Jay Chou", "Fantasy")
+	if err := storage.AddArtistAlias(db, jay.ArtistID, "Jay Chou"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewController(db, nil)
+	tests := []struct {
+		name   string
+		req    Request
+		source Source
+		titles []string
+	}{
+		{"专辑 ID", Request{AlbumID: queen.AlbumID}, Source{Type: "album", ID: queen.AlbumID, Name: "Greatest Hits"},
+			[]string{"Bohemian Rhapsody"}},
+		{"艺术家 ID 含别名", Request{ArtistID: jay.ArtistID}, Source{Type: "artist", ID: jay.ArtistID, Name: "周杰伦"},
+			[]string{"Nunchucks", "晴天"}},
+		{"ID 优先于名称", Request{AlbumID: queen.AlbumID, Album: "叶惠美"}, Source{Type: "album", ID: queen.AlbumID, Name: "Greatest Hits"},
+			[]string{"Bohemian Rhapsody"}},
+		{"按名称兼容", Request{Album: "Greatest Hits"}, Source{Type: "album", Name: "Greatest Hits"},
+			[]string{"Bohemian Rhapsody", "Dancing Queen"}},
+	}
+	for _, tt := range tests {
+		songs, source, err := c.resolve(tt.req)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var titles []string
+		for _, s := range songs {
+			titles = append(titles, s.Title)
+		}
+		slices.Sort(titles)
+		if source != tt.source || !slices.Equal(titles, tt.titles) {
+			t.Errorf("%s: 来源 %+v 歌曲 %q，期望 %+v %q", tt.name, source, titles, tt.source, tt.titles)
+		}
+	}
+
+	for _, req := range []Request{{AlbumID: 999}, {ArtistID: 999}} {
+		var nf *NotFoundError
+		if _, _, err := c.resolve(req); !errors.As(err, &nf) {
+			t.Errorf("%+v: 错误 = %v，期望 NotFoundError", req, err)
+		}
+	}
+}
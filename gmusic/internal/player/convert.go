@@ -0,0 +1,80 @@
+package player
+
+import "encoding/binary"
+
+// formatConverter 把解码器输出的 PCM 转换为设备的固定输出格式（见 OutputFormat）：
+// 单声道复制到左右声道，多声道取前两个声道，采样率不同时做线性插值重采样。
+// 按块转换，块之间保留插值位置与上一帧，分块方式不影响结果。
+type formatConverter struct {
+	srcRate  int
+	channels int
+
+	prev    []int16 // 上一块的最后一帧（立体声），插值时作为本块之前的一帧
+	base    int64   // frames 第一帧在源中的帧号
+	written int64   // 已输出的帧数；第 k 个输出帧位于源中 k·srcRate/fixedSampleRate 处，按整数计算不累积误差
+	pending []byte  // 不足一帧的残余输入字节
+	frames  []int16
+	out     []byte
+}
+
+// newFormatConverter 返回把 (srcRate, channels) 的 PCM 转为固定输出格式的转换器；
+// 格式已一致或参数未知时返回 nil（原样输出）
+func newFormatConverter(srcRate, channels int) *formatConverter {
+	if srcRate <= 0 || channels <= 0 || (srcRate == fixedSampleRate && channels == fixedChannelCount) {
+		return nil
+	}
+	return &formatConverter{
+		srcRate:  srcRate,
+		channels: channels,
+	}
+}
+
+// convert 转换一块 PCM，返回的切片在下一次调用前有效；c 为 nil 时原样返回
+func (c *formatConverter) convert(pcm []byte) []byte {
+	if c == nil {
+		return pcm
+	}
+	// 拆成立体声帧，prev 放在最前面
+	frameBytes := c.channels * fixedBytesPerSamp
+	data := append(c.pending, pcm...)
+	whole := len(data) - len(data)%frameBytes
+	c.frames = append(c.frames[:0], c.prev...)
+	for i := 0; i < whole; i += frameBytes {
+		l := int16(binary.LittleEndian.Uint16(data[i:]))
+		r := l
+		if c.channels > 1 {
+			r = int16(binary.LittleEndian.Uint16(data[i+2:]))
+		}
+		c.frames = append(c.frames, l, r)
+	}
+	c.pending = append(c.pending[:0], data[whole:]...)
+
+	c.out = c.out[:0]
+	n := len(c.frames) / 2
+	if c.srcRate == fixedSampleRate {
+		for _, v := range c.frames[len(c.prev):] {
+			c.out = binary.LittleEndian.AppendUint16(c.out, uint16(v))
+		}
+		return c.out
+	}
+	for {
+		pos := c.written * int64(c.srcRate)
+		i := int(pos/fixedSampleRate - c.base)
+		if i+1 >= n {
+			break
+		}
+		frac := float64(pos%fixedSampleRate) / fixedSampleRate
+		for ch := 0; ch < 2; ch++ {
+			a := float64(c.frames[i*2+ch])
+			b := float64(c.frames[(i+1)*2+ch])
+			c.out = binary.LittleEndian.AppendUint16(c.out, uint16(int16(a+(b-a)*frac)))
+		}
+		c.written++
+	}
+	// 只保留最后一帧
+	if n > 0 {
+		c.prev = append(c.prev[:0], c.frames[(n-1)*2:]...)
+		c.base += int64(n - 1)
+	}
+	return c.out
+}
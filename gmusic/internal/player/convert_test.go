@@ -0,0 +1,80 @@
+package player
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// pcm16 生成 frames 帧、channels 声道的 16-bit PCM，声道 c 的第 i 帧为 f(i, c)
+func pcm16(frames, channels int, f func(i, c int) int16) []byte {
+	out := make([]byte, 0, frames*channels*2)
+	for i := 0; i < frames; i++ {
+		for c := 0; c < channels; c++ {
+			out = binary.LittleEndian.AppendUint16(out, uint16(f(i, c)))
+		}
+	}
+	return out
+}
+
+// TestFormatConverter 输出为固定格式的立体声，帧数按采样率换算，分块转换与整块转换结果一致
+func TestFormatConverter(t *testing.T) {
+	sine := func(rate int) func(i, c int) int16 {
+		return func(i, c int) int16 {
+			return int16(10000 * math.Sin(2*math.Pi*440*float64(i)/float64(rate)+float64(c)))
+		}
+	}
+	tests := []struct {
+		name     string
+		rate     int
+		channels int
+	}{
+		{"48k 立体声", 48000, 2},
+		{"96k 立体声", 96000, 2},
+		{"22.05k 立体声", 22050, 2},
+		{"44.1k 单声道", 44100, 1},
+		{"48k 单声道", 48000, 1},
+		{"48k 六声道", 48000, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const seconds = 2
+			src := pcm16(tt.rate*seconds, tt.channels, sine(tt.rate))
+
+			whole := append([]byte(nil), newFormatConverter(tt.rate, tt.channels).convert(src)...)
+			wantFrames := fixedSampleRate * seconds
+			if got := len(whole) / (fixedChannelCount * fixedBytesPerSamp); got < wantFrames-2 || got > wantFrames {
+				t.Fatalf("输出 %d 帧，期望约 %d 帧", got, wantFrames)
+			}
+
+			// 输出第 k 帧对应源中 k·rate/44100 处，与源波形在该位置的值相差应在插值误差内
+			want := sine(fixedSampleRate)
+			for k := 0; k < len(whole)/4; k += 97 {
+				for c := 0; c < 2; c++ {
+					srcCh := c
+					if tt.channels == 1 {
+						srcCh = 0
+					}
+					got := int16(binary.LittleEndian.Uint16(whole[k*4+c*2:]))
+					if d := int(got) - int(want(k, srcCh)); d < -200 || d > 200 {
+						t.Fatalf("第 %d 帧声道 %d = %d，期望约 %d", k, c, got, want(k, srcCh))
+					}
+				}
+			}
+
+			// 按奇数字节分块（会切开帧）转换，结果应与整块一致
+			conv := newFormatConverter(tt.rate, tt.channels)
+			var chunked []byte
+			for off := 0; off < len(src); off += 4093 {
+				chunked = append(chunked, conv.convert(src[off:min(off+4093, len(src))])...)
+			}
+			if string(chunked) != string(whole) {
+				t.Fatalf("分块转换得到 %d 字节，整块转换 %d 字节，内容不一致", len(chunked), len(whole))
+			}
+		})
+	}
+
+	if newFormatConverter(fixedSampleRate, fixedChannelCount) != nil {
+		t.Error("格式一致时不应转换")
+	}
+}
@@ -0,0 +1,380 @@
+package player
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/mewkiz/flac"
+)
+
+// ErrUnsupportedFormat 没有可用解码器的音频格式
+var ErrUnsupportedFormat = errors.New("不支持的音频格式")
+
+// decodableExts 可以解码为 PCM 的扩展名。DSD（.dsf/.dff）、ALAC/AAC（.m4a/.aac）、APE 等格式没有解码器，
+// 播放、转码与 HLS 分段都会返回 ErrUnsupportedFormat
+var decodableExts = map[string]bool{".mp3": true, ".flac": true, ".wav": true}
+
+// CanDecode 判断文件能否解码为 PCM（即能否播放、转码）
+func CanDecode(filePath string) bool {
+	return decodableExts[strings.ToLower(filepath.Ext(filePath))]
+}
+
+// PCMStream 解码后的 16-bit 小端交织 PCM 流，供转码、推流等非声卡输出场景复用播放器的解码器
+type PCMStream struct {
+	io.Reader
+	SampleRate int     // 采样率（Hz）
+	Channels   int     // 声道数
+	Duration   float64 // 区间时长（秒），未知时为 0
+
+	file *os.File
+}
+
+// BitsPerSample 输出位深，解码器统一输出 16-bit
+func (s *PCMStream) BitsPerSample() int { return 16 }
+
+// Close 关闭底层文件
+func (s *PCMStream) Close() error { return s.file.Close() }
+
+// OpenPCM 打开音频文件并解码为 PCM，只输出 [startSec, endSec) 区间（endSec<=0 表示到文件末尾），
+// 用于 CUE 分轨等需要精确区间的场景。
+func OpenPCM(filePath string, startSec, endSec float64) (*PCMStream, error) {
+	return OpenPCMAt(filePath, startSec, 0, endSec)
+}
+
+// OpenPCMAt 同 OpenPCM，但从 startSec 之后再偏移 offset 帧（按源采样率）开始输出。
+// 偏移以整数帧计算，不经过浮点秒数换算，HLS 等按采样拼接的分段可以精确衔接。
+func OpenPCMAt(filePath string, startSec float64, offset int64, endSec float64) (*PCMStream, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	dec, dur, sr, ch, err := newDecoder(f, filePath)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if sr <= 0 || ch <= 0 {
+		_ = f.Close()
+		return nil, fmt.Errorf("无法确定采样参数: %s", filePath)
+	}
+
+	frameBytes := int64(ch * fixedBytesPerSamp)
+	toBytes := func(sec float64) int64 {
+		n := int64(sec * float64(int64(sr)*frameBytes))
+		return n - n%frameBytes
+	}
+	if startSec < 0 {
+		startSec = 0
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	startBytes := toBytes(startSec) + offset*frameBytes
+	if startBytes > 0 {
+		// 解码器支持定位时直接跳转，否则顺序解码并丢弃
+		seeked := false
+		if s, ok := dec.(io.Seeker); ok {
+			_, err := s.Seek(startBytes, io.SeekStart)
+			seeked = err == nil
+		}
+		if !seeked {
+			if _, err := io.CopyN(io.Discard, dec, startBytes); err != nil {
+				_ = f.Close()
+				return nil, fmt.Errorf("定位起点失败: %w", err)
+			}
+		}
+	}
+	var r io.Reader = dec
+	skipped := startSec + float64(offset)/float64(sr)
+	switch {
+	case endSec > startSec:
+		r = io.LimitReader(dec, max(toBytes(endSec)-startBytes, 0))
+		dur = max(endSec-skipped, 0)
+	case skipped > 0 && dur > skipped:
+		dur -= skipped
+	}
+	return &PCMStream{Reader: r, SampleRate: sr, Channels: ch, Duration: dur, file: f}, nil
+}
+
+// newDecoder 根据扩展名选择解码器，返回 16-bit 小端交织 PCM 的 io.Reader、时长（秒）、采样率与声道数
+func newDecoder(file *os.File, filePath string) (io.Reader, float64, int, int, error) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	switch ext {
+	case ".mp3":
+		dec, err := mp3.NewDecoder(file)
+		if err != nil {
+			return nil, 0, 0, 0, fmt.Errorf("MP3 解码失败: %w", err)
+		}
+		sr := dec.SampleRate()
+		ch := 2 // go-mp3 输出为 2 通道 16-bit PCM
+		bytesLen := float64(dec.Length())
+		bps := float64(sr*ch) * 2
+		var dur float64
+		if bps > 0 {
+			dur = bytesLen / bps
+		}
+		return dec, dur, sr, ch, nil
+	case ".flac":
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, 0, 0, 0, err
+		}
+		// 优先使用可定位的流；带 ID3v2 前缀等 NewSeek 无法处理的文件退回顺序解码
+		seekable := true
+		stream, err := flac.NewSeek(file)
+		if err != nil {
+			seekable = false
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return nil, 0, 0, 0, err
+			}
+			stream, err = flac.New(file)
+			if err != nil {
+				return nil, 0, 0, 0, fmt.Errorf("FLAC 解析失败: %w", err)
+			}
+		}
+		sr := int(stream.Info.SampleRate)
+		ch := int(stream.Info.NChannels)
+		bps := int(stream.Info.BitsPerSample)
+		reader := &flacPCMReader{stream: stream, bitsPerSample: bps, channels: ch, seekable: seekable}
+		var dur float64
+		if stream.Info.NSamples > 0 && stream.Info.SampleRate > 0 {
+			dur = float64(stream.Info.NSamples) / float64(stream.Info.SampleRate)
+		}
+		return reader, dur, sr, ch, nil
+	case ".wav":
+		return newWAVDecoder(file)
+	default:
+		return nil, 0, 0, 0, fmt.Errorf("%w: %s（只能解码 mp3、flac、wav，DSD、ALAC、AAC、APE 等格式暂不支持）", ErrUnsupportedFormat, ext)
+	}
+}
+
+// wavPCMReader 将 WAV 数据块（8/16/24/32-bit 整数或 32-bit 浮点）转换为 16-bit 小端 PCM
+type wavPCMReader struct {
+	r            io.Reader
+	file         *os.File
+	dataStart    int64 // data 块在文件中的起始偏移
+	dataSize     int64
+	bytesPerSamp int
+	isFloat      bool
+	buf          []byte
+	out          []byte
+	pos          int
+}
+
+// newWAVDecoder 解析 RIFF/WAVE 头部并定位到 data 块
+func newWAVDecoder(file *os.File) (io.Reader, float64, int, int, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, 0, 0, err
+	}
+	var hdr [12]byte
+	if _, err := io.ReadFull(file, hdr[:]); err != nil {
+		return nil, 0, 0, 0, fmt.Errorf("WAV 解析失败: %w", err)
+	}
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return nil, 0, 0, 0, fmt.Errorf("WAV 解析失败: 不是 RIFF/WAVE 文件")
+	}
+
+	var (
+		format, channels, bits uint16
+		sampleRate             uint32
+		haveFmt                bool
+	)
+	for {
+		var ch [8]byte
+		if _, err := io.ReadFull(file, ch[:]); err != nil {
+			return nil, 0, 0, 0, fmt.Errorf("WAV 解析失败: 缺少 data 块")
+		}
+		id := string(ch[0:4])
+		size := int64(binary.LittleEndian.Uint32(ch[4:8]))
+		switch id {
+		case "fmt ":
+			body := make([]byte, size)
+			if _, err := io.ReadFull(file, body); err != nil || size < 16 {
+				return nil, 0, 0, 0, fmt.Errorf("WAV 解析失败: fmt 块损坏")
+			}
+			format = binary.LittleEndian.Uint16(body[0:2])
+			channels = binary.LittleEndian.Uint16(body[2:4])
+			sampleRate = binary.LittleEndian.Uint32(body[4:8])
+			bits = binary.LittleEndian.Uint16(body[14:16])
+			// WAVE_FORMAT_EXTENSIBLE：真实格式在子格式 GUID 的前两个字节
+			if format == 0xFFFE && size >= 26 {
+				format = binary.LittleEndian.Uint16(body[24:26])
+			}
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return nil, 0, 0, 0, fmt.Errorf("WAV 解析失败: data 块位于 fmt 块之前")
+			}
+			isFloat := format == 3
+			if (format != 1 && !isFloat) || channels == 0 || bits%8 != 0 || bits == 0 || bits > 32 || (isFloat && bits != 32) {
+				return nil, 0, 0, 0, fmt.Errorf("%w: WAV 编码 %d/%d-bit", ErrUnsupportedFormat, format, bits)
+			}
+			var dur float64
+			if byteRate := float64(sampleRate) * float64(channels) * float64(bits/8); byteRate > 0 {
+				dur = float64(size) / byteRate
+			}
+			start, err := file.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, 0, 0, 0, err
+			}
+			reader := &wavPCMReader{
+				r:            io.LimitReader(file, size),
+				file:         file,
+				dataStart:    start,
+				dataSize:     size,
+				bytesPerSamp: int(bits / 8),
+				isFloat:      isFloat,
+			}
+			return reader, dur, int(sampleRate), int(channels), nil
+		default:
+			// RIFF 块按偶数字节对齐
+			if _, err := file.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return nil, 0, 0, 0, err
+			}
+		}
+	}
+}
+
+// Seek 定位到输出 PCM（16-bit）的字节偏移，仅支持 io.SeekStart
+func (r *wavPCMReader) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart || offset < 0 {
+		return 0, fmt.Errorf("WAV 流不支持该定位方式")
+	}
+	src := offset / 2 * int64(r.bytesPerSamp)
+	if src > r.dataSize {
+		src = r.dataSize
+	}
+	if _, err := r.file.Seek(r.dataStart+src, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r.r = io.LimitReader(r.file, r.dataSize-src)
+	r.out = r.out[:0]
+	r.pos = 0
+	return src / int64(r.bytesPerSamp) * 2, nil
+}
+
+func (r *wavPCMReader) Read(p []byte) (int, error) {
+	if r.bytesPerSamp == 2 && !r.isFloat {
+		return r.r.Read(p)
+	}
+	for r.pos >= len(r.out) {
+		if cap(r.buf) == 0 {
+			r.buf = make([]byte, 4096*r.bytesPerSamp)
+		}
+		n, err := io.ReadFull(r.r, r.buf)
+		n -= n % r.bytesPerSamp
+		if n == 0 {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		r.out = r.out[:0]
+		for i := 0; i < n; i += r.bytesPerSamp {
+			r.out = binary.LittleEndian.AppendUint16(r.out, uint16(r.sample16(r.buf[i:i+r.bytesPerSamp])))
+		}
+		r.pos = 0
+	}
+	n := copy(p, r.out[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+// sample16 将单个采样转换为 16-bit：整数取高 16 位，8-bit 为无符号偏移编码
+func (r *wavPCMReader) sample16(b []byte) int16 {
+	switch {
+	case r.isFloat:
+		v := float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) * math.MaxInt16
+		return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, v)))
+	case len(b) == 1:
+		return int16(int(b[0])-128) << 8
+	default:
+		return int16(binary.LittleEndian.Uint16(b[len(b)-2:]))
+	}
+}
+
+// flacPCMReader 将 mewkiz/flac 流转换为交织的 16-bit PCM 字节流（不重采样）
+type flacPCMReader struct {
+	stream        *flac.Stream
+	bitsPerSample int
+	channels      int
+	seekable      bool // stream 是否由 flac.NewSeek 创建
+	buf           []byte
+	pos           int
+	discard       int // 定位后需丢弃的字节数（Seek 只能定位到帧首）
+}
+
+// Seek 定位到输出 PCM 的字节偏移（仅支持 io.SeekStart），用于转码/分段时快速跳到区间起点
+func (r *flacPCMReader) Seek(offset int64, whence int) (int64, error) {
+	if !r.seekable || whence != io.SeekStart || r.channels <= 0 {
+		return 0, fmt.Errorf("FLAC 流不支持定位")
+	}
+	frameBytes := int64(r.channels * 2)
+	sample := uint64(offset / frameBytes)
+	got, err := r.stream.Seek(sample)
+	if err != nil {
+		return 0, err
+	}
+	r.buf = r.buf[:0]
+	r.pos = 0
+	r.discard = int(sample-got) * int(frameBytes)
+	return int64(sample) * frameBytes, nil
+}
+
+func (r *flacPCMReader) Read(p []byte) (n int, err error) {
+	for r.pos >= len(r.buf) {
+		fr, err := r.stream.ParseNext()
+		if err != nil {
+			return 0, err
+		}
+		chs := len(fr.Subframes)
+		if chs == 0 {
+			return 0, io.EOF
+		}
+		block := len(fr.Subframes[0].Samples)
+		need := chs * block * 2
+		if cap(r.buf) < need {
+			r.buf = make([]byte, 0, need)
+		} else {
+			r.buf = r.buf[:0]
+		}
+		shift := 0
+		if r.bitsPerSample > 16 {
+			shift = r.bitsPerSample - 16
+		}
+		for i := 0; i < block; i++ {
+			for c := 0; c < chs; c++ {
+				v := int32(fr.Subframes[c].Samples[i])
+				if shift > 0 {
+					v >>= uint(shift)
+				}
+				if v > math.MaxInt16 {
+					v = math.MaxInt16
+				}
+				if v < math.MinInt16 {
+					v = math.MinInt16
+				}
+				u := uint16(int16(v))
+				r.buf = append(r.buf, byte(u), byte(u>>8))
+			}
+		}
+		r.pos = 0
+		if r.discard > 0 {
+			skip := r.discard
+			if skip > len(r.buf) {
+				skip = len(r.buf)
+			}
+			r.pos = skip
+			r.discard -= skip
+		}
+	}
+	n = copy(p, r.buf[r.pos:])
+	r.pos += n
+	return n, nil
+}
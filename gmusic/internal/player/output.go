@@ -0,0 +1,125 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/oto"
+)
+
+// Output 音频输出后端：每次播放打开一路 PCM 输出（格式见 OutputFormat），
+// 写入在输出端来不及消费时阻塞，播放进度由此与实际发声保持一致
+type Output interface {
+	Open() (io.WriteCloser, error)
+	// Name 后端的可读描述，用于状态展示
+	Name() string
+}
+
+// oto v1 每个进程只能创建一个 Context，所有使用本机声卡的播放器共享它，同时播放时由 oto 混音
+var (
+	otoOnce    sync.Once
+	otoContext *oto.Context
+	otoErr     error
+)
+
+type localOutput struct{ ctx *oto.Context }
+
+// LocalOutput 本机默认声卡
+func LocalOutput() (Output, error) {
+	otoOnce.Do(func() {
+		otoContext, otoErr = oto.NewContext(fixedSampleRate, fixedChannelCount, fixedBytesPerSamp, otoBufferSize)
+		if otoErr != nil {
+			otoErr = fmt.Errorf("创建音频上下文失败: %w", otoErr)
+		}
+	})
+	if otoErr != nil {
+		return nil, otoErr
+	}
+	return &localOutput{ctx: otoContext}, nil
+}
+
+func (o *localOutput) Open() (io.WriteCloser, error) { return o.ctx.NewPlayer(), nil }
+func (o *localOutput) Name() string                  { return "local" }
+
+// NullOutput 丢弃数据但按实时速率消费，适合没有本地声卡、只通过 /live 或多房间转发收听的区域
+func NullOutput() Output { return nullOutput{} }
+
+type nullOutput struct{}
+
+func (nullOutput) Open() (io.WriteCloser, error) { return &nullSink{}, nil }
+func (nullOutput) Name() string                  { return "null" }
+
+// nullSink 模拟一个与本机声卡同样大小的缓冲：缓冲已满时等待，直到积压回落到缓冲时长以内
+type nullSink struct{ clock deviceClock }
+
+func (s *nullSink) Write(p []byte) (int, error) {
+	s.clock.advance(len(p))
+	if wait := time.Until(s.clock.end) - OutputLatency(); wait > 0 {
+		time.Sleep(wait)
+	}
+	return len(p), nil
+}
+
+func (s *nullSink) Close() error { return nil }
+
+// CommandOutput 把 PCM 写入外部命令的标准输入，用于驱动本机的其他声卡，
+// 如 aplay -D plughw:1 -t raw -f cd 或 pacat --device=<sink>。
+// 命令在首次打开时启动并保持运行（退出后下次打开时重启），播放速度由管道的背压控制。
+func CommandOutput(name string, args ...string) Output {
+	return &commandOutput{name: name, args: args}
+}
+
+type commandOutput struct {
+	name string
+	args []string
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+func (o *commandOutput) Name() string { return "command" }
+
+func (o *commandOutput) Open() (io.WriteCloser, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.cmd == nil {
+		cmd := exec.Command(o.name, o.args...)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("启动输出命令 %s 失败: %w", o.name, err)
+		}
+		o.cmd, o.stdin = cmd, stdin
+		go func() {
+			_ = cmd.Wait()
+			o.mu.Lock()
+			if o.cmd == cmd {
+				o.cmd, o.stdin = nil, nil
+			}
+			o.mu.Unlock()
+		}()
+	}
+	return &commandSink{w: o.stdin}, nil
+}
+
+// commandSink 一次播放对命令管道的引用；关闭它不会结束命令，使相邻歌曲之间没有重启间隙
+type commandSink struct{ w io.Writer }
+
+var errOutputExited = errors.New("输出命令已退出")
+
+func (s *commandSink) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("%w: %v", errOutputExited, err)
+	}
+	return n, nil
+}
+
+func (s *commandSink) Close() error { return nil }
@@ -6,14 +6,8 @@ import (
 	"io"
 	"math"
 	"os"
-	"path/filepath"
-	"strings"
 	"sync"
 	"time"
-
-	"github.com/hajimehoshi/go-mp3"
-	"github.com/hajimehoshi/oto"
-	"github.com/mewkiz/flac"
 )
 
 // 为 v1 版本固定一个输出参数，避免频繁创建 Context 造成设备异常
@@ -21,13 +15,15 @@ const (
 	fixedSampleRate   = 44100
 	fixedChannelCount = 2
 	fixedBytesPerSamp = 2 // 16-bit
+	// otoBufferSize 设备缓冲字节数，决定了从写入到发声的延迟（见 OutputLatency）
+	otoBufferSize = 8192
 )
 
-// Player 基于 oto v1 的播放器，支持 MP3 与 FLAC（16-bit PCM 输出）
+// Player 播放器，支持 MP3 与 FLAC（16-bit PCM 输出）；输出后端可替换，默认为 oto v1 驱动的本机声卡
 type Player struct {
 	mu           sync.Mutex
-	context      *oto.Context // Context 单例（仅创建一次）
-	player       *oto.Player
+	output       Output         // 输出后端（本机声卡、外部命令等）
+	player       io.WriteCloser // 本次播放打开的输出
 	playerInited bool
 
 	currentFile *os.File
@@ -43,30 +39,65 @@ type Player struct {
 
 	initialSkipBytes int64 // 首次播放/跳转时需要丢弃的 PCM 字节数
 
+	// 播放区间（CUE 分轨），相对源文件开头的秒数；rangeEnd<=0 表示到文件末尾
+	rangeStart float64
+	rangeEnd   float64
+
 	stopCh chan struct{}
 	doneCh chan struct{}
+
+	onFinished func()                  // 自然播放结束（EOF 或区间终点）时回调，Stop/切歌不触发
+	tap        func([]byte, time.Time) // 每块写入音频设备的 PCM（应用音量之前）及其预计发声时间都会同步交给 tap，用于直播与多房间转发
 }
 
-// NewPlayer 创建播放器，固定创建一个 Context，后续重复复用（v1 建议如此）
+// NewPlayer 创建输出到本机默认声卡的播放器
 func NewPlayer() (*Player, error) {
-	ctx, err := oto.NewContext(fixedSampleRate, fixedChannelCount, fixedBytesPerSamp, 8192)
+	out, err := LocalOutput()
 	if err != nil {
-		return nil, fmt.Errorf("创建音频上下文失败: %w", err)
+		return nil, err
 	}
+	return NewPlayerWithOutput(out), nil
+}
+
+// NewPlayerWithOutput 创建输出到指定后端的播放器
+func NewPlayerWithOutput(out Output) *Player {
 	return &Player{
-		context:     ctx,
+		output:      out,
 		volume:      1.0,
 		bytesPerSec: float64(fixedSampleRate * fixedChannelCount * fixedBytesPerSamp),
-	}, nil
+	}
 }
 
-// Play 兼容旧调用：从 0 秒开始
-func (p *Player) Play(filePath string) error { return p.playAt(filePath, 0) }
+// Output 播放器的输出后端
+func (p *Player) Output() Output { return p.output }
 
-// SeekTo 跳转到指定秒数（近似，按 PCM 字节跳过）
+// SetOnFinished 设置自然播放结束回调（在独立 goroutine 中调用，可在其中直接切歌）
+func (p *Player) SetOnFinished(fn func()) {
+	p.mu.Lock()
+	p.onFinished = fn
+	p.mu.Unlock()
+}
+
+// Play 兼容旧调用：从 0 秒开始播放整个文件
+func (p *Player) Play(filePath string) error { return p.playAt(filePath, 0, 0, 0) }
+
+// PlayRange 只播放文件中 [startSec, endSec) 区间（CUE 分轨），endSec<=0 表示播放到文件末尾。
+// 进度、时长与 SeekTo 均相对区间起点计算，对调用方而言与普通歌曲一致。
+func (p *Player) PlayRange(filePath string, startSec, endSec float64) error {
+	if startSec < 0 {
+		startSec = 0
+	}
+	if endSec > 0 && endSec <= startSec {
+		return fmt.Errorf("无效的播放区间: %.3f - %.3f", startSec, endSec)
+	}
+	return p.playAt(filePath, startSec, endSec, 0)
+}
+
+// SeekTo 跳转到指定秒数（相对区间起点）；解码器支持定位时直接跳转，否则按 PCM 字节顺序跳过
 func (p *Player) SeekTo(sec float64) error {
 	p.mu.Lock()
 	path := p.currentFilePath
+	rangeStart, rangeEnd := p.rangeStart, p.rangeEnd
 	p.mu.Unlock()
 	if path == "" {
 		return fmt.Errorf("无正在播放的文件")
@@ -74,11 +105,93 @@ func (p *Player) SeekTo(sec float64) error {
 	if sec < 0 {
 		sec = 0
 	}
-	return p.playAt(path, sec)
+	return p.playAt(path, rangeStart, rangeEnd, sec)
 }
 
-// playAt 播放指定文件并从 startSec 秒开始
-func (p *Player) playAt(filePath string, startSec float64) error {
+// SetPCMTap 设置 PCM 旁路：播放循环每向设备写入一块 PCM 就调用一次 fn（在播放 goroutine 中同步调用，
+// fn 不得阻塞且不能持有 pcm）。交给 fn 的是应用本机音量之前的数据，音量由各收听端自行控制；
+// at 为这块数据首个采样的预计发声时间（见 deviceClock）。PCM 格式见 OutputFormat。
+func (p *Player) SetPCMTap(fn func(pcm []byte, at time.Time)) {
+	p.mu.Lock()
+	p.tap = fn
+	p.mu.Unlock()
+}
+
+// OutputFormat 音频设备的输出格式：采样率、声道数（16-bit 小端 PCM）
+func OutputFormat() (sampleRate, channels int) {
+	return fixedSampleRate, fixedChannelCount
+}
+
+// OutputLatency 设备缓冲写满时，一块 PCM 从写入到发声的近似延迟
+func OutputLatency() time.Duration {
+	return pcmDuration(otoBufferSize)
+}
+
+// pcmDuration 按固定输出格式换算 PCM 字节数对应的时长
+func pcmDuration(n int) time.Duration {
+	bps := fixedSampleRate * fixedChannelCount * fixedBytesPerSamp
+	return time.Duration(n) * time.Second / time.Duration(bps)
+}
+
+// deviceClock 估计已写入设备的数据何时播完：设备按标称采样率连续消费，
+// 缓冲播空后从下一次写入时重新开始计时。据此得到每块数据的发声时间，用于多房间对齐。
+type deviceClock struct {
+	end time.Time
+}
+
+// next 下一个写入的采样预计的发声时间
+func (d *deviceClock) next() time.Time {
+	if now := time.Now(); d.end.Before(now) {
+		return now
+	}
+	return d.end
+}
+
+// advance 记录写入了 n 字节，返回这块数据的发声时间
+func (d *deviceClock) advance(n int) time.Time {
+	at := d.next()
+	d.end = at.Add(pcmDuration(n))
+	return at
+}
+
+// Stream 直接向音频设备写入外部 PCM 的输出流（多房间跟随模式下播放主机转发的声音）
+type Stream struct {
+	p     *Player
+	pl    io.WriteCloser
+	buf   []byte
+	clock deviceClock
+}
+
+// OpenStream 停止本地播放并打开一个外部 PCM 输出流；写入的数据同样受 SetVolume 控制
+func (p *Player) OpenStream() (*Stream, error) {
+	p.Stop()
+	pl, err := p.output.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{p: p, pl: pl}, nil
+}
+
+// Write 应用当前音量后写入设备；设备缓冲已满时阻塞。pcm 格式见 OutputFormat，调用方可复用 pcm。
+func (s *Stream) Write(pcm []byte) (int, error) {
+	vol := s.p.GetVolume()
+	if vol < 1.0 {
+		s.buf = append(s.buf[:0], pcm...)
+		applyVolume16LE(s.buf, vol)
+		pcm = s.buf
+	}
+	s.clock.advance(len(pcm))
+	return s.pl.Write(pcm)
+}
+
+// NextStart 此刻写入的下一个采样预计的发声时间
+func (s *Stream) NextStart() time.Time { return s.clock.next() }
+
+// Close 关闭输出流
+func (s *Stream) Close() error { return s.pl.Close() }
+
+// playAt 播放指定文件的 [rangeStart, rangeEnd) 区间，并从区间内 startSec 秒开始
+func (p *Player) playAt(filePath string, rangeStart, rangeEnd, startSec float64) error {
 	p.mu.Lock()
 	// 若正在播放，优雅停止并等待播放循环退出
 	if p.playerInited || p.isPlaying {
@@ -108,7 +221,7 @@ func (p *Player) playAt(filePath string, startSec float64) error {
 	p.currentFile = f
 	p.currentFilePath = filePath
 
-	dec, dur, _, _, err := p.getDecoder(f, filePath)
+	dec, dur, sr, ch, err := p.getDecoder(f, filePath)
 	if err != nil {
 		_ = f.Close()
 		p.currentFile = nil
@@ -116,29 +229,62 @@ func (p *Player) playAt(filePath string, startSec float64) error {
 		return err
 	}
 	p.decoder = dec
+	p.rangeStart, p.rangeEnd = rangeStart, rangeEnd
+	// 时长按区间计算：有终点取区间长度，否则为文件剩余部分
+	switch {
+	case rangeEnd > 0:
+		dur = rangeEnd - rangeStart
+	case rangeStart > 0 && dur > rangeStart:
+		dur -= rangeStart
+	}
 	p.duration = dur
 	// bytesPerSec 统一采用固定输出参数
 	p.bytesPerSec = float64(fixedSampleRate * fixedChannelCount * fixedBytesPerSamp)
 
-	// 使用已创建的 Context，避免重复创建导致设备异常
-	if p.context == nil {
-		ctx, err := oto.NewContext(fixedSampleRate, fixedChannelCount, fixedBytesPerSamp, 8192)
-		if err != nil {
-			p.mu.Unlock()
-			return fmt.Errorf("创建音频上下文失败: %w", err)
-		}
-		p.context = ctx
+	// 区间边界按源文件的 PCM 参数换算为字节，并对齐到完整采样帧，避免声道错位
+	frameBytes := int64(fixedChannelCount * fixedBytesPerSamp)
+	srcBytesPerSec := p.bytesPerSec
+	if sr > 0 && ch > 0 {
+		frameBytes = int64(ch * fixedBytesPerSamp)
+		srcBytesPerSec = float64(int64(sr) * frameBytes)
 	}
-	p.player = p.context.NewPlayer()
+	toBytes := func(sec float64) int64 {
+		n := int64(sec * srcBytesPerSec)
+		return n - n%frameBytes
+	}
+
+	out, err := p.output.Open()
+	if err != nil {
+		_ = f.Close()
+		p.currentFile = nil
+		p.mu.Unlock()
+		return fmt.Errorf("打开音频输出失败: %w", err)
+	}
+	p.player = out
 	p.playerInited = true
 
 	// 初始化跳过字节数与当前位置
-	if startSec > 0 && p.bytesPerSec > 0 {
-		p.initialSkipBytes = int64(startSec * p.bytesPerSec)
-		p.currentPosition = startSec
-	} else {
-		p.initialSkipBytes = 0
-		p.currentPosition = 0
+	if startSec < 0 || p.bytesPerSec <= 0 {
+		startSec = 0
+	}
+	// 解码器支持定位时直接跳到起点（FLAC 定位到帧首后自行丢弃帧内多出的采样），
+	// 只有定位失败或不支持定位时才由播放循环顺序解码并丢弃
+	skip := toBytes(rangeStart + startSec)
+	if s, ok := dec.(io.Seeker); ok && skip > 0 {
+		if pos, err := s.Seek(skip, io.SeekStart); err == nil && pos <= skip {
+			skip -= pos
+		}
+	}
+	p.initialSkipBytes = skip
+	p.currentPosition = startSec
+
+	// 区间剩余可写入的字节数，-1 表示不限（播放到文件末尾）
+	limit := int64(-1)
+	if rangeEnd > 0 {
+		limit = toBytes(rangeEnd) - toBytes(rangeStart+startSec)
+		if limit < 0 {
+			limit = 0
+		}
 	}
 
 	p.isPlaying = true
@@ -154,52 +300,20 @@ func (p *Player) playAt(filePath string, startSec float64) error {
 	pl := p.player
 	bps := p.bytesPerSec
 	p.mu.Unlock()
-	go p.playLoop(stopCh, decReader, pl, bps)
+	go p.playLoop(stopCh, decReader, newFormatConverter(sr, ch), pl, bps, limit)
 	return nil
 }
 
 // getDecoder 根据扩展名选择解码器，返回 PCM io.Reader
 func (p *Player) getDecoder(file *os.File, filePath string) (io.Reader, float64, int, int, error) {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	switch ext {
-	case ".mp3":
-		dec, err := mp3.NewDecoder(file)
-		if err != nil {
-			return nil, 0, 0, 0, fmt.Errorf("MP3 解码失败: %w", err)
-		}
-		sr := dec.SampleRate()
-		ch := 2 // go-mp3 输出为 2 通道 16-bit PCM
-		bytesLen := float64(dec.Length())
-		bps := float64(sr*ch) * 2
-		var dur float64
-		if bps > 0 {
-			dur = bytesLen / bps
-		}
-		return dec, dur, sr, ch, nil
-	case ".flac":
-		if _, err := file.Seek(0, io.SeekStart); err != nil {
-			return nil, 0, 0, 0, err
-		}
-		stream, err := flac.New(file)
-		if err != nil {
-			return nil, 0, 0, 0, fmt.Errorf("FLAC 解析失败: %w", err)
-		}
-		sr := int(stream.Info.SampleRate)
-		ch := int(stream.Info.NChannels)
-		bps := int(stream.Info.BitsPerSample)
-		reader := &flacPCMReader{stream: stream, bitsPerSample: bps}
-		var dur float64
-		if stream.Info.NSamples > 0 && stream.Info.SampleRate > 0 {
-			dur = float64(stream.Info.NSamples) / float64(stream.Info.SampleRate)
-		}
-		return reader, dur, sr, ch, nil
-	default:
-		return nil, 0, 0, 0, fmt.Errorf("不支持的音频格式: %s", ext)
-	}
+	return newDecoder(file, filePath)
 }
 
-// 播放循环：在收到 stopCh 或读到 EOF/错误时退出；退出后负责清理资源并发出 doneCh
-func (p *Player) playLoop(stopCh <-chan struct{}, dec io.Reader, pl *oto.Player, bps float64) {
+// 播放循环：在收到 stopCh、读到 EOF/错误或写满 limit 字节（limit>=0 时）后退出；
+// 退出后负责清理资源并发出 doneCh。跳过与 limit 按源 PCM 计算，之后经 conv 转为固定输出格式，
+// 设备与旁路收到的都是 OutputFormat 格式的数据，bps 为输出格式的字节率
+func (p *Player) playLoop(stopCh <-chan struct{}, dec io.Reader, conv *formatConverter, pl io.WriteCloser, bps float64, limit int64) {
+	finished := false // 是否为自然结束（区别于 stop）
 	defer func() {
 		if pl != nil {
 			_ = pl.Close()
@@ -215,13 +329,18 @@ func (p *Player) playLoop(stopCh <-chan struct{}, dec io.Reader, pl *oto.Player,
 		p.mu.Unlock()
 		p.mu.Lock()
 		done := p.doneCh
+		onFinished := p.onFinished
 		p.mu.Unlock()
 		if done != nil {
 			close(done)
 		}
+		if finished && onFinished != nil {
+			go onFinished()
+		}
 	}()
 
 	buf := make([]byte, 4096)
+	var clock deviceClock
 	for {
 		select {
 		case <-stopCh:
@@ -233,6 +352,7 @@ func (p *Player) playLoop(stopCh <-chan struct{}, dec io.Reader, pl *oto.Player,
 		paused := p.isPaused
 		vol := p.volume
 		skip := p.initialSkipBytes
+		tap := p.tap
 		p.mu.Unlock()
 		if paused {
 			select {
@@ -263,21 +383,39 @@ func (p *Player) playLoop(stopCh <-chan struct{}, dec io.Reader, pl *oto.Player,
 				}
 			}
 
+			// 区间播放：截断到剩余字节数
+			if limit >= 0 && int64(n) > limit {
+				n = int(limit)
+			}
+
 			if n > 0 {
+				pcm := conv.convert(buf[:n])
+				at := clock.advance(len(pcm))
+				if tap != nil && len(pcm) > 0 {
+					tap(pcm, at)
+				}
 				if vol < 1.0 {
-					applyVolume16LE(buf[:n], vol)
+					applyVolume16LE(pcm, vol)
 				}
-				if _, werr := pl.Write(buf[:n]); werr != nil {
+				if _, werr := pl.Write(pcm); werr != nil {
 					return
 				}
 				if bps > 0 {
 					p.mu.Lock()
-					p.currentPosition += float64(n) / bps
+					p.currentPosition += float64(len(pcm)) / bps
 					p.mu.Unlock()
 				}
+				if limit >= 0 {
+					limit -= int64(n)
+				}
+			}
+			if limit == 0 {
+				finished = true
+				return
 			}
 		}
 		if err == io.EOF {
+			finished = true
 			return
 		}
 		if err != nil && err != io.EOF {
@@ -329,7 +467,16 @@ func (p *Player) IsPlaying() bool {
 	p.mu.Unlock()
 	return v
 }
-func (p *Player) Close() error { p.Stop(); return nil }
+
+// IsPaused 是否处于暂停状态（已加载歌曲但未输出）
+func (p *Player) IsPaused() bool {
+	p.mu.Lock()
+	v := p.isPlaying && p.isPaused
+	p.mu.Unlock()
+	return v
+}
+func (p *Player) GetVolume() float32 { p.mu.Lock(); v := p.volume; p.mu.Unlock(); return v }
+func (p *Player) Close() error       { p.Stop(); return nil }
 
 // 16-bit 小端 PCM 应用音量
 func applyVolume16LE(b []byte, volume float32) {
@@ -354,55 +501,3 @@ func applyVolume16LE(b []byte, volume float32) {
 		binary.LittleEndian.PutUint16(b[i:], uint16(int16(fv)))
 	}
 }
-
-// flacPCMReader 将 mewkiz/flac 流转换为交织的 16-bit PCM 字节流（不重采样）
-type flacPCMReader struct {
-	stream        *flac.Stream
-	bitsPerSample int
-	buf           []byte
-	pos           int
-}
-
-func (r *flacPCMReader) Read(p []byte) (n int, err error) {
-	for r.pos >= len(r.buf) {
-		fr, err := r.stream.ParseNext()
-		if err != nil {
-			return 0, err
-		}
-		chs := len(fr.Subframes)
-		if chs == 0 {
-			return 0, io.EOF
-		}
-		block := len(fr.Subframes[0].Samples)
-		need := chs * block * 2
-		if cap(r.buf) < need {
-			r.buf = make([]byte, 0, need)
-		} else {
-			r.buf = r.buf[:0]
-		}
-		shift := 0
-		if r.bitsPerSample > 16 {
-			shift = r.bitsPerSample - 16
-		}
-		for i := 0; i < block; i++ {
-			for c := 0; c < chs; c++ {
-				v := int32(fr.Subframes[c].Samples[i])
-				if shift > 0 {
-					v >>= uint(shift)
-				}
-				if v > math.MaxInt16 {
-					v = math.MaxInt16
-				}
-				if v < math.MinInt16 {
-					v = math.MinInt16
-				}
-				u := uint16(int16(v))
-				r.buf = append(r.buf, byte(u), byte(u>>8))
-			}
-		}
-		r.pos = 0
-	}
-	n = copy(p, r.buf[r.pos:])
-	r.pos += n
-	return n, nil
-}
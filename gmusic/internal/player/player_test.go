@@ -0,0 +1,110 @@
+package player
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// rampSample 测试文件第 i 帧左声道的值（右声道取反），由采样值即可反推出帧号
+func rampSample(i int) int16 { return int16(i % 30000) }
+
+// writeRampFLAC 写入 44.1k 立体声的 FLAC，每帧 4096 个采样
+func writeRampFLAC(t *testing.T, path string, seconds int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	const block = 4096
+	total := fixedSampleRate * seconds
+	enc, err := flac.NewEncoder(f, &meta.StreamInfo{
+		BlockSizeMin: block, BlockSizeMax: block, SampleRate: fixedSampleRate,
+		NChannels: 2, BitsPerSample: 16, NSamples: uint64(total),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start := 0; start < total; start += block {
+		n := min(block, total-start)
+		l, r := make([]int32, n), make([]int32, n)
+		for i := range l {
+			l[i] = int32(rampSample(start + i))
+			r[i] = -l[i]
+		}
+		err := enc.WriteFrame(&frame.Frame{
+			Header: frame.Header{HasFixedBlockSize: true, BlockSize: uint16(n), SampleRate: fixedSampleRate,
+				Channels: frame.ChannelsLR, BitsPerSample: 16},
+			Subframes: []*frame.Subframe{
+				{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: l, NSamples: n},
+				{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: r, NSamples: n},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPlayRangeSeeks CUE 分轨与区间内跳转直接定位到起点（不再顺序解码之前的部分），第一块 PCM 即为起点处的采样
+func TestPlayRangeSeeks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "album.flac")
+	writeRampFLAC(t, path, 20)
+
+	p := NewPlayerWithOutput(NullOutput())
+	first := make(chan []byte, 1)
+	p.SetPCMTap(func(pcm []byte, _ time.Time) {
+		select {
+		case first <- append([]byte(nil), pcm...):
+		default:
+		}
+	})
+	defer p.Stop()
+
+	tests := []struct {
+		name  string
+		start func() error
+		frame int // 期望第一块 PCM 的首帧在文件中的帧号
+	}{
+		// 起点不在 FLAC 帧首，定位后须丢弃帧内多出的采样
+		{"分轨起点", func() error { return p.PlayRange(path, 12.3, 15) }, 542430},
+		{"分轨内跳转", func() error { return p.SeekTo(1.5) }, 608580},
+		{"整轨跳转", func() error { return p.playAt(path, 0, 0, 17) }, 749700},
+	}
+	for _, tt := range tests {
+		p.Stop() // 停止后不会再有上一次播放的数据
+		select {
+		case <-first:
+		default:
+		}
+		if err := tt.start(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		p.mu.Lock()
+		skip := p.initialSkipBytes
+		p.mu.Unlock()
+		if skip != 0 {
+			t.Errorf("%s: 仍需顺序丢弃 %d 字节", tt.name, skip)
+		}
+		select {
+		case pcm := <-first:
+			l := int16(binary.LittleEndian.Uint16(pcm))
+			r := int16(binary.LittleEndian.Uint16(pcm[2:]))
+			if want := rampSample(tt.frame); l != want || r != -want {
+				t.Errorf("%s: 首帧采样 = %d/%d，期望 %d/%d（第 %d 帧）", tt.name, l, r, want, -want, tt.frame)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: 没有输出", tt.name)
+		}
+	}
+}
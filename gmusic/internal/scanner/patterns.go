@@ -0,0 +1,43 @@
+package scanner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/yudongyouqing/GMusic/internal/metadata"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"gorm.io/gorm"
+)
+
+// TagPatterns 返回生效的文件名推断模式：保存过的模式（见 storage.SetTagPatterns），从未保存过时为默认模式
+func TagPatterns(db *gorm.DB) ([]*metadata.TagPattern, error) {
+	patterns, err := storage.GetTagPatterns(db)
+	if err != nil {
+		return nil, err
+	}
+	if patterns == nil {
+		patterns = metadata.DefaultTagPatterns
+	}
+	return metadata.ParseTagPatterns(patterns)
+}
+
+// patterns 本次扫描使用的推断模式，扫描期间只读取一次；读取失败时使用默认模式并记入错误列表
+func (s *Scanner) patterns() []*metadata.TagPattern {
+	s.patternsOnce.Do(func() {
+		patterns, err := TagPatterns(s.db)
+		if err != nil {
+			s.mu.Lock()
+			s.result.Errors = append(s.result.Errors, fmt.Sprintf("读取文件名推断模式失败，使用默认模式: %v", err))
+			s.mu.Unlock()
+			patterns, _ = metadata.ParseTagPatterns(metadata.DefaultTagPatterns)
+		}
+		s.tagPatterns = patterns
+	})
+	return s.tagPatterns
+}
+
+// IsSupportedAudio 判断文件是否为扫描器导入的音频格式
+func IsSupportedAudio(path string) bool {
+	return audioFormats[strings.ToLower(filepath.Ext(path))]
+}
@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yudongyouqing/GMusic/internal/metadata"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"gorm.io/gorm"
+)
+
+// tagCharset 文件手动指定的标签编码，未指定（或查询失败）时自动检测
+func (s *Scanner) tagCharset(filePath string) metadata.Charset {
+	name, err := storage.GetTagCharsetOverride(s.db, filePath)
+	if err != nil {
+		return metadata.CharsetAuto
+	}
+	charset, err := metadata.ParseCharset(name)
+	if err != nil {
+		return metadata.CharsetAuto
+	}
+	return charset
+}
+
+// Progress 返回当前扫描结果的副本，可在扫描进行中调用
+func (s *Scanner) Progress() ScanResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := *s.result
+	r.Errors = append([]string{}, r.Errors...)
+	return r
+}
+
+// songSource 重新导入歌曲时读取的文件：CUE 分轨读 CUE 文件（内嵌 CUESHEET 时即音频文件本身），其余读音频文件
+func songSource(song *storage.Song) string {
+	if song.IsCueTrack() {
+		return song.CueFile
+	}
+	return song.FilePath
+}
+
+// ReimportSong 重新读取歌曲所在的文件并刷新元数据（CUE 分轨会刷新同一 CUE 的全部分轨），随后整理艺术家与专辑
+func (s *Scanner) ReimportSong(ctx context.Context, song *storage.Song) (*ScanResult, error) {
+	s.mu.Lock()
+	s.result = &ScanResult{TotalFiles: 1, Errors: []string{}}
+	s.mu.Unlock()
+	s.processPathWithContext(ctx, songSource(song))
+	s.refreshLibrary()
+	if s.result.FailedFiles > 0 {
+		return s.result, fmt.Errorf("重新读取失败: %v", s.result.Errors)
+	}
+	return s.result, nil
+}
+
+// EncodingCandidate 标签文字疑似被错误解码的歌曲，Title/Artist/Album 为按检测出的编码重新解码后的预览
+type EncodingCandidate struct {
+	SongID   uint   `json:"song_id"`
+	FilePath string `json:"file_path"`
+	Charset  string `json:"charset"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album"`
+}
+
+// FindEncodingCandidates 遍历曲库，找出库中文字仍是按 Latin-1 解码的旧编码字节的歌曲（GBK、Big5、Shift-JIS），
+// 以及含 Latin-1 字符但导入时尚未做过编码检测的歌曲。visit 返回 false 时停止遍历。
+func FindEncodingCandidates(db *gorm.DB, visit func(EncodingCandidate) bool) error {
+	const batch = 500
+	var lastID uint
+	for {
+		var songs []storage.Song
+		if err := db.Select("id, file_path, cue_file, title, artist, album, album_artist, composer, comment, genres, tag_charset").
+			Where("id > ?", lastID).Order("id").Limit(batch).Find(&songs).Error; err != nil {
+			return err
+		}
+		if len(songs) == 0 {
+			return nil
+		}
+		lastID = songs[len(songs)-1].ID
+		for i := range songs {
+			song := &songs[i]
+			charset := metadata.DetectTextCharset(metadata.SongTexts(song)...)
+			if charset == metadata.CharsetAuto || (charset == metadata.CharsetLatin1 && song.TagCharset != "") {
+				continue
+			}
+			metadata.FixEncoding(charset, &song.Title, &song.Artist, &song.Album)
+			if !visit(EncodingCandidate{
+				SongID: song.ID, FilePath: songSource(song), Charset: string(charset),
+				Title: song.Title, Artist: song.Artist, Album: song.Album,
+			}) {
+				return nil
+			}
+		}
+	}
+}
+
+// RepairEncodings 曲库级的标签编码修复：找出 FindEncodingCandidates 列出的歌曲，逐个重新读取其文件
+// （按手动指定或自动检测的编码解码）并刷新元数据。CUE 分轨按 CUE 文件重新导入，同一文件只读取一次。
+func (s *Scanner) RepairEncodings(ctx context.Context) (*ScanResult, error) {
+	s.mu.Lock()
+	s.result = &ScanResult{Errors: []string{}}
+	s.mu.Unlock()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var paths []string
+	seen := map[string]bool{}
+	err := FindEncodingCandidates(s.db, func(c EncodingCandidate) bool {
+		if !seen[c.FilePath] {
+			seen[c.FilePath] = true
+			paths = append(paths, c.FilePath)
+		}
+		return ctx.Err() == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.result.TotalFiles = len(paths)
+	s.mu.Unlock()
+
+	for _, path := range paths {
+		select {
+		case <-ctx.Done():
+			s.refreshLibrary()
+			return s.result, fmt.Errorf("修复已取消")
+		default:
+		}
+		s.processPathWithContext(ctx, path)
+	}
+	s.refreshLibrary()
+	return s.result, nil
+}
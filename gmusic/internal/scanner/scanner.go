@@ -17,10 +17,19 @@ import (
 
 // ScanResult 扫描结果
 type ScanResult struct {
-	TotalFiles  int
-	AddedSongs  int
-	FailedFiles int
-	Errors      []string
+	TotalFiles   int
+	AddedSongs   int
+	UpdatedSongs int // 已在库中、本次重新导入刷新了元数据的歌曲
+	FailedFiles  int
+	Errors       []string
+}
+
+// audioFormats 扫描器导入的音频扩展名（只读）
+var audioFormats = map[string]bool{
+	".mp3":  true,
+	".flac": true,
+	".wav":  true,
+	".aac":  true,
 }
 
 // Scanner 目录扫描器
@@ -35,19 +44,16 @@ type Scanner struct {
 	resumeChan       chan struct{}      // 恢复信号
 	isPaused         bool               // 暂停状态
 	pauseMu          sync.Mutex         // 保护暂停状态
+	patternsOnce     sync.Once          // 首次用到时读取文件名推断模式
+	tagPatterns      []*metadata.TagPattern
 }
 
 // NewScanner 创建新扫描器
 func NewScanner(db *gorm.DB) *Scanner {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Scanner{
-		db: db,
-		supportedFormats: map[string]bool{
-			".mp3":  true,
-			".flac": true,
-			".wav":  true,
-			".aac":  true,
-		},
+		db:               db,
+		supportedFormats: audioFormats,
 		result: &ScanResult{
 			Errors: []string{},
 		},
@@ -62,13 +68,8 @@ func NewScanner(db *gorm.DB) *Scanner {
 func NewScannerWithContext(ctx context.Context, db *gorm.DB) *Scanner {
 	ctx, cancel := context.WithCancel(ctx)
 	return &Scanner{
-		db: db,
-		supportedFormats: map[string]bool{
-			".mp3":  true,
-			".flac": true,
-			".wav":  true,
-			".aac":  true,
-		},
+		db:               db,
+		supportedFormats: audioFormats,
 		result: &ScanResult{
 			Errors: []string{},
 		},
@@ -133,6 +134,7 @@ func (s *Scanner) ScanDirectory(dirPath string) (*ScanResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.refreshLibrary()
 
 	return s.result, nil
 }
@@ -144,6 +146,24 @@ func (s *Scanner) walkDirectory(dirPath string) error {
 		return fmt.Errorf("读取目录失败: %w", err)
 	}
 
+	// 先处理本目录的 CUE，被其引用的整轨音频不再单独导入
+	covered := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !isCueFile(entry.Name()) {
+			continue
+		}
+		fullPath := filepath.Join(dirPath, entry.Name())
+		audioFiles, err := metadata.CueAudioFiles(fullPath)
+		if err != nil {
+			continue
+		}
+		for _, f := range audioFiles {
+			covered[f] = true
+		}
+		s.result.TotalFiles++
+		s.processCueFileWithContext(context.Background(), fullPath)
+	}
+
 	for _, entry := range entries {
 		fullPath := filepath.Join(dirPath, entry.Name())
 
@@ -153,7 +173,7 @@ func (s *Scanner) walkDirectory(dirPath string) error {
 		} else {
 			// 检查是否是支持的格式
 			ext := strings.ToLower(filepath.Ext(entry.Name()))
-			if s.supportedFormats[ext] {
+			if s.supportedFormats[ext] && !covered[fullPath] {
 				s.result.TotalFiles++
 				s.processAudioFile(fullPath)
 			}
@@ -163,6 +183,110 @@ func (s *Scanner) walkDirectory(dirPath string) error {
 	return nil
 }
 
+// isCueFile 判断是否为 CUE 索引文件
+func isCueFile(name string) bool {
+	return strings.ToLower(filepath.Ext(name)) == ".cue"
+}
+
+// excludeCueCovered 解析 CUE 列表，返回需要处理的路径：所有 CUE 文件，
+// 以及未被任何 CUE 引用的普通音频文件（被引用的整轨镜像由 CUE 分轨导入）。
+func excludeCueCovered(audioFiles, cueFiles []string) []string {
+	covered := make(map[string]bool)
+	var paths []string
+	for _, cuePath := range cueFiles {
+		// 无法解析的 CUE 同样交给 worker，由其记录失败原因
+		paths = append(paths, cuePath)
+		refs, err := metadata.CueAudioFiles(cuePath)
+		if err != nil {
+			continue
+		}
+		for _, f := range refs {
+			covered[f] = true
+		}
+	}
+	for _, f := range audioFiles {
+		if !covered[f] {
+			paths = append(paths, f)
+		}
+	}
+	return paths
+}
+
+// processPathWithContext 按扩展名分派：CUE 走分轨导入，其余按普通音频处理
+func (s *Scanner) processPathWithContext(ctx context.Context, path string) {
+	if isCueFile(path) {
+		s.processCueFileWithContext(ctx, path)
+		return
+	}
+	s.processAudioFileWithContext(ctx, path)
+}
+
+// processCueFileWithContext 解析 CUE 文件并为每个音轨导入一条虚拟歌曲
+func (s *Scanner) processCueFileWithContext(ctx context.Context, cuePath string) {
+	select {
+	case <-ctx.Done():
+		return
+	default:
+	}
+
+	songs, err := metadata.ExtractCueTracksWithContext(ctx, cuePath)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+		s.mu.Lock()
+		s.result.FailedFiles++
+		s.result.Errors = append(s.result.Errors, fmt.Sprintf("%s: %v", cuePath, err))
+		s.mu.Unlock()
+		return
+	}
+	s.addCueTracks(ctx, songs)
+}
+
+// addCueTracks 保存 CUE 分轨歌曲，已存在的分轨（同文件同起点）刷新元数据
+func (s *Scanner) addCueTracks(ctx context.Context, songs []*storage.Song) {
+	for _, song := range songs {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		created, err := storage.UpsertSong(s.db, song)
+		if err != nil {
+			s.mu.Lock()
+			s.result.FailedFiles++
+			s.result.Errors = append(s.result.Errors, fmt.Sprintf("保存失败 %s#%d: %v", song.FilePath, song.TrackNum, err))
+			s.mu.Unlock()
+			continue
+		}
+		s.recordSaved(created)
+		if created {
+			fmt.Printf("✅ 已添加分轨: %s - %s\n", song.Artist, song.Title)
+		}
+	}
+}
+
+// recordSaved 按新增或刷新计数
+func (s *Scanner) recordSaved(created bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if created {
+		s.result.AddedSongs++
+	} else {
+		s.result.UpdatedSongs++
+	}
+}
+
+// refreshLibrary 扫描结束后整理艺术家与专辑（识别合辑、清理无歌曲的实体），失败记入错误列表
+func (s *Scanner) refreshLibrary() {
+	if err := storage.RefreshLibrary(s.db); err != nil {
+		s.mu.Lock()
+		s.result.Errors = append(s.result.Errors, fmt.Sprintf("整理艺术家与专辑失败: %v", err))
+		s.mu.Unlock()
+	}
+}
+
 // processAudioFile 处理单个音频文件（兼容旧接口）
 func (s *Scanner) processAudioFile(filePath string) {
 	s.processAudioFileWithContext(context.Background(), filePath)
@@ -177,15 +301,14 @@ func (s *Scanner) processAudioFileWithContext(ctx context.Context, filePath stri
 	default:
 	}
 
-	// 检查文件是否已存在
-	existing, _ := storage.GetSongByPath(s.db, filePath)
-	if existing != nil && existing.ID != 0 {
-		// 文件已存在，跳过
+	// 内嵌 CUESHEET 的整轨 FLAC 按分轨导入
+	if tracks, err := metadata.ExtractEmbeddedCueTracksWithContext(ctx, filePath); err == nil && len(tracks) > 0 {
+		s.addCueTracks(ctx, tracks)
 		return
 	}
 
-	// 提取元数据（支持 context 超时）
-	song, err := metadata.ExtractMetadataWithContext(ctx, filePath)
+	// 提取元数据（支持 context 超时）；文件手动指定了标签编码时按指定编码解码
+	song, err := metadata.ExtractMetadataWithCharset(ctx, filePath, s.tagCharset(filePath))
 	if err != nil {
 		// 如果是取消错误，不记录为失败
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -198,6 +321,9 @@ func (s *Scanner) processAudioFileWithContext(ctx context.Context, filePath stri
 		return
 	}
 
+	// 标签缺失或不完整时按文件名与目录推断
+	metadata.InferTags(song, s.patterns())
+
 	// 再次检查取消（元数据提取可能耗时）
 	select {
 	case <-ctx.Done():
@@ -205,8 +331,8 @@ func (s *Scanner) processAudioFileWithContext(ctx context.Context, filePath stri
 	default:
 	}
 
-	// 保存到数据库
-	err = storage.AddSong(s.db, song)
+	// 保存到数据库：已导入过的文件刷新元数据而不是重复添加
+	created, err := storage.UpsertSong(s.db, song)
 	if err != nil {
 		s.mu.Lock()
 		s.result.FailedFiles++
@@ -214,12 +340,10 @@ func (s *Scanner) processAudioFileWithContext(ctx context.Context, filePath stri
 		s.mu.Unlock()
 		return
 	}
-
-	s.mu.Lock()
-	s.result.AddedSongs++
-	s.mu.Unlock()
-
-	fmt.Printf("✅ 已添加: %s - %s\n", song.Artist, song.Title)
+	s.recordSaved(created)
+	if created {
+		fmt.Printf("✅ 已添加: %s - %s\n", song.Artist, song.Title)
+	}
 }
 
 // ScanDirectoryAsync 异步扫描目录
@@ -254,7 +378,7 @@ func (s *Scanner) ScanDirectoryWithWorkers(ctx context.Context, dirPath string,
 	}()
 
 	// 收集所有文件（支持取消）
-	var files []string
+	var files, cueFiles []string
 	walkErr := make(chan error, 1)
 	go func() {
 		defer close(walkErr)
@@ -274,6 +398,8 @@ func (s *Scanner) ScanDirectoryWithWorkers(ctx context.Context, dirPath string,
 				ext := strings.ToLower(filepath.Ext(path))
 				if s.supportedFormats[ext] {
 					files = append(files, path)
+				} else if isCueFile(path) {
+					cueFiles = append(cueFiles, path)
 				}
 			}
 
@@ -295,6 +421,8 @@ func (s *Scanner) ScanDirectoryWithWorkers(ctx context.Context, dirPath string,
 		return s.result, fmt.Errorf("扫描已取消")
 	}
 
+	// CUE 引用的整轨镜像不单独导入，改由 CUE 分轨
+	files = excludeCueCovered(files, cueFiles)
 	s.result.TotalFiles = len(files)
 
 	// 创建工作池
@@ -361,7 +489,7 @@ func (s *Scanner) ScanDirectoryWithWorkers(ctx context.Context, dirPath string,
 					}
 
 					// 处理文件（带 context）
-					s.processAudioFileWithContext(ctx, filePath)
+					s.processPathWithContext(ctx, filePath)
 				}
 			}
 		}(i) // 传递 worker ID 用于错误日志
@@ -400,6 +528,7 @@ func (s *Scanner) ScanDirectoryWithWorkers(ctx context.Context, dirPath string,
 		}
 		return s.result, fmt.Errorf("扫描已取消")
 	}
+	s.refreshLibrary()
 
 	return s.result, nil
 }
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/yudongyouqing/GMusic/internal/artists"
+	"gorm.io/gorm"
+)
+
+// 歌曲署名：艺术家标签按拆分规则（见 artists 包）拆成多位艺术家，每位以角色（main、featured、remixer、composer）
+// 关联到歌曲，按艺术家浏览时能找到其参与的全部歌曲。songs.artist 保留标签原文用于显示。
+//   - 写入歌曲时由 linkSong 拆分并解析艺术家实体，歌曲写入后由 saveCredits 记录关联
+//   - 拆分规则在启动前由 SetArtistSplitConfig 设置；规则变化后 InitDB 重新拆分全部歌曲（见 SyncArtistSplitRules）
+
+// SongArtist 歌曲与艺术家的署名关联
+type SongArtist struct {
+	SongID   uint   `gorm:"primaryKey"`
+	ArtistID uint   `gorm:"primaryKey"`
+	Role     string `gorm:"primaryKey"`
+	Position int    // 在歌曲署名中的顺序
+}
+
+// SongCredit 歌曲的一条署名
+type SongCredit struct {
+	ArtistID uint   `json:"artist_id"`
+	Name     string `json:"name"` // 艺术家实体的名称
+	Role     string `json:"role"`
+}
+
+// artistSplitRulesKey library_settings 中记录当前拆分规则摘要的键
+const artistSplitRulesKey = "artist_split_rules"
+
+var (
+	splitterMu sync.RWMutex
+	splitter   = artists.NewSplitter(artists.DefaultConfig())
+)
+
+// SetArtistSplitConfig 设置艺术家拆分规则，应在 InitDB 之前调用
+func SetArtistSplitConfig(cfg artists.Config) {
+	splitterMu.Lock()
+	defer splitterMu.Unlock()
+	splitter = artists.NewSplitter(cfg)
+}
+
+// artistSplitter 当前的拆分器
+func artistSplitter() *artists.Splitter {
+	splitterMu.RLock()
+	defer splitterMu.RUnlock()
+	return splitter
+}
+
+// primaryArtist 艺术家文本中的第一位 main 艺术家，作为歌曲/专辑关联的艺术家实体；拆不出时返回原文
+func primaryArtist(name string) string {
+	for _, c := range artistSplitter().Split(name, "", "") {
+		if c.Role == artists.RoleMain {
+			return c.Name
+		}
+	}
+	return name
+}
+
+// resolveCredits 拆分歌曲的署名并解析艺术家实体；cache 非空时缓存名称 → ID
+func resolveCredits(tx *gorm.DB, s *Song, cache map[string]uint) ([]SongCredit, error) {
+	split := artistSplitter().Split(s.Artist, s.Title, s.Composer)
+	credits := make([]SongCredit, 0, len(split))
+	for _, c := range split {
+		id, ok := cache[c.Name]
+		if !ok {
+			var err error
+			if id, err = resolveArtist(tx, c.Name); err != nil {
+				return nil, err
+			}
+			if cache != nil {
+				cache[c.Name] = id
+			}
+		}
+		if id != 0 {
+			credits = append(credits, SongCredit{ArtistID: id, Name: c.Name, Role: string(c.Role)})
+		}
+	}
+	return credits, nil
+}
+
+// saveCredits 用 credits 替换歌曲的署名关联。
+// 不使用事务（原因同 UpsertSong）；并发写入同一歌曲时重复的关联被忽略。
+func saveCredits(db *gorm.DB, songID uint, credits []SongCredit) error {
+	if err := db.Exec("DELETE FROM song_artists WHERE song_id = ?", songID).Error; err != nil {
+		return err
+	}
+	for i, c := range credits {
+		if err := db.Exec("INSERT INTO song_artists (song_id, artist_id, role, position) VALUES (?, ?, ?, ?) "+
+			"ON CONFLICT DO NOTHING", songID, c.ArtistID, c.Role, i).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncArtistSplitRules 拆分规则与上次生效的不同时（包括首次启用），清除全部署名与艺术家/专辑关联，
+// 交由随后的 BackfillLibrary 按新规则重新拆分。返回是否需要重新拆分。
+func SyncArtistSplitRules(db *gorm.DB) (bool, error) {
+	fingerprint := artistSplitter().Config().Fingerprint()
+	current, err := GetLibrarySetting(db, artistSplitRulesKey)
+	if err != nil {
+		return false, err
+	}
+	if current == fingerprint {
+		return false, nil
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM song_artists").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE songs SET artist_id = NULL, album_id = NULL").Error; err != nil {
+			return err
+		}
+		return SetLibrarySetting(tx, artistSplitRulesKey, fingerprint)
+	})
+	if err != nil {
+		return false, fmt.Errorf("重置艺术家拆分结果失败: %w", err)
+	}
+	return true, nil
+}
+
+// backfillCredits 为还没有署名关联、但有艺术家或作曲标签的歌曲补齐署名
+func backfillCredits(db *gorm.DB) error {
+	const batch = 500
+	cache := map[string]uint{}
+	var lastID uint
+	for {
+		var rows []Song
+		if err := db.Model(&Song{}).Select("id, title, artist, composer").
+			Where("id > ? AND (COALESCE(artist, '') <> '' OR composer <> '')", lastID).
+			Where("NOT EXISTS (SELECT 1 FROM song_artists WHERE song_artists.song_id = songs.id)").
+			Order("id").Limit(batch).Scan(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		lastID = rows[len(rows)-1].ID
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i := range rows {
+				credits, err := resolveCredits(tx, &rows[i], cache)
+				if err != nil {
+					return err
+				}
+				if err := saveCredits(tx, rows[i].ID, credits); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("拆分歌曲署名失败: %w", err)
+		}
+	}
+}
+
+// GetSongCredits 返回歌曲的署名，按角色（main、featured、remixer、composer）与标签中的顺序排列
+func GetSongCredits(db *gorm.DB, songID uint) ([]SongCredit, error) {
+	credits := []SongCredit{}
+	err := db.Raw(`SELECT song_artists.artist_id, artists.name, song_artists.role
+		FROM song_artists JOIN artists ON artists.id = song_artists.artist_id
+		WHERE song_artists.song_id = ? ORDER BY song_artists.position`, songID).Scan(&credits).Error
+	return credits, err
+}
@@ -1,21 +1,26 @@
 // Package storage 提供 GMusic 的持久化存储层实现。
 //
 // 职责概览：
-// 1) 定义核心数据模型（Song、Playlist、PlayHistory）。
-// 2) 封装数据库初始化（基于 GORM，默认使用本地 SQLite 文件）。
+// 1) 定义核心数据模型（Song、Artist、Album、Playlist、PlayHistory）。
+// 2) 封装数据库初始化（基于 GORM，默认使用本地 SQLite 文件）与版本化迁移（见 migrate.go）。
 // 3) 提供常用的数据访问方法（查询、搜索、新增等）。
 //
 // 说明：当前实现面向单机使用场景，SQLite 简单轻量；若需更高并发或分布式，
 package storage
 
 import (
+	"strings"
+	"time"
+
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Song 表示一首歌曲的元数据记录。
 // 注意：
-// - FilePath 建议在数据库层面设置唯一约束以避免重复导入（当前模型未强制）。
+// - (FilePath, StartMs) 上有唯一索引（迁移 0003），导入请使用 UpsertSong，重复导入只会刷新元数据。
+// - 表结构由 migrations 目录下的迁移脚本定义，修改字段时需同时新增迁移。
 // - 如需区分“未知值”和“明确为 0/空值”，可将部分字段改为指针或使用 sql.NullXxx。
 type Song struct {
 	ID       uint   `gorm:"primaryKey" json:"id"` // 主键 ID
@@ -29,6 +34,50 @@ type Song struct {
 	CoverURL string `json:"cover_url"`            // 封面图片路径或 URL
 	TrackNum int    `json:"track_num"`            // 专辑内的曲目序号
 	Year     int    `json:"year"`                 // 发行年份
+
+	AlbumArtist string `json:"album_artist"` // 专辑艺术家（标签 ALBUMARTIST/TPE2），为空时取 Artist
+	DiscNum     int    `json:"disc_num"`     // 碟号，0 表示未知
+	Compilation bool   `json:"compilation"`  // 标签标记为合辑（TCMP/COMPILATION）
+
+	Composer   string   `json:"composer"`                      // 作曲
+	Genres     []string `gorm:"serializer:json" json:"genres"` // 流派，可多值；以 JSON 数组存储，查询见 query.go 的 genre 字段
+	TrackTotal int      `json:"track_total"`                   // 专辑（本碟）总曲目数，0 表示未知
+	DiscTotal  int      `json:"disc_total"`                    // 总碟数，0 表示未知
+	Comment    string   `json:"comment"`                       // 注释
+	TagCharset string   `json:"tag_charset"`                   // 导入时对 ID3 文字采用的编码（gbk、big5、shift_jis、latin1），空串表示无需转换
+
+	// 关联的艺术家（曲目艺术家）与专辑实体，写入时由 UpsertSong/AddSong 按名称解析（见 library.go）；名称为空时为 0
+	ArtistID uint `json:"artist_id"`
+	AlbumID  uint `json:"album_id"`
+	// 拆分艺术家标签、标题与作曲得到的署名（见 credits.go），保存在 song_artists 表；
+	// 写入时由 linkSong 填充，读取时仅歌曲详情接口加载
+	Credits []SongCredit `gorm:"-" json:"artists,omitempty"`
+	// 手动修改过、重新扫描时保留的字段名（见 overrides.go）；仅歌曲接口返回时填充
+	Overridden []string `gorm:"-" json:"overridden,omitempty"`
+
+	// CUE 分轨：多首歌曲共享同一个 FilePath，以源文件中的偏移区分。
+	// 普通歌曲三者均为零值。
+	StartMs int64  `json:"start_ms"` // 在源文件中的起始偏移（毫秒）
+	EndMs   int64  `json:"end_ms"`   // 在源文件中的结束偏移（毫秒），0 表示播放到文件末尾
+	CueFile string `json:"cue_file"` // 来源 CUE 文件路径；内嵌 CUESHEET 时为音频文件本身
+
+	// SearchKeys 搜索辅助文本（简体形式、全拼、拼音首字母），保存时由 BeforeSave 生成
+	SearchKeys string `json:"-"`
+}
+
+// BeforeSave GORM 钩子：标题、艺术家、专辑变化后同步重建搜索辅助文本；
+// nil 的 Genres 会被序列化为 NULL（违反 genres 列的 NOT NULL 约束），统一存为空数组
+func (s *Song) BeforeSave(tx *gorm.DB) error {
+	s.SearchKeys = BuildSearchKeys(s.Title, s.Artist, s.Album)
+	if s.Genres == nil {
+		s.Genres = []string{}
+	}
+	return nil
+}
+
+// IsCueTrack 判断歌曲是否为 CUE 分轨生成的虚拟歌曲
+func (s *Song) IsCueTrack() bool {
+	return s.CueFile != ""
 }
 
 // Playlist 表示一个播放列表，Songs 通过 many2many 中间表 playlist_songs 关联。
@@ -40,7 +89,7 @@ type Playlist struct {
 }
 
 // PlayHistory 记录歌曲的播放历史。
-// 建议：为 SongID、PlayedAt 建索引，便于按歌曲/时间范围查询。
+// SongID、PlayedAt 上建有索引（迁移 0002），便于按歌曲/时间范围查询。
 // 可选：增加外键约束（SQLite 下外键默认关闭，需要 PRAGMA foreign_keys=ON）。
 type PlayHistory struct {
 	ID       uint  `gorm:"primaryKey" json:"id"` // 主键 ID
@@ -48,18 +97,31 @@ type PlayHistory struct {
 	PlayedAt int64 `json:"played_at"`            // 播放时间（Unix 时间戳，秒）
 }
 
-// InitDB 初始化数据库连接并进行自动迁移，返回 *gorm.DB。
+// InitDB 初始化数据库连接并执行尚未应用的迁移，返回 *gorm.DB。
 // 当前使用 sqlite 驱动，数据库保存在给定的文件路径（如 gmusic.db）中。
-// 注意：AutoMigrate 只做“无损”变更，复杂 Schema 变更请使用显式迁移工具。
+// 数据库已被更新版本的程序升级过时返回 *SchemaTooNewError，调用方应拒绝启动。
 func InitDB(dbPath string) (*gorm.DB, error) {
 	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
 
-	// 自动迁移：若表不存在则创建，字段缺失则补齐，不会删除列。
-	err = db.AutoMigrate(&Song{}, &Playlist{}, &PlayHistory{})
-	if err != nil {
+	// 版本化迁移：旧版本由 AutoMigrate 创建的数据库会被初始迁移直接接管
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	// 为旧记录补齐拼音等搜索辅助文本；全文索引需以 -tags sqlite_fts5 编译，否则搜索使用 LIKE
+	if err := BackfillSearchKeys(db); err != nil {
+		return nil, err
+	}
+	if _, err := EnsureSearchIndex(db); err != nil {
+		return nil, err
+	}
+	// 拆分规则变化后重新拆分；为迁移前导入的歌曲关联艺术家与专辑实体、补齐署名
+	if _, err := SyncArtistSplitRules(db); err != nil {
+		return nil, err
+	}
+	if err := BackfillLibrary(db); err != nil {
 		return nil, err
 	}
 
@@ -78,29 +140,215 @@ func GetAllSongs(db *gorm.DB) ([]Song, error) {
 	return songs, result.Error
 }
 
-// SearchSongs 基于关键字在标题、艺术家、专辑上做模糊匹配（%keyword%）。
-// 注意：前后皆为 % 的 LIKE 查询无法利用普通 B-Tree 索引，数据增大后性能会下降。
-// 可选方案：
-// - 使用 SQLite FTS5 建全文索引表以获得更好的搜索性能；
-// - 或改为前缀匹配（keyword%）以便部分利用索引（语义会变化）。
+// SearchSongs 按关键字搜索歌曲，按相关度排序返回全部结果（实现见 Search）。
 func SearchSongs(db *gorm.DB, keyword string) ([]Song, error) {
-	var songs []Song
-	result := db.Where("title LIKE ? OR artist LIKE ? OR album LIKE ?",
-		"%"+keyword+"%", "%"+keyword+"%", "%"+keyword+"%").
-		Find(&songs)
-	return songs, result.Error
+	res, err := Search(db, SearchQuery{Keyword: keyword})
+	if err != nil {
+		return nil, err
+	}
+	songs := make([]Song, len(res.Hits))
+	for i, h := range res.Hits {
+		songs[i] = h.Song
+	}
+	return songs, nil
 }
 
-// AddSong 插入一条歌曲记录。
-// 说明：函数本身不做去重判断，若需避免重复，请在 FilePath 上加唯一约束或在写入前查重。
+// AddSong 插入一条歌曲记录，合并手动修改过的字段，并关联艺术家与专辑实体、记录署名。
+// 说明：同一 (FilePath, StartMs) 已存在时违反唯一索引并返回错误；导入文件请使用 UpsertSong。
 func AddSong(db *gorm.DB, song *Song) error {
-	return db.Create(song).Error
+	if err := applyOverrides(db, song); err != nil {
+		return err
+	}
+	if err := linkSong(db, song); err != nil {
+		return err
+	}
+	if err := db.Create(song).Error; err != nil {
+		return err
+	}
+	return saveCredits(db, song.ID, song.Credits)
+}
+
+// songRefreshColumns 重新导入时刷新的列；主键与唯一键 (file_path, start_ms) 保持不变
+var songRefreshColumns = []string{
+	"title", "artist", "album", "duration", "bit_rate", "format",
+	"cover_url", "track_num", "year", "end_ms", "cue_file", "search_keys",
+	"album_artist", "disc_num", "compilation", "artist_id", "album_id",
+	"composer", "genres", "track_total", "disc_total", "comment", "tag_charset",
+}
+
+// UpsertSong 按 (FilePath, StartMs) 插入或更新歌曲：不存在时插入，已存在时刷新元数据并保留原 ID，
+// 播放列表与播放历史中的引用因此不受影响。写入由单条 INSERT ... ON CONFLICT DO UPDATE 完成，
+// 多个扫描协程同时导入同一文件也不会产生重复记录。song.ID 回填为库中记录的 ID。
+// 写入前合并手动修改过的字段（见 overrides.go）并按名称关联艺术家与专辑实体，写入后记录署名。实体的创建是幂等的，不与写入放在同一事务中：
+// SQLite 中多个“先读后写”的事务并发时会因锁升级冲突直接失败，而单条语句可以等待锁。
+// created 表示是否为新增，仅用于统计：它来自写入前的查询，并发导入同一文件时可能不准确。
+func UpsertSong(db *gorm.DB, song *Song) (created bool, err error) {
+	var count int64
+	if err := db.Model(&Song{}).Where("file_path = ? AND start_ms = ?", song.FilePath, song.StartMs).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if err := applyOverrides(db, song); err != nil {
+		return false, err
+	}
+	if err := linkSong(db, song); err != nil {
+		return false, err
+	}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_path"}, {Name: "start_ms"}},
+		DoUpdates: clause.AssignmentColumns(songRefreshColumns),
+	}).Create(song).Error
+	if err != nil {
+		return false, err
+	}
+	return count == 0, saveCredits(db, song.ID, song.Credits)
+}
+
+// UpdateSongDuration 写入时长（秒）。时长不属于可手动修改的字段，直接写入，不产生修改记录
+func UpdateSongDuration(db *gorm.DB, song *Song, sec int) error {
+	song.Duration = sec
+	return db.Model(song).Update("duration", sec).Error
 }
 
 // GetSongByPath 根据文件路径查询歌曲，若不存在返回 gorm.ErrRecordNotFound。
-// 建议：为 file_path 建索引/唯一约束以提升查询性能与数据一致性。
+// CUE 分轨共享文件路径，此时返回其中任意一条；需要精确定位请使用 GetSongByPathAndOffset。
 func GetSongByPath(db *gorm.DB, filePath string) (*Song, error) {
 	var song Song
 	result := db.Where("file_path = ?", filePath).First(&song)
 	return &song, result.Error
 }
+
+// GetSongByPathAndOffset 根据文件路径与起始偏移查询歌曲，用于定位 CUE 分轨。
+// 普通歌曲的 StartMs 为 0，因此 startMs=0 也能匹配到整轨导入的记录。
+func GetSongByPathAndOffset(db *gorm.DB, filePath string, startMs int64) (*Song, error) {
+	var song Song
+	result := db.Where("file_path = ? AND start_ms = ?", filePath, startMs).First(&song)
+	return &song, result.Error
+}
+
+// GetSongByID 根据主键查询歌曲，若不存在返回 gorm.ErrRecordNotFound
+func GetSongByID(db *gorm.DB, id uint) (*Song, error) {
+	var song Song
+	result := db.First(&song, id)
+	return &song, result.Error
+}
+
+// GetSongsByAlbum 返回指定专辑的歌曲，按碟号、曲目序号排序；artist 非空时额外按艺术家过滤（区分同名专辑）
+func GetSongsByAlbum(db *gorm.DB, album, artist string) ([]Song, error) {
+	var songs []Song
+	q := db.Where("album = ?", album)
+	if artist != "" {
+		q = q.Where("artist = ?", artist)
+	}
+	result := q.Order("disc_num, track_num, start_ms, id").Find(&songs)
+	return songs, result.Error
+}
+
+// GetSongsByArtist 返回指定艺术家的歌曲，按专辑、碟号、曲目序号排序
+func GetSongsByArtist(db *gorm.DB, artist string) ([]Song, error) {
+	var songs []Song
+	result := db.Where("artist = ?", artist).Order("album, disc_num, track_num, start_ms, id").Find(&songs)
+	return songs, result.Error
+}
+
+// GetPlaylistSongs 返回播放列表中的歌曲；播放列表不存在时返回 gorm.ErrRecordNotFound
+func GetPlaylistSongs(db *gorm.DB, playlistID uint) ([]Song, error) {
+	var playlist Playlist
+	if err := db.First(&playlist, playlistID).Error; err != nil {
+		return nil, err
+	}
+	var songs []Song
+	err := db.Model(&playlist).Association("Songs").Find(&songs)
+	return songs, err
+}
+
+// AddPlayHistory 记录一次播放
+func AddPlayHistory(db *gorm.DB, songID uint) error {
+	return db.Create(&PlayHistory{SongID: songID, PlayedAt: time.Now().Unix()}).Error
+}
+
+// ArtistSummary 按艺术家文本聚合的曲库统计（由 songs 表分组得到；艺术家实体见 library.go 的 ArtistInfo）
+type ArtistSummary struct {
+	Name        string `json:"name"`
+	AlbumCount  int    `json:"album_count"`
+	SongCount   int    `json:"song_count"`
+	CoverSongID uint   `json:"cover_song_id"` // 任意一首带封面的歌曲 ID，0 表示没有封面
+}
+
+// AlbumSummary 按（专辑，艺术家）聚合的曲库统计
+type AlbumSummary struct {
+	Name        string `json:"name"`
+	Artist      string `json:"artist"`
+	SongCount   int    `json:"song_count"`
+	Duration    int    `json:"duration"` // 总时长（秒）
+	Year        int    `json:"year"`
+	CoverSongID uint   `json:"cover_song_id"`
+}
+
+// ListArtists 返回全部艺术家（按名称排序）；keyword 非空时按名称模糊匹配
+func ListArtists(db *gorm.DB, keyword string) ([]ArtistSummary, error) {
+	var artists []ArtistSummary
+	q := db.Model(&Song{}).Select(`artist AS name, COUNT(DISTINCT album) AS album_count, COUNT(*) AS song_count,
+		COALESCE(MAX(CASE WHEN cover_url <> '' THEN id END), 0) AS cover_song_id`)
+	if keyword != "" {
+		q = q.Where("artist LIKE ?", "%"+keyword+"%")
+	}
+	result := q.Group("artist").Order("artist").Scan(&artists)
+	return artists, result.Error
+}
+
+// AlbumQuery ListAlbums 的过滤条件，零值表示不过滤
+type AlbumQuery struct {
+	Artist  string // 精确匹配艺术家
+	Keyword string // 专辑名模糊匹配
+}
+
+// ListAlbums 返回按（专辑，艺术家）分组的专辑列表，按专辑名排序
+func ListAlbums(db *gorm.DB, query AlbumQuery) ([]AlbumSummary, error) {
+	var albums []AlbumSummary
+	q := db.Model(&Song{}).Select(`album AS name, artist, COUNT(*) AS song_count, COALESCE(SUM(duration), 0) AS duration,
+		COALESCE(MAX(year), 0) AS year, COALESCE(MAX(CASE WHEN cover_url <> '' THEN id END), 0) AS cover_song_id`)
+	if query.Artist != "" {
+		q = q.Where("artist = ?", query.Artist)
+	}
+	if query.Keyword != "" {
+		q = q.Where("album LIKE ?", "%"+query.Keyword+"%")
+	}
+	result := q.Group("album, artist").Order("album, artist").Scan(&albums)
+	return albums, result.Error
+}
+
+// ListPlaylists 返回全部播放列表（含歌曲）
+func ListPlaylists(db *gorm.DB) ([]Playlist, error) {
+	var playlists []Playlist
+	result := db.Preload("Songs").Order("id").Find(&playlists)
+	return playlists, result.Error
+}
+
+// GetPlaylist 根据 ID 查询播放列表（含歌曲），不存在时返回 gorm.ErrRecordNotFound
+func GetPlaylist(db *gorm.DB, id uint) (*Playlist, error) {
+	var playlist Playlist
+	result := db.Preload("Songs").First(&playlist, id)
+	return &playlist, result.Error
+}
+
+// GetSongsUnderDir 返回位于任一目录 dirs（含子目录）下的歌曲。
+// 目录使用正斜杠分隔，数据库中的反斜杠路径（Windows）会先统一为正斜杠再匹配；dirs 为空时返回全部歌曲。
+func GetSongsUnderDir(db *gorm.DB, dirs ...string) ([]Song, error) {
+	var songs []Song
+	q := db.Model(&Song{})
+	if len(dirs) > 0 {
+		escape := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+		cond := db
+		for i, dir := range dirs {
+			pattern := escape.Replace(strings.TrimSuffix(dir, "/")) + "/%"
+			if i == 0 {
+				cond = cond.Where(`REPLACE(file_path, '\', '/') LIKE ? ESCAPE '!'`, pattern)
+			} else {
+				cond = cond.Or(`REPLACE(file_path, '\', '/') LIKE ? ESCAPE '!'`, pattern)
+			}
+		}
+		q = q.Where(cond)
+	}
+	result := q.Find(&songs)
+	return songs, result.Error
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestNilGenres 未设置流派（Genres 为 nil）的歌曲经新增、重新扫描与保存都能写入，读出为空数组
+func TestNilGenres(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "gmusic.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		path  string
+		write func(s *Song) error
+	}{
+		{"AddSong", "/music/浮夸.mp3", func(s *Song) error { return AddSong(db, s) }},
+		{"UpsertSong 新增", "/music/十年.mp3", func(s *Song) error { _, err := UpsertSong(db, s); return err }},
+		{"UpsertSong 更新", "/music/十年.mp3", func(s *Song) error { _, err := UpsertSong(db, s); return err }},
+		{"Save", "/music/十年.mp3", func(s *Song) error {
+			if err := db.Where("file_path = ?", s.FilePath).First(s).Error; err != nil {
+				return err
+			}
+			s.Genres = nil
+			return db.Save(s).Error
+		}},
+	}
+	for _, tt := range tests {
+		s := &Song{Title: tt.name, Artist: "陈奕迅", FilePath: tt.path}
+		if err := tt.write(s); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got, err := GetSongByID(db, s.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Genres == nil || len(got.Genres) != 0 {
+			t.Errorf("%s: 流派 = %#v，期望空数组", tt.name, got.Genres)
+		}
+	}
+}
@@ -0,0 +1,603 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yudongyouqing/GMusic/internal/artists"
+	"github.com/yudongyouqing/GMusic/internal/hanzi"
+	"gorm.io/gorm"
+)
+
+// 艺术家与专辑实体：歌曲的 artist/album 文本保留标签原文用于显示，另以 ArtistID/AlbumID 关联实体。
+//   - 名称按 nameKey 归一（简体、小写、合并空白），"周杰倫" 与 "周杰伦" 是同一艺术家；
+//     写法差异更大的名称（"Jay Chou"）通过别名归并，见 AddArtistAlias
+//   - 专辑以（名称，专辑艺术家）区分。专辑艺术家取标签 ALBUMARTIST，缺失时取曲目艺术家；
+//     标签标记为合辑、或专辑艺术家为 "Various Artists"/"群星" 等时专辑为合辑
+//   - 艺术家标签含多位艺术家时（"A feat. B"、"A & B"）按拆分规则拆开，歌曲与专辑关联第一位 main，
+//     全部艺术家另以署名关联（见 credits.go）
+//   - 写入歌曲时即关联实体（UpsertSong/AddSong）；扫描结束后由 RefreshLibrary 识别同一目录下
+//     没有专辑艺术家标签的合辑，并清理不再被引用的实体
+
+// VariousArtists 合辑的专辑艺术家
+const VariousArtists = "Various Artists"
+
+// variousArtistKeys 视为合辑的专辑艺术家名称（归一化后）
+var variousArtistKeys = map[string]bool{
+	"various artists": true, "various": true, "va": true, "v.a.": true, "群星": true, "合辑": true,
+}
+
+// Artist 艺术家
+type Artist struct {
+	ID      uint   `gorm:"primaryKey" json:"id"` // 主键 ID
+	Name    string `json:"name"`                 // 显示名称（首次出现的写法）
+	NameKey string `json:"-"`                    // 归一化名称，唯一
+}
+
+// ArtistAlias 艺术家别名：名称归一化后等于 NameKey 的标签归入 ArtistID
+type ArtistAlias struct {
+	NameKey  string `gorm:"primaryKey" json:"-"`
+	Name     string `json:"name"`
+	ArtistID uint   `json:"artist_id"`
+}
+
+// Album 专辑
+type Album struct {
+	ID            uint   `gorm:"primaryKey" json:"id"` // 主键 ID
+	Name          string `json:"name"`                 // 显示名称
+	NameKey       string `json:"-"`                    // 归一化名称，与 AlbumArtistID 组合唯一
+	AlbumArtistID uint   `json:"album_artist_id"`      // 专辑艺术家，0 表示未知
+	Compilation   bool   `json:"compilation"`          // 是否为合辑
+}
+
+// nameKey 名称归一化：简体、小写、合并空白
+func nameKey(name string) string {
+	return hanzi.Fold(strings.Join(strings.Fields(name), " "))
+}
+
+// albumArtistOf 歌曲的专辑艺术家名称及专辑是否为合辑
+func albumArtistOf(s *Song) (string, bool) {
+	name := strings.TrimSpace(s.AlbumArtist)
+	if name == "" {
+		name = s.Artist
+		if s.Compilation {
+			name = VariousArtists
+		}
+	}
+	return name, s.Compilation || variousArtistKeys[nameKey(name)]
+}
+
+// resolveArtist 返回名称对应的艺术家 ID（先查别名），不存在时创建；名称为空返回 0
+func resolveArtist(tx *gorm.DB, name string) (uint, error) {
+	key := nameKey(name)
+	if key == "" {
+		return 0, nil
+	}
+	var id uint
+	if err := tx.Raw("SELECT artist_id FROM artist_aliases WHERE name_key = ?", key).Scan(&id).Error; err != nil || id != 0 {
+		return id, err
+	}
+	if err := tx.Raw("SELECT id FROM artists WHERE name_key = ?", key).Scan(&id).Error; err != nil || id != 0 {
+		return id, err
+	}
+	if err := tx.Exec("INSERT INTO artists (name, name_key) VALUES (?, ?) ON CONFLICT (name_key) DO NOTHING",
+		strings.TrimSpace(name), key).Error; err != nil {
+		return 0, err
+	}
+	err := tx.Raw("SELECT id FROM artists WHERE name_key = ?", key).Scan(&id).Error
+	return id, err
+}
+
+// resolveAlbum 返回（专辑名，专辑艺术家）对应的专辑 ID，不存在时创建；专辑名为空返回 0。
+// 专辑艺术家含多位艺术家时归入第一位名下
+func resolveAlbum(tx *gorm.DB, name, albumArtist string, compilation bool) (uint, error) {
+	key := nameKey(name)
+	if key == "" {
+		return 0, nil
+	}
+	artistID, err := resolveArtist(tx, primaryArtist(albumArtist))
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Exec("INSERT INTO albums (name, name_key, album_artist_id, compilation) VALUES (?, ?, ?, ?) "+
+		"ON CONFLICT (name_key, album_artist_id) DO UPDATE SET compilation = MAX(compilation, excluded.compilation)",
+		strings.TrimSpace(name), key, artistID, compilation).Error; err != nil {
+		return 0, err
+	}
+	var id uint
+	err = tx.Raw("SELECT id FROM albums WHERE name_key = ? AND album_artist_id = ?", key, artistID).Scan(&id).Error
+	return id, err
+}
+
+// linkSong 拆分歌曲的署名填入 Credits，按第一位 main 艺术家与专辑文本填入 ArtistID/AlbumID
+func linkSong(tx *gorm.DB, s *Song) error {
+	var err error
+	if s.Credits, err = resolveCredits(tx, s, nil); err != nil {
+		return err
+	}
+	s.ArtistID = 0
+	for _, c := range s.Credits {
+		if c.Role == string(artists.RoleMain) {
+			s.ArtistID = c.ArtistID
+			break
+		}
+	}
+	albumArtist, compilation := albumArtistOf(s)
+	s.AlbumID, err = resolveAlbum(tx, s.Album, albumArtist, compilation)
+	return err
+}
+
+// BackfillLibrary 为尚未关联实体的歌曲（迁移新增关联列前导入的记录）补齐 ArtistID/AlbumID 与署名，随后执行 RefreshLibrary。
+// 按（艺术家，专辑，专辑艺术家，合辑标记）的不同组合解析，每个组合只需一次批量 UPDATE。
+func BackfillLibrary(db *gorm.DB) error {
+	var groups []struct {
+		Artist, Album *string
+		AlbumArtist   string
+		Compilation   bool
+	}
+	if err := db.Model(&Song{}).Distinct("artist", "album", "album_artist", "compilation").
+		Where("artist_id IS NULL OR album_id IS NULL").Scan(&groups).Error; err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return backfillCredits(db)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, g := range groups {
+			s := Song{AlbumArtist: g.AlbumArtist, Compilation: g.Compilation}
+			if g.Artist != nil {
+				s.Artist = *g.Artist
+			}
+			if g.Album != nil {
+				s.Album = *g.Album
+			}
+			if err := linkSong(tx, &s); err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE songs SET artist_id = ?, album_id = ? WHERE (artist_id IS NULL OR album_id IS NULL) "+
+				"AND artist IS ? AND album IS ? AND album_artist = ? AND compilation = ?",
+				s.ArtistID, s.AlbumID, g.Artist, g.Album, g.AlbumArtist, g.Compilation).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("关联艺术家与专辑失败: %w", err)
+	}
+	if err := backfillCredits(db); err != nil {
+		return err
+	}
+	return RefreshLibrary(db)
+}
+
+// RefreshLibrary 整理艺术家与专辑实体，扫描结束后调用：
+//  1. 同一目录下同名专辑的歌曲若都没有专辑艺术家标签，却来自多位艺术家：
+//     没有哪位艺术家占到一半曲目时视为合辑，归入 Various Artists 名下；否则归入曲目最多的艺术家名下
+//  2. 按专辑艺术家与歌曲的合辑标记重新计算专辑的合辑标记
+//  3. 删除已删除歌曲的署名、没有歌曲的专辑，以及既没有歌曲、署名、专辑也没有别名引用的艺术家
+func RefreshLibrary(db *gorm.DB) error {
+	var rows []struct {
+		ID            uint
+		Artist, Album string
+		FilePath      string
+		ArtistID      uint
+		AlbumID       uint
+	}
+	// 只有同名专辑下出现多位艺术家时才可能需要调整，先在 SQL 中筛出这些专辑
+	candidates := db.Model(&Song{}).Select("album").
+		Where("COALESCE(album, '') <> '' AND album_artist = '' AND compilation = 0").
+		Group("album").Having("COUNT(DISTINCT artist_id) > 1")
+	if err := db.Model(&Song{}).Select("id, artist, album, file_path, artist_id, album_id").
+		Where("album IN (?) AND album_artist = '' AND compilation = 0", candidates).Scan(&rows).Error; err != nil {
+		return err
+	}
+	type folderAlbum struct{ album, dir string }
+	groups := map[folderAlbum][]int{}
+	for i, r := range rows {
+		k := folderAlbum{nameKey(r.Album), filepath.Dir(r.FilePath)}
+		groups[k] = append(groups[k], i)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, idx := range groups {
+			counts := map[uint]int{}
+			for _, i := range idx {
+				counts[rows[i].ArtistID]++
+			}
+			if len(counts) < 2 {
+				continue
+			}
+			// 曲目最多的艺术家（并列时取 ID 较小者，保证结果稳定）
+			var top uint
+			for id, n := range counts {
+				if n > counts[top] || (n == counts[top] && id < top) {
+					top = id
+				}
+			}
+			var albumArtist string
+			compilation := counts[top]*2 < len(idx)
+			if compilation {
+				albumArtist = VariousArtists
+			} else {
+				for _, i := range idx {
+					if rows[i].ArtistID == top {
+						albumArtist = rows[i].Artist
+						break
+					}
+				}
+			}
+			albumID, err := resolveAlbum(tx, rows[idx[0]].Album, albumArtist, compilation)
+			if err != nil {
+				return err
+			}
+			var ids []uint
+			for _, i := range idx {
+				if rows[i].AlbumID != albumID {
+					ids = append(ids, rows[i].ID)
+				}
+			}
+			if len(ids) > 0 {
+				if err := tx.Model(&Song{}).Where("id IN ?", ids).Update("album_id", albumID).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		various := make([]string, 0, len(variousArtistKeys))
+		for k := range variousArtistKeys {
+			various = append(various, k)
+		}
+		steps := []struct {
+			sql  string
+			args []interface{}
+		}{
+			{"UPDATE albums SET compilation = (album_artist_id IN (SELECT id FROM artists WHERE name_key IN ?) " +
+				"OR EXISTS (SELECT 1 FROM songs WHERE songs.album_id = albums.id AND songs.compilation = 1))", []interface{}{various}},
+			{"DELETE FROM song_artists WHERE song_id NOT IN (SELECT id FROM songs)", nil},
+			{"DELETE FROM albums WHERE id NOT IN (SELECT album_id FROM songs WHERE album_id IS NOT NULL)", nil},
+			{"DELETE FROM artists WHERE id NOT IN (SELECT artist_id FROM songs WHERE artist_id IS NOT NULL) " +
+				"AND id NOT IN (SELECT artist_id FROM song_artists) AND id NOT IN (SELECT album_artist_id FROM albums) " +
+				"AND id NOT IN (SELECT artist_id FROM artist_aliases)", nil},
+		}
+		for _, s := range steps {
+			if err := tx.Exec(s.sql, s.args...).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddArtistAlias 为艺术家添加别名，此后名称为 name 的标签都归入该艺术家。
+// 若已有以 name 为名的艺术家，将其歌曲、专辑与别名合并过来并删除它；同名专辑合并为一张。
+func AddArtistAlias(db *gorm.DB, artistID uint, name string) error {
+	key := nameKey(name)
+	if key == "" {
+		return errors.New("别名不能为空")
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var target Artist
+		if err := tx.First(&target, artistID).Error; err != nil {
+			return err
+		}
+		if target.NameKey == key {
+			return errors.New("别名与艺术家名称相同")
+		}
+		var other Artist
+		err := tx.Where("name_key = ?", key).First(&other).Error
+		switch {
+		case err == nil:
+			if err := mergeArtist(tx, other.ID, target.ID); err != nil {
+				return err
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+		return tx.Save(&ArtistAlias{NameKey: key, Name: strings.Join(strings.Fields(name), " "), ArtistID: target.ID}).Error
+	})
+}
+
+// mergeArtist 把艺术家 from 的歌曲、署名、专辑与别名并入 to，然后删除 from
+func mergeArtist(tx *gorm.DB, from, to uint) error {
+	var albums []Album
+	if err := tx.Where("album_artist_id = ?", from).Find(&albums).Error; err != nil {
+		return err
+	}
+	for _, a := range albums {
+		var existing Album
+		err := tx.Where("name_key = ? AND album_artist_id = ?", a.NameKey, to).First(&existing).Error
+		switch {
+		case err == nil:
+			// 目标艺术家已有同名专辑：歌曲并入该专辑
+			if err := tx.Model(&Song{}).Where("album_id = ?", a.ID).Update("album_id", existing.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&Album{}, a.ID).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Model(&Album{}).Where("id = ?", a.ID).Update("album_artist_id", to).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+	if err := tx.Model(&Song{}).Where("artist_id = ?", from).Update("artist_id", to).Error; err != nil {
+		return err
+	}
+	// 同一歌曲已以同一角色署名 to 时，from 的那条署名直接删除
+	if err := tx.Exec("UPDATE OR IGNORE song_artists SET artist_id = ? WHERE artist_id = ?", to, from).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM song_artists WHERE artist_id = ?", from).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&ArtistAlias{}).Where("artist_id = ?", from).Update("artist_id", to).Error; err != nil {
+		return err
+	}
+	var fromArtist Artist
+	if err := tx.First(&fromArtist, from).Error; err != nil {
+		return err
+	}
+	// 被合并艺术家的原名也作为别名保留
+	if err := tx.Save(&ArtistAlias{NameKey: fromArtist.NameKey, Name: fromArtist.Name, ArtistID: to}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&Artist{}, from).Error
+}
+
+// RemoveArtistAlias 删除别名，并按名称重新关联该艺术家的歌曲、署名与专辑：原先经别名归入的歌曲回到以其原名为名的艺术家
+func RemoveArtistAlias(db *gorm.DB, artistID uint, name string) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("artist_id = ? AND name_key = ?", artistID, nameKey(name)).Delete(&ArtistAlias{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Exec("UPDATE songs SET artist_id = NULL, album_id = NULL WHERE artist_id = ? "+
+			"OR album_id IN (SELECT id FROM albums WHERE album_artist_id = ?) "+
+			"OR id IN (SELECT song_id FROM song_artists WHERE artist_id = ?)", artistID, artistID, artistID).Error; err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM song_artists WHERE song_id IN (SELECT id FROM songs WHERE artist_id IS NULL)").Error
+	})
+	if err != nil {
+		return err
+	}
+	return BackfillLibrary(db)
+}
+
+// ---- 查询 ----
+
+// ArtistInfo 艺术家及其曲库统计
+type ArtistInfo struct {
+	Artist
+	AlbumCount  int  `json:"album_count"`   // 作为专辑艺术家的专辑数
+	SongCount   int  `json:"song_count"`    // 署名的歌曲数（任意角色）
+	CoverSongID uint `json:"cover_song_id"` // 任意一首带封面的歌曲 ID，0 表示没有封面
+}
+
+// AlbumInfo 专辑及其统计
+type AlbumInfo struct {
+	Album
+	AlbumArtist string `json:"album_artist"` // 专辑艺术家名称
+	SongCount   int    `json:"song_count"`
+	DiscCount   int    `json:"disc_count"`
+	Duration    int    `json:"duration"` // 总时长（秒）
+	Year        int    `json:"year"`
+	CoverSongID uint   `json:"cover_song_id"`
+}
+
+// LibraryQuery 艺术家/专辑列表的过滤与分页，零值表示不过滤
+type LibraryQuery struct {
+	Keyword       string // 名称包含（不区分大小写与简繁体）
+	AlbumArtistID uint   // 仅专辑：指定专辑艺术家
+	Compilation   *bool  // 仅专辑：是否合辑
+	Sort          string // 仅专辑：name（默认）、year、-year、newest
+	Limit         int    // <= 0 表示不限制
+	Offset        int
+}
+
+const artistInfoSelect = `artists.*,
+	(SELECT COUNT(*) FROM albums WHERE albums.album_artist_id = artists.id) AS album_count,
+	(SELECT COUNT(DISTINCT song_id) FROM song_artists WHERE song_artists.artist_id = artists.id) AS song_count,
+	COALESCE((SELECT MAX(songs.id) FROM songs WHERE songs.artist_id = artists.id AND songs.cover_url <> ''), 0) AS cover_song_id`
+
+const albumInfoSelect = `albums.*, COALESCE(artists.name, '') AS album_artist,
+	COUNT(songs.id) AS song_count, COUNT(DISTINCT songs.disc_num) AS disc_count,
+	COALESCE(SUM(songs.duration), 0) AS duration, COALESCE(MAX(songs.year), 0) AS year,
+	COALESCE(MAX(CASE WHEN songs.cover_url <> '' THEN songs.id END), 0) AS cover_song_id`
+
+// albumOrders 专辑排序方式 → ORDER BY 子句
+var albumOrders = map[string]string{
+	"":       "albums.name_key, album_artist",
+	"name":   "albums.name_key, album_artist",
+	"year":   "year, albums.name_key",
+	"-year":  "year DESC, albums.name_key",
+	"newest": "albums.id DESC",
+}
+
+// AlbumSorts 专辑列表支持的排序方式
+func AlbumSorts() []string {
+	sorts := make([]string, 0, len(albumOrders))
+	for s := range albumOrders {
+		if s != "" {
+			sorts = append(sorts, s)
+		}
+	}
+	sort.Strings(sorts)
+	return sorts
+}
+
+// ListArtistInfos 返回艺术家列表（按名称排序）及过滤后的总数
+func ListArtistInfos(db *gorm.DB, q LibraryQuery) ([]ArtistInfo, int64, error) {
+	tx := db.Model(&Artist{})
+	if k := nameKey(q.Keyword); k != "" {
+		tx = tx.Where(`name_key LIKE ? ESCAPE '\'`, likePattern(k))
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	tx = tx.Select(artistInfoSelect).Order("name_key")
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit).Offset(q.Offset)
+	}
+	artists := []ArtistInfo{}
+	err := tx.Scan(&artists).Error
+	return artists, total, err
+}
+
+// GetArtistInfo 根据 ID 查询艺术家，不存在时返回 gorm.ErrRecordNotFound
+func GetArtistInfo(db *gorm.DB, id uint) (*ArtistInfo, error) {
+	var artists []ArtistInfo
+	if err := db.Model(&Artist{}).Select(artistInfoSelect).Where("id = ?", id).Scan(&artists).Error; err != nil {
+		return nil, err
+	}
+	if len(artists) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &artists[0], nil
+}
+
+// GetArtistAliases 返回艺术家的别名
+func GetArtistAliases(db *gorm.DB, artistID uint) ([]ArtistAlias, error) {
+	aliases := []ArtistAlias{}
+	err := db.Where("artist_id = ?", artistID).Order("name_key").Find(&aliases).Error
+	return aliases, err
+}
+
+// ListAlbumInfos 返回专辑列表及过滤后的总数；排序方式未知时返回错误
+func ListAlbumInfos(db *gorm.DB, q LibraryQuery) ([]AlbumInfo, int64, error) {
+	order, ok := albumOrders[q.Sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("未知的排序方式 %q（可选：%s）", q.Sort, strings.Join(AlbumSorts(), ", "))
+	}
+	tx := db.Model(&Album{})
+	if k := nameKey(q.Keyword); k != "" {
+		tx = tx.Where(`albums.name_key LIKE ? ESCAPE '\'`, likePattern(k))
+	}
+	if q.AlbumArtistID != 0 {
+		tx = tx.Where("albums.album_artist_id = ?", q.AlbumArtistID)
+	}
+	if q.Compilation != nil {
+		tx = tx.Where("albums.compilation = ?", *q.Compilation)
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	tx = tx.Select(albumInfoSelect).
+		Joins("LEFT JOIN artists ON artists.id = albums.album_artist_id").
+		Joins("LEFT JOIN songs ON songs.album_id = albums.id").
+		Group("albums.id").Order(order)
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit).Offset(q.Offset)
+	}
+	albums := []AlbumInfo{}
+	err := tx.Scan(&albums).Error
+	return albums, total, err
+}
+
+// GetAlbumInfo 根据 ID 查询专辑，不存在时返回 gorm.ErrRecordNotFound
+func GetAlbumInfo(db *gorm.DB, id uint) (*AlbumInfo, error) {
+	var albums []AlbumInfo
+	err := db.Model(&Album{}).Select(albumInfoSelect).
+		Joins("LEFT JOIN artists ON artists.id = albums.album_artist_id").
+		Joins("LEFT JOIN songs ON songs.album_id = albums.id").
+		Where("albums.id = ?", id).Group("albums.id").Scan(&albums).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(albums) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &albums[0], nil
+}
+
+// GetAlbumTracks 返回专辑的歌曲，按碟号、曲目序号排序
+func GetAlbumTracks(db *gorm.DB, albumID uint) ([]Song, error) {
+	var songs []Song
+	err := db.Where("album_id = ?", albumID).Order("disc_num, track_num, start_ms, id").Find(&songs).Error
+	return songs, err
+}
+
+// GetArtistSongs 返回主艺术家为该艺术家（含别名归并的写法）的歌曲，按专辑、碟号、曲目序号排序
+func GetArtistSongs(db *gorm.DB, artistID uint) ([]Song, error) {
+	var songs []Song
+	err := db.Where("artist_id = ?", artistID).Order("album, disc_num, track_num, start_ms, id").Find(&songs).Error
+	return songs, err
+}
+
+// GetAlbumArtists 返回专辑中出现的曲目艺术家（按曲目数降序）
+func GetAlbumArtists(db *gorm.DB, albumID uint) ([]Artist, error) {
+	artists := []Artist{}
+	err := db.Model(&Artist{}).Joins("JOIN songs ON songs.artist_id = artists.id").
+		Where("songs.album_id = ?", albumID).Group("artists.id").
+		Order("COUNT(*) DESC, artists.name_key").Find(&artists).Error
+	return artists, err
+}
+
+// GetArtistAppearances 返回艺术家有署名（任意角色）、但专辑艺术家不是其本人的专辑（如合辑、客串、混音）
+func GetArtistAppearances(db *gorm.DB, artistID uint) ([]AlbumInfo, error) {
+	albums := []AlbumInfo{}
+	err := db.Model(&Album{}).Select(albumInfoSelect).
+		Joins("LEFT JOIN artists ON artists.id = albums.album_artist_id").
+		Joins("LEFT JOIN songs ON songs.album_id = albums.id").
+		Where("albums.album_artist_id <> ? AND albums.id IN (SELECT songs.album_id FROM songs "+
+			"JOIN song_artists ON song_artists.song_id = songs.id WHERE song_artists.artist_id = ?)", artistID, artistID).
+		Group("albums.id").Order("year, albums.name_key").Scan(&albums).Error
+	return albums, err
+}
+
+// ArtistTrack 艺术家署名的一首歌曲及其在歌曲中的角色
+type ArtistTrack struct {
+	Song
+	Roles []string `json:"roles"`
+}
+
+// GetArtistTracks 返回艺术家署名的歌曲，按专辑、碟号、曲目序号排序；role 非空时只返回该角色的歌曲
+func GetArtistTracks(db *gorm.DB, artistID uint, role string) ([]ArtistTrack, error) {
+	credits := db.Model(&SongArtist{}).Select("song_id").Where("artist_id = ?", artistID)
+	if role != "" {
+		credits = credits.Where("role = ?", role)
+	}
+	var songs []Song
+	if err := db.Where("id IN (?)", credits).Order("album, disc_num, track_num, start_ms, id").Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	var rows []SongArtist
+	if err := db.Where("artist_id = ?", artistID).Order("role").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	roles := make(map[uint][]string, len(songs))
+	for _, r := range rows {
+		roles[r.SongID] = append(roles[r.SongID], r.Role)
+	}
+	tracks := make([]ArtistTrack, len(songs))
+	for i, s := range songs {
+		tracks[i] = ArtistTrack{Song: s, Roles: roles[s.ID]}
+	}
+	return tracks, nil
+}
+
+// GenreInfo 流派及其歌曲数
+type GenreInfo struct {
+	Name      string `json:"name"`
+	SongCount int    `json:"song_count"`
+}
+
+// ListGenres 返回全部流派（不区分大小写合并），按歌曲数降序
+func ListGenres(db *gorm.DB) ([]GenreInfo, error) {
+	genres := []GenreInfo{}
+	err := db.Raw(`SELECT MIN(value) AS name, COUNT(DISTINCT songs.id) AS song_count
+		FROM songs, json_each(songs.genres) GROUP BY LOWER(value) ORDER BY song_count DESC, name`).Scan(&genres).Error
+	return genres, err
+}
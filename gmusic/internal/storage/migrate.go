@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 版本化迁移：migrations 目录下的 NNNN_说明.sql 随程序一起编译进二进制，
+// 按版本号升序执行，每个迁移在独立事务中完成并记入 schema_migrations 表。
+// 新增结构变更时只需添加下一个编号的文件，已发布的迁移文件不应再修改。
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// Migration 一个嵌入的迁移脚本
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	SQL     string `json:"-"`
+}
+
+// SchemaTooNewError 数据库结构版本高于当前程序所知的最新版本（通常是被新版本 GMusic 升级过），
+// 此时继续运行可能破坏数据，启动应当中止
+type SchemaTooNewError struct {
+	Current int // 数据库中已应用的最高版本
+	Latest  int // 当前程序内置的最高版本
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("数据库结构版本 %d 高于当前程序支持的版本 %d，请升级 GMusic 或使用匹配的数据库文件", e.Current, e.Latest)
+}
+
+// Migrations 返回内置的全部迁移，按版本号升序
+func Migrations() ([]Migration, error) {
+	files, err := fs.Glob(migrationFS, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Migration, 0, len(files))
+	seen := make(map[int]string)
+	for _, f := range files {
+		base := strings.TrimSuffix(path.Base(f), ".sql")
+		num, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("迁移文件名无效: %s（应为 NNNN_说明.sql）", f)
+		}
+		if prev, dup := seen[version]; dup {
+			return nil, fmt.Errorf("迁移版本 %d 重复: %s 与 %s", version, prev, f)
+		}
+		seen[version] = f
+		data, err := migrationFS.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Migration{Version: version, Name: name, SQL: string(data)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// beforeMigration 在对应版本的迁移脚本之前、于同一事务中执行的步骤，处理 SQL 脚本无法按条件表达的结构差异
+var beforeMigration = map[int]func(tx *gorm.DB) error{
+	3: addMissingSongColumns,
+}
+
+// initialSongColumns 0001 中 songs 表的全部列。0001 以 IF NOT EXISTS 接管旧数据库时不会改动已有的表，
+// 更早版本 AutoMigrate 建的 songs 表缺少 start_ms、end_ms、cue_file 等后加的列，需在用到它们之前补齐
+var initialSongColumns = []struct{ name, typ string }{
+	{"title", "text"}, {"artist", "text"}, {"album", "text"}, {"file_path", "text"},
+	{"duration", "integer"}, {"bit_rate", "integer"}, {"format", "text"}, {"cover_url", "text"},
+	{"track_num", "integer"}, {"year", "integer"},
+	{"start_ms", "integer"}, {"end_ms", "integer"}, {"cue_file", "text"},
+}
+
+// addMissingSongColumns 按 PRAGMA table_info(songs) 为旧表补上 0001 中有而表中缺少的列
+func addMissingSongColumns(tx *gorm.DB) error {
+	var cols []struct{ Name string }
+	if err := tx.Raw("PRAGMA table_info(`songs`)").Scan(&cols).Error; err != nil {
+		return err
+	}
+	have := make(map[string]bool, len(cols))
+	for _, c := range cols {
+		have[strings.ToLower(c.Name)] = true
+	}
+	for _, c := range initialSongColumns {
+		if have[c.name] {
+			continue
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE `songs` ADD COLUMN `%s` %s", c.name, c.typ)).Error; err != nil {
+			return fmt.Errorf("补齐 songs.%s 失败: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// Migrate 执行全部尚未应用的迁移。
+// 数据库版本高于程序所知版本时返回 *SchemaTooNewError 且不做任何修改；
+// 某个迁移失败时其事务回滚，之前已成功的迁移保留，错误中指明失败的版本。
+func Migrate(db *gorm.DB) error {
+	list, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if err := db.Exec("CREATE TABLE IF NOT EXISTS `schema_migrations` (" +
+		"`version` integer PRIMARY KEY, `name` text NOT NULL, `applied_at` integer NOT NULL)").Error; err != nil {
+		return fmt.Errorf("创建 schema_migrations 失败: %w", err)
+	}
+
+	var applied []int
+	if err := db.Raw("SELECT version FROM schema_migrations").Scan(&applied).Error; err != nil {
+		return err
+	}
+	done := make(map[int]bool, len(applied))
+	current := 0
+	for _, v := range applied {
+		done[v] = true
+		current = max(current, v)
+	}
+	latest := 0
+	if len(list) > 0 {
+		latest = list[len(list)-1].Version
+	}
+	if current > latest {
+		return &SchemaTooNewError{Current: current, Latest: latest}
+	}
+
+	for _, m := range list {
+		if done[m.Version] {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if before := beforeMigration[m.Version]; before != nil {
+				if err := before(tx); err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec(m.SQL).Error; err != nil {
+				return err
+			}
+			return tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				m.Version, m.Name, time.Now().Unix()).Error
+		})
+		if err != nil {
+			return fmt.Errorf("执行迁移 %04d_%s 失败: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// baselineSchema 引入版本化迁移之前 AutoMigrate 生成的结构：songs 还没有 start_ms、end_ms、cue_file
+const baselineSchema = "CREATE TABLE `songs` (`id` integer,`title` text,`artist` text,`album` text,`file_path` text," +
+	"`duration` integer,`bit_rate` integer,`format` text,`cover_url` text,`track_num` integer,`year` integer,PRIMARY KEY (`id`));" +
+	"CREATE TABLE `playlists` (`id` integer,`name` text,PRIMARY KEY (`id`));" +
+	"CREATE TABLE `playlist_songs` (`playlist_id` integer,`song_id` integer,PRIMARY KEY (`playlist_id`,`song_id`)," +
+	"CONSTRAINT `fk_playlist_songs_playlist` FOREIGN KEY (`playlist_id`) REFERENCES `playlists`(`id`)," +
+	"CONSTRAINT `fk_playlist_songs_song` FOREIGN KEY (`song_id`) REFERENCES `songs`(`id`));" +
+	"CREATE TABLE `play_histories` (`id` integer,`song_id` integer,`played_at` integer,PRIMARY KEY (`id`));"
+
+// TestInitDBUpgradesBaseline 旧版本的数据库（含重复导入的歌曲）可以直接升级到最新结构，数据保留、重复记录合并
+func TestInitDBUpgradesBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gmusic.db")
+	old, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		baselineSchema,
+		"INSERT INTO songs (id, title, artist, album, file_path, duration, format, track_num, year) VALUES " +
+			"(1, '晴天', '周杰伦', '叶惠美', '/music/qingtian.flac', 269, 'flac', 3, 2003)," +
+			"(2, '晴天', '周杰伦', '叶惠美', '/music/qingtian.flac', 269, 'flac', 3, 2003)," +
+			"(3, 'Hoppípolla', 'Sigur Rós', 'Takk...', '/music/hoppipolla.mp3', 268, 'mp3', 2, 2005)",
+		"INSERT INTO playlists (id, name) VALUES (1, '收藏')",
+		"INSERT INTO playlist_songs (playlist_id, song_id) VALUES (1, 2), (1, 3)",
+		"INSERT INTO play_histories (id, song_id, played_at) VALUES (1, 2, 1700000000)",
+	} {
+		if err := old.Exec(stmt).Error; err != nil {
+			t.Fatalf("建立旧数据库失败: %v", err)
+		}
+	}
+	if sqlDB, err := old.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+
+	db, err := InitDB(path)
+	if err != nil {
+		t.Fatalf("升级旧数据库失败: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	list, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied int64
+	db.Raw("SELECT COUNT(*) FROM schema_migrations").Scan(&applied)
+	if int(applied) != len(list) {
+		t.Errorf("已应用 %d 个迁移，期望 %d", applied, len(list))
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  int64
+	}{
+		{"重复记录合并为一条", "SELECT COUNT(*) FROM songs WHERE file_path = '/music/qingtian.flac'", 1},
+		{"旧记录的 start_ms 为 0", "SELECT COUNT(*) FROM songs WHERE start_ms = 0", 2},
+		{"播放列表改指向保留的记录", "SELECT COUNT(*) FROM playlist_songs WHERE song_id IN (1, 3)", 2},
+		{"播放历史改指向保留的记录", "SELECT COUNT(*) FROM play_histories WHERE song_id = 1", 1},
+	}
+	for _, tt := range tests {
+		var got int64
+		if err := db.Raw(tt.query).Scan(&got).Error; err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: 得到 %d，期望 %d", tt.name, got, tt.want)
+		}
+	}
+
+	song, err := GetSongByID(db, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if song.Title != "Hoppípolla" || song.Artist != "Sigur Rós" || song.CueFile != "" || song.EndMs != 0 {
+		t.Errorf("升级后歌曲 = %+v", song)
+	}
+
+	// 已是最新版本时再次启动不做任何变更
+	if err := Migrate(db); err != nil {
+		t.Errorf("重复执行迁移: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+-- 初始结构：与此前 AutoMigrate 生成的表结构一致。
+-- 使用 IF NOT EXISTS，使由旧版本（AutoMigrate）创建的数据库可以直接纳入版本管理。
+CREATE TABLE IF NOT EXISTS `songs` (
+    `id` integer,
+    `title` text,
+    `artist` text,
+    `album` text,
+    `file_path` text,
+    `duration` integer,
+    `bit_rate` integer,
+    `format` text,
+    `cover_url` text,
+    `track_num` integer,
+    `year` integer,
+    `start_ms` integer,
+    `end_ms` integer,
+    `cue_file` text,
+    PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `playlists` (
+    `id` integer,
+    `name` text,
+    PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `playlist_songs` (
+    `playlist_id` integer,
+    `song_id` integer,
+    PRIMARY KEY (`playlist_id`, `song_id`),
+    CONSTRAINT `fk_playlist_songs_playlist` FOREIGN KEY (`playlist_id`) REFERENCES `playlists`(`id`),
+    CONSTRAINT `fk_playlist_songs_song` FOREIGN KEY (`song_id`) REFERENCES `songs`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `play_histories` (
+    `id` integer,
+    `song_id` integer,
+    `played_at` integer,
+    PRIMARY KEY (`id`)
+);
@@ -0,0 +1,5 @@
+-- 常用查询的索引：按路径查重/定位、按艺术家与专辑浏览、按歌曲或时间范围统计播放历史
+CREATE INDEX IF NOT EXISTS `idx_songs_file_path` ON `songs` (`file_path`);
+CREATE INDEX IF NOT EXISTS `idx_songs_artist_album` ON `songs` (`artist`, `album`);
+CREATE INDEX IF NOT EXISTS `idx_play_histories_song_id` ON `play_histories` (`song_id`);
+CREATE INDEX IF NOT EXISTS `idx_play_histories_played_at` ON `play_histories` (`played_at`);
@@ -0,0 +1,30 @@
+-- 歌曲唯一键：(file_path, start_ms)。CUE 分轨共享同一文件，以起始偏移区分；普通歌曲 start_ms 为 0。
+-- 先合并此前并发扫描产生的重复记录：保留 ID 最小的一条，播放列表与播放历史改指向保留的记录。
+UPDATE `songs` SET `start_ms` = 0 WHERE `start_ms` IS NULL;
+
+CREATE TEMP TABLE `song_dupes` AS
+SELECT s.`id` AS `dup_id`, k.`keep_id`
+FROM `songs` s
+JOIN (
+    SELECT `file_path`, `start_ms`, MIN(`id`) AS `keep_id`
+    FROM `songs`
+    GROUP BY `file_path`, `start_ms`
+    HAVING COUNT(*) > 1
+) k ON s.`file_path` = k.`file_path` AND s.`start_ms` = k.`start_ms`
+WHERE s.`id` <> k.`keep_id`;
+
+INSERT OR IGNORE INTO `playlist_songs` (`playlist_id`, `song_id`)
+SELECT ps.`playlist_id`, d.`keep_id`
+FROM `playlist_songs` ps JOIN `song_dupes` d ON ps.`song_id` = d.`dup_id`;
+DELETE FROM `playlist_songs` WHERE `song_id` IN (SELECT `dup_id` FROM `song_dupes`);
+
+UPDATE `play_histories`
+SET `song_id` = (SELECT `keep_id` FROM `song_dupes` WHERE `dup_id` = `play_histories`.`song_id`)
+WHERE `song_id` IN (SELECT `dup_id` FROM `song_dupes`);
+
+DELETE FROM `songs` WHERE `id` IN (SELECT `dup_id` FROM `song_dupes`);
+DROP TABLE `song_dupes`;
+
+-- 唯一索引的前缀列同样可用于按路径查询，原普通索引不再需要
+DROP INDEX IF EXISTS `idx_songs_file_path`;
+CREATE UNIQUE INDEX `idx_songs_file_path_start` ON `songs` (`file_path`, `start_ms`);
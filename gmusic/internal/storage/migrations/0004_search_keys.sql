@@ -0,0 +1,3 @@
+-- 搜索辅助列：标题、艺术家、专辑的简体形式、全拼与拼音首字母，由程序在写入时生成。
+-- 新列为 NULL，启动时由 BackfillSearchKeys 补齐；今后生成规则变化时，新迁移只需将其置回 NULL。
+ALTER TABLE `songs` ADD COLUMN `search_keys` text;
@@ -0,0 +1,9 @@
+-- 智能播放列表：保存结构化查询，歌曲在读取时实时计算
+CREATE TABLE `smart_playlists` (
+    `id` integer,
+    `name` text NOT NULL,
+    `query` text NOT NULL DEFAULT '',
+    `sort` text NOT NULL DEFAULT '',
+    `max_songs` integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`)
+);
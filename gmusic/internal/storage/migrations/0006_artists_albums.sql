@@ -0,0 +1,39 @@
+-- 艺术家与专辑实体。歌曲保留原始的 artist/album 文本用于显示，另以 artist_id/album_id 关联实体。
+-- name_key 为归一化名称（简体、小写、合并空白），简繁体或大小写不同的名称视为同一艺术家/专辑。
+CREATE TABLE `artists` (
+    `id` integer,
+    `name` text NOT NULL,
+    `name_key` text NOT NULL,
+    PRIMARY KEY (`id`)
+);
+CREATE UNIQUE INDEX `idx_artists_name_key` ON `artists`(`name_key`);
+
+-- 艺术家别名：如 "Jay Chou" → 周杰伦，扫描时按别名归入同一艺术家
+CREATE TABLE `artist_aliases` (
+    `name_key` text NOT NULL,
+    `name` text NOT NULL,
+    `artist_id` integer NOT NULL,
+    PRIMARY KEY (`name_key`)
+);
+CREATE INDEX `idx_artist_aliases_artist_id` ON `artist_aliases`(`artist_id`);
+
+-- 专辑以（名称，专辑艺术家）区分；合辑的专辑艺术家为 Various Artists
+CREATE TABLE `albums` (
+    `id` integer,
+    `name` text NOT NULL,
+    `name_key` text NOT NULL,
+    `album_artist_id` integer NOT NULL DEFAULT 0,
+    `compilation` numeric NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`)
+);
+CREATE UNIQUE INDEX `idx_albums_name_artist` ON `albums`(`name_key`, `album_artist_id`);
+CREATE INDEX `idx_albums_album_artist_id` ON `albums`(`album_artist_id`);
+
+-- 标签中的专辑艺术家、碟号与合辑标记；artist_id/album_id 为 NULL 表示尚未关联，启动时由 BackfillLibrary 补齐
+ALTER TABLE `songs` ADD COLUMN `album_artist` text NOT NULL DEFAULT '';
+ALTER TABLE `songs` ADD COLUMN `disc_num` integer NOT NULL DEFAULT 0;
+ALTER TABLE `songs` ADD COLUMN `compilation` numeric NOT NULL DEFAULT 0;
+ALTER TABLE `songs` ADD COLUMN `artist_id` integer;
+ALTER TABLE `songs` ADD COLUMN `album_id` integer;
+CREATE INDEX `idx_songs_artist_id` ON `songs`(`artist_id`);
+CREATE INDEX `idx_songs_album_id` ON `songs`(`album_id`, `disc_num`, `track_num`);
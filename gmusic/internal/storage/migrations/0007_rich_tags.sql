@@ -0,0 +1,7 @@
+-- 更多标签字段：作曲、流派（JSON 数组，可多值）、总曲目数、总碟数、注释。
+-- 已导入的歌曲在重新扫描时补齐。
+ALTER TABLE `songs` ADD COLUMN `composer` text NOT NULL DEFAULT '';
+ALTER TABLE `songs` ADD COLUMN `genres` text NOT NULL DEFAULT '[]';
+ALTER TABLE `songs` ADD COLUMN `track_total` integer NOT NULL DEFAULT 0;
+ALTER TABLE `songs` ADD COLUMN `disc_total` integer NOT NULL DEFAULT 0;
+ALTER TABLE `songs` ADD COLUMN `comment` text NOT NULL DEFAULT '';
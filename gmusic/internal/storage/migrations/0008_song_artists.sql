@@ -0,0 +1,17 @@
+-- 歌曲署名：艺术家标签按拆分规则拆成多位艺术家（"A feat. B" → A 为 main、B 为 featured），
+-- 连同标题中的混音者与作曲一起记录。songs.artist 保留原文用于显示，songs.artist_id 指向第一位 main。
+CREATE TABLE `song_artists` (
+    `song_id` integer NOT NULL,
+    `artist_id` integer NOT NULL,
+    `role` text NOT NULL,
+    `position` integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (`song_id`, `artist_id`, `role`)
+);
+CREATE INDEX `idx_song_artists_artist_id` ON `song_artists`(`artist_id`, `role`);
+
+-- 曲库级别的设置，如当前生效的拆分规则摘要；规则变化后启动时重新拆分全部歌曲
+CREATE TABLE `library_settings` (
+    `key` text NOT NULL,
+    `value` text NOT NULL DEFAULT '',
+    PRIMARY KEY (`key`)
+);
@@ -0,0 +1,8 @@
+-- 标签编码：tag_charset 记录导入时对 ID3 文字采用的编码（gbk、big5、shift_jis、latin1），
+-- 空串表示标签已是 Unicode 或纯 ASCII。tag_charset_overrides 为检测出错的文件手动指定编码，扫描时优先使用。
+ALTER TABLE `songs` ADD COLUMN `tag_charset` text NOT NULL DEFAULT '';
+CREATE TABLE `tag_charset_overrides` (
+    `file_path` text NOT NULL,
+    `charset` text NOT NULL,
+    PRIMARY KEY (`file_path`)
+);
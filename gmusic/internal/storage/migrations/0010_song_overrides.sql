@@ -0,0 +1,10 @@
+-- 手动修改的元数据：按 (file_path, start_ms) 记录用户修改过的字段，重新扫描同一文件时覆盖标签中的值。
+-- value 为用户设定的值，file_value 为文件标签中的值（恢复时使用），均为 JSON 文本。
+CREATE TABLE `song_overrides` (
+    `file_path` text NOT NULL,
+    `start_ms` integer NOT NULL DEFAULT 0,
+    `field` text NOT NULL,
+    `value` text NOT NULL,
+    `file_value` text NOT NULL,
+    PRIMARY KEY (`file_path`, `start_ms`, `field`)
+);
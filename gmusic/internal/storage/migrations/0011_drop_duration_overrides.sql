@@ -0,0 +1,3 @@
+-- 时长不再可手动修改（由文件计算，界面探测到的时长也直接写入），删除此前记录的时长修改，
+-- songs.duration 保留当前值，之后补齐时长或重新扫描会按文件更新。
+DELETE FROM `song_overrides` WHERE `field` = 'duration';
@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 手动修改的元数据：用户通过接口修改的字段单独记录在 song_overrides 表（按 (file_path, start_ms)，与歌曲 ID 无关），
+// 同时保存文件标签中的原值。
+//
+// 修改在写入时合并，而不是读取时：songs 表保存的是合并后的值，文件中的原值只存在于 song_overrides.file_value。
+// 这样搜索（含全文索引与拼音）、查询语言、智能播放列表、艺术家/专辑实体与署名都直接按修改后的值工作，
+// GetSong* 等读取函数无需再合并。代价是任何把文件中读出的值写入这些列的代码都必须先经过 applyOverrides，
+// 否则会覆盖用户的修改。目前的写入路径：
+//   - 扫描导入：UpsertSong/AddSong 先把文件读出的值记为原值，再合并修改过的字段
+//   - 接口修改与恢复：SetSongOverrides/RevertSongOverrides 直接写入修改值或原值
+//
+// 值与原值均以 JSON 文本保存，类型与 Song 的对应字段一致。
+// 时长不可手动修改：它由文件计算（见 UpdateSongDuration），界面探测到的时长也按普通写入处理，不应冻结为修改值。
+
+// SongOverride 一个手动修改过的字段
+type SongOverride struct {
+	FilePath  string `gorm:"primaryKey"`
+	StartMs   int64  `gorm:"primaryKey"`
+	Field     string `gorm:"primaryKey"` // 字段名，同 Song 的 JSON 字段名
+	Value     string // 用户设定的值（JSON）
+	FileValue string // 文件标签中的值（JSON）
+}
+
+// overrideField 可手动修改的字段：ptr 返回 Song 中对应字段的指针
+type overrideField struct {
+	ptr func(s *Song) any
+}
+
+// overrideFields 可手动修改的字段，键为 Song 的 JSON 字段名（同时也是列名）
+var overrideFields = map[string]overrideField{
+	"title":        {func(s *Song) any { return &s.Title }},
+	"artist":       {func(s *Song) any { return &s.Artist }},
+	"album":        {func(s *Song) any { return &s.Album }},
+	"album_artist": {func(s *Song) any { return &s.AlbumArtist }},
+	"composer":     {func(s *Song) any { return &s.Composer }},
+	"genres":       {func(s *Song) any { return &s.Genres }},
+	"comment":      {func(s *Song) any { return &s.Comment }},
+	"year":         {func(s *Song) any { return &s.Year }},
+	"track_num":    {func(s *Song) any { return &s.TrackNum }},
+	"track_total":  {func(s *Song) any { return &s.TrackTotal }},
+	"disc_num":     {func(s *Song) any { return &s.DiscNum }},
+	"disc_total":   {func(s *Song) any { return &s.DiscTotal }},
+	"compilation":  {func(s *Song) any { return &s.Compilation }},
+}
+
+// OverrideFields 可手动修改的字段名，按字母排序
+func OverrideFields() []string {
+	names := make([]string, 0, len(overrideFields))
+	for name := range overrideFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsOverrideField 字段是否可手动修改
+func IsOverrideField(field string) bool {
+	_, ok := overrideFields[field]
+	return ok
+}
+
+// get 歌曲中字段的当前值（JSON）
+func (f overrideField) get(s *Song) string {
+	data, _ := json.Marshal(f.ptr(s))
+	return string(data)
+}
+
+// set 把 JSON 值写入歌曲的字段
+func (f overrideField) set(s *Song, value string) error {
+	return json.Unmarshal([]byte(value), f.ptr(s))
+}
+
+// NormalizeOverride 校验并规范化字段的新值：类型须与字段一致（文字、整数、布尔或流派数组），
+// 文字去掉首尾空白，整数不能为负；返回可保存的 JSON 文本
+func NormalizeOverride(field string, raw json.RawMessage) (string, error) {
+	f, ok := overrideFields[field]
+	if !ok {
+		return "", fmt.Errorf("字段 %q 不可修改（可修改：%s）", field, strings.Join(OverrideFields(), "、"))
+	}
+	var s Song
+	if err := json.Unmarshal(raw, f.ptr(&s)); err != nil {
+		return "", fmt.Errorf("字段 %q 的值类型不正确: %w", field, err)
+	}
+	switch v := f.ptr(&s).(type) {
+	case *string:
+		*v = strings.TrimSpace(*v)
+	case *int:
+		if *v < 0 {
+			return "", fmt.Errorf("字段 %q 不能为负数", field)
+		}
+	case *[]string:
+		genres := []string{}
+		for _, g := range *v {
+			if g = strings.TrimSpace(g); g != "" {
+				genres = append(genres, g)
+			}
+		}
+		*v = genres
+	}
+	return f.get(&s), nil
+}
+
+// getSongOverrides 读取文件（或 CUE 分轨）的全部修改记录
+func getSongOverrides(db *gorm.DB, filePath string, startMs int64) ([]SongOverride, error) {
+	var rows []SongOverride
+	err := db.Where("file_path = ? AND start_ms = ?", filePath, startMs).Order("field").Find(&rows).Error
+	return rows, err
+}
+
+// applyOverrides 在把文件中读出的值写入 songs 之前合并手动修改：s 中是刚从文件读出的值，
+// 记为各字段的原值（有变化时更新），再用修改过的值替换，并填充 s.Overridden
+func applyOverrides(db *gorm.DB, s *Song) error {
+	rows, err := getSongOverrides(db, s.FilePath, s.StartMs)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+	s.Overridden = s.Overridden[:0]
+	for _, o := range rows {
+		f, ok := overrideFields[o.Field]
+		if !ok {
+			continue
+		}
+		s.Overridden = append(s.Overridden, o.Field)
+		if fileValue := f.get(s); fileValue != o.FileValue {
+			if err := db.Model(&SongOverride{}).
+				Where("file_path = ? AND start_ms = ? AND field = ?", o.FilePath, o.StartMs, o.Field).
+				Update("file_value", fileValue).Error; err != nil {
+				return err
+			}
+		}
+		if err := f.set(s, o.Value); err != nil {
+			return fmt.Errorf("合并字段 %s 的修改失败: %w", o.Field, err)
+		}
+	}
+	return nil
+}
+
+// GetSongOverrides 返回歌曲修改过的字段：字段名 → {value, file_value}
+func GetSongOverrides(db *gorm.DB, s *Song) (map[string]map[string]json.RawMessage, error) {
+	rows, err := getSongOverrides(db, s.FilePath, s.StartMs)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]map[string]json.RawMessage, len(rows))
+	for _, o := range rows {
+		out[o.Field] = map[string]json.RawMessage{"value": json.RawMessage(o.Value), "file_value": json.RawMessage(o.FileValue)}
+	}
+	return out, nil
+}
+
+// AttachOverrides 为一组歌曲填充 Overridden（修改过的字段名），用于接口返回
+func AttachOverrides(db *gorm.DB, songs []Song) error {
+	const batch = 500
+	type key struct {
+		path    string
+		startMs int64
+	}
+	fields := map[key][]string{}
+	for i := 0; i < len(songs); i += batch {
+		paths := make([]string, 0, batch)
+		for _, s := range songs[i:min(i+batch, len(songs))] {
+			paths = append(paths, s.FilePath)
+		}
+		var rows []SongOverride
+		if err := db.Select("file_path, start_ms, field").Where("file_path IN ?", paths).
+			Order("field").Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, o := range rows {
+			k := key{o.FilePath, o.StartMs}
+			fields[k] = append(fields[k], o.Field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	for i := range songs {
+		songs[i].Overridden = fields[key{songs[i].FilePath, songs[i].StartMs}]
+	}
+	return nil
+}
+
+// SetSongOverrides 手动修改歌曲的字段（values 为字段名 → NormalizeOverride 规范化后的值），
+// 之后重新扫描也保留修改。与文件中的值相同时视为恢复，不再记录。返回修改后的歌曲。
+func SetSongOverrides(db *gorm.DB, id uint, values map[string]string) (*Song, error) {
+	song, err := GetSongByID(db, id)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := overridesByField(db, song)
+	if err != nil {
+		return nil, err
+	}
+	for field, value := range values {
+		f, ok := overrideFields[field]
+		if !ok {
+			return nil, fmt.Errorf("字段 %q 不可修改", field)
+		}
+		// 没有修改记录时，库中的值就是文件中的值
+		fileValue := f.get(song)
+		if o, ok := existing[field]; ok {
+			fileValue = o.FileValue
+		}
+		if err := f.set(song, value); err != nil {
+			return nil, fmt.Errorf("字段 %q 的值类型不正确: %w", field, err)
+		}
+		o := SongOverride{FilePath: song.FilePath, StartMs: song.StartMs, Field: field, Value: f.get(song), FileValue: fileValue}
+		if o.Value == fileValue {
+			err = db.Delete(&o).Error
+		} else {
+			err = db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&o).Error
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return song, saveSongEdits(db, song)
+}
+
+// RevertSongOverrides 把歌曲的字段恢复为文件中的值并删除修改记录；fields 为空时恢复全部字段。返回恢复后的歌曲。
+func RevertSongOverrides(db *gorm.DB, id uint, fields ...string) (*Song, error) {
+	song, err := GetSongByID(db, id)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := overridesByField(db, song)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		for field := range existing {
+			fields = append(fields, field)
+		}
+	}
+	for _, field := range fields {
+		o, ok := existing[field]
+		if !ok {
+			continue
+		}
+		if err := overrideFields[field].set(song, o.FileValue); err != nil {
+			return nil, fmt.Errorf("恢复字段 %s 失败: %w", field, err)
+		}
+		if err := db.Delete(&o).Error; err != nil {
+			return nil, err
+		}
+	}
+	return song, saveSongEdits(db, song)
+}
+
+// overridesByField 歌曲现有的修改记录，按字段名索引（忽略已不可修改的字段）
+func overridesByField(db *gorm.DB, s *Song) (map[string]SongOverride, error) {
+	rows, err := getSongOverrides(db, s.FilePath, s.StartMs)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]SongOverride, len(rows))
+	for _, o := range rows {
+		if _, ok := overrideFields[o.Field]; ok {
+			out[o.Field] = o
+		}
+	}
+	return out, nil
+}
+
+// saveSongEdits 写回手动修改后的歌曲：重新关联艺术家与专辑实体、更新署名，并填充 Overridden
+func saveSongEdits(db *gorm.DB, s *Song) error {
+	if err := linkSong(db, s); err != nil {
+		return err
+	}
+	if err := db.Model(s).Select(songRefreshColumns).Updates(s).Error; err != nil {
+		return err
+	}
+	if err := saveCredits(db, s.ID, s.Credits); err != nil {
+		return err
+	}
+	songs := []Song{*s}
+	if err := AttachOverrides(db, songs); err != nil {
+		return err
+	}
+	s.Overridden = songs[0].Overridden
+	return nil
+}
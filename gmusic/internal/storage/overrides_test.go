@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestOverridesSurviveWrites 手动修改在重新扫描与补齐时长后保留，恢复时得到文件中最新的值
+func TestOverridesSurviveWrites(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "gmusic.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanned := func(title string, duration int) *Song {
+		return &Song{Title: title, Artist: "Sigur Rós", Album: "Takk...", FilePath: "/music/hoppipolla.flac", Duration: duration, Genres: []string{"Post-rock"}}
+	}
+	song := scanned("Hoppipolla", 0)
+	if _, err := UpsertSong(db, song); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetSongOverrides(db, song.ID, map[string]string{"title": `"Hoppípolla"`, "year": "2005"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新扫描：文件中的标题变了，补齐时长写入文件计算出的值
+	if _, err := UpsertSong(db, scanned("Hoppipolla (remaster)", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateSongDuration(db, song, 268); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		field     string
+		value     string
+		fileValue string
+	}{
+		{"title", `"Hoppípolla"`, `"Hoppipolla (remaster)"`},
+		{"year", "2005", "0"},
+	}
+	got, err := GetSongByID(db, song.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := GetSongOverrides(db, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if v := overrideFields[tt.field].get(got); v != tt.value {
+			t.Errorf("%s = %s，期望保留修改值 %s", tt.field, v, tt.value)
+		}
+		if fv := string(overrides[tt.field]["file_value"]); fv != tt.fileValue {
+			t.Errorf("%s 的原值 = %s，期望 %s", tt.field, fv, tt.fileValue)
+		}
+	}
+
+	reverted, err := RevertSongOverrides(db, song.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Title != "Hoppipolla (remaster)" || reverted.Year != 0 || reverted.Duration != 268 || len(reverted.Overridden) != 0 {
+		t.Errorf("恢复后 = %q/%d/%d，修改过的字段 %v", reverted.Title, reverted.Year, reverted.Duration, reverted.Overridden)
+	}
+}
+
+// TestDurationNotOverridable 时长不是可手动修改的字段，写入时长不产生修改记录，重新扫描按文件更新
+func TestDurationNotOverridable(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "gmusic.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NormalizeOverride("duration", []byte("270")); err == nil {
+		t.Error("duration 不应可修改")
+	}
+	song := &Song{Title: "十年", Artist: "陈奕迅", FilePath: "/music/十年.mp3"}
+	if _, err := UpsertSong(db, song); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateSongDuration(db, song, 205); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpsertSong(db, &Song{Title: "十年", Artist: "陈奕迅", FilePath: "/music/十年.mp3", Duration: 206}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetSongByID(db, song.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := GetSongOverrides(db, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Duration != 206 || len(got.Overridden) != 0 || len(overrides) != 0 {
+		t.Errorf("时长 = %d，修改过的字段 %v %v，期望 206 且没有修改记录", got.Duration, got.Overridden, overrides)
+	}
+}
@@ -0,0 +1,608 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yudongyouqing/GMusic/internal/hanzi"
+	"gorm.io/gorm"
+)
+
+// 结构化查询语言，用于 /api/songs/search 与智能播放列表，例如：
+//
+//	artist:"陈奕迅" year:2000..2010 format:flac duration:>300 -live
+//
+// 语法：
+//   - 空白分隔的条件之间为 AND（也可显式写 AND），OR 或 | 表示或，括号分组；AND 优先于 OR
+//   - -条件 或 NOT 条件 表示取反
+//   - 裸词在标题、艺术家、专辑（含拼音、简繁体）中做子串匹配，与普通搜索一致
+//   - 文本字段 field:值 为包含匹配，field:=值 为精确匹配（不区分大小写），field:"" 匹配空值；
+//     流派可多值，genre:rock 表示任一流派包含 rock
+//   - 数值字段支持 field:N、field:>N、>=、<、<=、field:A..B（闭区间，可省略一端）；
+//     时长以秒为单位，也可写作 分:秒，如 duration:>4:30
+//   - 值中含空白或特殊字符时用双引号包裹，引号内用 \" 表示引号本身
+//
+// 字段名与值都只作为参数绑定进 SQL，字段名经白名单映射为列名，不存在注入风险。
+
+// queryFieldKind 字段类型
+type queryFieldKind int
+
+const (
+	fieldText     queryFieldKind = iota // 包含匹配
+	fieldKeyword                        // 精确匹配（不区分大小写），如格式
+	fieldNumber                         // 数值比较与区间
+	fieldDuration                       // 数值，另外接受 分:秒
+	fieldList                           // JSON 数组，任一元素匹配即可（与文本字段同样支持包含、精确与空值）
+)
+
+type queryField struct {
+	column string
+	kind   queryFieldKind
+}
+
+// queryFields 可查询的字段（含别名）→ 列
+var queryFields = map[string]queryField{
+	"title":       {"title", fieldText},
+	"artist":      {"artist", fieldText},
+	"album":       {"album", fieldText},
+	"path":        {"file_path", fieldText},
+	"albumartist": {"album_artist", fieldText},
+	"composer":    {"composer", fieldText},
+	"comment":     {"comment", fieldText},
+	"genre":       {"genres", fieldList},
+	"format":      {"format", fieldKeyword},
+	"year":        {"year", fieldNumber},
+	"track":       {"track_num", fieldNumber},
+	"disc":        {"disc_num", fieldNumber},
+	"bitrate":     {"bit_rate", fieldNumber},
+	"duration":    {"duration", fieldDuration},
+}
+
+// maxQueryDepth 括号与取反的最大嵌套层数
+const maxQueryDepth = 32
+
+// QueryError 查询语法错误，Pos 为出错位置（从 1 开始的字符序号）
+type QueryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("查询语法错误（第 %d 个字符）: %s", e.Pos, e.Msg)
+}
+
+// Query 解析后的结构化查询
+type Query struct {
+	root  queryNode // nil 表示空查询（匹配全部）
+	words []string  // 所有非取反的裸词与文本字段值，用于高亮
+	plain bool      // 只由裸词以 AND 组成，可直接走全文搜索与相关度排序
+}
+
+// ParseQuery 解析查询字符串；空白字符串得到匹配全部歌曲的空查询
+func ParseQuery(s string) (*Query, error) {
+	toks, err := tokenizeQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{toks: toks, end: utf8.RuneCountInString(s) + 1}
+	q := &Query{plain: true}
+	if len(toks) == 0 {
+		return q, nil
+	}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil {
+		if t.kind == tokRParen {
+			return nil, &QueryError{t.pos, "多余的右括号"}
+		}
+		return nil, &QueryError{t.pos, "无法解析 " + t.text}
+	}
+	q.root = root
+	q.collect(root, false)
+	return q, nil
+}
+
+// KeywordQuery 不解析语法，把输入按空白拆成须全部命中的裸词。用于边输入边提示：
+// 输入到一半的 artist:"陈 不会报错，而是当作普通关键词
+func KeywordQuery(s string) *Query {
+	q := &Query{plain: true}
+	var terms andNode
+	for _, w := range strings.Fields(s) {
+		t := termNode(hanzi.Fold(w))
+		terms = append(terms, t)
+		q.words = append(q.words, string(t))
+	}
+	if len(terms) > 0 {
+		q.root = terms
+	}
+	return q
+}
+
+// Empty 是否为空查询
+func (q *Query) Empty() bool { return q.root == nil }
+
+// Where 编译为 WHERE 条件片段与参数（列名以 songs. 限定）；空查询返回 "1 = 1"
+func (q *Query) Where(db *gorm.DB) (string, []interface{}) {
+	if q.root == nil {
+		return "1 = 1", nil
+	}
+	c := &queryCompiler{fts: db.Migrator().HasTable("songs_fts") && FTSAvailable(db)}
+	return q.root.sql(c), c.args
+}
+
+// Apply 把查询条件加到 GORM 查询上
+func (q *Query) Apply(db *gorm.DB) *gorm.DB {
+	cond, args := q.Where(db)
+	return db.Where(cond, args...)
+}
+
+// collect 汇总高亮词并判断是否为纯关键词查询
+func (q *Query) collect(n queryNode, negated bool) {
+	switch n := n.(type) {
+	case andNode:
+		for _, c := range n {
+			q.collect(c, negated)
+		}
+	case orNode:
+		q.plain = false
+		for _, c := range n {
+			q.collect(c, negated)
+		}
+	case notNode:
+		q.plain = false
+		q.collect(n.child, !negated)
+	case termNode:
+		if !negated {
+			q.words = append(q.words, string(n))
+		}
+	case *fieldNode:
+		q.plain = false
+		if !negated && n.field.kind == fieldText && n.value != "" {
+			q.words = append(q.words, hanzi.Fold(n.value))
+		}
+	}
+}
+
+// ---- 词法分析 ----
+
+type tokKind int
+
+const (
+	tokWord tokKind = iota
+	tokField
+	tokLParen
+	tokRParen
+	tokOr
+	tokAnd
+	tokNot
+)
+
+type queryToken struct {
+	kind   tokKind
+	pos    int    // 从 1 开始的字符序号
+	text   string // 原文（用于错误信息）
+	name   string // 字段名（tokField）
+	value  string // 词或字段值（已去引号）
+	quoted bool
+}
+
+func tokenizeQuery(s string) ([]queryToken, error) {
+	rs := []rune(s)
+	var toks []queryToken
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			toks = append(toks, queryToken{kind: tokLParen, pos: i + 1, text: "("})
+			i++
+			continue
+		case r == ')':
+			toks = append(toks, queryToken{kind: tokRParen, pos: i + 1, text: ")"})
+			i++
+			continue
+		case r == '|':
+			toks = append(toks, queryToken{kind: tokOr, pos: i + 1, text: "|"})
+			i++
+			continue
+		case r == '-' && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]) && rs[i+1] != ')':
+			toks = append(toks, queryToken{kind: tokNot, pos: i + 1, text: "-"})
+			i++
+			continue
+		case r == '"':
+			v, next, err := readQuoted(rs, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, queryToken{kind: tokWord, pos: i + 1, text: string(rs[i:next]), value: v, quoted: true})
+			i = next
+			continue
+		}
+
+		start := i
+		for i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '(' && rs[i] != ')' && rs[i] != '"' {
+			if rs[i] == ':' {
+				break
+			}
+			i++
+		}
+		word := string(rs[start:i])
+		if i < len(rs) && rs[i] == ':' && word != "" {
+			name := strings.ToLower(word)
+			if _, ok := queryFields[name]; !ok {
+				return nil, &QueryError{start + 1, fmt.Sprintf("未知字段 %q（可用字段：%s）；如果要搜索含冒号的文本，请用双引号包裹", word, fieldNames())}
+			}
+			i++ // 跳过冒号
+			tok := queryToken{kind: tokField, pos: start + 1, name: name}
+			// 值可以以比较符开头，之后可能是引号
+			vstart := i
+			for i < len(rs) && strings.ContainsRune("<>=", rs[i]) {
+				i++
+			}
+			prefix := string(rs[vstart:i])
+			if i < len(rs) && rs[i] == '"' {
+				v, next, err := readQuoted(rs, i)
+				if err != nil {
+					return nil, err
+				}
+				tok.value, tok.quoted, i = prefix+v, true, next
+			} else {
+				for i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '(' && rs[i] != ')' {
+					i++
+				}
+				tok.value = string(rs[vstart:i])
+				if tok.value == "" {
+					return nil, &QueryError{start + 1, fmt.Sprintf("字段 %s 缺少值", word)}
+				}
+			}
+			tok.text = string(rs[start:i])
+			toks = append(toks, tok)
+			continue
+		}
+		for i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '(' && rs[i] != ')' && rs[i] != '"' {
+			i++
+		}
+		word = string(rs[start:i])
+		tok := queryToken{kind: tokWord, pos: start + 1, text: word, value: word}
+		switch word {
+		case "OR":
+			tok.kind = tokOr
+		case "AND":
+			tok.kind = tokAnd
+		case "NOT":
+			tok.kind = tokNot
+		}
+		toks = append(toks, tok)
+	}
+	return toks, nil
+}
+
+// readQuoted 读取从 rs[i]（双引号）开始的带引号字符串，返回内容与引号之后的位置
+func readQuoted(rs []rune, i int) (string, int, error) {
+	var b strings.Builder
+	for j := i + 1; j < len(rs); j++ {
+		switch {
+		case rs[j] == '\\' && j+1 < len(rs) && (rs[j+1] == '"' || rs[j+1] == '\\'):
+			b.WriteRune(rs[j+1])
+			j++
+		case rs[j] == '"':
+			return b.String(), j + 1, nil
+		default:
+			b.WriteRune(rs[j])
+		}
+	}
+	return "", 0, &QueryError{i + 1, "引号没有闭合"}
+}
+
+func fieldNames() string {
+	names := make([]string, 0, len(queryFields))
+	for n := range queryFields {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// ---- 语法分析 ----
+
+type queryNode interface {
+	sql(c *queryCompiler) string
+}
+
+type (
+	andNode  []queryNode
+	orNode   []queryNode
+	notNode  struct{ child queryNode }
+	termNode string // 已归一化（简体小写）的裸词
+)
+
+// fieldNode 字段条件：文本字段使用 op/value；数值字段使用 lo/hi（闭区间，nil 表示不限）或 op/num
+type fieldNode struct {
+	field  queryField
+	op     string // 文本："" 包含、"=" 精确；数值：= > >= < <=
+	value  string
+	num    float64
+	lo, hi *float64
+}
+
+type queryParser struct {
+	toks []queryToken
+	i    int
+	end  int // 输入末尾位置，用于“意外结束”的错误
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.i < len(p.toks) {
+		return &p.toks[p.i]
+	}
+	return nil
+}
+
+func (p *queryParser) parseOr(depth int) (queryNode, error) {
+	first, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	nodes := orNode{first}
+	for t := p.peek(); t != nil && t.kind == tokOr; t = p.peek() {
+		p.i++
+		next, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, next)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseAnd(depth int) (queryNode, error) {
+	var nodes andNode
+	for {
+		t := p.peek()
+		if t == nil || t.kind == tokOr || t.kind == tokRParen {
+			break
+		}
+		if t.kind == tokAnd {
+			if len(nodes) == 0 {
+				return nil, &QueryError{t.pos, "AND 前缺少条件"}
+			}
+			p.i++
+			if n := p.peek(); n == nil || n.kind == tokOr || n.kind == tokRParen || n.kind == tokAnd {
+				return nil, &QueryError{t.pos, "AND 后缺少条件"}
+			}
+			continue
+		}
+		n, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 0 {
+		pos := p.end
+		if t := p.peek(); t != nil {
+			pos = t.pos
+		}
+		if p.i > 0 && p.toks[p.i-1].kind == tokOr {
+			return nil, &QueryError{p.toks[p.i-1].pos, "OR 后缺少条件"}
+		}
+		if t := p.peek(); t != nil && t.kind == tokOr {
+			return nil, &QueryError{pos, "OR 前缺少条件"}
+		}
+		return nil, &QueryError{pos, "缺少条件"}
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseUnary(depth int) (queryNode, error) {
+	t := p.peek()
+	if depth > maxQueryDepth {
+		return nil, &QueryError{t.pos, "嵌套层数过多"}
+	}
+	switch t.kind {
+	case tokNot:
+		p.i++
+		if n := p.peek(); n == nil || n.kind == tokOr || n.kind == tokRParen || n.kind == tokAnd {
+			return nil, &QueryError{t.pos, "取反符号后缺少条件"}
+		}
+		child, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{child}, nil
+	case tokLParen:
+		p.i++
+		inner, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if n := p.peek(); n == nil || n.kind != tokRParen {
+			return nil, &QueryError{t.pos, "括号没有闭合"}
+		}
+		p.i++
+		return inner, nil
+	case tokField:
+		p.i++
+		return parseFieldValue(t)
+	default: // tokWord
+		p.i++
+		return termNode(hanzi.Fold(t.value)), nil
+	}
+}
+
+// parseFieldValue 按字段类型解析值
+func parseFieldValue(t *queryToken) (queryNode, error) {
+	f := queryFields[t.name]
+	n := &fieldNode{field: f}
+	v := t.value
+	if f.kind == fieldText || f.kind == fieldKeyword || f.kind == fieldList {
+		if strings.HasPrefix(v, "=") {
+			n.op, v = "=", v[1:]
+		} else if strings.ContainsAny(v[:min(1, len(v))], "<>") {
+			return nil, &QueryError{t.pos, fmt.Sprintf("文本字段 %s 不支持大小比较", t.name)}
+		}
+		if f.kind == fieldKeyword {
+			n.op = "="
+		}
+		n.value = v
+		return n, nil
+	}
+
+	if lo, hi, ok := strings.Cut(v, ".."); ok {
+		if lo == "" && hi == "" {
+			return nil, &QueryError{t.pos, fmt.Sprintf("%s 的区间至少要给出一端，如 %s:2000..2010", t.name, t.name)}
+		}
+		for _, part := range []struct {
+			s   string
+			dst **float64
+		}{{lo, &n.lo}, {hi, &n.hi}} {
+			if part.s == "" {
+				continue
+			}
+			x, err := parseQueryNumber(f, part.s)
+			if err != nil {
+				return nil, &QueryError{t.pos, fmt.Sprintf("%s 的值 %q 无效: %v", t.name, part.s, err)}
+			}
+			*part.dst = &x
+		}
+		if n.lo != nil && n.hi != nil && *n.lo > *n.hi {
+			return nil, &QueryError{t.pos, fmt.Sprintf("%s 的区间下限大于上限", t.name)}
+		}
+		return n, nil
+	}
+	n.op = "="
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(v, op) {
+			n.op, v = op, v[len(op):]
+			break
+		}
+	}
+	x, err := parseQueryNumber(f, v)
+	if err != nil {
+		return nil, &QueryError{t.pos, fmt.Sprintf("%s 的值 %q 无效: %v", t.name, v, err)}
+	}
+	n.num = x
+	return n, nil
+}
+
+// parseQueryNumber 解析数值；时长字段另外接受 分:秒 或 时:分:秒
+func parseQueryNumber(f queryField, s string) (float64, error) {
+	if f.kind == fieldDuration && strings.Contains(s, ":") {
+		total := 0
+		for _, part := range strings.Split(s, ":") {
+			x, err := strconv.Atoi(part)
+			if err != nil || x < 0 {
+				return 0, fmt.Errorf("时长应为秒数或 分:秒")
+			}
+			total = total*60 + x
+		}
+		return float64(total), nil
+	}
+	x, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("需要数字")
+	}
+	return x, nil
+}
+
+// ---- 生成 SQL ----
+
+type queryCompiler struct {
+	fts  bool
+	args []interface{}
+}
+
+func (c *queryCompiler) arg(v ...interface{}) { c.args = append(c.args, v...) }
+
+func (n andNode) sql(c *queryCompiler) string {
+	parts := make([]string, len(n))
+	for i, child := range n {
+		parts[i] = child.sql(c)
+	}
+	return "(" + strings.Join(parts, " AND ") + ")"
+}
+
+func (n orNode) sql(c *queryCompiler) string {
+	parts := make([]string, len(n))
+	for i, child := range n {
+		parts[i] = child.sql(c)
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
+func (n notNode) sql(c *queryCompiler) string {
+	return "NOT " + n.child.sql(c)
+}
+
+// 裸词：有全文索引且词长足够时走索引，否则在四个文本列上 LIKE
+func (n termNode) sql(c *queryCompiler) string {
+	t := string(n)
+	if c.fts && utf8.RuneCountInString(t) >= minIndexedTermLen {
+		c.arg(`"` + strings.ReplaceAll(t, `"`, `""`) + `"`)
+		return "songs.id IN (SELECT rowid FROM songs_fts WHERE songs_fts MATCH ?)"
+	}
+	p := likePattern(t)
+	c.arg(p, p, p, p)
+	return `(COALESCE(songs.title, '') LIKE ? ESCAPE '\' OR COALESCE(songs.artist, '') LIKE ? ESCAPE '\' OR ` +
+		`COALESCE(songs.album, '') LIKE ? ESCAPE '\' OR COALESCE(songs.search_keys, '') LIKE ? ESCAPE '\')`
+}
+
+func (n *fieldNode) sql(c *queryCompiler) string {
+	col := "songs." + n.field.column
+	switch n.field.kind {
+	case fieldList:
+		each := "SELECT 1 FROM json_each(" + col + ")"
+		switch {
+		case n.value == "":
+			return "NOT EXISTS (" + each + ")"
+		case n.op == "=":
+			c.arg(n.value)
+			return "EXISTS (" + each + " WHERE LOWER(value) = LOWER(?))"
+		default:
+			c.arg(likePattern(n.value))
+			return "EXISTS (" + each + ` WHERE value LIKE ? ESCAPE '\')`
+		}
+	case fieldText, fieldKeyword:
+		expr := "COALESCE(" + col + ", '')"
+		switch {
+		case n.value == "":
+			return expr + " = ''"
+		case n.op == "=":
+			c.arg(n.value)
+			return "LOWER(" + expr + ") = LOWER(?)"
+		default:
+			c.arg(likePattern(n.value))
+			return expr + ` LIKE ? ESCAPE '\'`
+		}
+	default:
+		expr := "COALESCE(" + col + ", 0)"
+		if n.lo != nil || n.hi != nil {
+			var parts []string
+			if n.lo != nil {
+				c.arg(*n.lo)
+				parts = append(parts, expr+" >= ?")
+			}
+			if n.hi != nil {
+				c.arg(*n.hi)
+				parts = append(parts, expr+" <= ?")
+			}
+			return "(" + strings.Join(parts, " AND ") + ")"
+		}
+		c.arg(n.num)
+		return expr + " " + n.op + " ?"
+	}
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestParseQueryErrors 语法错误指出出错位置（从 1 开始的字符序号，中文按一个字符计）
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{`artist:"陈奕迅`, 8, "引号没有闭合"},
+		{`陈奕迅 "十年`, 5, "引号没有闭合"},
+		{`foo:bar`, 1, "未知字段"},
+		{`陈奕迅 year:`, 5, "缺少值"},
+		{`(十年 陈奕迅`, 1, "括号没有闭合"},
+		{`十年 陈奕迅)`, 7, "多余的右括号"},
+		{`十年 ()`, 5, "缺少条件"},
+		{`十年 OR`, 4, "OR 后缺少条件"},
+		{`OR 十年`, 1, "OR 前缺少条件"},
+		{`十年 | | 浮夸`, 4, "OR 后缺少条件"},
+		{`十年 AND`, 4, "AND 后缺少条件"},
+		{`AND 十年`, 1, "AND 前缺少条件"},
+		{`十年 NOT`, 4, "取反符号后缺少条件"},
+		{`-(十年)`, 0, ""},
+		{`year:2010..2000`, 1, "下限大于上限"},
+		{`浮夸 year:..`, 4, "至少要给出一端"},
+		{`year:abc`, 1, "无效"},
+		{`year:2000..x`, 1, "无效"},
+		{`duration:4:xx`, 1, "分:秒"},
+		{`title:>3`, 1, "不支持大小比较"},
+		{strings.Repeat("(", 40) + "a" + strings.Repeat(")", 40), 34, "嵌套层数过多"},
+		{strings.Repeat("-", 40) + "a", 34, "嵌套层数过多"},
+	}
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		if tt.msg == "" {
+			if err != nil {
+				t.Errorf("%q: 意外的错误 %v", tt.query, err)
+			}
+			continue
+		}
+		var qe *QueryError
+		if !errors.As(err, &qe) {
+			t.Errorf("%q: 错误 = %v，期望 *QueryError", tt.query, err)
+			continue
+		}
+		if qe.Pos != tt.pos || !strings.Contains(qe.Msg, tt.msg) {
+			t.Errorf("%q: 第 %d 个字符 %q，期望第 %d 个字符 %q", tt.query, qe.Pos, qe.Msg, tt.pos, tt.msg)
+		}
+	}
+}
+
+// TestQueryMatches 解析后的查询在曲库上的匹配结果：区间、比较、取反、OR 与 AND 的优先级、括号
+func TestQueryMatches(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "gmusic.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []Song{
+		{Title: "十年", Artist: "陈奕迅", Album: "黑白灰", Year: 2003, Format: "flac", Duration: 205, Genres: []string{"Pop"}},
+		{Title: "浮夸", Artist: "陈奕迅", Album: "U87", Year: 2005, Format: "mp3", Duration: 283, Genres: []string{"Pop"}},
+		{Title: "Hoppípolla", Artist: "Sigur Rós", Album: "Takk...", Year: 2005, Format: "flac", Duration: 268, Genres: []string{"Post-rock"}},
+		{Title: "晴天 Live", Artist: "周杰伦", Album: "2004 无与伦比演唱会", Year: 2004, Format: "mp3", Duration: 330, Genres: []string{"Pop", "Live"}},
+	} {
+		s.FilePath = "/music/" + s.Title
+		if err := AddSong(db, &s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{``, []string{"Hoppípolla", "十年", "晴天 Live", "浮夸"}},
+		{`artist:陈奕迅`, []string{"十年", "浮夸"}},
+		{`artist:=sigur`, nil},
+		{`artist:="sigur rós"`, []string{"Hoppípolla"}},
+		{`year:2003..2004`, []string{"十年", "晴天 Live"}},
+		{`year:..2003`, []string{"十年"}},
+		{`year:2005..`, []string{"Hoppípolla", "浮夸"}},
+		{`year:>=2004 year:<2005`, []string{"晴天 Live"}},
+		{`duration:>4:30`, []string{"晴天 Live", "浮夸"}},
+		{`duration:200..4:30`, []string{"Hoppípolla", "十年"}},
+		{`-live`, []string{"Hoppípolla", "十年", "浮夸"}},
+		{`NOT format:flac`, []string{"晴天 Live", "浮夸"}},
+		{`- 陈奕迅`, nil}, // 后面是空白的 - 不是取反，而是普通的词
+		{`artist:陈奕迅 OR format:flac`, []string{"Hoppípolla", "十年", "浮夸"}},
+		{`artist:周杰伦 | year:2003`, []string{"十年", "晴天 Live"}},
+		{`format:flac year:2005 OR artist:周杰伦`, []string{"Hoppípolla", "晴天 Live"}},
+		{`format:flac AND (year:2005 OR artist:陈奕迅)`, []string{"Hoppípolla", "十年"}},
+		{`-(artist:陈奕迅 | genre:post-rock)`, []string{"晴天 Live"}},
+		{`genre:=pop -genre:live`, []string{"十年", "浮夸"}},
+		{`title:"" OR genre:""`, nil},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		var got []string
+		if err := q.Apply(db.Model(&Song{})).Order("title").Pluck("title", &got).Error; err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: 匹配 %q，期望 %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package storage
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yudongyouqing/GMusic/internal/hanzi"
+	"gorm.io/gorm"
+)
+
+// 全文搜索：songs_fts 是 songs 的 FTS5 外部内容索引（只存倒排表，不重复存储文本），
+// 由触发器与 songs 保持同步。索引属于可重建的派生数据，不走版本化迁移：
+// mattn/go-sqlite3 只有在 -tags sqlite_fts5 编译时才包含 FTS5，未启用时搜索退回 LIKE 扫描。
+//
+// 分词使用 trigram：中文没有空格分词，按三字组索引即可做任意子串匹配，
+// 同时覆盖“边输入边搜索”的前缀匹配。不足三个字符的词无法走索引，改用 LIKE 过滤。
+//
+// 除标题、艺术家、专辑外还索引 search_keys（简体形式、全拼、首字母，见 BuildSearchKeys），
+// 查询词先转为简体小写，因此简繁体互通，"zjl"、"zhoujielun" 可以找到 周杰伦。
+
+// searchIndexDDL 索引及其触发器的定义；任一定义与库中不一致（或缺失）时整体重建
+var searchIndexDDL = []struct{ name, sql string }{
+	{"songs_fts", "CREATE VIRTUAL TABLE songs_fts USING fts5(title, artist, album, search_keys, content='songs', content_rowid='id', tokenize='trigram')"},
+	{"songs_fts_ai", "CREATE TRIGGER songs_fts_ai AFTER INSERT ON songs BEGIN " +
+		"INSERT INTO songs_fts(rowid, title, artist, album, search_keys) VALUES (new.id, new.title, new.artist, new.album, new.search_keys); END"},
+	{"songs_fts_ad", "CREATE TRIGGER songs_fts_ad AFTER DELETE ON songs BEGIN " +
+		"INSERT INTO songs_fts(songs_fts, rowid, title, artist, album, search_keys) VALUES ('delete', old.id, old.title, old.artist, old.album, old.search_keys); END"},
+	{"songs_fts_au", "CREATE TRIGGER songs_fts_au AFTER UPDATE OF title, artist, album, search_keys ON songs BEGIN " +
+		"INSERT INTO songs_fts(songs_fts, rowid, title, artist, album, search_keys) VALUES ('delete', old.id, old.title, old.artist, old.album, old.search_keys); " +
+		"INSERT INTO songs_fts(rowid, title, artist, album, search_keys) VALUES (new.id, new.title, new.artist, new.album, new.search_keys); END"},
+}
+
+// searchWeights bm25 中各列的权重，顺序与索引列一致：标题命中比专辑命中更相关
+const searchWeights = "10.0, 5.0, 2.0, 1.0"
+
+// minIndexedTermLen trigram 索引可匹配的最短词长（字符数）
+const minIndexedTermLen = 3
+
+// FTSAvailable 判断当前 SQLite 是否编译了 FTS5
+func FTSAvailable(db *gorm.DB) bool {
+	var used int
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used).Error; err != nil {
+		return false
+	}
+	return used == 1
+}
+
+// EnsureSearchIndex 创建或修复全文索引：定义有变化或缺失时删除重建并从 songs 全量回填。
+// 未编译 FTS5 时删除遗留的触发器（否则写入 songs 会因缺少 fts5 模块而失败），返回 false。
+func EnsureSearchIndex(db *gorm.DB) (bool, error) {
+	if !FTSAvailable(db) {
+		for _, obj := range searchIndexDDL[1:] {
+			if err := db.Exec("DROP TRIGGER IF EXISTS " + obj.name).Error; err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+
+	upToDate := true
+	for _, obj := range searchIndexDDL {
+		var existing string
+		db.Raw("SELECT sql FROM sqlite_master WHERE name = ?", obj.name).Scan(&existing)
+		if existing != obj.sql {
+			upToDate = false
+			break
+		}
+	}
+	if upToDate {
+		return true, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, obj := range searchIndexDDL[1:] {
+			if err := tx.Exec("DROP TRIGGER IF EXISTS " + obj.name).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DROP TABLE IF EXISTS songs_fts").Error; err != nil {
+			return err
+		}
+		for _, obj := range searchIndexDDL {
+			if err := tx.Exec(obj.sql).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec("INSERT INTO songs_fts(songs_fts) VALUES ('rebuild')").Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("重建全文索引失败: %w", err)
+	}
+	return true, nil
+}
+
+// SearchQuery 搜索条件
+type SearchQuery struct {
+	Keyword string // 查询字符串，语法见 query.go；最简单的形式是以空白分隔、须全部命中的关键词
+	Limit   int    // <= 0 表示不限制
+	Offset  int
+}
+
+// SearchHit 一条搜索结果：歌曲字段平铺在顶层，与 Song 的 JSON 保持兼容
+type SearchHit struct {
+	Song
+	Score     float64           `json:"score"`               // 相关度，越大越相关（BM25 取负；LIKE 退化模式下为 0）
+	Highlight map[string]string `json:"highlight,omitempty"` // 字段名 → 命中部分以 <mark> 包裹的 HTML 片段（已转义）
+}
+
+// SearchResult 搜索结果
+type SearchResult struct {
+	Engine string      `json:"engine"` // fts5、like，或含字段条件时的 query
+	Total  int64       `json:"total"`  // 命中总数（不受 Limit/Offset 影响）
+	Hits   []SearchHit `json:"hits"`
+}
+
+// Search 搜索歌曲。Keyword 按结构化查询语法解析（见 query.go），语法错误返回 *QueryError。
+// 只含关键词时走全文索引按 BM25 排序（无索引时退回 LIKE 并把前缀命中排在前面）；
+// 含字段条件、取反或 OR 时编译为 SQL 条件，结果按艺术家、专辑、曲序排列（有关键词时前缀命中在前）。
+func Search(db *gorm.DB, q SearchQuery) (*SearchResult, error) {
+	query, err := ParseQuery(q.Keyword)
+	if err != nil {
+		return nil, err
+	}
+	return searchQuery(db, query, q)
+}
+
+// searchQuery 按已解析的查询搜索，q.Keyword 不再使用
+func searchQuery(db *gorm.DB, query *Query, q SearchQuery) (*SearchResult, error) {
+	var err error
+	if query.Empty() {
+		return &SearchResult{Engine: "none", Hits: []SearchHit{}}, nil
+	}
+	var res *SearchResult
+	switch {
+	case !query.plain:
+		res, err = searchStructured(db, query, q)
+	case db.Migrator().HasTable("songs_fts") && FTSAvailable(db):
+		res, err = searchFTS(db, query.words, q)
+	default:
+		res, err = searchLike(db, query.words, q)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range res.Hits {
+		res.Hits[i].Highlight = highlightSong(&res.Hits[i].Song, query.words)
+	}
+	return res, nil
+}
+
+// likeEscaper 转义 LIKE 通配符，配合 ESCAPE '\' 使用
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePattern 子串匹配模式 %term%
+func likePattern(term string) string {
+	return "%" + likeEscaper.Replace(term) + "%"
+}
+
+// prefixRank 前缀命中优先的排序表达式（标题前缀 → 艺术家前缀 → 其他）
+func prefixRank(table string, term string) (string, []interface{}) {
+	p := likeEscaper.Replace(term) + "%"
+	return fmt.Sprintf("CASE WHEN %[1]s.title LIKE ? ESCAPE '\\' THEN 0 WHEN %[1]s.artist LIKE ? ESCAPE '\\' THEN 1 ELSE 2 END", table),
+		[]interface{}{p, p}
+}
+
+func searchFTS(db *gorm.DB, terms []string, q SearchQuery) (*SearchResult, error) {
+	var phrases []string
+	where := []string{}
+	args := []interface{}{}
+	for _, t := range terms {
+		if utf8.RuneCountInString(t) >= minIndexedTermLen {
+			phrases = append(phrases, `"`+strings.ReplaceAll(t, `"`, `""`)+`"`)
+			continue
+		}
+		p := likePattern(t)
+		where = append(where, `(songs_fts.title LIKE ? ESCAPE '\' OR songs_fts.artist LIKE ? ESCAPE '\' OR songs_fts.album LIKE ? ESCAPE '\' OR songs_fts.search_keys LIKE ? ESCAPE '\')`)
+		args = append(args, p, p, p, p)
+	}
+	score := "0.0"
+	if len(phrases) > 0 {
+		where = append([]string{"songs_fts MATCH ?"}, where...)
+		args = append([]interface{}{strings.Join(phrases, " AND ")}, args...)
+		score = "-bm25(songs_fts, " + searchWeights + ")"
+	}
+	cond := strings.Join(where, " AND ")
+
+	res := &SearchResult{Engine: "fts5"}
+	if err := db.Raw("SELECT COUNT(*) FROM songs_fts WHERE "+cond, args...).Scan(&res.Total).Error; err != nil {
+		return nil, err
+	}
+
+	rank, rankArgs := prefixRank("songs", terms[0])
+	query := "SELECT songs.*, " + score + " AS score FROM songs_fts JOIN songs ON songs.id = songs_fts.rowid WHERE " + cond +
+		" ORDER BY score DESC, " + rank + ", songs.title"
+	all := append(append([]interface{}{}, args...), rankArgs...)
+	query, all = withWindow(query, all, q)
+
+	var rows []struct {
+		Song
+		Score float64
+	}
+	if err := db.Raw(query, all...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	res.Hits = make([]SearchHit, len(rows))
+	for i, r := range rows {
+		res.Hits[i] = SearchHit{Song: r.Song, Score: r.Score}
+	}
+	return res, nil
+}
+
+func searchLike(db *gorm.DB, terms []string, q SearchQuery) (*SearchResult, error) {
+	tx := db.Model(&Song{})
+	for _, t := range terms {
+		p := likePattern(t)
+		tx = tx.Where(`title LIKE ? ESCAPE '\' OR artist LIKE ? ESCAPE '\' OR album LIKE ? ESCAPE '\' OR search_keys LIKE ? ESCAPE '\'`, p, p, p, p)
+	}
+	res := &SearchResult{Engine: "like"}
+	if err := tx.Count(&res.Total).Error; err != nil {
+		return nil, err
+	}
+	rank, rankArgs := prefixRank("songs", terms[0])
+	tx = tx.Order(gorm.Expr(rank, rankArgs...)).Order("title")
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit).Offset(q.Offset)
+	}
+	var songs []Song
+	if err := tx.Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	res.Hits = make([]SearchHit, len(songs))
+	for i, s := range songs {
+		res.Hits[i] = SearchHit{Song: s}
+	}
+	return res, nil
+}
+
+func searchStructured(db *gorm.DB, query *Query, q SearchQuery) (*SearchResult, error) {
+	tx := query.Apply(db.Model(&Song{}))
+	res := &SearchResult{Engine: "query"}
+	if err := tx.Count(&res.Total).Error; err != nil {
+		return nil, err
+	}
+	if len(query.words) > 0 {
+		rank, rankArgs := prefixRank("songs", query.words[0])
+		tx = tx.Order(gorm.Expr(rank, rankArgs...)).Order("title")
+	} else {
+		tx = tx.Order(defaultSongOrder)
+	}
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit).Offset(q.Offset)
+	}
+	var songs []Song
+	if err := tx.Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	res.Hits = make([]SearchHit, len(songs))
+	for i, s := range songs {
+		res.Hits[i] = SearchHit{Song: s}
+	}
+	return res, nil
+}
+
+// withWindow 为原生查询追加 LIMIT/OFFSET
+func withWindow(query string, args []interface{}, q SearchQuery) (string, []interface{}) {
+	if q.Limit <= 0 {
+		return query, args
+	}
+	return query + " LIMIT ? OFFSET ?", append(args, q.Limit, q.Offset)
+}
+
+// highlightSong 在标题、艺术家、专辑中标记命中的词，只返回有命中的字段
+func highlightSong(s *Song, terms []string) map[string]string {
+	out := map[string]string{}
+	for field, text := range map[string]string{"title": s.Title, "artist": s.Artist, "album": s.Album} {
+		if h, ok := highlight(text, terms); ok {
+			out[field] = h
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// highlight 标记各词在 text 中的命中位置，合并重叠区间后以 <mark> 包裹，其余部分做 HTML 转义。
+// 比较时忽略大小写与简繁差异；字面上找不到的纯字母词再尝试按拼音匹配汉字（全拼或首字母）。
+// 在 Go 中统一标记而不用 FTS5 的 highlight()：短词经 LIKE 匹配、拼音命中在 search_keys 列，highlight() 都无法覆盖。
+func highlight(text string, terms []string) (string, bool) {
+	runes := []rune(text)
+	folded := []rune(hanzi.Fold(text))
+	if len(folded) != len(runes) {
+		folded = runes
+	}
+	type span struct{ start, end int }
+	var spans []span
+	for _, t := range terms {
+		term := []rune(t)
+		found := false
+		for i := 0; i+len(term) <= len(folded); i++ {
+			if string(folded[i:i+len(term)]) == t {
+				spans = append(spans, span{i, i + len(term)})
+				found = true
+				i += len(term) - 1
+			}
+		}
+		if found || !isLetters(t) {
+			continue
+		}
+		for i := 0; i < len(runes); i++ {
+			if hanzi.Syllable(runes[i]) == "" {
+				continue
+			}
+			if n, ok := hanzi.MatchRomanized(runes, i, t); ok {
+				spans = append(spans, span{i, i + n})
+				i += n - 1
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return "", false
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var b strings.Builder
+	pos := 0
+	for i := 0; i < len(spans); {
+		start, end := spans[i].start, spans[i].end
+		for i++; i < len(spans) && spans[i].start <= end; i++ {
+			end = max(end, spans[i].end)
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:start])))
+		b.WriteString("<mark>" + html.EscapeString(string(runes[start:end])) + "</mark>")
+		pos = end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:])))
+	return b.String(), true
+}
+
+// isLetters 是否为纯 ASCII 字母（可能是拼音输入）
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// BuildSearchKeys 生成 songs.search_keys：每个字段依次给出简体形式（仅在与原文不同时）、全拼、首字母，
+// 字段之间以 " | " 分隔，避免子串跨字段命中
+func BuildSearchKeys(fields ...string) string {
+	var parts []string
+	for _, f := range fields {
+		var keys []string
+		if simp := hanzi.Simplify(f); simp != f {
+			keys = append(keys, simp)
+		}
+		if full, initials := hanzi.Romanize(f); full != "" {
+			keys = append(keys, full, initials)
+		}
+		parts = append(parts, strings.Join(keys, " "))
+	}
+	if strings.TrimSpace(strings.Join(parts, "")) == "" {
+		return ""
+	}
+	return strings.Join(parts, " | ")
+}
+
+// BackfillSearchKeys 为 search_keys 为 NULL 的歌曲（迁移新增该列前导入、或生成规则变化后被置空）批量生成搜索辅助文本
+func BackfillSearchKeys(db *gorm.DB) error {
+	const batch = 500
+	for {
+		var rows []struct {
+			ID                   uint
+			Title, Artist, Album string
+		}
+		if err := db.Model(&Song{}).Select("id, title, artist, album").
+			Where("search_keys IS NULL").Limit(batch).Scan(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, r := range rows {
+				if err := tx.Exec("UPDATE songs SET search_keys = ? WHERE id = ?",
+					BuildSearchKeys(r.Title, r.Artist, r.Album), r.ID).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("生成搜索辅助文本失败: %w", err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// LibrarySetting 曲库级别的设置项，保存在 library_settings 表
+type LibrarySetting struct {
+	Key   string `gorm:"primaryKey"`
+	Value string
+}
+
+// tagPatternsKey 按文件名推断标签的模式（JSON 数组）
+const tagPatternsKey = "tag_patterns"
+
+// GetLibrarySetting 读取设置项，不存在时返回空串
+func GetLibrarySetting(db *gorm.DB, key string) (string, error) {
+	var value string
+	err := db.Raw("SELECT value FROM library_settings WHERE key = ?", key).Scan(&value).Error
+	return value, err
+}
+
+// SetLibrarySetting 保存设置项
+func SetLibrarySetting(db *gorm.DB, key, value string) error {
+	return db.Save(&LibrarySetting{Key: key, Value: value}).Error
+}
+
+// GetTagPatterns 读取保存的文件名推断模式；从未保存过时返回 nil（由调用方使用默认模式）
+func GetTagPatterns(db *gorm.DB) ([]string, error) {
+	value, err := GetLibrarySetting(db, tagPatternsKey)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var patterns []string
+	err = json.Unmarshal([]byte(value), &patterns)
+	return patterns, err
+}
+
+// SetTagPatterns 保存文件名推断模式；patterns 为 nil 时删除，恢复默认模式
+func SetTagPatterns(db *gorm.DB, patterns []string) error {
+	if patterns == nil {
+		return db.Where("key = ?", tagPatternsKey).Delete(&LibrarySetting{}).Error
+	}
+	data, err := json.Marshal(patterns)
+	if err != nil {
+		return err
+	}
+	return SetLibrarySetting(db, tagPatternsKey, string(data))
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SmartPlaylist 智能播放列表：保存一条结构化查询（语法见 query.go），歌曲在每次读取时按查询实时计算，
+// 新导入的歌曲满足条件即自动出现在列表中。
+type SmartPlaylist struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`          // 主键 ID
+	Name  string `json:"name"`                          // 列表名称
+	Query string `json:"query"`                         // 结构化查询，如 artist:"陈奕迅" year:2000..2010
+	Sort  string `json:"sort"`                          // 排序方式，见 SmartPlaylistSorts；空为按艺术家、专辑、曲序
+	Limit int    `gorm:"column:max_songs" json:"limit"` // 最多包含的歌曲数，0 表示不限
+}
+
+// defaultSongOrder 默认排序：按艺术家、专辑、碟号、曲序
+const defaultSongOrder = "artist, album, disc_num, track_num, title"
+
+// smartPlaylistOrders 排序方式 → ORDER BY 子句
+var smartPlaylistOrders = map[string]string{
+	"":          defaultSongOrder,
+	"title":     "title, artist",
+	"year":      "year, " + defaultSongOrder,
+	"-year":     "year DESC, " + defaultSongOrder,
+	"duration":  "duration, title",
+	"-duration": "duration DESC, title",
+	"newest":    "id DESC",
+	"random":    "RANDOM()",
+}
+
+// SmartPlaylistSorts 支持的排序方式
+func SmartPlaylistSorts() []string {
+	sorts := make([]string, 0, len(smartPlaylistOrders))
+	for s := range smartPlaylistOrders {
+		if s != "" {
+			sorts = append(sorts, s)
+		}
+	}
+	sort.Strings(sorts)
+	return sorts
+}
+
+// Validate 检查名称、查询语法、排序方式与数量上限；查询语法错误返回 *QueryError
+func (p *SmartPlaylist) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("名称不能为空")
+	}
+	if _, ok := smartPlaylistOrders[p.Sort]; !ok {
+		return fmt.Errorf("未知的排序方式 %q（可选：%s）", p.Sort, strings.Join(SmartPlaylistSorts(), ", "))
+	}
+	if p.Limit < 0 {
+		return errors.New("limit 不能为负数")
+	}
+	_, err := ParseQuery(p.Query)
+	return err
+}
+
+// ListSmartPlaylists 返回全部智能播放列表
+func ListSmartPlaylists(db *gorm.DB) ([]SmartPlaylist, error) {
+	var lists []SmartPlaylist
+	err := db.Order("id").Find(&lists).Error
+	return lists, err
+}
+
+// GetSmartPlaylist 根据 ID 查询智能播放列表，不存在时返回 gorm.ErrRecordNotFound
+func GetSmartPlaylist(db *gorm.DB, id uint) (*SmartPlaylist, error) {
+	var p SmartPlaylist
+	err := db.First(&p, id).Error
+	return &p, err
+}
+
+// SaveSmartPlaylist 校验后新增（ID 为 0）或更新智能播放列表
+func SaveSmartPlaylist(db *gorm.DB, p *SmartPlaylist) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	return db.Save(p).Error
+}
+
+// DeleteSmartPlaylist 删除智能播放列表，不存在时返回 gorm.ErrRecordNotFound
+func DeleteSmartPlaylist(db *gorm.DB, id uint) error {
+	res := db.Delete(&SmartPlaylist{}, id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return res.Error
+}
+
+// SmartPlaylistSongs 按查询计算智能播放列表当前包含的歌曲
+func SmartPlaylistSongs(db *gorm.DB, p *SmartPlaylist) ([]Song, error) {
+	query, err := ParseQuery(p.Query)
+	if err != nil {
+		return nil, err
+	}
+	order, ok := smartPlaylistOrders[p.Sort]
+	if !ok {
+		order = defaultSongOrder
+	}
+	tx := query.Apply(db.Model(&Song{})).Order(order)
+	if p.Limit > 0 {
+		tx = tx.Limit(p.Limit)
+	}
+	var songs []Song
+	err = tx.Find(&songs).Error
+	return songs, err
+}
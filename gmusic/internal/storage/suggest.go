@@ -0,0 +1,365 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yudongyouqing/GMusic/internal/hanzi"
+	"gorm.io/gorm"
+)
+
+// 搜索框自动补全：每次按键都会请求，因此
+//   - 输入不按查询语法解析（见 KeywordQuery），半截的 artist:"陈 也能给出提示
+//   - 艺术家、专辑、播放列表、歌名的去重名单（词表）整体缓存在内存中，匹配与纠错都在 Go 中完成
+//   - 相同输入的结果在 ttl 内直接复用
+//
+// 没有任何命中时按编辑距离在词表中找最接近的名称，作为“你是不是要找”。
+
+// maxSuggestCache 结果缓存的最大条目数
+const maxSuggestCache = 1024
+
+// maxDidYouMean 纠错建议最多返回的条数
+const maxDidYouMean = 5
+
+// SuggestGroup 一组提示：Total 为该组命中总数，Items 最多 limit 条
+type SuggestGroup[T any] struct {
+	Total int64 `json:"total"`
+	Items []T   `json:"items"`
+}
+
+// SongSuggestion 歌曲提示
+type SongSuggestion struct {
+	ID        uint              `json:"id"`
+	Title     string            `json:"title"`
+	Artist    string            `json:"artist"`
+	Album     string            `json:"album"`
+	Highlight map[string]string `json:"highlight,omitempty"` // 同 SearchHit.Highlight
+}
+
+// NameSuggestion 艺术家、专辑或播放列表提示
+type NameSuggestion struct {
+	ID        uint   `json:"id,omitempty"`     // 艺术家或播放列表 ID
+	Name      string `json:"name"`             // 名称
+	Artist    string `json:"artist,omitempty"` // 专辑所属艺术家
+	Songs     int64  `json:"songs"`            // 包含的歌曲数
+	Highlight string `json:"highlight"`        // 命中部分以 <mark> 包裹的 HTML 片段（已转义）
+}
+
+// Correction 纠错建议
+type Correction struct {
+	Text     string `json:"text"`     // 建议的搜索词（库中实际存在的名称）
+	Kind     string `json:"kind"`     // artist、album 或 song
+	Distance int    `json:"distance"` // 与输入的编辑距离
+}
+
+// Suggestions 自动补全结果
+type Suggestions struct {
+	Query      string                       `json:"query"`
+	Songs      SuggestGroup[SongSuggestion] `json:"songs"`
+	Artists    SuggestGroup[NameSuggestion] `json:"artists"`
+	Albums     SuggestGroup[NameSuggestion] `json:"albums"`
+	Playlists  SuggestGroup[NameSuggestion] `json:"playlists"`
+	DidYouMean []Correction                 `json:"did_you_mean,omitempty"` // 所有分组都没有命中时给出
+}
+
+// vocabEntry 词表中的一个名称，预先算好比较用的形式
+type vocabEntry struct {
+	NameSuggestion
+	folded   string // 简体小写
+	full     string // 全拼（不含汉字时为空）
+	initials string // 拼音首字母
+}
+
+func newVocabEntry(n NameSuggestion) vocabEntry {
+	full, initials := hanzi.Romanize(n.Name)
+	return vocabEntry{NameSuggestion: n, folded: hanzi.Fold(n.Name), full: full, initials: initials}
+}
+
+// match 判断每个词是否都出现在名称中（原文或拼音），prefix 表示第一个词是名称的前缀
+func (e *vocabEntry) match(words []string) (prefix, ok bool) {
+	for i, w := range words {
+		var at int
+		if at = strings.Index(e.folded, w); at < 0 && isLetters(w) {
+			if at = strings.Index(e.full, w); at < 0 {
+				at = strings.Index(e.initials, w)
+			}
+		}
+		if at < 0 {
+			return false, false
+		}
+		if i == 0 {
+			prefix = at == 0
+		}
+	}
+	return prefix, true
+}
+
+// suggestVocab 词表
+type suggestVocab struct {
+	artists, albums, playlists, titles []vocabEntry
+}
+
+type cachedSuggestions struct {
+	res     *Suggestions
+	expires time.Time
+}
+
+// Suggester 自动补全服务，并发安全
+type Suggester struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	vocabMu sync.Mutex
+	vocab   *suggestVocab
+	vocabAt time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedSuggestions
+}
+
+// NewSuggester 创建自动补全服务；ttl 同时是结果缓存与词表的有效期，新导入的歌曲最多延迟 ttl 出现在艺术家、专辑提示中
+func NewSuggester(db *gorm.DB, ttl time.Duration) *Suggester {
+	return &Suggester{db: db, ttl: ttl, cache: make(map[string]cachedSuggestions)}
+}
+
+// Suggest 返回输入 q 的分组提示，每组最多 limit 条
+func (s *Suggester) Suggest(q string, limit int) (*Suggestions, error) {
+	query := KeywordQuery(q)
+	key := fmt.Sprintf("%d\x00%s", limit, strings.Join(query.words, " "))
+	now := time.Now()
+
+	s.mu.Lock()
+	if c, ok := s.cache[key]; ok && now.Before(c.expires) {
+		s.mu.Unlock()
+		res := *c.res // 大小写、繁简不同的输入共用缓存，Query 保持调用方的原文
+		res.Query = q
+		return &res, nil
+	}
+	s.mu.Unlock()
+
+	res, err := s.suggest(query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if len(s.cache) >= maxSuggestCache {
+		for k, c := range s.cache {
+			if !now.Before(c.expires) {
+				delete(s.cache, k)
+			}
+		}
+		if len(s.cache) >= maxSuggestCache {
+			s.cache = make(map[string]cachedSuggestions)
+		}
+	}
+	s.cache[key] = cachedSuggestions{res: res, expires: now.Add(s.ttl)}
+	s.mu.Unlock()
+	out := *res
+	out.Query = q
+	return &out, nil
+}
+
+func (s *Suggester) suggest(query *Query, limit int) (*Suggestions, error) {
+	res := &Suggestions{
+		Songs:     SuggestGroup[SongSuggestion]{Items: []SongSuggestion{}},
+		Artists:   SuggestGroup[NameSuggestion]{Items: []NameSuggestion{}},
+		Albums:    SuggestGroup[NameSuggestion]{Items: []NameSuggestion{}},
+		Playlists: SuggestGroup[NameSuggestion]{Items: []NameSuggestion{}},
+	}
+	if query.Empty() {
+		return res, nil
+	}
+
+	songs, err := searchQuery(s.db, query, SearchQuery{Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	res.Songs.Total = songs.Total
+	for _, h := range songs.Hits {
+		res.Songs.Items = append(res.Songs.Items, SongSuggestion{
+			ID: h.ID, Title: h.Title, Artist: h.Artist, Album: h.Album, Highlight: h.Highlight,
+		})
+	}
+
+	vocab, err := s.vocabulary()
+	if err != nil {
+		return nil, err
+	}
+	res.Artists = matchNames(vocab.artists, query.words, limit)
+	res.Albums = matchNames(vocab.albums, query.words, limit)
+	res.Playlists = matchNames(vocab.playlists, query.words, limit)
+
+	if res.Songs.Total+res.Artists.Total+res.Albums.Total+res.Playlists.Total == 0 {
+		res.DidYouMean = didYouMean(vocab, strings.Join(query.words, " "))
+	}
+	return res, nil
+}
+
+// matchNames 在词表中匹配：前缀命中在前，其次按歌曲数降序
+func matchNames(entries []vocabEntry, words []string, limit int) SuggestGroup[NameSuggestion] {
+	type hit struct {
+		e      *vocabEntry
+		prefix bool
+	}
+	var hits []hit
+	for i := range entries {
+		if prefix, ok := entries[i].match(words); ok {
+			hits = append(hits, hit{&entries[i], prefix})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].prefix != hits[j].prefix {
+			return hits[i].prefix
+		}
+		return hits[i].e.Songs > hits[j].e.Songs
+	})
+	g := SuggestGroup[NameSuggestion]{Total: int64(len(hits)), Items: []NameSuggestion{}}
+	for _, h := range hits[:min(limit, len(hits))] {
+		n := h.e.NameSuggestion
+		n.Highlight, _ = highlight(n.Name, words)
+		g.Items = append(g.Items, n)
+	}
+	return g
+}
+
+// vocabulary 返回词表，过期时从库中重新加载
+func (s *Suggester) vocabulary() (*suggestVocab, error) {
+	s.vocabMu.Lock()
+	defer s.vocabMu.Unlock()
+	if s.vocab != nil && time.Since(s.vocabAt) < s.ttl {
+		return s.vocab, nil
+	}
+
+	var v suggestVocab
+	load := func(dst *[]vocabEntry, query string) error {
+		var rows []NameSuggestion
+		if err := s.db.Raw(query).Scan(&rows).Error; err != nil {
+			return err
+		}
+		*dst = make([]vocabEntry, len(rows))
+		for i, r := range rows {
+			(*dst)[i] = newVocabEntry(r)
+		}
+		return nil
+	}
+	queries := []struct {
+		dst   *[]vocabEntry
+		query string
+	}{
+		// 艺术家取拆分后的实体，"A feat. B" 中的 B 也能被提示
+		{&v.artists, "SELECT artists.id, artists.name, COUNT(DISTINCT song_artists.song_id) AS songs FROM artists " +
+			"JOIN song_artists ON song_artists.artist_id = artists.id GROUP BY artists.id"},
+		{&v.albums, "SELECT album AS name, artist, COUNT(*) AS songs FROM songs WHERE COALESCE(album, '') <> '' GROUP BY album, artist"},
+		{&v.playlists, "SELECT playlists.id, playlists.name, COUNT(playlist_songs.song_id) AS songs FROM playlists " +
+			"LEFT JOIN playlist_songs ON playlist_songs.playlist_id = playlists.id GROUP BY playlists.id"},
+		{&v.titles, "SELECT title AS name, COUNT(*) AS songs FROM songs WHERE COALESCE(title, '') <> '' GROUP BY title"},
+	}
+	for _, q := range queries {
+		if err := load(q.dst, q.query); err != nil {
+			return nil, fmt.Errorf("加载自动补全词表失败: %w", err)
+		}
+	}
+	s.vocab, s.vocabAt = &v, time.Now()
+	return s.vocab, nil
+}
+
+// didYouMean 在艺术家、专辑、歌名中找与输入编辑距离最小的名称。
+// 既与整个名称比较，也与等长的名称前缀比较（输入尚未打完）；纯字母输入另与全拼比较（拼音打错）。
+// 允许的距离随输入长度增加：4 个字符以内 1，8 个以内 2，更长 3。
+func didYouMean(v *suggestVocab, input string) []Correction {
+	in := []rune(input)
+	maxDist := 1
+	if len(in) > 8 {
+		maxDist = 3
+	} else if len(in) > 4 {
+		maxDist = 2
+	}
+	letters := isLetters(strings.ReplaceAll(input, " ", ""))
+
+	type candidate struct {
+		Correction
+		songs int64
+	}
+	best := map[string]candidate{}
+	consider := func(entries []vocabEntry, kind string) {
+		for i := range entries {
+			e := &entries[i]
+			d := nameDistance(in, e.folded, maxDist)
+			if letters && e.full != "" {
+				d = min(d, nameDistance(in, e.full, maxDist))
+			}
+			if d > maxDist {
+				continue
+			}
+			c := candidate{Correction{Text: e.Name, Kind: kind, Distance: d}, e.Songs}
+			if old, ok := best[e.Name]; !ok || d < old.Distance || (d == old.Distance && c.songs > old.songs) {
+				best[e.Name] = c
+			}
+		}
+	}
+	consider(v.artists, "artist")
+	consider(v.albums, "album")
+	consider(v.titles, "song")
+
+	cands := make([]candidate, 0, len(best))
+	for _, c := range best {
+		cands = append(cands, c)
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		if cands[i].Distance != cands[j].Distance {
+			return cands[i].Distance < cands[j].Distance
+		}
+		if cands[i].songs != cands[j].songs {
+			return cands[i].songs > cands[j].songs
+		}
+		return cands[i].Text < cands[j].Text
+	})
+	out := make([]Correction, 0, maxDidYouMean)
+	for _, c := range cands[:min(maxDidYouMean, len(cands))] {
+		out = append(out, c.Correction)
+	}
+	return out
+}
+
+// nameDistance 输入与名称（或名称的等长前缀）之间较小的编辑距离；超过 maxDist 时返回 maxDist+1
+func nameDistance(in []rune, name string, maxDist int) int {
+	if utf8.RuneCountInString(name) > len(in) {
+		rs := []rune(name)
+		return min(levenshtein(in, rs, maxDist), levenshtein(in, rs[:len(in)], maxDist))
+	}
+	return levenshtein(in, []rune(name), maxDist)
+}
+
+// levenshtein 编辑距离（插入、删除、替换各计 1），超过 maxDist 时提前结束并返回 maxDist+1
+func levenshtein(a, b []rune, maxDist int) int {
+	if d := len(a) - len(b); d > maxDist || -d > maxDist {
+		return maxDist + 1
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > maxDist {
+			return maxDist + 1
+		}
+		prev, cur = cur, prev
+	}
+	return min(prev[len(b)], maxDist+1)
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagCharsetOverride 为文件手动指定的标签编码，自动检测出错时使用（检测与转换见 metadata 包）
+type TagCharsetOverride struct {
+	FilePath string `gorm:"primaryKey" json:"file_path"`
+	Charset  string `json:"charset"`
+}
+
+// GetTagCharsetOverride 返回文件手动指定的标签编码，未指定时返回空串
+func GetTagCharsetOverride(db *gorm.DB, filePath string) (string, error) {
+	var charset string
+	err := db.Raw("SELECT charset FROM tag_charset_overrides WHERE file_path = ?", filePath).Scan(&charset).Error
+	return charset, err
+}
+
+// SetTagCharsetOverride 为文件指定标签编码；charset 为空时删除指定，恢复自动检测
+func SetTagCharsetOverride(db *gorm.DB, filePath, charset string) error {
+	if charset == "" {
+		return db.Where("file_path = ?", filePath).Delete(&TagCharsetOverride{}).Error
+	}
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&TagCharsetOverride{FilePath: filePath, Charset: charset}).Error
+}
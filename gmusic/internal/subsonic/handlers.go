@@ -0,0 +1,672 @@
+package subsonic
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/lyrics"
+	"github.com/yudongyouqing/GMusic/internal/metadata"
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"github.com/yudongyouqing/GMusic/internal/transcode"
+	"gorm.io/gorm"
+)
+
+// musicFolderID 唯一的音乐文件夹（整个曲库）
+const musicFolderID = 1
+
+// ignoredArticles 建立索引时忽略的冠词
+const ignoredArticles = "The El La Los Las Le Les"
+
+// unknownArtist 艺术家为空时的显示名称
+const unknownArtist = "未知艺术家"
+
+// =========== ID 编码 ===========
+
+// artistID 由艺术家名称编码得到的 ID
+func artistID(name string) string {
+	return "ar-" + hex.EncodeToString([]byte(name))
+}
+
+// albumID 由（专辑，艺术家）编码得到的 ID
+func albumID(album, artist string) string {
+	return "al-" + hex.EncodeToString([]byte(album+"\x00"+artist))
+}
+
+// parseArtistID 解析 artistID 生成的 ID
+func parseArtistID(id string) (string, bool) {
+	raw, ok := strings.CutPrefix(id, "ar-")
+	if !ok {
+		return "", false
+	}
+	name, err := hex.DecodeString(raw)
+	return string(name), err == nil
+}
+
+// parseAlbumID 解析 albumID 生成的 ID
+func parseAlbumID(id string) (album, artist string, ok bool) {
+	raw, found := strings.CutPrefix(id, "al-")
+	if !found {
+		return "", "", false
+	}
+	decoded, err := hex.DecodeString(raw)
+	if err != nil {
+		return "", "", false
+	}
+	album, artist, ok = strings.Cut(string(decoded), "\x00")
+	return album, artist, ok
+}
+
+// coverArtID 封面 ID 直接使用带封面歌曲的 ID
+func coverArtID(songID uint) string {
+	if songID == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(songID), 10)
+}
+
+// =========== 模型转换 ===========
+
+func displayArtist(name string) string {
+	if name == "" {
+		return unknownArtist
+	}
+	return name
+}
+
+func toArtist(a storage.ArtistSummary) Artist {
+	return Artist{
+		ID:         artistID(a.Name),
+		Name:       displayArtist(a.Name),
+		CoverArt:   coverArtID(a.CoverSongID),
+		AlbumCount: a.AlbumCount,
+	}
+}
+
+func toAlbum(a storage.AlbumSummary) Album {
+	return Album{
+		ID:        albumID(a.Name, a.Artist),
+		Name:      a.Name,
+		Artist:    displayArtist(a.Artist),
+		ArtistID:  artistID(a.Artist),
+		CoverArt:  coverArtID(a.CoverSongID),
+		SongCount: a.SongCount,
+		Duration:  a.Duration,
+		Year:      a.Year,
+	}
+}
+
+// toChild 将歌曲转换为 Subsonic 目录项。CUE 分轨以 FLAC 转码输出，因此声明 transcoded 类型。
+func toChild(song *storage.Song) Child {
+	child := Child{
+		ID:       strconv.FormatUint(uint64(song.ID), 10),
+		Parent:   albumID(song.Album, song.Artist),
+		Title:    song.Title,
+		Album:    song.Album,
+		Artist:   displayArtist(song.Artist),
+		Track:    song.TrackNum,
+		Year:     song.Year,
+		Duration: song.Duration,
+		BitRate:  song.BitRate,
+		Path:     song.FilePath,
+		Type:     "music",
+		AlbumID:  albumID(song.Album, song.Artist),
+		ArtistID: artistID(song.Artist),
+
+		ContentType: metadata.AudioContentType(song.FilePath),
+		Suffix:      strings.TrimPrefix(strings.ToLower(filepath.Ext(song.FilePath)), "."),
+	}
+	if len(song.Genres) > 0 {
+		child.Genre = song.Genres[0] // 协议的 genre 只有一个值
+	}
+	child.DiscNumber = song.DiscNum
+	if song.CoverURL != "" {
+		child.CoverArt = child.ID
+	}
+	if song.IsCueTrack() {
+		child.TranscodedContentType = "audio/flac"
+		child.TranscodedSuffix = transcode.FormatFLAC
+	} else if info, err := os.Stat(song.FilePath); err == nil {
+		child.Size = info.Size()
+	}
+	return child
+}
+
+func toChildren(songs []storage.Song) []Child {
+	children := make([]Child, 0, len(songs))
+	for i := range songs {
+		children = append(children, toChild(&songs[i]))
+	}
+	return children
+}
+
+// indexName 艺术家的索引分组：忽略冠词后的首字母，非拉丁字母归入 #
+func indexName(name string) string {
+	for _, article := range strings.Fields(ignoredArticles) {
+		if rest, ok := strings.CutPrefix(name, article+" "); ok {
+			name = rest
+			break
+		}
+	}
+	for _, r := range name {
+		r = unicode.ToUpper(r)
+		if r >= 'A' && r <= 'Z' {
+			return string(r)
+		}
+		break
+	}
+	return "#"
+}
+
+// buildIndexes 将艺术家按首字母分组，# 组排在最后
+func buildIndexes(artists []storage.ArtistSummary) []Index {
+	groups := make(map[string][]Artist)
+	for _, a := range artists {
+		name := indexName(a.Name)
+		groups[name] = append(groups[name], toArtist(a))
+	}
+	indexes := make([]Index, 0, len(groups))
+	for name, list := range groups {
+		indexes = append(indexes, Index{Name: name, Artists: list})
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		if (indexes[i].Name == "#") != (indexes[j].Name == "#") {
+			return indexes[j].Name == "#"
+		}
+		return indexes[i].Name < indexes[j].Name
+	})
+	return indexes
+}
+
+// =========== 系统 ===========
+
+func (s *Server) ping(c *gin.Context) {
+	s.write(c, newResponse())
+}
+
+func (s *Server) getLicense(c *gin.Context) {
+	resp := newResponse()
+	resp.License = &License{Valid: true}
+	s.write(c, resp)
+}
+
+// =========== 浏览 ===========
+
+func (s *Server) getMusicFolders(c *gin.Context) {
+	resp := newResponse()
+	resp.MusicFolders = &MusicFolders{Folders: []MusicFolder{{ID: musicFolderID, Name: "音乐库"}}}
+	s.write(c, resp)
+}
+
+func (s *Server) getIndexes(c *gin.Context) {
+	artists, err := storage.ListArtists(s.db, "")
+	if err != nil {
+		s.fail(c, ErrGeneric, err.Error())
+		return
+	}
+	resp := newResponse()
+	resp.Indexes = &Indexes{
+		LastModified:    time.Now().UnixMilli(),
+		IgnoredArticles: ignoredArticles,
+		Index:           buildIndexes(artists),
+	}
+	s.write(c, resp)
+}
+
+// getMusicDirectory 按文件夹浏览：艺术家目录下是专辑目录，专辑目录下是歌曲
+func (s *Server) getMusicDirectory(c *gin.Context) {
+	id := param(c, "id")
+	if id == "" {
+		s.fail(c, ErrMissingParam, "缺少参数 id")
+		return
+	}
+	resp := newResponse()
+	if artist, ok := parseArtistID(id); ok {
+		albums, err := storage.ListAlbums(s.db, storage.AlbumQuery{Artist: artist})
+		if err != nil {
+			s.fail(c, ErrGeneric, err.Error())
+			return
+		}
+		dir := &Directory{ID: id, Name: displayArtist(artist), Children: make([]Child, 0, len(albums))}
+		for _, a := range albums {
+			dir.Children = append(dir.Children, Child{
+				ID:       albumID(a.Name, a.Artist),
+				Parent:   id,
+				IsDir:    true,
+				Title:    a.Name,
+				Album:    a.Name,
+				Artist:   displayArtist(a.Artist),
+				Year:     a.Year,
+				CoverArt: coverArtID(a.CoverSongID),
+			})
+		}
+		resp.Directory = dir
+		s.write(c, resp)
+		return
+	}
+	if album, artist, ok := parseAlbumID(id); ok {
+		songs, err := storage.GetSongsByAlbum(s.db, album, artist)
+		if err != nil {
+			s.fail(c, ErrGeneric, err.Error())
+			return
+		}
+		if len(songs) == 0 {
+			s.fail(c, ErrNotFound, "目录不存在")
+			return
+		}
+		resp.Directory = &Directory{ID: id, Parent: artistID(artist), Name: album, Children: toChildren(songs)}
+		s.write(c, resp)
+		return
+	}
+	s.fail(c, ErrNotFound, "目录不存在")
+}
+
+func (s *Server) getArtists(c *gin.Context) {
+	artists, err := storage.ListArtists(s.db, "")
+	if err != nil {
+		s.fail(c, ErrGeneric, err.Error())
+		return
+	}
+	resp := newResponse()
+	resp.Artists = &Artists{IgnoredArticles: ignoredArticles, Index: buildIndexes(artists)}
+	s.write(c, resp)
+}
+
+func (s *Server) getArtist(c *gin.Context) {
+	name, ok := parseArtistID(param(c, "id"))
+	if !ok {
+		s.fail(c, ErrNotFound, "艺术家不存在")
+		return
+	}
+	albums, err := storage.ListAlbums(s.db, storage.AlbumQuery{Artist: name})
+	if err != nil {
+		s.fail(c, ErrGeneric, err.Error())
+		return
+	}
+	if len(albums) == 0 {
+		s.fail(c, ErrNotFound, "艺术家不存在")
+		return
+	}
+	artist := &ArtistWithAlbums{
+		Artist: Artist{ID: artistID(name), Name: displayArtist(name), AlbumCount: len(albums)},
+		Albums: make([]Album, 0, len(albums)),
+	}
+	for _, a := range albums {
+		artist.Albums = append(artist.Albums, toAlbum(a))
+		if artist.CoverArt == "" {
+			artist.CoverArt = coverArtID(a.CoverSongID)
+		}
+	}
+	resp := newResponse()
+	resp.Artist = artist
+	s.write(c, resp)
+}
+
+func (s *Server) getAlbum(c *gin.Context) {
+	album, artist, ok := parseAlbumID(param(c, "id"))
+	if !ok {
+		s.fail(c, ErrNotFound, "专辑不存在")
+		return
+	}
+	songs, err := storage.GetSongsByAlbum(s.db, album, artist)
+	if err != nil {
+		s.fail(c, ErrGeneric, err.Error())
+		return
+	}
+	if len(songs) == 0 {
+		s.fail(c, ErrNotFound, "专辑不存在")
+		return
+	}
+	summary := storage.AlbumSummary{Name: album, Artist: artist, SongCount: len(songs)}
+	for _, song := range songs {
+		summary.Duration += song.Duration
+		summary.Year = max(summary.Year, song.Year)
+		if summary.CoverSongID == 0 && song.CoverURL != "" {
+			summary.CoverSongID = song.ID
+		}
+	}
+	resp := newResponse()
+	resp.Album = &AlbumWithSongs{Album: toAlbum(summary), Songs: toChildren(songs)}
+	s.write(c, resp)
+}
+
+func (s *Server) getSong(c *gin.Context) {
+	song, ok := s.lookupSong(c)
+	if !ok {
+		return
+	}
+	child := toChild(song)
+	resp := newResponse()
+	resp.Song = &child
+	s.write(c, resp)
+}
+
+// lookupSong 按 id 参数查询歌曲，失败时已输出错误响应
+func (s *Server) lookupSong(c *gin.Context) (*storage.Song, bool) {
+	raw := param(c, "id")
+	if raw == "" {
+		s.fail(c, ErrMissingParam, "缺少参数 id")
+		return nil, false
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		s.fail(c, ErrNotFound, "歌曲不存在")
+		return nil, false
+	}
+	song, err := storage.GetSongByID(s.db, uint(id))
+	if err != nil {
+		s.fail(c, ErrNotFound, "歌曲不存在")
+		return nil, false
+	}
+	return song, true
+}
+
+// =========== 搜索 ===========
+
+// search3 同时搜索艺术家、专辑与歌曲；query 为空（或 ""）时返回全部，供客户端同步整个曲库
+func (s *Server) search3(c *gin.Context) {
+	query := strings.Trim(strings.TrimSpace(param(c, "query")), `"`)
+	count := func(name string, def int) int {
+		if n, err := strconv.Atoi(param(c, name)); err == nil && n >= 0 {
+			return n
+		}
+		return def
+	}
+	artistCount, artistOffset := count("artistCount", 20), count("artistOffset", 0)
+	albumCount, albumOffset := count("albumCount", 20), count("albumOffset", 0)
+	songCount, songOffset := count("songCount", 20), count("songOffset", 0)
+
+	result := &SearchResult3{}
+	if artistCount > 0 {
+		artists, err := storage.ListArtists(s.db, query)
+		if err != nil {
+			s.fail(c, ErrGeneric, err.Error())
+			return
+		}
+		for _, a := range page(artists, artistOffset, artistCount) {
+			result.Artists = append(result.Artists, toArtist(a))
+		}
+	}
+	if albumCount > 0 {
+		albums, err := storage.ListAlbums(s.db, storage.AlbumQuery{Keyword: query})
+		if err != nil {
+			s.fail(c, ErrGeneric, err.Error())
+			return
+		}
+		for _, a := range page(albums, albumOffset, albumCount) {
+			result.Albums = append(result.Albums, toAlbum(a))
+		}
+	}
+	if songCount > 0 {
+		var songs []storage.Song
+		q := s.db.Order("artist, album, track_num, start_ms, id").Offset(songOffset).Limit(songCount)
+		if query != "" {
+			like := "%" + query + "%"
+			q = q.Where("title LIKE ? OR artist LIKE ? OR album LIKE ?", like, like, like)
+		}
+		if err := q.Find(&songs).Error; err != nil {
+			s.fail(c, ErrGeneric, err.Error())
+			return
+		}
+		result.Songs = toChildren(songs)
+	}
+	resp := newResponse()
+	resp.SearchResult3 = result
+	s.write(c, resp)
+}
+
+// page 对切片做 offset/limit 分页
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// =========== 媒体 ===========
+
+// stream 输出歌曲音频。format=wav|flac|ogg 时转码（与 /api/stream 共用转码缓存）；
+// CUE 分轨的源文件是整张专辑，客户端无法只播放其中一段，因此总是转码为 FLAC。
+// 其他格式请求（如 mp3、opus）与 maxBitRate 暂不支持，按原始文件输出。
+func (s *Server) stream(c *gin.Context) {
+	song, ok := s.lookupSong(c)
+	if !ok {
+		return
+	}
+	format := strings.ToLower(param(c, "format"))
+	switch {
+	case format == transcode.FormatWAV || format == transcode.FormatFLAC || format == transcode.FormatOgg:
+	case song.IsCueTrack():
+		format = transcode.FormatFLAC
+	default:
+		s.serveFile(c, song.FilePath, metadata.AudioContentType(song.FilePath))
+		return
+	}
+	profile, err := transcode.ParseProfile(format, "")
+	if err != nil {
+		s.fail(c, ErrGeneric, err.Error())
+		return
+	}
+	path, err := s.transcoder.Transcode(c.Request.Context(), song, profile)
+	if err != nil {
+		if errors.Is(err, player.ErrUnsupportedFormat) && !song.IsCueTrack() {
+			// 无法解码的格式仍可原样交给客户端播放
+			s.serveFile(c, song.FilePath, metadata.AudioContentType(song.FilePath))
+			return
+		}
+		s.fail(c, ErrGeneric, fmt.Sprintf("转码失败: %v", err))
+		return
+	}
+	s.serveFile(c, path, profile.ContentType())
+}
+
+// download 原样输出歌曲文件
+func (s *Server) download(c *gin.Context) {
+	song, ok := s.lookupSong(c)
+	if !ok {
+		return
+	}
+	s.serveFile(c, song.FilePath, metadata.AudioContentType(song.FilePath))
+}
+
+// serveFile 输出文件，Range 请求由 http.ServeContent 处理
+func (s *Server) serveFile(c *gin.Context, path, contentType string) {
+	f, err := os.Open(path)
+	if err != nil {
+		s.fail(c, ErrNotFound, "音频文件不存在或不可读")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		s.fail(c, ErrNotFound, "音频文件不存在或不可读")
+		return
+	}
+	c.Header("Content-Type", contentType)
+	http.ServeContent(c.Writer, c.Request, filepath.Base(path), info.ModTime(), f)
+}
+
+// getCoverArt 输出封面；id 可以是歌曲、专辑或艺术家 ID（取其中任意一首带封面的歌曲）
+func (s *Server) getCoverArt(c *gin.Context) {
+	id := param(c, "id")
+	if id == "" {
+		s.fail(c, ErrMissingParam, "缺少参数 id")
+		return
+	}
+	var coverSongID uint
+	switch {
+	case strings.HasPrefix(id, "al-"):
+		if album, artist, ok := parseAlbumID(id); ok {
+			songs, _ := storage.GetSongsByAlbum(s.db, album, artist)
+			for _, song := range songs {
+				if song.CoverURL != "" {
+					coverSongID = song.ID
+					break
+				}
+			}
+		}
+	case strings.HasPrefix(id, "ar-"):
+		if name, ok := parseArtistID(id); ok {
+			if artists, err := storage.ListArtists(s.db, ""); err == nil {
+				for _, a := range artists {
+					if a.Name == name {
+						coverSongID = a.CoverSongID
+						break
+					}
+				}
+			}
+		}
+	default:
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+			coverSongID = uint(n)
+		}
+	}
+	if coverSongID == 0 {
+		s.fail(c, ErrNotFound, "封面不存在")
+		return
+	}
+	song, err := storage.GetSongByID(s.db, coverSongID)
+	if err != nil || song.CoverURL == "" {
+		s.fail(c, ErrNotFound, "封面不存在")
+		return
+	}
+	if _, err := os.Stat(song.CoverURL); err != nil {
+		s.fail(c, ErrNotFound, "封面文件不存在")
+		return
+	}
+	c.File(song.CoverURL)
+}
+
+// getLyrics 按艺术家与标题查找歌词，返回去掉时间轴的纯文本；找不到时返回空歌词
+func (s *Server) getLyrics(c *gin.Context) {
+	artist, title := param(c, "artist"), param(c, "title")
+	resp := newResponse()
+	resp.Lyrics = &Lyrics{Artist: artist, Title: title}
+	if title == "" {
+		s.write(c, resp)
+		return
+	}
+
+	var song storage.Song
+	q := s.db.Where("title = ?", title)
+	if artist != "" {
+		q = q.Where("artist = ?", artist)
+	}
+	if err := q.First(&song).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.fail(c, ErrGeneric, err.Error())
+			return
+		}
+		s.write(c, resp)
+		return
+	}
+	content, err := metadata.ExtractLyrics(&song)
+	if err != nil {
+		s.write(c, resp)
+		return
+	}
+	resp.Lyrics.Artist, resp.Lyrics.Title = song.Artist, song.Title
+	resp.Lyrics.Value = content
+	if data, err := lyrics.ParseLRC(content); err == nil && len(data.Lines) > 0 {
+		lines := make([]string, 0, len(data.Lines))
+		for _, line := range data.Lines {
+			lines = append(lines, line.Text)
+		}
+		resp.Lyrics.Value = strings.Join(lines, "\n")
+	}
+	s.write(c, resp)
+}
+
+// =========== 播放列表 ===========
+
+func toPlaylist(p *storage.Playlist, owner string) Playlist {
+	pl := Playlist{
+		ID:        strconv.FormatUint(uint64(p.ID), 10),
+		Name:      p.Name,
+		Owner:     owner,
+		SongCount: len(p.Songs),
+	}
+	for _, song := range p.Songs {
+		pl.Duration += song.Duration
+		if pl.CoverArt == "" && song.CoverURL != "" {
+			pl.CoverArt = coverArtID(song.ID)
+		}
+	}
+	return pl
+}
+
+func (s *Server) getPlaylists(c *gin.Context) {
+	playlists, err := storage.ListPlaylists(s.db)
+	if err != nil {
+		s.fail(c, ErrGeneric, err.Error())
+		return
+	}
+	result := &Playlists{Playlists: make([]Playlist, 0, len(playlists))}
+	for i := range playlists {
+		result.Playlists = append(result.Playlists, toPlaylist(&playlists[i], s.cfg.User))
+	}
+	resp := newResponse()
+	resp.Playlists = result
+	s.write(c, resp)
+}
+
+func (s *Server) getPlaylist(c *gin.Context) {
+	id, err := strconv.ParseUint(param(c, "id"), 10, 64)
+	if err != nil {
+		s.fail(c, ErrMissingParam, "缺少或无效的参数 id")
+		return
+	}
+	playlist, err := storage.GetPlaylist(s.db, uint(id))
+	if err != nil {
+		s.fail(c, ErrNotFound, "播放列表不存在")
+		return
+	}
+	resp := newResponse()
+	resp.Playlist = &PlaylistWithSongs{Playlist: toPlaylist(playlist, s.cfg.User), Entries: toChildren(playlist.Songs)}
+	s.write(c, resp)
+}
+
+// =========== 播放记录 ===========
+
+// scrobble 记录播放历史；submission=false 表示“正在播放”通知，不写入历史
+func (s *Server) scrobble(c *gin.Context) {
+	ids := params(c, "id")
+	if len(ids) == 0 {
+		s.fail(c, ErrMissingParam, "缺少参数 id")
+		return
+	}
+	if param(c, "submission") == "false" {
+		s.write(c, newResponse())
+		return
+	}
+	for _, raw := range ids {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			s.fail(c, ErrNotFound, "歌曲不存在: "+raw)
+			return
+		}
+		if _, err := storage.GetSongByID(s.db, uint(id)); err != nil {
+			s.fail(c, ErrNotFound, "歌曲不存在: "+raw)
+			return
+		}
+		if err := storage.AddPlayHistory(s.db, uint(id)); err != nil {
+			s.fail(c, ErrGeneric, err.Error())
+			return
+		}
+	}
+	s.write(c, newResponse())
+}
@@ -0,0 +1,184 @@
+// Package subsonic 实现 Subsonic REST API 的核心子集，使 DSub、Symfonium、Sonixd 等现有客户端
+// 可以直接连接 GMusic 浏览曲库、搜索、播放与记录播放历史。
+//
+// 曲库虽有艺术家/专辑实体（见 storage 的 artists、albums 表），这里的艺术家与专辑仍按 songs 表中的名称分组，
+// 其 ID 由名称编码而来（ar-<hex>、al-<hex>），可逆且不随实体合并、重建而变化，客户端缓存的 ID 保持有效；
+// 歌曲与播放列表直接使用数据库主键。
+package subsonic
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/transcode"
+	"gorm.io/gorm"
+)
+
+// APIVersion 实现的 Subsonic API 版本
+const APIVersion = "1.16.1"
+
+// serverVersion 响应中的服务端版本标识
+const serverVersion = "gmusic"
+
+// jsonpCallback f=jsonp 时允许的 callback：JavaScript 标识符，可用 . 连接（如 jQuery123.cb）
+var jsonpCallback = regexp.MustCompile(`^[A-Za-z_$][\w$.]*$`)
+
+// Config 认证配置。Subsonic 协议的 token 认证需要服务端持有明文密码。
+type Config struct {
+	User     string
+	Password string
+}
+
+// ConfigFromEnv 从环境变量读取配置：GMUSIC_SUBSONIC_USER（默认 admin）与 GMUSIC_SUBSONIC_PASSWORD。
+// 未设置密码时所有请求都会认证失败，即默认不开放 Subsonic 接口。
+func ConfigFromEnv() Config {
+	cfg := Config{User: os.Getenv("GMUSIC_SUBSONIC_USER"), Password: os.Getenv("GMUSIC_SUBSONIC_PASSWORD")}
+	if cfg.User == "" {
+		cfg.User = "admin"
+	}
+	return cfg
+}
+
+// Server Subsonic 接口实现
+type Server struct {
+	db         *gorm.DB
+	cfg        Config
+	transcoder *transcode.Transcoder
+}
+
+// Register 在 router 上注册 /rest/* 路由；每个接口同时支持 GET/POST 以及带 .view 后缀的旧式路径
+func Register(router *gin.Engine, db *gorm.DB, transcoder *transcode.Transcoder, cfg Config) {
+	s := &Server{db: db, cfg: cfg, transcoder: transcoder}
+	endpoints := map[string]gin.HandlerFunc{
+		"ping":              s.ping,
+		"getLicense":        s.getLicense,
+		"getMusicFolders":   s.getMusicFolders,
+		"getIndexes":        s.getIndexes,
+		"getMusicDirectory": s.getMusicDirectory,
+		"getArtists":        s.getArtists,
+		"getArtist":         s.getArtist,
+		"getAlbum":          s.getAlbum,
+		"getSong":           s.getSong,
+		"search3":           s.search3,
+		"stream":            s.stream,
+		"download":          s.download,
+		"getCoverArt":       s.getCoverArt,
+		"getLyrics":         s.getLyrics,
+		"getPlaylists":      s.getPlaylists,
+		"getPlaylist":       s.getPlaylist,
+		"scrobble":          s.scrobble,
+	}
+	rest := router.Group("/rest", s.authenticate)
+	for name, h := range endpoints {
+		for _, path := range []string{"/" + name, "/" + name + ".view"} {
+			rest.GET(path, h)
+			rest.POST(path, h)
+		}
+	}
+}
+
+// param 读取请求参数（查询字符串或表单）
+func param(c *gin.Context, name string) string {
+	return c.Request.FormValue(name)
+}
+
+// params 读取可重复的请求参数（如 scrobble 的多个 id）
+func params(c *gin.Context, name string) []string {
+	_ = c.Request.ParseForm()
+	return c.Request.Form[name]
+}
+
+// authenticate 校验 u + (t,s) 或 u + p（支持 enc: 十六进制编码）
+func (s *Server) authenticate(c *gin.Context) {
+	user := param(c, "u")
+	token, salt, password := param(c, "t"), param(c, "s"), param(c, "p")
+	if user == "" || (password == "" && (token == "" || salt == "")) {
+		s.fail(c, ErrMissingParam, "缺少认证参数 u 以及 t+s 或 p")
+		c.Abort()
+		return
+	}
+	if s.cfg.Password == "" {
+		s.fail(c, ErrWrongCredential, "Subsonic 接口未启用：请设置环境变量 GMUSIC_SUBSONIC_PASSWORD")
+		c.Abort()
+		return
+	}
+
+	ok := false
+	if user == s.cfg.User {
+		if token != "" && salt != "" {
+			sum := md5.Sum([]byte(s.cfg.Password + salt))
+			ok = subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(token))) == 1
+		} else {
+			if strings.HasPrefix(password, "enc:") {
+				if decoded, err := hex.DecodeString(password[4:]); err == nil {
+					password = string(decoded)
+				}
+			}
+			ok = subtle.ConstantTimeCompare([]byte(password), []byte(s.cfg.Password)) == 1
+		}
+	}
+	if !ok {
+		s.fail(c, ErrWrongCredential, "用户名或密码错误")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// newResponse 构造 status=ok 的响应
+func newResponse() *Response {
+	return &Response{
+		Xmlns:         "http://subsonic.org/restapi",
+		Status:        "ok",
+		Version:       APIVersion,
+		Type:          "gmusic",
+		ServerVersion: serverVersion,
+	}
+}
+
+// fail 输出错误响应（Subsonic 约定错误也使用 HTTP 200）
+func (s *Server) fail(c *gin.Context, code int, message string) {
+	resp := newResponse()
+	resp.Status = "failed"
+	resp.Error = &Error{Code: code, Message: message}
+	s.write(c, resp)
+}
+
+// write 按 f 参数输出 XML（默认）、JSON 或 JSONP
+func (s *Server) write(c *gin.Context, resp *Response) {
+	switch param(c, "f") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"subsonic-response": resp})
+	case "jsonp":
+		out, err := json.Marshal(gin.H{"subsonic-response": resp})
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		// callback 原样拼进脚本，只接受 JavaScript 标识符（可用 . 访问属性），其他值一律拒绝
+		callback := param(c, "callback")
+		if !jsonpCallback.MatchString(callback) {
+			failed := newResponse()
+			failed.Status = "failed"
+			failed.Error = &Error{Code: ErrMissingParam, Message: "jsonp 需要合法的 callback 参数（JavaScript 标识符）"}
+			c.JSON(http.StatusBadRequest, gin.H{"subsonic-response": failed})
+			return
+		}
+		c.Data(http.StatusOK, "application/javascript; charset=utf-8", []byte(callback+"("+string(out)+");"))
+	default:
+		out, err := xml.Marshal(resp)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Data(http.StatusOK, "text/xml; charset=utf-8", append([]byte(xml.Header), out...))
+	}
+}
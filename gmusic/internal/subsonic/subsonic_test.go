@@ -0,0 +1,55 @@
+package subsonic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestJSONPCallback 只有 JavaScript 标识符可以作为 callback，其他值返回 400 且不输出脚本
+func TestJSONPCallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		callback string
+		ok       bool
+	}{
+		{"cb", true},
+		{"_cb1", true},
+		{"$", true},
+		{"jQuery1234_5678", true},
+		{"window.app.onPing", true},
+		{"", false},
+		{"1cb", false},
+		{"alert(document.cookie);f", false},
+		{"cb()", false},
+		{"cb;alert(1)//", false},
+		{"a b", false},
+		{"cb\n", false},
+		{"</script>", false},
+		{"回调", false},
+	}
+	s := &Server{}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/rest/ping?f=jsonp&callback="+url.QueryEscape(tt.callback), nil)
+		s.write(c, newResponse())
+
+		if tt.ok {
+			if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), tt.callback+"(") {
+				t.Errorf("callback %q: 状态 %d，响应 %q，期望以 %q( 开头", tt.callback, w.Code, w.Body.String(), tt.callback)
+			}
+			continue
+		}
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("callback %q: 状态 %d，期望 400", tt.callback, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); strings.Contains(ct, "javascript") {
+			t.Errorf("callback %q: 不应以 %s 输出", tt.callback, ct)
+		}
+	}
+}
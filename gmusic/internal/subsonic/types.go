@@ -0,0 +1,183 @@
+package subsonic
+
+import "encoding/xml"
+
+// 本文件定义 Subsonic 响应结构。同一结构同时用于 XML 与 JSON 输出：
+// XML 中的属性对应 JSON 中的同名字段，重复元素在 JSON 中为数组，文本内容在 JSON 中为 value 字段。
+
+// Response subsonic-response 根元素
+type Response struct {
+	XMLName       xml.Name `xml:"subsonic-response" json:"-"`
+	Xmlns         string   `xml:"xmlns,attr" json:"-"`
+	Status        string   `xml:"status,attr" json:"status"`
+	Version       string   `xml:"version,attr" json:"version"`
+	Type          string   `xml:"type,attr" json:"type"`
+	ServerVersion string   `xml:"serverVersion,attr" json:"serverVersion"`
+
+	Error         *Error             `xml:"error,omitempty" json:"error,omitempty"`
+	License       *License           `xml:"license,omitempty" json:"license,omitempty"`
+	MusicFolders  *MusicFolders      `xml:"musicFolders,omitempty" json:"musicFolders,omitempty"`
+	Indexes       *Indexes           `xml:"indexes,omitempty" json:"indexes,omitempty"`
+	Directory     *Directory         `xml:"directory,omitempty" json:"directory,omitempty"`
+	Artists       *Artists           `xml:"artists,omitempty" json:"artists,omitempty"`
+	Artist        *ArtistWithAlbums  `xml:"artist,omitempty" json:"artist,omitempty"`
+	Album         *AlbumWithSongs    `xml:"album,omitempty" json:"album,omitempty"`
+	Song          *Child             `xml:"song,omitempty" json:"song,omitempty"`
+	SearchResult3 *SearchResult3     `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+	Lyrics        *Lyrics            `xml:"lyrics,omitempty" json:"lyrics,omitempty"`
+	Playlists     *Playlists         `xml:"playlists,omitempty" json:"playlists,omitempty"`
+	Playlist      *PlaylistWithSongs `xml:"playlist,omitempty" json:"playlist,omitempty"`
+}
+
+// Error 失败响应中的错误信息
+type Error struct {
+	Code    int    `xml:"code,attr" json:"code"`
+	Message string `xml:"message,attr" json:"message"`
+}
+
+// Subsonic 错误码
+const (
+	ErrGeneric         = 0
+	ErrMissingParam    = 10
+	ErrClientTooOld    = 20
+	ErrWrongCredential = 40
+	ErrNotAuthorized   = 50
+	ErrNotFound        = 70
+)
+
+// License 许可信息（GMusic 无需许可，始终有效）
+type License struct {
+	Valid bool `xml:"valid,attr" json:"valid"`
+}
+
+// MusicFolders 音乐文件夹列表
+type MusicFolders struct {
+	Folders []MusicFolder `xml:"musicFolder" json:"musicFolder"`
+}
+
+// MusicFolder 音乐文件夹
+type MusicFolder struct {
+	ID   int    `xml:"id,attr" json:"id"`
+	Name string `xml:"name,attr" json:"name"`
+}
+
+// Indexes getIndexes 的结果（按文件夹浏览）
+type Indexes struct {
+	LastModified    int64   `xml:"lastModified,attr" json:"lastModified"`
+	IgnoredArticles string  `xml:"ignoredArticles,attr" json:"ignoredArticles"`
+	Index           []Index `xml:"index" json:"index,omitempty"`
+}
+
+// Artists getArtists 的结果（按 ID3 标签浏览）
+type Artists struct {
+	IgnoredArticles string  `xml:"ignoredArticles,attr" json:"ignoredArticles"`
+	Index           []Index `xml:"index" json:"index,omitempty"`
+}
+
+// Index 以首字母分组的艺术家
+type Index struct {
+	Name    string   `xml:"name,attr" json:"name"`
+	Artists []Artist `xml:"artist" json:"artist"`
+}
+
+// Artist 艺术家
+type Artist struct {
+	ID         string `xml:"id,attr" json:"id"`
+	Name       string `xml:"name,attr" json:"name"`
+	CoverArt   string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	AlbumCount int    `xml:"albumCount,attr" json:"albumCount"`
+}
+
+// ArtistWithAlbums getArtist 的结果
+type ArtistWithAlbums struct {
+	Artist
+	Albums []Album `xml:"album" json:"album"`
+}
+
+// Album 专辑（ID3）
+type Album struct {
+	ID        string `xml:"id,attr" json:"id"`
+	Name      string `xml:"name,attr" json:"name"`
+	Artist    string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	ArtistID  string `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	CoverArt  string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	SongCount int    `xml:"songCount,attr" json:"songCount"`
+	Duration  int    `xml:"duration,attr" json:"duration"`
+	Year      int    `xml:"year,attr,omitempty" json:"year,omitempty"`
+}
+
+// AlbumWithSongs getAlbum 的结果
+type AlbumWithSongs struct {
+	Album
+	Songs []Child `xml:"song" json:"song"`
+}
+
+// Child 目录项：歌曲或（按文件夹浏览时的）专辑目录
+type Child struct {
+	ID                    string `xml:"id,attr" json:"id"`
+	Parent                string `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	IsDir                 bool   `xml:"isDir,attr" json:"isDir"`
+	Title                 string `xml:"title,attr" json:"title"`
+	Album                 string `xml:"album,attr,omitempty" json:"album,omitempty"`
+	Artist                string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	Track                 int    `xml:"track,attr,omitempty" json:"track,omitempty"`
+	Year                  int    `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Genre                 string `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	DiscNumber            int    `xml:"discNumber,attr,omitempty" json:"discNumber,omitempty"`
+	CoverArt              string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	Size                  int64  `xml:"size,attr,omitempty" json:"size,omitempty"`
+	ContentType           string `xml:"contentType,attr,omitempty" json:"contentType,omitempty"`
+	Suffix                string `xml:"suffix,attr,omitempty" json:"suffix,omitempty"`
+	TranscodedContentType string `xml:"transcodedContentType,attr,omitempty" json:"transcodedContentType,omitempty"`
+	TranscodedSuffix      string `xml:"transcodedSuffix,attr,omitempty" json:"transcodedSuffix,omitempty"`
+	Duration              int    `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+	BitRate               int    `xml:"bitRate,attr,omitempty" json:"bitRate,omitempty"`
+	Path                  string `xml:"path,attr,omitempty" json:"path,omitempty"`
+	Type                  string `xml:"type,attr,omitempty" json:"type,omitempty"`
+	AlbumID               string `xml:"albumId,attr,omitempty" json:"albumId,omitempty"`
+	ArtistID              string `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+}
+
+// Directory getMusicDirectory 的结果
+type Directory struct {
+	ID       string  `xml:"id,attr" json:"id"`
+	Parent   string  `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	Name     string  `xml:"name,attr" json:"name"`
+	Children []Child `xml:"child" json:"child"`
+}
+
+// SearchResult3 search3 的结果
+type SearchResult3 struct {
+	Artists []Artist `xml:"artist" json:"artist,omitempty"`
+	Albums  []Album  `xml:"album" json:"album,omitempty"`
+	Songs   []Child  `xml:"song" json:"song,omitempty"`
+}
+
+// Lyrics getLyrics 的结果（纯文本，不含时间轴）
+type Lyrics struct {
+	Artist string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	Title  string `xml:"title,attr,omitempty" json:"title,omitempty"`
+	Value  string `xml:",chardata" json:"value"`
+}
+
+// Playlists getPlaylists 的结果
+type Playlists struct {
+	Playlists []Playlist `xml:"playlist" json:"playlist"`
+}
+
+// Playlist 播放列表
+type Playlist struct {
+	ID        string `xml:"id,attr" json:"id"`
+	Name      string `xml:"name,attr" json:"name"`
+	Owner     string `xml:"owner,attr,omitempty" json:"owner,omitempty"`
+	Public    bool   `xml:"public,attr" json:"public"`
+	SongCount int    `xml:"songCount,attr" json:"songCount"`
+	Duration  int    `xml:"duration,attr" json:"duration"`
+	CoverArt  string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+}
+
+// PlaylistWithSongs getPlaylist 的结果
+type PlaylistWithSongs struct {
+	Playlist
+	Entries []Child `xml:"entry" json:"entry"`
+}
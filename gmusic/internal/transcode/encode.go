@@ -0,0 +1,142 @@
+package transcode
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// flacBlockSize 每个 FLAC 帧包含的采样帧数（与参考编码器默认值一致）
+const flacBlockSize = 4096
+
+// wavHeaderSize 标准 PCM WAV 头长度
+const wavHeaderSize = 44
+
+// encodeWAV 将 16-bit PCM 写为 WAV；先写占位头，结束后回填 RIFF 与 data 块长度
+func encodeWAV(out *os.File, pcm io.Reader, sampleRate, channels int) error {
+	if err := WriteWAVHeader(out, sampleRate, channels, 0); err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(out, 64*1024)
+	n, err := io.Copy(bw, pcm)
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return WriteWAVHeader(out, sampleRate, channels, uint32(n))
+}
+
+// WriteWAVHeader 写入 16-bit PCM WAV 头；dataSize 为 data 块长度（未知长度的流可传最大值）
+func WriteWAVHeader(w io.Writer, sampleRate, channels int, dataSize uint32) error {
+	const bits = 16
+	blockAlign := channels * bits / 8
+	h := make([]byte, 0, wavHeaderSize)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, 36+dataSize)
+	h = append(h, "WAVEfmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 16)
+	h = binary.LittleEndian.AppendUint16(h, 1) // PCM
+	h = binary.LittleEndian.AppendUint16(h, uint16(channels))
+	h = binary.LittleEndian.AppendUint32(h, uint32(sampleRate))
+	h = binary.LittleEndian.AppendUint32(h, uint32(sampleRate*blockAlign))
+	h = binary.LittleEndian.AppendUint16(h, uint16(blockAlign))
+	h = binary.LittleEndian.AppendUint16(h, bits)
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, dataSize)
+	_, err := w.Write(h)
+	return err
+}
+
+// encodeFLAC 将 16-bit PCM 编码为 FLAC（由编码器做预测分析压缩）；
+// out 可 Seek，Close 时编码器会回填 StreamInfo 中的采样总数与 MD5。
+func encodeFLAC(out *os.File, pcm io.Reader, sampleRate, channels int) error {
+	if channels < 1 || channels > 8 {
+		return fmt.Errorf("FLAC 不支持 %d 声道", channels)
+	}
+	info := &meta.StreamInfo{
+		BlockSizeMin:  flacBlockSize,
+		BlockSizeMax:  flacBlockSize,
+		SampleRate:    uint32(sampleRate),
+		NChannels:     uint8(channels),
+		BitsPerSample: 16,
+	}
+	enc, err := flac.NewEncoder(out, info)
+	if err != nil {
+		return fmt.Errorf("创建 FLAC 编码器失败: %w", err)
+	}
+
+	frameBytes := channels * 2
+	buf := make([]byte, flacBlockSize*frameBytes)
+	for {
+		n, rerr := io.ReadFull(pcm, buf)
+		n -= n % frameBytes
+		if n > 0 {
+			if err := enc.WriteFrame(pcmToFLACFrame(buf[:n], sampleRate, channels)); err != nil {
+				_ = enc.Close()
+				return fmt.Errorf("FLAC 编码失败: %w", err)
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			_ = enc.Close()
+			return rerr
+		}
+	}
+	return enc.Close()
+}
+
+// pcmToFLACFrame 将交织 PCM 拆分为各声道的未压缩子帧
+func pcmToFLACFrame(b []byte, sampleRate, channels int) *frame.Frame {
+	nframes := len(b) / (channels * 2)
+	subframes := make([]*frame.Subframe, channels)
+	for c := range subframes {
+		subframes[c] = &frame.Subframe{
+			SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+			Samples:   make([]int32, nframes),
+			NSamples:  nframes,
+		}
+	}
+	for i := 0; i < nframes; i++ {
+		for c := 0; c < channels; c++ {
+			off := (i*channels + c) * 2
+			subframes[c].Samples[i] = int32(int16(binary.LittleEndian.Uint16(b[off:])))
+		}
+	}
+	return &frame.Frame{
+		Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         uint16(nframes),
+			SampleRate:        uint32(sampleRate),
+			// 独立声道的枚举值恰为“声道数 - 1”
+			Channels:      frame.Channels(channels - 1),
+			BitsPerSample: 16,
+		},
+		Subframes: subframes,
+	}
+}
+
+// encodeOgg 通过外部编码器（ffmpeg/oggenc）生成 Ogg Vorbis
+func encodeOgg(outPath string, pcm io.Reader, sampleRate, channels int) error {
+	cmd := oggEncoderCommand(sampleRate, channels, outPath)
+	if cmd == nil {
+		return fmt.Errorf("服务器未安装 Ogg 编码器（ffmpeg 或 oggenc）")
+	}
+	cmd.Stdin = pcm
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Ogg 编码失败: %v: %s", err, output)
+	}
+	return nil
+}
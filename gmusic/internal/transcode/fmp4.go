@@ -0,0 +1,164 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// 本文件实现 HLS 所需的最小 fragmented MP4（CMAF）封装，音频编码为 FLAC：
+// 初始化分段（ftyp + moov，含 fLaC/dfLa 样本描述）与媒体分段（moof + mdat），
+// 参考 ISO/IEC 14496-12 与 “Encapsulation of FLAC in ISO Base Media File Format”。
+
+// fmp4TrackID 唯一的音频轨 ID
+const fmp4TrackID = 1
+
+// mp4Box 构造一个 box：4 字节长度 + 4 字节类型 + 内容
+func mp4Box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	b := make([]byte, 0, size)
+	b = binary.BigEndian.AppendUint32(b, uint32(size))
+	b = append(b, typ...)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// mp4FullBox 构造带 version/flags 的 FullBox
+func mp4FullBox(typ string, version byte, flags uint32, payload ...[]byte) []byte {
+	vf := binary.BigEndian.AppendUint32(nil, uint32(version)<<24|flags&0xFFFFFF)
+	return mp4Box(typ, append([][]byte{vf}, payload...)...)
+}
+
+// be 依次以大端序写入整数（支持 uint8/uint16/uint32/uint64）
+func be(values ...any) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		_ = binary.Write(&buf, binary.BigEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// unityMatrix tkhd/mvhd 中的单位变换矩阵
+var unityMatrix = be(
+	uint32(0x00010000), uint32(0), uint32(0),
+	uint32(0), uint32(0x00010000), uint32(0),
+	uint32(0), uint32(0), uint32(0x40000000),
+)
+
+// flacStreamInfoBlock 构造 dfLa 中的 STREAMINFO 元数据块（含 4 字节块头，标记为最后一个块）
+func flacStreamInfoBlock(sampleRate, channels, blockSize int, totalSamples uint64) []byte {
+	body := make([]byte, 34)
+	binary.BigEndian.PutUint16(body[0:], uint16(blockSize))
+	binary.BigEndian.PutUint16(body[2:], uint16(blockSize))
+	// 帧大小上下限未知，留 0；随后 20 位采样率、3 位声道数-1、5 位位深-1、36 位采样总数
+	packed := uint64(sampleRate)<<44 | uint64(channels-1)<<41 | uint64(16-1)<<36 | totalSamples&0xFFFFFFFFF
+	binary.BigEndian.PutUint64(body[10:], packed)
+	// MD5 未计算，保持全 0
+	hdr := []byte{0x80, 0, 0, 34} // last-metadata-block=1, type=STREAMINFO, length=34
+	return append(hdr, body...)
+}
+
+// fmp4InitSegment 生成初始化分段
+func fmp4InitSegment(sampleRate, channels, blockSize int, totalSamples uint64) []byte {
+	ftyp := mp4Box("ftyp", []byte("iso6"), be(uint32(0)), []byte("iso6mp41"))
+
+	mvhd := mp4FullBox("mvhd", 0, 0,
+		be(uint32(0), uint32(0), uint32(1000), uint32(0)), // 创建/修改时间、timescale、duration
+		be(uint32(0x00010000), uint16(0x0100), uint16(0), uint32(0), uint32(0)),
+		unityMatrix,
+		make([]byte, 24), // pre_defined
+		be(uint32(fmp4TrackID+1)),
+	)
+
+	tkhd := mp4FullBox("tkhd", 0, 0x7, // enabled | in_movie | in_preview
+		be(uint32(0), uint32(0), uint32(fmp4TrackID), uint32(0), uint32(0)),
+		be(uint32(0), uint32(0), uint16(0), uint16(0), uint16(0x0100), uint16(0)),
+		unityMatrix,
+		be(uint32(0), uint32(0)), // 音轨宽高为 0
+	)
+
+	mdhd := mp4FullBox("mdhd", 0, 0,
+		be(uint32(0), uint32(0), uint32(sampleRate), uint32(0)),
+		be(uint16(0x55C4), uint16(0)), // language = und
+	)
+	hdlr := mp4FullBox("hdlr", 0, 0,
+		be(uint32(0)), []byte("soun"), make([]byte, 12), []byte("SoundHandler\x00"),
+	)
+
+	// AudioSampleEntry：采样率字段为 16.16 定点，超出 16 位时按规范置 0，以 dfLa 为准
+	rateField := uint32(0)
+	if sampleRate <= 0xFFFF {
+		rateField = uint32(sampleRate) << 16
+	}
+	dfLa := mp4FullBox("dfLa", 0, 0, flacStreamInfoBlock(sampleRate, channels, blockSize, totalSamples))
+	fLaC := mp4Box("fLaC",
+		make([]byte, 6), be(uint16(1)), // reserved + data_reference_index
+		make([]byte, 8), be(uint16(channels), uint16(16), uint16(0), uint16(0), rateField),
+		dfLa,
+	)
+	stbl := mp4Box("stbl",
+		mp4FullBox("stsd", 0, 0, be(uint32(1)), fLaC),
+		mp4FullBox("stts", 0, 0, be(uint32(0))),
+		mp4FullBox("stsc", 0, 0, be(uint32(0))),
+		mp4FullBox("stsz", 0, 0, be(uint32(0), uint32(0))),
+		mp4FullBox("stco", 0, 0, be(uint32(0))),
+	)
+	dinf := mp4Box("dinf", mp4FullBox("dref", 0, 0, be(uint32(1)), mp4FullBox("url ", 0, 1)))
+	minf := mp4Box("minf", mp4FullBox("smhd", 0, 0, be(uint16(0), uint16(0))), dinf, stbl)
+	trak := mp4Box("trak", tkhd, mp4Box("mdia", mdhd, hdlr, minf))
+	mvex := mp4Box("mvex", mp4FullBox("trex", 0, 0,
+		be(uint32(fmp4TrackID), uint32(1), uint32(0), uint32(0), uint32(0)),
+	))
+	return append(ftyp, mp4Box("moov", mvhd, trak, mvex)...)
+}
+
+// fmp4Sample 媒体分段中的一个样本（一个 FLAC 帧）
+type fmp4Sample struct {
+	data     []byte
+	duration uint32 // 以采样率为 timescale
+}
+
+// fmp4MediaSegment 生成媒体分段：sequence 从 1 开始，baseTime 为首个样本的解码时间（采样数）
+func fmp4MediaSegment(sequence uint32, baseTime uint64, samples []fmp4Sample) []byte {
+	const (
+		trunDataOffset      = 0x000001
+		trunSampleDuration  = 0x000100
+		trunSampleSize      = 0x000200
+		tfhdDefaultBaseMoof = 0x020000
+	)
+
+	entries := make([]byte, 0, len(samples)*8)
+	mdatSize := 0
+	for _, s := range samples {
+		entries = binary.BigEndian.AppendUint32(entries, s.duration)
+		entries = binary.BigEndian.AppendUint32(entries, uint32(len(s.data)))
+		mdatSize += len(s.data)
+	}
+
+	build := func(dataOffset uint32) []byte {
+		trun := mp4FullBox("trun", 0, trunDataOffset|trunSampleDuration|trunSampleSize,
+			be(uint32(len(samples)), dataOffset), entries)
+		traf := mp4Box("traf",
+			mp4FullBox("tfhd", 0, tfhdDefaultBaseMoof, be(uint32(fmp4TrackID))),
+			mp4FullBox("tfdt", 1, 0, be(baseTime)),
+			trun,
+		)
+		return mp4Box("moof", mp4FullBox("mfhd", 0, 0, be(sequence)), traf)
+	}
+	// data_offset 相对 moof 起点，指向 mdat 内容（moof 长度 + mdat 头 8 字节）
+	moof := build(0)
+	moof = build(uint32(len(moof) + 8))
+
+	out := make([]byte, 0, len(moof)+8+mdatSize)
+	out = append(out, moof...)
+	out = binary.BigEndian.AppendUint32(out, uint32(8+mdatSize))
+	out = append(out, "mdat"...)
+	for _, s := range samples {
+		out = append(out, s.data...)
+	}
+	return out
+}
@@ -0,0 +1,296 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/meta"
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// HLS 打包：每首歌曲按配置生成 VOD 播放列表，分段为 fMP4 封装的 FLAC（HLS v7，EXT-X-MAP 初始化分段）。
+// 分段在首次请求时才从解码后的 PCM 生成，并逐段缓存；远程客户端拖动进度时只需请求对应分段。
+
+// hlsSegmentSeconds 目标分段时长（秒），与 Apple 推荐值一致
+const hlsSegmentSeconds = 6
+
+// hlsCacheDir 分段缓存目录名；分段的生成方式改变时更换，避免与旧版本缓存的分段混用
+const hlsCacheDir = "hls-v2"
+
+// hlsLayout 一首歌曲在某个配置下的分段布局
+type hlsLayout struct {
+	key          string // 缓存键（含源文件指纹）
+	srcRate      int    // 源采样率
+	sampleRate   int    // 输出采样率
+	channels     int
+	blockSize    int   // 每个 FLAC 帧的采样数
+	segSamples   int   // 每个分段的采样数（blockSize 的整数倍）
+	totalSamples int64 // 总采样数
+}
+
+// segments 分段数量
+func (l *hlsLayout) segments() int {
+	return int((l.totalSamples + int64(l.segSamples) - 1) / int64(l.segSamples))
+}
+
+// segmentLen 第 i 个分段的采样数（最后一段可能较短）
+func (l *hlsLayout) segmentLen(i int) int {
+	start := int64(i) * int64(l.segSamples)
+	if rest := l.totalSamples - start; rest < int64(l.segSamples) {
+		return int(rest)
+	}
+	return l.segSamples
+}
+
+// HLSPlaylist 生成歌曲的 m3u8 播放列表，分段 URI 为相对路径（init.mp4、seg-N.m4s）
+func (t *Transcoder) HLSPlaylist(song *storage.Song, p Profile) (string, error) {
+	l, err := t.hlsLayout(song, p)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	target := int(math.Ceil(float64(l.segSamples) / float64(l.sampleRate)))
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:%d\n", target)
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+	b.WriteString("#EXT-X-MAP:URI=\"init.mp4\"\n")
+	for i := 0; i < l.segments(); i++ {
+		fmt.Fprintf(&b, "#EXTINF:%.6f,\nseg-%d.m4s\n", float64(l.segmentLen(i))/float64(l.sampleRate), i)
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String(), nil
+}
+
+// HLSInit 返回初始化分段的缓存文件路径
+func (t *Transcoder) HLSInit(ctx context.Context, song *storage.Song, p Profile) (string, error) {
+	l, err := t.hlsLayout(song, p)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(t.cacheDir, hlsCacheDir, l.key, "init.mp4")
+	return t.produce(ctx, path, func(tmpPath string) error {
+		init := fmp4InitSegment(l.sampleRate, l.channels, l.blockSize, uint64(l.totalSamples))
+		return os.WriteFile(tmpPath, init, 0644)
+	})
+}
+
+// HLSSegment 返回第 i 个媒体分段的缓存文件路径，不存在时从 PCM 生成
+func (t *Transcoder) HLSSegment(ctx context.Context, song *storage.Song, p Profile, i int) (string, error) {
+	l, err := t.hlsLayout(song, p)
+	if err != nil {
+		return "", err
+	}
+	if i < 0 || i >= l.segments() {
+		return "", fmt.Errorf("分段不存在: %d", i)
+	}
+	path := filepath.Join(t.cacheDir, hlsCacheDir, l.key, fmt.Sprintf("seg-%d.m4s", i))
+	return t.produce(ctx, path, func(tmpPath string) error {
+		data, err := encodeHLSSegment(song, l, i)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(tmpPath, data, 0644)
+	})
+}
+
+// hlsLayout 计算（并在内存中缓存）分段布局；需要打开一次解码器获取采样参数与时长
+func (t *Transcoder) hlsLayout(song *storage.Song, p Profile) (*hlsLayout, error) {
+	if p.Format != FormatFLAC {
+		return nil, fmt.Errorf("HLS 仅支持 flac 分段")
+	}
+	key, err := cacheKey(song, p)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	if l, ok := t.layouts[key]; ok {
+		t.mu.Unlock()
+		return l, nil
+	}
+	t.mu.Unlock()
+
+	src, err := player.OpenPCM(song.FilePath, float64(song.StartMs)/1000, float64(song.EndMs)/1000)
+	if err != nil {
+		return nil, err
+	}
+	_ = src.Close()
+	if src.Duration <= 0 {
+		return nil, fmt.Errorf("无法确定歌曲时长，不能生成 HLS 播放列表")
+	}
+
+	rate := p.outputRate(src.SampleRate)
+	block := 4096
+	if rate%20 == 0 {
+		// 20 帧/秒，使分段边界恰好落在帧边界上
+		block = rate / 20
+	}
+	l := &hlsLayout{
+		key:          key,
+		srcRate:      src.SampleRate,
+		sampleRate:   rate,
+		channels:     src.Channels,
+		blockSize:    block,
+		segSamples:   hlsSegmentSeconds * rate / block * block,
+		totalSamples: int64(src.Duration * float64(rate)),
+	}
+
+	t.mu.Lock()
+	t.layouts[key] = l
+	t.mu.Unlock()
+	return l, nil
+}
+
+// encodeHLSSegment 解码第 i 段对应的 PCM 区间并封装为 fMP4 媒体分段。
+// 各分段独立生成，但与整首连续转码的结果逐采样一致：源文件的起始帧与重采样相位由分段序号精确推算，
+// FLAC 帧号也从本段第一帧在整首中的序号开始，分段之间没有接缝
+func encodeHLSSegment(song *storage.Song, l *hlsLayout, i int) ([]byte, error) {
+	start := int64(i) * int64(l.segSamples)
+	n := l.segmentLen(i)
+
+	// 输出第 start 帧对应源文件第 start·srcRate/sampleRate 帧：整数部分用于定位，小数部分作为重采样相位
+	pos := start * int64(l.srcRate)
+	srcStart := pos / int64(l.sampleRate)
+	phase := float64(pos%int64(l.sampleRate)) / float64(l.sampleRate)
+	src, err := player.OpenPCMAt(song.FilePath, float64(song.StartMs)/1000, srcStart, float64(song.EndMs)/1000)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	frameBytes := l.channels * 2
+	pcm := make([]byte, n*frameBytes)
+	// 源文件比时长信息略短时补静音
+	if _, err := io.ReadFull(newResamplerAt(src, l.srcRate, l.sampleRate, l.channels, phase), pcm); err != nil &&
+		err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc, err := flac.NewEncoder(&buf, &meta.StreamInfo{
+		BlockSizeMin:  uint16(l.blockSize),
+		BlockSizeMax:  uint16(l.blockSize),
+		SampleRate:    uint32(l.sampleRate),
+		NChannels:     uint8(l.channels),
+		BitsPerSample: 16,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 FLAC 编码器失败: %w", err)
+	}
+	// 丢弃编码器写出的 fLaC 签名与 STREAMINFO，分段中只保留音频帧
+	buf.Reset()
+
+	// 分段长度是帧长的整数倍，本段第一帧在整首中的帧号即 start / blockSize
+	frameNum := uint64(start / int64(l.blockSize))
+	samples := make([]fmp4Sample, 0, n/l.blockSize+1)
+	for off := 0; off < len(pcm); off += l.blockSize * frameBytes {
+		end := off + l.blockSize*frameBytes
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+		if err := enc.WriteFrame(pcmToFLACFrame(pcm[off:end], l.sampleRate, l.channels)); err != nil {
+			return nil, fmt.Errorf("FLAC 编码失败: %w", err)
+		}
+		// 编码器的帧号总是从 0 开始，改写为整首中的帧号
+		data, err := setFLACFrameNumber(buf.Bytes(), frameNum)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, fmp4Sample{data: data, duration: uint32((end - off) / frameBytes)})
+		frameNum++
+		buf.Reset()
+	}
+	return fmp4MediaSegment(uint32(i+1), uint64(start), samples), nil
+}
+
+// setFLACFrameNumber 返回把 FLAC 帧头中的帧号改写为 num 后的帧（新切片），并重新计算帧头 CRC-8 与整帧 CRC-16。
+// 帧头布局：同步码等 4 字节、UTF-8 编码的帧号、按块长/采样率编码附加的 0–2 字节、CRC-8；帧末为 CRC-16
+func setFLACFrameNumber(frame []byte, num uint64) ([]byte, error) {
+	if len(frame) < 8 || frame[0] != 0xFF || frame[1]&0xFE != 0xF8 {
+		return nil, fmt.Errorf("FLAC 帧头无效")
+	}
+	// 单字节编码首位为 0；多字节时首字节前导 1 的个数即字节数
+	numLen := max(bits.LeadingZeros8(^frame[4]), 1)
+	extra := 0
+	switch frame[2] >> 4 {
+	case 6:
+		extra++
+	case 7:
+		extra += 2
+	}
+	switch frame[2] & 0x0F {
+	case 12:
+		extra++
+	case 13, 14:
+		extra += 2
+	}
+	hdrEnd := 4 + numLen + extra // CRC-8 的位置
+	if hdrEnd+3 > len(frame) {
+		return nil, fmt.Errorf("FLAC 帧头无效")
+	}
+
+	out := make([]byte, 0, len(frame)+6)
+	out = append(out, frame[:4]...)
+	out = appendFLACUTF8(out, num)
+	out = append(out, frame[4+numLen:hdrEnd]...)
+	out = append(out, crc8(out))
+	out = append(out, frame[hdrEnd+1:len(frame)-2]...)
+	crc := crc16(out)
+	return append(out, byte(crc>>8), byte(crc)), nil
+}
+
+// appendFLACUTF8 按 FLAC 扩展的 UTF-8 方式（最多 7 字节、36 位）编码帧号
+func appendFLACUTF8(b []byte, n uint64) []byte {
+	if n < 0x80 {
+		return append(b, byte(n))
+	}
+	// 续字节数：每个续字节 6 位，首字节剩余 6-k 位
+	k := 1
+	for n>>(6*k) >= 1<<(6-k) {
+		k++
+	}
+	b = append(b, byte(0xFF<<(7-k))|byte(n>>(6*k)))
+	for j := k - 1; j >= 0; j-- {
+		b = append(b, 0x80|byte(n>>(6*j))&0x3F)
+	}
+	return b
+}
+
+// crc8 FLAC 帧头校验：多项式 x^8+x^2+x+1，初值 0
+func crc8(data []byte) byte {
+	var crc byte
+	for _, x := range data {
+		crc ^= x
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// crc16 FLAC 整帧校验：多项式 x^16+x^15+x^2+1，初值 0
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, x := range data {
+		crc ^= uint16(x) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
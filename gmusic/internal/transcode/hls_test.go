@@ -0,0 +1,178 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/meta"
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// writeSineWAV 写入 16-bit 立体声正弦波 WAV（左右声道频率不同）
+func writeSineWAV(t *testing.T, path string, rate int, seconds float64) {
+	t.Helper()
+	n := int(float64(rate) * seconds)
+	var buf bytes.Buffer
+	if err := WriteWAVHeader(&buf, rate, 2, uint32(n*4)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		for _, freq := range []float64{997, 1499} {
+			v := int16(12000 * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+			buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(v)))
+		}
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mdatPayload 取出媒体分段中 mdat 的内容（即依次排列的 FLAC 帧）
+func mdatPayload(t *testing.T, seg []byte) []byte {
+	t.Helper()
+	for off := 0; off+8 <= len(seg); {
+		size := int(binary.BigEndian.Uint32(seg[off:]))
+		if string(seg[off+4:off+8]) == "mdat" {
+			return seg[off+8 : off+size]
+		}
+		off += size
+	}
+	t.Fatal("分段中没有 mdat")
+	return nil
+}
+
+// TestHLSSegmentsAreContinuous 各分段独立生成，拼起来应与整首连续重采样的结果一致，帧号连续且校验和正确
+func TestHLSSegmentsAreContinuous(t *testing.T) {
+	tests := []struct {
+		name    string
+		srcRate int
+		maxRate int
+	}{
+		{"插值降采样，分段边界不在源采样点上", 48000, 22050},
+		{"插值降采样，整秒分段", 48000, 44100},
+		{"整数倍降采样", 96000, 48000},
+		{"不重采样", 44100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sine.wav")
+			writeSineWAV(t, path, tt.srcRate, 14.5)
+			song := &storage.Song{ID: 1, FilePath: path}
+			tc := NewTranscoder(t.TempDir(), 0)
+			l, err := tc.hlsLayout(song, Profile{Format: FormatFLAC, MaxRate: tt.maxRate})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.segments() < 3 {
+				t.Fatalf("分段数 = %d，至少需要 3 段", l.segments())
+			}
+
+			// 参照：从头连续重采样
+			src, err := player.OpenPCM(path, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := io.ReadAll(newResampler(src, l.srcRate, l.sampleRate, l.channels))
+			_ = src.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 所有分段的 FLAC 帧拼成一个完整的 FLAC 流再解码
+			stream := append([]byte("fLaC"), flacStreamInfoBlock(l.sampleRate, l.channels, l.blockSize, uint64(l.totalSamples))...)
+			for i := 0; i < l.segments(); i++ {
+				seg, err := encodeHLSSegment(song, l, i)
+				if err != nil {
+					t.Fatalf("分段 %d: %v", i, err)
+				}
+				stream = append(stream, mdatPayload(t, seg)...)
+			}
+			dec, err := flac.New(bytes.NewReader(stream))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int16
+			for num := uint64(0); ; num++ {
+				fr, err := dec.ParseNext()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("第 %d 帧解码失败（校验和或帧头错误）: %v", num, err)
+				}
+				if fr.Num != num {
+					t.Fatalf("第 %d 帧的帧号为 %d", num, fr.Num)
+				}
+				for i := range fr.Subframes[0].Samples {
+					for c := range fr.Subframes {
+						got = append(got, int16(fr.Subframes[c].Samples[i]))
+					}
+				}
+			}
+
+			if len(got) != int(l.totalSamples)*l.channels {
+				t.Fatalf("采样数 = %d，期望 %d", len(got)/l.channels, l.totalSamples)
+			}
+			// 连续重采样的相位是逐帧累加的浮点数，与按分段序号推算的相位可能差最后一位，允许 ±1 的量化误差
+			for i, v := range got {
+				if i*2+1 >= len(want) {
+					break // 参照在文件末尾可能少出最后一帧，分段此处补静音
+				}
+				w := int16(binary.LittleEndian.Uint16(want[i*2:]))
+				if d := int(v) - int(w); d < -1 || d > 1 {
+					frame := i / l.channels
+					t.Fatalf("第 %d 帧（分段 %d）采样 = %d，连续重采样为 %d", frame, frame/l.segSamples, v, w)
+				}
+			}
+		})
+	}
+}
+
+// TestSetFLACFrameNumber 改写帧号覆盖单字节与多字节编码，改写后的帧能通过解码器的校验
+func TestSetFLACFrameNumber(t *testing.T) {
+	var buf bytes.Buffer
+	pcm := make([]byte, 256*4)
+	enc, err := flac.NewEncoder(&buf, &meta.StreamInfo{
+		BlockSizeMin: 256, BlockSizeMax: 256, SampleRate: 44100, NChannels: 2, BitsPerSample: 16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	if err := enc.WriteFrame(pcmToFLACFrame(pcm, 44100, 2)); err != nil {
+		t.Fatal(err)
+	}
+	frame := append([]byte(nil), buf.Bytes()...)
+
+	for _, num := range []uint64{0, 1, 0x7F, 0x80, 0x7FF, 0x800, 0xFFFF, 0x10000, 1 << 30, 1<<31 - 1} {
+		patched, err := setFLACFrameNumber(frame, num)
+		if err != nil {
+			t.Fatalf("帧号 %d: %v", num, err)
+		}
+		// 帧号只在 fixed-blocksize 流中按帧计数，单独解码一帧即可检查
+		dec, err := flac.New(bytes.NewReader(append(append([]byte(nil), header...), patched...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fr, err := dec.ParseNext()
+		if err != nil {
+			t.Fatalf("帧号 %d: 解码失败: %v", num, err)
+		}
+		if fr.Num != num {
+			t.Errorf("帧号 = %d，期望 %d", fr.Num, num)
+		}
+	}
+
+	if _, err := setFLACFrameNumber([]byte("not a flac frame"), 1); err == nil {
+		t.Error("无效的帧应返回错误")
+	}
+}
@@ -0,0 +1,114 @@
+// Package transcode 为流媒体客户端提供按需转码：复用播放器的解码器得到 PCM，
+// 再编码为客户端可播放的目标格式（WAV、降采样率的 16-bit FLAC、Ogg Vorbis），
+// 完成的结果按“歌曲 + 转码配置”缓存到磁盘，后续请求直接命中。
+// 只能转码播放器能解码的源格式（mp3、flac、wav，含 24-bit/高采样率 FLAC）；
+// DSD、ALAC、AAC、APE 等格式暂不支持，返回 player.ErrUnsupportedFormat。
+package transcode
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// 支持的目标格式
+const (
+	FormatWAV  = "wav"
+	FormatFLAC = "flac"
+	FormatOgg  = "ogg"
+)
+
+// maxSupportedRate 允许请求的最高采样率，超过视为参数错误
+const maxSupportedRate = 384000
+
+// Profile 转码配置，作为缓存键的一部分
+type Profile struct {
+	Format  string // 目标格式：wav / flac / ogg
+	MaxRate int    // 最高采样率（Hz），源采样率更高时降采样；0 表示保持原采样率
+}
+
+// ParseProfile 解析查询参数 format / maxRate
+func ParseProfile(format, maxRate string) (Profile, error) {
+	p := Profile{Format: strings.ToLower(strings.TrimSpace(format))}
+	switch p.Format {
+	case FormatWAV, FormatFLAC:
+	case FormatOgg:
+		if !OggAvailable() {
+			return Profile{}, fmt.Errorf("服务器未安装 Ogg 编码器（ffmpeg 或 oggenc）")
+		}
+	default:
+		return Profile{}, fmt.Errorf("不支持的转码格式: %q（可选 wav、flac、ogg）", format)
+	}
+	if maxRate != "" {
+		rate, err := strconv.Atoi(maxRate)
+		if err != nil || rate < 8000 || rate > maxSupportedRate {
+			return Profile{}, fmt.Errorf("无效的 maxRate: %q（范围 8000-%d）", maxRate, maxSupportedRate)
+		}
+		p.MaxRate = rate
+	}
+	return p, nil
+}
+
+// Key 配置的缓存键，如 flac-48000、wav-src
+func (p Profile) Key() string {
+	if p.MaxRate > 0 {
+		return fmt.Sprintf("%s-%d", p.Format, p.MaxRate)
+	}
+	return p.Format + "-src"
+}
+
+// outputRate 给定源采样率时的输出采样率
+func (p Profile) outputRate(srcRate int) int {
+	if p.MaxRate > 0 && srcRate > p.MaxRate {
+		return p.MaxRate
+	}
+	return srcRate
+}
+
+// ContentType 目标格式的 MIME 类型
+func (p Profile) ContentType() string {
+	switch p.Format {
+	case FormatWAV:
+		return "audio/wav"
+	case FormatFLAC:
+		return "audio/flac"
+	case FormatOgg:
+		return "audio/ogg"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// OggAvailable 判断是否存在可用的 Ogg Vorbis 编码器（纯 Go 没有 Vorbis 编码实现，依赖外部程序）
+func OggAvailable() bool {
+	return oggEncoderCommand(44100, 2, "") != nil
+}
+
+// oggEncoderCommand 构造从 stdin 读取 16-bit 小端 PCM、输出 Ogg 到 outPath 的外部编码命令；
+// 优先 ffmpeg，其次 oggenc，都不存在时返回 nil
+func oggEncoderCommand(sampleRate, channels int, outPath string) *exec.Cmd {
+	if bin, err := exec.LookPath("ffmpeg"); err == nil {
+		return exec.Command(bin, "-hide_banner", "-loglevel", "error", "-y",
+			"-f", "s16le", "-ar", strconv.Itoa(sampleRate), "-ac", strconv.Itoa(channels), "-i", "pipe:0",
+			"-c:a", "libvorbis", "-q:a", "5", "-f", "ogg", outPath)
+	}
+	if bin, err := exec.LookPath("oggenc"); err == nil {
+		return exec.Command(bin, "-Q", "--raw", "--raw-bits=16", "--raw-endianness", "0",
+			"--raw-chan="+strconv.Itoa(channels), "--raw-rate="+strconv.Itoa(sampleRate),
+			"-q", "5", "-o", outPath, "-")
+	}
+	return nil
+}
+
+// ParseProfileKey 解析 Key() 生成的字符串（如 flac-48000、flac-src），用于 HLS 分段 URL
+func ParseProfileKey(key string) (Profile, error) {
+	format, rate, ok := strings.Cut(key, "-")
+	if !ok {
+		return ParseProfile(key, "")
+	}
+	if rate == "src" {
+		rate = ""
+	}
+	return ParseProfile(format, rate)
+}
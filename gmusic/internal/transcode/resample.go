@@ -0,0 +1,122 @@
+package transcode
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// resampler 对 16-bit 交织 PCM 做线性插值重采样。
+// 主要用于 88.2k/96k/192k 等高采样率降到客户端可播放的采样率，
+// 整数倍降采样时对相邻样本取平均以减轻混叠。
+type resampler struct {
+	src      io.Reader
+	channels int
+	step     float64 // 每输出一帧前进的输入帧数（srcRate / dstRate）
+	average  int     // 整数倍降采样时参与平均的输入帧数，0 表示使用插值
+
+	in      []int16 // 尚未消费的输入样本（交织）
+	t       float64 // 下一个输出帧在 in 中的位置（以帧为单位）
+	pending []byte  // 不足一帧的残余输入字节
+	raw     []byte
+	out     []byte
+	pos     int
+	eof     bool
+}
+
+// newResampler 返回从 srcRate 转换到 dstRate 的 PCM Reader；采样率相同时原样返回
+func newResampler(src io.Reader, srcRate, dstRate, channels int) io.Reader {
+	return newResamplerAt(src, srcRate, dstRate, channels, 0)
+}
+
+// newResamplerAt 同 newResampler，但第一个输出帧位于 src 第一帧之后 phase（[0, 1)）帧处。
+// 从整段输出中间的第 k 帧开始时，src 应从第 floor(k·srcRate/dstRate) 帧开始，phase 取其小数部分，
+// 这样输出与从头连续重采样的结果一致（见 HLS 分段）
+func newResamplerAt(src io.Reader, srcRate, dstRate, channels int, phase float64) io.Reader {
+	if srcRate == dstRate || srcRate <= 0 || dstRate <= 0 {
+		return src
+	}
+	r := &resampler{
+		src:      src,
+		channels: channels,
+		step:     float64(srcRate) / float64(dstRate),
+		t:        phase,
+		raw:      make([]byte, 8192*channels*2),
+	}
+	if srcRate > dstRate && srcRate%dstRate == 0 {
+		r.average = srcRate / dstRate
+	}
+	return r
+}
+
+func (r *resampler) Read(p []byte) (int, error) {
+	for r.pos >= len(r.out) {
+		if r.eof {
+			return 0, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+// fill 读取一批输入并尽可能多地生成输出帧
+func (r *resampler) fill() error {
+	n, err := r.src.Read(r.raw)
+	if n > 0 {
+		data := append(r.pending, r.raw[:n]...)
+		whole := len(data) - len(data)%2
+		for i := 0; i < whole; i += 2 {
+			r.in = append(r.in, int16(binary.LittleEndian.Uint16(data[i:])))
+		}
+		r.pending = append(r.pending[:0], data[whole:]...)
+	}
+	if err == io.EOF {
+		r.eof = true
+	} else if err != nil {
+		return err
+	}
+
+	r.out = r.out[:0]
+	r.pos = 0
+	ch := r.channels
+	frames := len(r.in) / ch
+	for {
+		i := int(r.t)
+		if r.average > 0 {
+			if i+r.average > frames {
+				break
+			}
+			for c := 0; c < ch; c++ {
+				sum := 0
+				for k := 0; k < r.average; k++ {
+					sum += int(r.in[(i+k)*ch+c])
+				}
+				r.out = binary.LittleEndian.AppendUint16(r.out, uint16(int16(sum/r.average)))
+			}
+		} else {
+			if i+1 >= frames {
+				break
+			}
+			frac := r.t - float64(i)
+			for c := 0; c < ch; c++ {
+				a := float64(r.in[i*ch+c])
+				b := float64(r.in[(i+1)*ch+c])
+				r.out = binary.LittleEndian.AppendUint16(r.out, uint16(int16(a+(b-a)*frac)))
+			}
+		}
+		r.t += r.step
+	}
+
+	// 丢弃已经用不到的输入帧
+	if drop := int(r.t); drop > 0 {
+		if drop > frames {
+			drop = frames
+		}
+		r.in = append(r.in[:0], r.in[drop*ch:]...)
+		r.t -= float64(drop)
+	}
+	return nil
+}
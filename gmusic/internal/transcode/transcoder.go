@@ -0,0 +1,197 @@
+package transcode
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// Transcoder 转码器：负责转码、磁盘缓存与并发去重
+type Transcoder struct {
+	cacheDir string
+	maxBytes int64 // 缓存总大小上限，超过后按最近访问时间淘汰；<=0 表示不限
+
+	mu       sync.Mutex
+	inflight map[string]*job       // 正在进行的转码，同一键的并发请求共享结果
+	layouts  map[string]*hlsLayout // HLS 分段布局（内存缓存，键含源文件指纹）
+}
+
+// job 一次进行中的转码
+type job struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+// NewTranscoder 创建转码器，缓存文件写入 cacheDir
+func NewTranscoder(cacheDir string, maxBytes int64) *Transcoder {
+	return &Transcoder{
+		cacheDir: cacheDir,
+		maxBytes: maxBytes,
+		inflight: make(map[string]*job),
+		layouts:  make(map[string]*hlsLayout),
+	}
+}
+
+// Transcode 返回歌曲按 profile 转码后的缓存文件路径。
+// 命中缓存时直接返回；否则同步完成转码（同一键的并发请求只转码一次）。
+func (t *Transcoder) Transcode(ctx context.Context, song *storage.Song, p Profile) (string, error) {
+	key, err := cacheKey(song, p)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(t.cacheDir, key+"."+p.Format)
+	return t.produce(ctx, path, func(tmpPath string) error { return encodeFile(song, p, tmpPath) })
+}
+
+// produce 返回缓存文件 path；不存在时调用 fn 生成到同目录的临时文件后原子重命名。
+// 同一 path 的并发请求共享一次生成过程。
+func (t *Transcoder) produce(ctx context.Context, path string, fn func(tmpPath string) error) (string, error) {
+	t.mu.Lock()
+	if j, ok := t.inflight[path]; ok {
+		t.mu.Unlock()
+		select {
+		case <-j.done:
+			return j.path, j.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.mu.Unlock()
+		// 刷新访问时间，供 LRU 淘汰参考
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		return path, nil
+	}
+	j := &job{done: make(chan struct{}), path: path}
+	t.inflight[path] = j
+	t.mu.Unlock()
+
+	// 生成过程不跟随单个请求取消：结果会被缓存，其他等待者也依赖它
+	j.err = t.generate(path, fn)
+	t.mu.Lock()
+	delete(t.inflight, path)
+	t.mu.Unlock()
+	close(j.done)
+
+	if j.err != nil {
+		return "", j.err
+	}
+	t.prune()
+	return path, nil
+}
+
+// generate 在目标目录创建临时文件交给 fn 写入，成功后重命名为 path
+func (t *Transcoder) generate(path string, fn func(tmpPath string) error) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建转码缓存目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".transcode-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer os.Remove(tmpPath) // 成功重命名后删除会失败，忽略即可
+
+	if err := fn(tmpPath); err != nil {
+		return err
+	}
+	// CreateTemp 创建的文件权限为 0600，与其他缓存文件保持一致
+	_ = os.Chmod(tmpPath, 0644)
+	return os.Rename(tmpPath, path)
+}
+
+// encodeFile 解码 → 重采样 → 编码为完整文件
+func encodeFile(song *storage.Song, p Profile, outPath string) error {
+	src, err := player.OpenPCM(song.FilePath, float64(song.StartMs)/1000, float64(song.EndMs)/1000)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	rate := p.outputRate(src.SampleRate)
+	pcm := newResampler(src, src.SampleRate, rate, src.Channels)
+
+	if p.Format == FormatOgg {
+		// 外部编码器自行写文件
+		return encodeOgg(outPath, pcm, rate, src.Channels)
+	}
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	switch p.Format {
+	case FormatWAV:
+		err = encodeWAV(out, pcm, rate, src.Channels)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	case FormatFLAC:
+		// FLAC 编码器 Close 时会一并关闭文件
+		err = encodeFLAC(out, pcm, rate, src.Channels)
+	default:
+		_ = out.Close()
+		err = fmt.Errorf("不支持的转码格式: %s", p.Format)
+	}
+	return err
+}
+
+// prune 缓存超出上限时按修改（访问）时间从旧到新删除（含 HLS 分段子目录）
+func (t *Transcoder) prune() {
+	if t.maxBytes <= 0 {
+		return
+	}
+	type cached struct {
+		path string
+		size int64
+		mod  time.Time
+	}
+	var files []cached
+	var total int64
+	_ = filepath.WalkDir(t.cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			files = append(files, cached{path, info.Size(), info.ModTime()})
+			total += info.Size()
+		}
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.Before(files[j].mod) })
+	for _, f := range files {
+		if total <= t.maxBytes {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			total -= f.size
+		}
+	}
+}
+
+// cacheKey 缓存键：歌曲 ID + 配置 + 源文件指纹（路径、区间、大小、修改时间），
+// 源文件被替换或重新分轨后自动失效
+func cacheKey(song *storage.Song, p Profile) (string, error) {
+	info, err := os.Stat(song.FilePath)
+	if err != nil {
+		return "", fmt.Errorf("音频文件不存在或不可读: %w", err)
+	}
+	h := sha1.New()
+	_, _ = io.WriteString(h, fmt.Sprintf("%s|%d|%d|%d|%d", song.FilePath, song.StartMs, song.EndMs, info.Size(), info.ModTime().UnixNano()))
+	return fmt.Sprintf("%d-%s-%s", song.ID, p.Key(), hex.EncodeToString(h.Sum(nil))[:12]), nil
+}
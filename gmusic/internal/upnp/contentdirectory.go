@@ -0,0 +1,592 @@
+package upnp
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/metadata"
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+	"gorm.io/gorm"
+)
+
+// 顶层容器 ID。子容器 ID 为 “类型/编码”，如 artist/<hex 名称>、album/<hex 专辑\x00艺术家>、
+// folder/<hex 目录>、playlist/<ID>；歌曲 ID 为 “所在容器 ID$歌曲 ID”，使 BrowseMetadata 能还原父容器。
+const (
+	rootID      = "0"
+	artistsID   = "artists"
+	albumsID    = "albums"
+	foldersID   = "folders"
+	playlistsID = "playlists"
+	songsID     = "songs"
+)
+
+// topContainers 根容器下的固定容器
+var topContainers = []struct{ id, title string }{
+	{artistsID, "艺术家"},
+	{albumsID, "专辑"},
+	{foldersID, "文件夹"},
+	{playlistsID, "播放列表"},
+	{songsID, "全部歌曲"},
+}
+
+// protocolContentTypes GetProtocolInfo 中声明可提供的 MIME 类型
+var protocolContentTypes = []string{"audio/mpeg", "audio/flac", "audio/wav", "audio/aac", "audio/mp4", "audio/ogg"}
+
+// dlnaFlags DLNA.ORG_FLAGS：流式传输、支持后台传输、DLNA 1.5
+const dlnaFlags = "01700000000000000000000000000000"
+
+// ContentFeatures 返回资源的 DLNA 第四段 protocolInfo（同时用作 contentFeatures.dlna.org 响应头）。
+// 所有资源均支持 Range 请求（DLNA.ORG_OP=01）；transcoded 表示资源为转码结果（DLNA.ORG_CI=1）。
+func ContentFeatures(contentType string, transcoded bool) string {
+	features := ""
+	if contentType == "audio/mpeg" {
+		features = "DLNA.ORG_PN=MP3;"
+	}
+	ci := "0"
+	if transcoded {
+		ci = "1"
+	}
+	return features + "DLNA.ORG_OP=01;DLNA.ORG_CI=" + ci + ";DLNA.ORG_FLAGS=" + dlnaFlags
+}
+
+// object ContentDirectory 中的一个条目（容器或歌曲）
+type object struct {
+	id       string
+	parentID string
+	title    string
+	class    string
+
+	container   bool
+	childCount  int
+	artist      string // 容器（专辑）的艺术家
+	coverSongID uint   // 容器封面取自该歌曲
+
+	song *storage.Song
+}
+
+// errNoSuchObject 对象 ID 无效或已不存在
+var errNoSuchObject = errors.New("对象不存在")
+
+// controlContentDirectory 处理 ContentDirectory 的 SOAP 动作
+func (s *Server) controlContentDirectory(c *gin.Context) {
+	action, args, err := parseSOAP(c.Request.Body)
+	if err != nil {
+		soapFault(c, codeInvalidAction, "无法解析 SOAP 请求")
+		return
+	}
+	switch action {
+	case "Browse":
+		s.browse(c, args)
+	case "GetSearchCapabilities":
+		writeSOAP(c, contentDirType, action, []soapArg{{"SearchCaps", ""}})
+	case "GetSortCapabilities":
+		writeSOAP(c, contentDirType, action, []soapArg{{"SortCaps", ""}})
+	case "GetSystemUpdateID":
+		writeSOAP(c, contentDirType, action, []soapArg{{"Id", strconv.FormatUint(uint64(s.systemUpdateID()), 10)}})
+	default:
+		soapFault(c, codeInvalidAction, "不支持的动作: "+action)
+	}
+}
+
+// browse 实现 Browse 动作（BrowseMetadata / BrowseDirectChildren，支持 StartingIndex/RequestedCount 分页）
+func (s *Server) browse(c *gin.Context, args map[string]string) {
+	id := args["ObjectID"]
+	start, err1 := strconv.Atoi(defaultString(args["StartingIndex"], "0"))
+	count, err2 := strconv.Atoi(defaultString(args["RequestedCount"], "0"))
+	if id == "" || err1 != nil || err2 != nil || start < 0 || count < 0 {
+		soapFault(c, codeInvalidArgs, "参数无效")
+		return
+	}
+
+	var objects []object
+	var total int
+	switch args["BrowseFlag"] {
+	case "BrowseMetadata":
+		obj, err := s.lookup(id)
+		if err != nil {
+			s.browseError(c, err)
+			return
+		}
+		objects, total = []object{obj}, 1
+	case "BrowseDirectChildren":
+		children, err := s.children(id)
+		if err != nil {
+			s.browseError(c, err)
+			return
+		}
+		total = len(children)
+		if start > total {
+			start = total
+		}
+		end := total
+		if count > 0 && start+count < total {
+			end = start + count
+		}
+		objects = children[start:end]
+	default:
+		soapFault(c, codeInvalidArgs, "BrowseFlag 无效")
+		return
+	}
+
+	writeSOAP(c, contentDirType, "Browse", []soapArg{
+		{"Result", s.didl(objects, "http://"+c.Request.Host)},
+		{"NumberReturned", strconv.Itoa(len(objects))},
+		{"TotalMatches", strconv.Itoa(total)},
+		{"UpdateID", strconv.FormatUint(uint64(s.systemUpdateID()), 10)},
+	})
+}
+
+// browseError 将查询错误映射为 UPnP 错误码
+func (s *Server) browseError(c *gin.Context, err error) {
+	if errors.Is(err, errNoSuchObject) || errors.Is(err, gorm.ErrRecordNotFound) {
+		soapFault(c, codeNoSuchObject, "对象不存在")
+		return
+	}
+	soapFault(c, codeActionFailed, err.Error())
+}
+
+// systemUpdateID 曲库的近似版本号：歌曲、播放列表条目的数量与最大 ID 之和，增删后随之变化，
+// 使缓存了目录结构的渲染端重新浏览
+func (s *Server) systemUpdateID() uint32 {
+	var row struct{ Songs, MaxID, Links int64 }
+	s.db.Raw(`SELECT (SELECT COUNT(*) FROM songs) AS songs, (SELECT COALESCE(MAX(id), 0) FROM songs) AS max_id,
+		(SELECT COUNT(*) FROM playlist_songs) AS links`).Scan(&row)
+	return uint32(row.Songs + row.MaxID + row.Links)
+}
+
+// lookup 返回对象自身（BrowseMetadata），容器的 childCount 按其子对象计数
+func (s *Server) lookup(id string) (object, error) {
+	if i := strings.LastIndex(id, "$"); i >= 0 {
+		songID, err := strconv.ParseUint(id[i+1:], 10, 64)
+		if err != nil {
+			return object{}, errNoSuchObject
+		}
+		song, err := storage.GetSongByID(s.db, uint(songID))
+		if err != nil {
+			return object{}, err
+		}
+		return songObject(id[:i], song), nil
+	}
+
+	obj := object{id: id, container: true, class: "object.container"}
+	kind, key, _ := strings.Cut(id, "/")
+	switch kind {
+	case rootID:
+		obj.parentID, obj.title = "-1", s.cfg.FriendlyName
+	case artistsID, albumsID, foldersID, playlistsID, songsID:
+		obj.parentID = rootID
+		for _, top := range topContainers {
+			if top.id == id {
+				obj.title = top.title
+			}
+		}
+	case "artist":
+		name, err := decodeKey(key)
+		if err != nil {
+			return object{}, err
+		}
+		obj.parentID, obj.title, obj.class = artistsID, displayName(name, "未知艺术家"), "object.container.person.musicArtist"
+	case "album":
+		album, artist, err := decodeAlbumKey(key)
+		if err != nil {
+			return object{}, err
+		}
+		obj.parentID, obj.title, obj.class, obj.artist = albumsID, displayName(album, "未知专辑"), "object.container.album.musicAlbum", artist
+	case "folder":
+		dir, err := decodeKey(key)
+		if err != nil {
+			return object{}, err
+		}
+		root, err := s.folderRoot()
+		if err != nil {
+			return object{}, err
+		}
+		obj.parentID, obj.title, obj.class = folderID(path.Dir(dir)), path.Base(dir), "object.container.storageFolder"
+		if path.Dir(dir) == root {
+			obj.parentID = foldersID
+		}
+	case "playlist":
+		playlistID, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return object{}, errNoSuchObject
+		}
+		playlist, err := storage.GetPlaylist(s.db, uint(playlistID))
+		if err != nil {
+			return object{}, err
+		}
+		obj.parentID, obj.title, obj.class = playlistsID, playlist.Name, "object.container.playlistContainer"
+	default:
+		return object{}, errNoSuchObject
+	}
+
+	children, err := s.children(id)
+	if err != nil {
+		return object{}, err
+	}
+	obj.childCount = len(children)
+	return obj, nil
+}
+
+// children 返回容器的直接子对象（BrowseDirectChildren）
+func (s *Server) children(id string) ([]object, error) {
+	kind, key, _ := strings.Cut(id, "/")
+	switch kind {
+	case rootID:
+		objects := make([]object, 0, len(topContainers))
+		for _, top := range topContainers {
+			objects = append(objects, object{id: top.id, parentID: rootID, title: top.title, class: "object.container", container: true, childCount: -1})
+		}
+		return objects, nil
+
+	case artistsID:
+		artists, err := storage.ListArtists(s.db, "")
+		if err != nil {
+			return nil, err
+		}
+		objects := make([]object, 0, len(artists))
+		for _, a := range artists {
+			objects = append(objects, object{
+				id: "artist/" + hex.EncodeToString([]byte(a.Name)), parentID: id, title: displayName(a.Name, "未知艺术家"),
+				class: "object.container.person.musicArtist", container: true, childCount: a.AlbumCount, coverSongID: a.CoverSongID,
+			})
+		}
+		return objects, nil
+
+	case "artist":
+		name, err := decodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return s.albumObjects(id, storage.AlbumQuery{Artist: name})
+
+	case albumsID:
+		return s.albumObjects(id, storage.AlbumQuery{})
+
+	case "album":
+		album, artist, err := decodeAlbumKey(key)
+		if err != nil {
+			return nil, err
+		}
+		songs, err := storage.GetSongsByAlbum(s.db, album, artist)
+		if err != nil {
+			return nil, err
+		}
+		if len(songs) == 0 {
+			return nil, errNoSuchObject
+		}
+		return songObjects(id, songs), nil
+
+	case foldersID:
+		root, err := s.folderRoot()
+		if err != nil {
+			return nil, err
+		}
+		return s.folderChildren(id, root)
+
+	case "folder":
+		dir, err := decodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return s.folderChildren(id, dir)
+
+	case playlistsID:
+		playlists, err := storage.ListPlaylists(s.db)
+		if err != nil {
+			return nil, err
+		}
+		objects := make([]object, 0, len(playlists))
+		for _, p := range playlists {
+			objects = append(objects, object{
+				id: "playlist/" + strconv.FormatUint(uint64(p.ID), 10), parentID: id, title: p.Name,
+				class: "object.container.playlistContainer", container: true, childCount: len(p.Songs),
+			})
+		}
+		return objects, nil
+
+	case "playlist":
+		playlistID, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return nil, errNoSuchObject
+		}
+		songs, err := storage.GetPlaylistSongs(s.db, uint(playlistID))
+		if err != nil {
+			return nil, err
+		}
+		return songObjects(id, songs), nil
+
+	case songsID:
+		songs, err := storage.GetAllSongs(s.db)
+		if err != nil {
+			return nil, err
+		}
+		return songObjects(id, songs), nil
+	}
+	return nil, errNoSuchObject
+}
+
+// albumObjects 返回专辑容器列表
+func (s *Server) albumObjects(parentID string, query storage.AlbumQuery) ([]object, error) {
+	albums, err := storage.ListAlbums(s.db, query)
+	if err != nil {
+		return nil, err
+	}
+	if query.Artist != "" && len(albums) == 0 {
+		return nil, errNoSuchObject
+	}
+	objects := make([]object, 0, len(albums))
+	for _, a := range albums {
+		objects = append(objects, object{
+			id: "album/" + hex.EncodeToString([]byte(a.Name+"\x00"+a.Artist)), parentID: parentID, title: displayName(a.Name, "未知专辑"),
+			class: "object.container.album.musicAlbum", container: true, childCount: a.SongCount,
+			artist: a.Artist, coverSongID: a.CoverSongID,
+		})
+	}
+	return objects, nil
+}
+
+// songObjects 将歌曲列表转换为 parentID 容器下的条目
+func songObjects(parentID string, songs []storage.Song) []object {
+	objects := make([]object, 0, len(songs))
+	for i := range songs {
+		objects = append(objects, songObject(parentID, &songs[i]))
+	}
+	return objects
+}
+
+// songObject 构造歌曲条目
+func songObject(parentID string, song *storage.Song) object {
+	return object{
+		id:       parentID + "$" + strconv.FormatUint(uint64(song.ID), 10),
+		parentID: parentID,
+		title:    displayName(song.Title, filepath.Base(song.FilePath)),
+		class:    "object.item.audioItem.musicTrack",
+		song:     song,
+	}
+}
+
+// slashPath 将数据库中的路径统一为正斜杠形式
+func slashPath(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// folderID 目录对应的容器 ID
+func folderID(dir string) string {
+	return "folder/" + hex.EncodeToString([]byte(dir))
+}
+
+// folderRoot 返回全部歌曲所在目录的最长公共目录，作为“文件夹”容器的起点，
+// 避免渲染端需要逐级进入 /home/<user>/Music 之类的路径前缀
+func (s *Server) folderRoot() (string, error) {
+	var paths []string
+	if err := s.db.Model(&storage.Song{}).Distinct("file_path").Pluck("file_path", &paths).Error; err != nil {
+		return "", err
+	}
+	root, first := "", true
+	for _, p := range paths {
+		dir := path.Dir(slashPath(p))
+		if first {
+			root, first = dir, false
+			continue
+		}
+		for root != "" && dir != root && !strings.HasPrefix(dir, strings.TrimSuffix(root, "/")+"/") {
+			parent := path.Dir(root)
+			if parent == root {
+				root = ""
+				break
+			}
+			root = parent
+		}
+	}
+	return root, nil
+}
+
+// folderChildren 列出目录 dir 下的子目录与歌曲：子目录在前（按名称排序），歌曲按文件名与 CUE 偏移排序
+func (s *Server) folderChildren(id, dir string) ([]object, error) {
+	var songs []storage.Song
+	var err error
+	if dir == "" {
+		songs, err = storage.GetSongsUnderDir(s.db)
+	} else {
+		songs, err = storage.GetSongsUnderDir(s.db, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(songs) == 0 && id != foldersID {
+		return nil, errNoSuchObject
+	}
+
+	prefix := ""
+	if dir != "" {
+		prefix = strings.TrimSuffix(dir, "/") + "/"
+	}
+	subdirs := make(map[string]map[string]bool) // 子目录 -> 其直接子项集合（用于 childCount）
+	var files []storage.Song
+	for _, song := range songs {
+		rel := strings.TrimPrefix(slashPath(song.FilePath), prefix)
+		name, rest, nested := strings.Cut(rel, "/")
+		if !nested {
+			files = append(files, song)
+			continue
+		}
+		if subdirs[name] == nil {
+			subdirs[name] = make(map[string]bool)
+		}
+		// 子目录按名称计一次；文件按 CUE 偏移区分，每条分轨各计一次
+		child, _, deeper := strings.Cut(rest, "/")
+		if deeper {
+			subdirs[name]["d:"+child] = true
+		} else {
+			subdirs[name]["f:"+child+"#"+strconv.FormatInt(song.StartMs, 10)] = true
+		}
+	}
+
+	names := make([]string, 0, len(subdirs))
+	for name := range subdirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	objects := make([]object, 0, len(names)+len(files))
+	for _, name := range names {
+		objects = append(objects, object{
+			id: folderID(prefix + name), parentID: id, title: name,
+			class: "object.container.storageFolder", container: true, childCount: len(subdirs[name]),
+		})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].FilePath != files[j].FilePath {
+			return files[i].FilePath < files[j].FilePath
+		}
+		return files[i].StartMs < files[j].StartMs
+	})
+	return append(objects, songObjects(id, files)...), nil
+}
+
+// decodeKey 解码十六进制编码的 ID 部分
+func decodeKey(key string) (string, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return "", errNoSuchObject
+	}
+	return string(raw), nil
+}
+
+// decodeAlbumKey 解码专辑 ID 中的（专辑，艺术家）
+func decodeAlbumKey(key string) (string, string, error) {
+	raw, err := decodeKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	album, artist, ok := strings.Cut(raw, "\x00")
+	if !ok {
+		return "", "", errNoSuchObject
+	}
+	return album, artist, nil
+}
+
+// displayName 名称为空时使用占位名称
+func displayName(name, fallback string) string {
+	if strings.TrimSpace(name) == "" {
+		return fallback
+	}
+	return name
+}
+
+// defaultString s 为空时返回 def
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// formatDuration 按 DIDL-Lite 的 H:MM:SS.mmm 格式输出时长
+func formatDuration(seconds int) string {
+	return fmt.Sprintf("%d:%02d:%02d.000", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// didl 将对象列表序列化为 DIDL-Lite 文档，资源地址以 base（http://host:port）为前缀
+func (s *Server) didl(objects []object, base string) string {
+	var b strings.Builder
+	b.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/"` +
+		` xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/" xmlns:dlna="urn:schemas-dlna-org:metadata-1-0/">`)
+	for _, obj := range objects {
+		if obj.container {
+			b.WriteString(`<container id="` + escape(obj.id) + `" parentID="` + escape(obj.parentID) + `" restricted="1" searchable="0"`)
+			if obj.childCount >= 0 {
+				b.WriteString(` childCount="` + strconv.Itoa(obj.childCount) + `"`)
+			}
+			b.WriteString(`><dc:title>` + escape(obj.title) + `</dc:title><upnp:class>` + obj.class + `</upnp:class>`)
+			if obj.artist != "" {
+				b.WriteString(`<upnp:artist>` + escape(obj.artist) + `</upnp:artist>`)
+			}
+			if obj.coverSongID != 0 {
+				b.WriteString(`<upnp:albumArtURI>` + base + "/api/cover/" + strconv.FormatUint(uint64(obj.coverSongID), 10) + `</upnp:albumArtURI>`)
+			}
+			b.WriteString(`</container>`)
+			continue
+		}
+
+		song := obj.song
+		b.WriteString(`<item id="` + escape(obj.id) + `" parentID="` + escape(obj.parentID) + `" restricted="1">`)
+		b.WriteString(`<dc:title>` + escape(obj.title) + `</dc:title><upnp:class>` + obj.class + `</upnp:class>`)
+		if song.Artist != "" {
+			b.WriteString(`<dc:creator>` + escape(song.Artist) + `</dc:creator><upnp:artist>` + escape(song.Artist) + `</upnp:artist>`)
+		}
+		if song.Album != "" {
+			b.WriteString(`<upnp:album>` + escape(song.Album) + `</upnp:album>`)
+		}
+		if song.TrackNum > 0 {
+			b.WriteString(`<upnp:originalTrackNumber>` + strconv.Itoa(song.TrackNum) + `</upnp:originalTrackNumber>`)
+		}
+		if song.Year > 0 {
+			b.WriteString(fmt.Sprintf(`<dc:date>%04d-01-01</dc:date>`, song.Year))
+		}
+		if song.CoverURL != "" {
+			b.WriteString(`<upnp:albumArtURI>` + base + "/api/cover/" + strconv.FormatUint(uint64(song.ID), 10) + `</upnp:albumArtURI>`)
+		}
+		b.WriteString(resElement(song, base))
+		b.WriteString(`</item>`)
+	}
+	b.WriteString(`</DIDL-Lite>`)
+	return b.String()
+}
+
+// resElement 构造歌曲的 res 元素。渲染端普遍支持的格式直接输出源文件；
+// CUE 分轨（需截取区间）与渲染端无法识别、但能解码的格式通过流媒体接口转码为 FLAC；
+// 无法解码的格式（如 APE、DSD）只能原样输出，由渲染端自行判断能否播放。
+func resElement(song *storage.Song, base string) string {
+	url := base + "/api/stream/" + strconv.FormatUint(uint64(song.ID), 10)
+	contentType := metadata.AudioContentType(song.FilePath)
+	transcoded := song.IsCueTrack()
+	supported := false
+	for _, ct := range protocolContentTypes {
+		supported = supported || ct == contentType
+	}
+	if !supported && player.CanDecode(song.FilePath) {
+		transcoded = true
+	}
+
+	attrs := ""
+	if transcoded {
+		url += "?format=flac"
+		contentType = "audio/flac"
+	} else if info, err := os.Stat(song.FilePath); err == nil {
+		attrs += ` size="` + strconv.FormatInt(info.Size(), 10) + `"`
+	}
+	if song.Duration > 0 {
+		attrs += ` duration="` + formatDuration(song.Duration) + `"`
+	}
+	protocolInfo := "http-get:*:" + contentType + ":" + ContentFeatures(contentType, transcoded)
+	return `<res protocolInfo="` + protocolInfo + `"` + attrs + `>` + escape(url) + `</res>`
+}
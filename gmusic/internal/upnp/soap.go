@@ -0,0 +1,130 @@
+package upnp
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UPnP 控制错误码
+const (
+	codeInvalidAction = 401
+	codeInvalidArgs   = 402
+	codeActionFailed  = 501
+	codeNoSuchObject  = 701
+)
+
+// soapArg SOAP 响应中的一个输出参数（保持声明顺序）
+type soapArg struct {
+	Name  string
+	Value string
+}
+
+// parseSOAP 解析 SOAP 请求，返回 Body 下第一个元素的名称（动作名）及其子元素构成的参数表
+func parseSOAP(r io.Reader) (string, map[string]string, error) {
+	dec := xml.NewDecoder(r)
+	var action, arg string
+	var text strings.Builder
+	args := make(map[string]string)
+	depth := 0 // Envelope=1, Body=2, 动作=3, 参数=4
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch depth {
+			case 3:
+				if action == "" {
+					action = t.Name.Local
+				}
+			case 4:
+				arg = t.Name.Local
+				text.Reset()
+			}
+		case xml.CharData:
+			if depth == 4 {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if depth == 4 {
+				args[arg] = text.String()
+			}
+			depth--
+		}
+	}
+	if action == "" {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	return action, args, nil
+}
+
+// writeSOAP 输出动作响应
+func writeSOAP(c *gin.Context, serviceType, action string, out []soapArg) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	b.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	b.WriteString(`<u:` + action + `Response xmlns:u="` + serviceType + `">`)
+	for _, arg := range out {
+		b.WriteString("<" + arg.Name + ">" + escape(arg.Value) + "</" + arg.Name + ">")
+	}
+	b.WriteString(`</u:` + action + `Response></s:Body></s:Envelope>`)
+	c.Header("EXT", "")
+	c.Header("Server", serverHeader)
+	c.Data(http.StatusOK, `text/xml; charset="utf-8"`, []byte(b.String()))
+}
+
+// soapFault 按 UPnP 约定以 HTTP 500 + UPnPError 返回错误
+func soapFault(c *gin.Context, code int, description string) {
+	body := `<?xml version="1.0" encoding="utf-8"?>` + "\n" +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>` +
+		`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>` +
+		`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>` + strconv.Itoa(code) + `</errorCode>` +
+		`<errorDescription>` + escape(description) + `</errorDescription></UPnPError>` +
+		`</detail></s:Fault></s:Body></s:Envelope>`
+	c.Data(http.StatusInternalServerError, `text/xml; charset="utf-8"`, []byte(body))
+}
+
+// escape 转义 XML 文本与属性值
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// controlConnectionManager 处理 ConnectionManager 的 SOAP 动作。
+// 媒体服务器只提供 HTTP GET 资源，不建立连接，因此只有一个固定的连接 0。
+func (s *Server) controlConnectionManager(c *gin.Context) {
+	action, _, err := parseSOAP(c.Request.Body)
+	if err != nil {
+		soapFault(c, codeInvalidAction, "无法解析 SOAP 请求")
+		return
+	}
+	switch action {
+	case "GetProtocolInfo":
+		var source []string
+		for _, ct := range protocolContentTypes {
+			source = append(source, "http-get:*:"+ct+":*")
+		}
+		writeSOAP(c, connManagerType, action, []soapArg{{"Source", strings.Join(source, ",")}, {"Sink", ""}})
+	case "GetCurrentConnectionIDs":
+		writeSOAP(c, connManagerType, action, []soapArg{{"ConnectionIDs", "0"}})
+	case "GetCurrentConnectionInfo":
+		writeSOAP(c, connManagerType, action, []soapArg{
+			{"RcsID", "-1"}, {"AVTransportID", "-1"}, {"ProtocolInfo", ""},
+			{"PeerConnectionManager", ""}, {"PeerConnectionID", "-1"},
+			{"Direction", "Output"}, {"Status", "OK"},
+		})
+	default:
+		soapFault(c, codeInvalidAction, "不支持的动作: "+action)
+	}
+}
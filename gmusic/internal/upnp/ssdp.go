@@ -0,0 +1,282 @@
+package upnp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SSDPGroup SSDP 组播地址
+const SSDPGroup = "239.255.255.250:1900"
+
+const (
+	// maxAge 通告的有效期（秒），控制点超过该时间未收到新通告即认为设备离线
+	maxAge = 1800
+	// notifyInterval 重发 ssdp:alive 的间隔，远小于 maxAge 以容忍 UDP 丢包
+	notifyInterval = 5 * time.Minute
+)
+
+// serverHeader SSDP 与 HTTP 响应中的 SERVER 头
+var serverHeader = runtime.GOOS + "/1.0 UPnP/1.0 GMusic/1.0"
+
+// ssdpServer 响应 M-SEARCH 并周期性发送 NOTIFY
+type ssdpServer struct {
+	conn     *net.UDPConn
+	group    *net.UDPAddr
+	udn      string
+	httpPort int
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// startSSDP 在 addr 上监听 SSDP。端口与组播地址的端口一致；若主机没有可用的组播网卡
+// （如仅有回环的容器），退化为普通 UDP 监听，仍可响应发往本机的单播 M-SEARCH。
+func startSSDP(addr, udn string, httpPort int) (*ssdpServer, error) {
+	laddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return nil, fmt.Errorf("SSDP 地址无效: %w", err)
+	}
+	group, _ := net.ResolveUDPAddr("udp4", SSDPGroup)
+	group.Port = laddr.Port
+
+	var conn *net.UDPConn
+	if laddr.IP == nil || laddr.IP.IsUnspecified() {
+		conn, err = net.ListenMulticastUDP("udp4", nil, group)
+	}
+	if conn == nil {
+		conn, err = net.ListenUDP("udp4", laddr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("SSDP 监听失败: %w", err)
+	}
+
+	s := &ssdpServer{conn: conn, group: group, udn: udn, httpPort: httpPort, done: make(chan struct{})}
+	s.wg.Add(2)
+	go s.serve()
+	go s.announce()
+	return s, nil
+}
+
+// Close 发送 byebye 并关闭监听
+func (s *ssdpServer) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.notify("ssdp:byebye")
+		err = s.conn.Close()
+		s.wg.Wait()
+	})
+	return err
+}
+
+// targets 设备对外通告的全部 NT/ST 值
+func (s *ssdpServer) targets() []string {
+	return []string{"upnp:rootdevice", s.udn, deviceType, contentDirType, connManagerType}
+}
+
+// usn 由通告类型构造 USN
+func (s *ssdpServer) usn(target string) string {
+	if target == s.udn {
+		return s.udn
+	}
+	return s.udn + "::" + target
+}
+
+// location 返回 remote 可访问的设备描述地址：取发往 remote 时本机使用的网卡地址
+func (s *ssdpServer) location(remote *net.UDPAddr) string {
+	ip := "127.0.0.1"
+	if conn, err := net.DialUDP("udp4", nil, remote); err == nil {
+		ip = conn.LocalAddr().(*net.UDPAddr).IP.String()
+		conn.Close()
+	}
+	return "http://" + net.JoinHostPort(ip, strconv.Itoa(s.httpPort)) + descriptionPath
+}
+
+// serve 读取 SSDP 报文并响应 M-SEARCH
+func (s *ssdpServer) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, 2048)
+	for {
+		n, remote, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buf[:n])))
+		if err != nil || req.Method != "M-SEARCH" || req.Header.Get("MAN") != `"ssdp:discover"` {
+			continue
+		}
+		st := req.Header.Get("ST")
+		var matched []string
+		for _, target := range s.targets() {
+			if st == "ssdp:all" || st == target {
+				matched = append(matched, target)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		// 按 MX 随机延迟响应，避免大量设备同时回复；单播搜索（无 MX）立即响应
+		mx, _ := strconv.Atoi(req.Header.Get("MX"))
+		if mx > 5 {
+			mx = 5
+		}
+		var delay time.Duration
+		if mx > 0 {
+			delay = time.Duration(rand.Int63n(int64(mx) * int64(time.Second)))
+		}
+		s.wg.Add(1)
+		go s.respond(remote, matched, delay)
+	}
+}
+
+// respond 向搜索方逐条回复匹配的通告类型
+func (s *ssdpServer) respond(remote *net.UDPAddr, targets []string, delay time.Duration) {
+	defer s.wg.Done()
+	select {
+	case <-time.After(delay):
+	case <-s.done:
+		return
+	}
+	location := s.location(remote)
+	for _, target := range targets {
+		msg := "HTTP/1.1 200 OK\r\n" +
+			"CACHE-CONTROL: max-age=" + strconv.Itoa(maxAge) + "\r\n" +
+			"DATE: " + time.Now().UTC().Format(http.TimeFormat) + "\r\n" +
+			"EXT:\r\n" +
+			"LOCATION: " + location + "\r\n" +
+			"SERVER: " + serverHeader + "\r\n" +
+			"ST: " + target + "\r\n" +
+			"USN: " + s.usn(target) + "\r\n\r\n"
+		if _, err := s.conn.WriteToUDP([]byte(msg), remote); err != nil {
+			return
+		}
+	}
+}
+
+// announce 启动时及之后每隔 notifyInterval 发送 ssdp:alive
+func (s *ssdpServer) announce() {
+	defer s.wg.Done()
+	s.notify("ssdp:alive")
+	ticker := time.NewTicker(notifyInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.notify("ssdp:alive")
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// notify 向组播地址发送 NOTIFY（nts 为 ssdp:alive 或 ssdp:byebye）。
+// 没有组播路由时发送失败，忽略即可：控制点仍能通过 M-SEARCH 发现设备。
+func (s *ssdpServer) notify(nts string) {
+	conn, err := net.DialUDP("udp4", nil, s.group)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	location := s.location(s.group)
+	for _, target := range s.targets() {
+		msg := "NOTIFY * HTTP/1.1\r\n" +
+			"HOST: " + s.group.String() + "\r\n" +
+			"NT: " + target + "\r\n" +
+			"NTS: " + nts + "\r\n" +
+			"USN: " + s.usn(target) + "\r\n"
+		if nts == "ssdp:alive" {
+			msg += "CACHE-CONTROL: max-age=" + strconv.Itoa(maxAge) + "\r\n" +
+				"LOCATION: " + location + "\r\n" +
+				"SERVER: " + serverHeader + "\r\n"
+		}
+		if _, err := conn.Write([]byte(msg + "\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+// SearchResult M-SEARCH 的一条响应
+type SearchResult struct {
+	ST       string
+	USN      string
+	Location string
+	Server   string
+	From     *net.UDPAddr
+}
+
+// Search 向 addr（为空时为 SSDP 组播地址）发送 M-SEARCH，收集 mx 秒（外加 1 秒余量）内的响应，
+// 按 USN 去重。向 127.0.0.1:1900 单播即可在回环上测试本机的 MediaServer。
+func Search(ctx context.Context, addr, st string, mx int) ([]SearchResult, error) {
+	if addr == "" {
+		addr = SSDPGroup
+	}
+	raddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	msg := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + SSDPGroup + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: " + strconv.Itoa(mx) + "\r\n" +
+		"ST: " + st + "\r\n\r\n"
+	if _, err := conn.WriteToUDP([]byte(msg), raddr); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(time.Duration(mx+1) * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	var results []SearchResult
+	seen := make(map[string]bool)
+	buf := make([]byte, 2048)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return results, nil
+			}
+			return results, err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			continue
+		}
+		r := SearchResult{
+			ST:       resp.Header.Get("ST"),
+			USN:      resp.Header.Get("USN"),
+			Location: resp.Header.Get("LOCATION"),
+			Server:   resp.Header.Get("SERVER"),
+			From:     from,
+		}
+		if r.USN != "" && !seen[r.USN] {
+			seen[r.USN] = true
+			results = append(results, r)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package upnp 实现 UPnP AV MediaServer（DLNA DMS），使局域网内的电视、功放等设备可以浏览并播放曲库。
+//
+// 组成：
+//   - SSDP：在 239.255.255.250:1900 上响应 M-SEARCH，并周期性发送 NOTIFY 存活通告；
+//   - 设备描述与服务描述（SCPD）XML，由 HTTP 服务在 /upnp/ 下提供；
+//   - ContentDirectory 与 ConnectionManager 两个服务的 SOAP 控制接口。
+//
+// ContentDirectory 将曲库组织为 艺术家 / 专辑 / 文件夹 / 播放列表 / 全部歌曲 五个顶层容器，
+// 歌曲的 res 地址指向 /api/stream/:songID（CUE 分轨转码为 FLAC），因此渲染端直接走现有的流媒体接口。
+package upnp
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	deviceType      = "urn:schemas-upnp-org:device:MediaServer:1"
+	contentDirType  = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	connManagerType = "urn:schemas-upnp-org:service:ConnectionManager:1"
+
+	// descriptionPath 设备描述地址，即 SSDP 中 LOCATION 的路径部分
+	descriptionPath = "/upnp/device.xml"
+)
+
+// Config UPnP 服务配置
+type Config struct {
+	SSDPAddr     string // SSDP 监听地址，如 :1900；为空表示不启用
+	HTTPPort     int    // HTTP 服务端口，用于拼接 LOCATION
+	FriendlyName string // 设备在渲染端中显示的名称
+}
+
+// ConfigFromEnv 从环境变量 GMUSIC_UPNP_ADDR、GMUSIC_UPNP_NAME 读取配置。
+// HTTP 端口与 cmd/server 的监听端口一致（GMUSIC_HTTP_ADDR，默认 8080）。
+func ConfigFromEnv() Config {
+	cfg := Config{SSDPAddr: os.Getenv("GMUSIC_UPNP_ADDR"), HTTPPort: 8080, FriendlyName: os.Getenv("GMUSIC_UPNP_NAME")}
+	if _, port, err := net.SplitHostPort(os.Getenv("GMUSIC_HTTP_ADDR")); err == nil {
+		if n, err := strconv.Atoi(port); err == nil {
+			cfg.HTTPPort = n
+		}
+	}
+	if cfg.FriendlyName == "" {
+		host, _ := os.Hostname()
+		cfg.FriendlyName = strings.TrimSpace("GMusic " + host)
+	}
+	return cfg
+}
+
+// Server UPnP MediaServer
+type Server struct {
+	db   *gorm.DB
+	cfg  Config
+	udn  string // 设备唯一标识 uuid:...
+	ssdp *ssdpServer
+}
+
+// Start 按配置注册 /upnp/ 路由并启动 SSDP（SSDPAddr 为空时什么都不做）
+func Start(router *gin.Engine, db *gorm.DB, cfg Config) (*Server, error) {
+	if cfg.SSDPAddr == "" {
+		return nil, nil
+	}
+	s := &Server{db: db, cfg: cfg, udn: deviceUDN()}
+	s.Register(router)
+	ssdp, err := startSSDP(cfg.SSDPAddr, s.udn, cfg.HTTPPort)
+	if err != nil {
+		return nil, err
+	}
+	s.ssdp = ssdp
+	return s, nil
+}
+
+// Close 发送 byebye 通告并停止 SSDP
+func (s *Server) Close() error {
+	if s.ssdp == nil {
+		return nil
+	}
+	return s.ssdp.Close()
+}
+
+// Register 注册设备描述、服务描述、SOAP 控制与事件订阅路由
+func (s *Server) Register(router *gin.Engine) {
+	g := router.Group("/upnp")
+	g.GET("/device.xml", s.deviceDescription)
+	g.GET("/ContentDirectory.xml", serveXML(contentDirectorySCPD))
+	g.GET("/ConnectionManager.xml", serveXML(connectionManagerSCPD))
+	g.POST("/control/ContentDirectory", s.controlContentDirectory)
+	g.POST("/control/ConnectionManager", s.controlConnectionManager)
+	for _, svc := range []string{"ContentDirectory", "ConnectionManager"} {
+		g.Handle("SUBSCRIBE", "/event/"+svc, s.subscribe)
+		g.Handle("UNSUBSCRIBE", "/event/"+svc, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+}
+
+// deviceUDN 由主机名派生稳定的设备 UUID，保证重启后渲染端仍能识别为同一台设备
+func deviceUDN() string {
+	host, _ := os.Hostname()
+	sum := sha1.Sum([]byte("gmusic-upnp:" + host))
+	sum[6] = sum[6]&0x0f | 0x50 // version 5
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// serveXML 输出固定的 XML 文档
+func serveXML(doc string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, `text/xml; charset="utf-8"`, []byte(doc))
+	}
+}
+
+// deviceDescription 输出设备描述 XML
+func (s *Server) deviceDescription(c *gin.Context) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0" xmlns:dlna="urn:schemas-dlna-org:device-1-0">
+<specVersion><major>1</major><minor>0</minor></specVersion>
+<device>
+<deviceType>` + deviceType + `</deviceType>
+<friendlyName>` + escape(s.cfg.FriendlyName) + `</friendlyName>
+<manufacturer>GMusic</manufacturer>
+<manufacturerURL>https://github.com/yudongyouqing/GMusic</manufacturerURL>
+<modelDescription>GMusic UPnP Media Server</modelDescription>
+<modelName>GMusic</modelName>
+<modelNumber>1</modelNumber>
+<UDN>` + s.udn + `</UDN>
+<dlna:X_DLNADOC>DMS-1.50</dlna:X_DLNADOC>
+<presentationURL>/</presentationURL>
+<serviceList>
+`)
+	for _, svc := range []struct{ typ, name string }{
+		{contentDirType, "ContentDirectory"},
+		{connManagerType, "ConnectionManager"},
+	} {
+		b.WriteString("<service><serviceType>" + svc.typ + "</serviceType>")
+		b.WriteString("<serviceId>urn:upnp-org:serviceId:" + svc.name + "</serviceId>")
+		b.WriteString("<SCPDURL>/upnp/" + svc.name + ".xml</SCPDURL>")
+		b.WriteString("<controlURL>/upnp/control/" + svc.name + "</controlURL>")
+		b.WriteString("<eventSubURL>/upnp/event/" + svc.name + "</eventSubURL></service>\n")
+	}
+	b.WriteString("</serviceList>\n</device>\n</root>\n")
+	c.Data(http.StatusOK, `text/xml; charset="utf-8"`, []byte(b.String()))
+}
+
+// subscribe 接受 GENA 事件订阅。曲库变化不主动推送，仅返回订阅 ID 以兼容要求订阅成功的控制点。
+func (s *Server) subscribe(c *gin.Context) {
+	sid := c.GetHeader("SID") // 续订时沿用原 SID
+	if sid == "" {
+		buf := make([]byte, 16)
+		_, _ = rand.Read(buf)
+		sid = "uuid:" + hex.EncodeToString(buf)
+	}
+	c.Header("SID", sid)
+	c.Header("TIMEOUT", "Second-1800")
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,145 @@
+package upnp
+
+import (
+	"context"
+	"encoding/xml"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yudongyouqing/GMusic/internal/storage"
+)
+
+// TestLoopbackDiscovery 在回环上启动 MediaServer：M-SEARCH 发现设备，按 LOCATION 取得设备描述，
+// 再向描述中的 ContentDirectory 控制地址发送 Browse，得到曲库中的歌曲及指向流媒体接口的 res
+func TestLoopbackDiscovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := storage.InitDB(filepath.Join(t.TempDir(), "gmusic.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	song := &storage.Song{Title: "十年", Artist: "陈奕迅", Album: "黑白灰", FilePath: "/music/十年.mp3", Format: "mp3", Duration: 205}
+	if err := storage.AddSong(db, song); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	srv := httptest.NewUnstartedServer(router)
+	httpPort := srv.Listener.Addr().(*net.TCPAddr).Port
+	s, err := Start(router, db, Config{SSDPAddr: "127.0.0.1:0", HTTPPort: httpPort, FriendlyName: "GMusic 测试"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	srv.Start()
+	defer srv.Close()
+
+	// 发现
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results, err := Search(ctx, s.ssdp.conn.LocalAddr().String(), deviceType, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("发现 %d 个设备，期望 1 个: %+v", len(results), results)
+	}
+	found := results[0]
+	wantLocation := "http://127.0.0.1:" + strconv.Itoa(httpPort) + descriptionPath
+	if found.ST != deviceType || found.USN != s.udn+"::"+deviceType || found.Location != wantLocation {
+		t.Errorf("响应 ST=%q USN=%q LOCATION=%q，期望 %q %q %q", found.ST, found.USN, found.Location, deviceType, s.udn+"::"+deviceType, wantLocation)
+	}
+
+	// 设备描述
+	resp, err := http.Get(found.Location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var desc struct {
+		Device struct {
+			DeviceType   string `xml:"deviceType"`
+			FriendlyName string `xml:"friendlyName"`
+			UDN          string `xml:"UDN"`
+			Services     []struct {
+				ServiceType string `xml:"serviceType"`
+				ControlURL  string `xml:"controlURL"`
+			} `xml:"serviceList>service"`
+		} `xml:"device"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&desc)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Device.DeviceType != deviceType || desc.Device.FriendlyName != "GMusic 测试" || desc.Device.UDN != s.udn {
+		t.Errorf("设备描述 %+v", desc.Device)
+	}
+	var control string
+	for _, svc := range desc.Device.Services {
+		if svc.ServiceType == contentDirType {
+			control = svc.ControlURL
+		}
+	}
+	if control == "" {
+		t.Fatalf("设备描述中没有 ContentDirectory 服务: %+v", desc.Device.Services)
+	}
+
+	// Browse「全部歌曲」
+	base, _ := url.Parse(found.Location)
+	controlURL, err := base.Parse(control)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>
+<u:Browse xmlns:u="` + contentDirType + `"><ObjectID>` + songsID + `</ObjectID><BrowseFlag>BrowseDirectChildren</BrowseFlag>
+<Filter>*</Filter><StartingIndex>0</StartingIndex><RequestedCount>0</RequestedCount><SortCriteria></SortCriteria></u:Browse>
+</s:Body></s:Envelope>`
+	req, _ := http.NewRequest(http.MethodPost, controlURL.String(), strings.NewReader(body))
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPACTION", `"`+contentDirType+`#Browse"`)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envelope struct {
+		Result         string `xml:"Body>BrowseResponse>Result"`
+		NumberReturned int    `xml:"Body>BrowseResponse>NumberReturned"`
+		TotalMatches   int    `xml:"Body>BrowseResponse>TotalMatches"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&envelope)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Browse 状态 %d: %v", resp.StatusCode, err)
+	}
+	var didl struct {
+		Items []struct {
+			Title  string `xml:"title"`
+			Artist string `xml:"artist"`
+			Res    struct {
+				ProtocolInfo string `xml:"protocolInfo,attr"`
+				Duration     string `xml:"duration,attr"`
+				URL          string `xml:",chardata"`
+			} `xml:"res"`
+		} `xml:"item"`
+	}
+	if err := xml.Unmarshal([]byte(envelope.Result), &didl); err != nil {
+		t.Fatalf("解析 DIDL-Lite 失败: %v\n%s", err, envelope.Result)
+	}
+	if envelope.NumberReturned != 1 || envelope.TotalMatches != 1 || len(didl.Items) != 1 {
+		t.Fatalf("返回 %d/%d 项: %s", envelope.NumberReturned, envelope.TotalMatches, envelope.Result)
+	}
+	item := didl.Items[0]
+	wantURL := "http://" + base.Host + "/api/stream/" + strconv.FormatUint(uint64(song.ID), 10)
+	if item.Title != "十年" || item.Artist != "陈奕迅" || item.Res.URL != wantURL || item.Res.Duration != "0:03:25.000" ||
+		!strings.HasPrefix(item.Res.ProtocolInfo, "http-get:*:audio/mpeg:") {
+		t.Errorf("歌曲 %+v，期望 res 为 %s", item, wantURL)
+	}
+}
@@ -0,0 +1,179 @@
+// Package zone 管理命名播放区域：每个区域有独立的输出后端、播放器、队列、音量与播放状态，
+// 一台服务器可以同时驱动厨房的音箱和书房的耳机。
+//
+// 默认区域（DefaultName）输出到本机声卡，承载 /api/player、MPD、MPRIS、直播与多房间同步；
+// 其他区域通过环境变量 GMUSIC_ZONES 或 API 创建，经 /api/zones/:zone/player/... 控制。
+package zone
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yudongyouqing/GMusic/internal/playback"
+	"github.com/yudongyouqing/GMusic/internal/player"
+	"gorm.io/gorm"
+)
+
+// DefaultName 默认区域的名称
+const DefaultName = "default"
+
+var (
+	// ErrNotFound 区域不存在
+	ErrNotFound = errors.New("区域不存在")
+	// ErrExists 区域已存在
+	ErrExists = errors.New("区域已存在")
+	// ErrDefaultZone 默认区域不能删除
+	ErrDefaultZone = errors.New("默认区域不能删除")
+)
+
+// validName 区域名只允许字母、数字、下划线与连字符，便于直接出现在 URL 路径中
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Zone 一个播放区域
+type Zone struct {
+	Name       string
+	Output     string // 输出后端描述，如 local、null、command:aplay ...
+	Player     *player.Player
+	Controller *playback.Controller
+}
+
+// Manager 区域注册表
+type Manager struct {
+	db *gorm.DB
+
+	mu    sync.RWMutex
+	zones map[string]*Zone
+}
+
+// NewManager 创建注册表并登记默认区域；p 为默认区域的播放器（本机声卡不可用时可为 nil）
+func NewManager(db *gorm.DB, p *player.Player) *Manager {
+	m := &Manager{db: db, zones: make(map[string]*Zone)}
+	output := "local"
+	if p == nil {
+		output = "unavailable"
+	}
+	m.zones[DefaultName] = &Zone{Name: DefaultName, Output: output, Player: p, Controller: playback.NewController(db, p)}
+	return m
+}
+
+// Default 默认区域
+func (m *Manager) Default() *Zone {
+	z, _ := m.Get(DefaultName)
+	return z
+}
+
+// Get 按名称查找区域
+func (m *Manager) Get(name string) (*Zone, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	z, ok := m.zones[name]
+	return z, ok
+}
+
+// List 全部区域，默认区域在前，其余按名称排序
+func (m *Manager) List() []*Zone {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	zones := make([]*Zone, 0, len(m.zones))
+	for _, z := range m.zones {
+		zones = append(zones, z)
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		if (zones[i].Name == DefaultName) != (zones[j].Name == DefaultName) {
+			return zones[i].Name == DefaultName
+		}
+		return zones[i].Name < zones[j].Name
+	})
+	return zones
+}
+
+// Add 按输出描述创建区域，描述格式见 ParseOutput
+func (m *Manager) Add(name, output string) (*Zone, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("无效的区域名: %q（仅允许字母、数字、_ 与 -）", name)
+	}
+	out, err := ParseOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.zones[name]; ok {
+		return nil, ErrExists
+	}
+	p := player.NewPlayerWithOutput(out)
+	z := &Zone{Name: name, Output: output, Player: p, Controller: playback.NewController(m.db, p)}
+	m.zones[name] = z
+	return z, nil
+}
+
+// Remove 停止并删除区域
+func (m *Manager) Remove(name string) error {
+	if name == DefaultName {
+		return ErrDefaultZone
+	}
+	m.mu.Lock()
+	z, ok := m.zones[name]
+	delete(m.zones, name)
+	m.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	return z.Player.Close()
+}
+
+// ParseOutput 解析输出描述：
+//
+//	local                本机默认声卡（与默认区域共享，同时播放时混音）
+//	null                 不发声，仅按实时速率消费
+//	command:<命令行>      把 PCM（44.1 kHz/16-bit/立体声，小端）写入命令的标准输入，
+//	                     如 command:aplay -D plughw:1 -t raw -f cd（参数按空白拆分，不经过 shell）
+func ParseOutput(spec string) (player.Output, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "local":
+		return player.LocalOutput()
+	case "null":
+		return player.NullOutput(), nil
+	case "command":
+		fields := strings.Fields(arg)
+		if len(fields) == 0 {
+			return nil, errors.New("command 输出缺少命令")
+		}
+		return player.CommandOutput(fields[0], fields[1:]...), nil
+	default:
+		return nil, fmt.Errorf("未知的输出后端: %q（可选 local、null、command:<命令行>）", spec)
+	}
+}
+
+// Config 启动时创建的区域，名称 → 输出描述
+type Config map[string]string
+
+// ConfigFromEnv 从环境变量 GMUSIC_ZONES 读取区域定义，多个区域以分号分隔，如
+// GMUSIC_ZONES="kitchen=command:aplay -D plughw:1 -t raw -f cd;office=null"
+func ConfigFromEnv() Config {
+	cfg := Config{}
+	for _, item := range strings.Split(os.Getenv("GMUSIC_ZONES"), ";") {
+		name, output, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if ok {
+			cfg[strings.TrimSpace(name)] = strings.TrimSpace(output)
+		}
+	}
+	return cfg
+}
+
+// Start 按配置创建区域；单个区域失败不影响其他区域，错误合并返回
+func (m *Manager) Start(cfg Config) error {
+	var errs []error
+	for name, output := range cfg {
+		if _, err := m.Add(name, output); err != nil {
+			errs = append(errs, fmt.Errorf("区域 %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
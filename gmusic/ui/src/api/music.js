@@ -4,7 +4,12 @@ export const getSongs = () => http.get('/songs')
 export const getSongById = (id) => http.get(`/songs/${id}`)
 export const searchSongs = (q) => http.get('/songs/search', { params: { q } })
 
-export const play = (file_path) => http.post('/player/play', { file_path })
+// 按曲库实体播放：{ song_id } | { album, artist? } | { artist } | { playlist_id }，可选 start_index / shuffle
+export const play = (target) => http.post('/player/play', target)
+export const playSongById = (song_id) => play({ song_id })
+export const next = () => http.post('/player/next')
+export const previous = () => http.post('/player/previous')
+export const getQueue = () => http.get('/player/queue')
 export const pause = () => http.post('/player/pause')
 export const resume = () => http.post('/player/resume')
 export const stop = () => http.post('/player/stop')
@@ -1,6 +1,6 @@
 import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
-import { getSongs, searchSongs, play, pause, resume, stop, setVolume, status, getLyrics, scan, seek, audioInfoById, updateSong } from '../api/music'
+import { getSongs, searchSongs, playSongById, pause, resume, stop, setVolume, status, getLyrics, scan, seek, audioInfoById, updateSong } from '../api/music'
 
 export const usePlayerStore = defineStore('player', () => {
   // state
@@ -184,7 +184,7 @@ export const usePlayerStore = defineStore('player', () => {
     playPending.value = true
     try {
       currentSong.value = song
-      await play(song.file_path)
+      await playSongById(song.id)
       isPlaying.value = true
       try {
         const { data } = await getLyrics(song.id)